@@ -65,7 +65,7 @@ func migrateTaskArchive(ctx context.Context, repo task.Repository, tempDir, outp
 		}
 		repacked = true
 	}
-	manifest, err := report.BuildZipManifest(taskID, archiveEntries, "src")
+	manifest, err := report.BuildZipManifest(taskID, zipPath, archiveEntries, "src")
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/keepbuild/seewxapkg/internal/app"
+	"github.com/keepbuild/seewxapkg/internal/config"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/events"
+	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
+	"github.com/keepbuild/seewxapkg/internal/infra/queue"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	"github.com/keepbuild/seewxapkg/internal/report"
+)
+
+const batchPollInterval = 300 * time.Millisecond
+
+type batchOptions struct {
+	pipelineOptions
+	input     string
+	outputDir string
+	workers   int
+}
+
+func runBatch(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	opts, err := parseBatchArgs(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	cfg, cleanup, err := setupRuntime(opts.pipelineOptions)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailed
+	}
+	defer cleanup()
+
+	result, err := decompileBatch(ctx, cfg, opts, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "batch failed: %v\n", err)
+		return exitFailed
+	}
+	printBatchSummary(stdout, result, opts.outputDir)
+	return batchExitCode(result)
+}
+
+func parseBatchArgs(args []string, stderr io.Writer) (batchOptions, error) {
+	opts := batchOptions{}
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.outputDir, "o", "", "output directory; one sub-directory per package plus batch-report.json")
+	fs.IntVar(&opts.workers, "workers", 0, "concurrent packages (default MAX_CONCURRENT_TASKS)")
+	finish := registerPipelineFlags(fs, &opts.pipelineOptions)
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return opts, err
+	}
+	if len(positional) != 1 {
+		return opts, fmt.Errorf("batch expects exactly one directory or archive")
+	}
+	if opts.outputDir == "" {
+		return opts, fmt.Errorf("-o output directory is required")
+	}
+	if opts.workers < 0 {
+		return opts, fmt.Errorf("--workers cannot be negative")
+	}
+	opts.input = positional[0]
+	finish()
+	return opts, nil
+}
+
+// decompileBatch fans the packages out through an in-memory JobQueue, the
+// same path the server uses, and waits for every task to reach a terminal
+// state before exporting results.
+func decompileBatch(ctx context.Context, cfg *config.Config, opts batchOptions, stdout io.Writer) (*report.BatchReport, error) {
+	repo := persistence.NewMemoryTaskRepo()
+	jobQueue := queue.NewInMemoryQueue(cfg.MaxBatchPackages)
+	compileService := app.NewCompileService(cfg, repo, events.NewBroker(), jobQueue)
+	batchService := app.NewBatchService(cfg, repo, compileService)
+
+	workers := opts.workers
+	if workers == 0 {
+		workers = cfg.MaxConcurrentTasks
+	}
+	workerCtx, cancelWorkers := context.WithCancel(ctx)
+	defer func() {
+		cancelWorkers()
+		jobQueue.Wait()
+	}()
+	jobQueue.StartWorkers(workerCtx, workers, compileService.RunTask)
+
+	batch, err := batchService.StartBatch(ctx, app.StartBatchCommand{
+		AppID:           opts.appID,
+		Beautify:        opts.beautify,
+		Decompile:       opts.decompile,
+		RemoveGuideHTML: opts.removeGuideHTML,
+		SearchAppID:     opts.appIDCandidates != "",
+		Path:            opts.input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(stdout, "batch %s: %d packages\n", batch.ID, len(batch.Items))
+
+	result, err := waitForBatch(ctx, batchService, batch.ID, stdout)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range batch.Items {
+		if item.TaskID == "" {
+			continue
+		}
+		current, err := repo.Get(ctx, item.TaskID)
+		if err != nil {
+			continue
+		}
+		if err := exportResult(cfg, current, filepath.Join(opts.outputDir, batchItemDir(item)), archiveName(item.Name)); err != nil {
+			return nil, fmt.Errorf("write output for package %d: %w", item.Index, err)
+		}
+	}
+	if err := storage.WriteJSON(filepath.Join(opts.outputDir, "batch-report.json"), result); err != nil {
+		return nil, fmt.Errorf("write batch report: %w", err)
+	}
+	return result, nil
+}
+
+func waitForBatch(ctx context.Context, batchService *app.BatchService, batchID string, stdout io.Writer) (*report.BatchReport, error) {
+	ticker := time.NewTicker(batchPollInterval)
+	defer ticker.Stop()
+	lastFinished := -1
+	for {
+		result, err := batchService.GetBatchReport(ctx, batchID)
+		if err != nil {
+			return nil, err
+		}
+		finished := 0
+		for _, item := range result.Items {
+			if task.TaskStatus(item.Status).IsTerminal() {
+				finished++
+			}
+		}
+		if finished != lastFinished {
+			fmt.Fprintf(stdout, "[%d/%d] packages finished\n", finished, result.Total)
+			lastFinished = finished
+		}
+		if result.Done {
+			return result, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// batchItemDir keeps output directories ordered and unique even when several
+// packages share a file name such as __APP__.wxapkg.
+func batchItemDir(item task.BatchItem) string {
+	label := strings.TrimSuffix(item.Name, filepath.Ext(item.Name))
+	label = strings.NewReplacer("/", "_", "*", "").Replace(label)
+	return fmt.Sprintf("%03d-%s", item.Index+1, label)
+}
+
+func batchExitCode(result *report.BatchReport) int {
+	code := exitOK
+	for _, item := range result.Items {
+		switch item.Status {
+		case string(task.TaskCompleted):
+		case string(task.TaskPartial):
+			code = exitPartial
+		default:
+			return exitFailed
+		}
+	}
+	return code
+}
+
+func printBatchSummary(w io.Writer, result *report.BatchReport, outputDir string) {
+	fmt.Fprintln(w)
+	for _, item := range result.Items {
+		score := "-"
+		if item.Score != nil {
+			score = fmt.Sprintf("%d", item.Score.Overall)
+		}
+		codes := make([]string, 0, len(item.TopDiagnostics))
+		for _, diagnostic := range item.TopDiagnostics {
+			codes = append(codes, diagnostic.Code)
+		}
+		fmt.Fprintf(w, "  %3d %-10s %5s  %s  %s\n", item.Index+1, item.Status, score, item.Name, strings.Join(codes, ","))
+	}
+	fmt.Fprintf(w, "summary: %v\n", result.Summary)
+	fmt.Fprintf(w, "output: %s\n", outputDir)
+}
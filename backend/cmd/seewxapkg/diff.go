@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/keepbuild/seewxapkg/internal/app"
+	"github.com/keepbuild/seewxapkg/internal/config"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	"github.com/keepbuild/seewxapkg/internal/report"
+)
+
+type diffOptions struct {
+	pipelineOptions
+	base      string
+	head      string
+	outputDir string
+}
+
+// runDiff decompiles two builds of one mini program into <out>/base and
+// <out>/head and writes diff-report.json plus a combined diff.patch.
+func runDiff(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	opts, err := parseDiffArgs(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	cfg, cleanup, err := setupRuntime(opts.pipelineOptions)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailed
+	}
+	defer cleanup()
+
+	result, err := diffLocal(ctx, cfg, opts, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "diff failed: %v\n", err)
+		return exitFailed
+	}
+	printDiffSummary(stdout, result, opts.outputDir)
+	return exitOK
+}
+
+func parseDiffArgs(args []string, stderr io.Writer) (diffOptions, error) {
+	opts := diffOptions{}
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.outputDir, "o", "", "output directory for base/, head/ and the diff report")
+	finish := registerPipelineFlags(fs, &opts.pipelineOptions)
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return opts, err
+	}
+	if len(positional) != 2 {
+		return opts, fmt.Errorf("diff expects a base and a head package")
+	}
+	if opts.outputDir == "" {
+		return opts, fmt.Errorf("-o output directory is required")
+	}
+	opts.base, opts.head = positional[0], positional[1]
+	finish()
+	return opts, nil
+}
+
+func diffLocal(ctx context.Context, cfg *config.Config, opts diffOptions, stdout io.Writer) (*report.DiffReport, error) {
+	sides := make([]*report.DiffSide, 0, 2)
+	for _, side := range []struct{ name, input string }{{"base", opts.base}, {"head", opts.head}} {
+		fmt.Fprintf(stdout, "== %s: %s\n", side.name, filepath.Base(side.input))
+		sideDir := filepath.Join(opts.outputDir, side.name)
+		result, err := decompileLocal(ctx, cfg, decompileOptions{
+			pipelineOptions: opts.pipelineOptions,
+			input:           side.input,
+			outputDir:       sideDir,
+		}, stdout)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", side.name, err)
+		}
+		if result.Status == task.TaskFailed {
+			return nil, fmt.Errorf("%s package could not be recovered", side.name)
+		}
+		loaded, err := app.LoadDiffSide(result, filepath.Join(sideDir, "src"), filepath.Join(sideDir, "reports"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", side.name, err)
+		}
+		sides = append(sides, loaded)
+	}
+
+	result, err := report.BuildDiffReport(*sides[0], *sides[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.WriteJSON(filepath.Join(opts.outputDir, "diff-report.json"), result); err != nil {
+		return nil, err
+	}
+	var patch strings.Builder
+	for _, textDiff := range result.TextDiffs {
+		patch.WriteString(textDiff.Diff)
+	}
+	if err := os.WriteFile(filepath.Join(opts.outputDir, "diff.patch"), []byte(patch.String()), 0600); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func printDiffSummary(w io.Writer, result *report.DiffReport, outputDir string) {
+	summary := result.Summary
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "files:      +%d -%d ~%d\n", summary.FilesAdded, summary.FilesRemoved, summary.FilesModified)
+	fmt.Fprintf(w, "routes:     +%d -%d\n", summary.RoutesAdded, summary.RoutesRemoved)
+	fmt.Fprintf(w, "components: %d page(s) changed\n", summary.ComponentChanges)
+	if !result.Beautified {
+		fmt.Fprintln(w, "note:       formatting was skipped; text diffs may include formatting noise")
+	}
+	fmt.Fprintf(w, "report:     %s\n", filepath.Join(outputDir, "diff-report.json"))
+}
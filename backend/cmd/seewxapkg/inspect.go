@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/keepbuild/seewxapkg/internal/app"
+)
+
+type inspectOptions struct {
+	input    string
+	appID    string
+	jsonMode bool
+}
+
+// runInspect prints a package's header and index. Like pack it needs neither
+// the runtime configuration nor Node, and it never writes files.
+func runInspect(args []string, stdout, stderr io.Writer) int {
+	opts, err := parseInspectArgs(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	data, err := os.ReadFile(opts.input)
+	if err != nil {
+		fmt.Fprintf(stderr, "inspect failed: %v\n", err)
+		return exitFailed
+	}
+	inspection, err := app.InspectPackage(data, opts.appID)
+	if err != nil {
+		fmt.Fprintf(stderr, "inspect failed: %v\n", err)
+		return exitFailed
+	}
+	if opts.jsonMode {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(inspection); err != nil {
+			fmt.Fprintf(stderr, "inspect failed: %v\n", err)
+			return exitFailed
+		}
+		return exitOK
+	}
+	printInspection(stdout, inspection)
+	return exitOK
+}
+
+func printInspection(w io.Writer, inspection *app.PackageInspection) {
+	fmt.Fprintf(w, "format: %s  encrypted: %t  size: %d\n", inspection.Format, inspection.Encrypted, inspection.Size)
+	fmt.Fprintf(w, "variant: %s\n", inspection.Profile.SuspectedVariant)
+	if inspection.IndexLength == nil {
+		fmt.Fprintln(w, "index: not read (encrypted; pass --appid to decrypt)")
+		return
+	}
+	fmt.Fprintf(w, "index: %d bytes, body: %d bytes, entries: %d\n", *inspection.IndexLength, *inspection.BodyLength, len(inspection.Entries))
+	for index, entry := range inspection.Entries {
+		note := ""
+		switch {
+		case entry.DuplicateOf != nil:
+			note = fmt.Sprintf("  (duplicate of #%d)", *entry.DuplicateOf)
+		case entry.RuntimeAlias:
+			note = "  (shared runtime alias)"
+		}
+		fmt.Fprintf(w, "%4d  %10d  %10d  %s  %s%s\n", index, entry.Offset, entry.Size, entry.SHA256[:12], entry.Name, note)
+	}
+	if !inspection.Extractable {
+		fmt.Fprintf(w, "not extractable: %s\n", inspection.ExtractIssue)
+	}
+}
+
+func parseInspectArgs(args []string, stderr io.Writer) (inspectOptions, error) {
+	opts := inspectOptions{}
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.appID, "appid", "", "decrypt a V1MMWX package to read its index")
+	fs.BoolVar(&opts.jsonMode, "json", false, "print the full inspection as JSON")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return opts, err
+	}
+	if len(positional) != 1 {
+		return opts, fmt.Errorf("inspect expects exactly one input package")
+	}
+	opts.input = positional[0]
+	return opts, nil
+}
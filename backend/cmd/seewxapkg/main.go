@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/keepbuild/seewxapkg/internal/app"
+	"github.com/keepbuild/seewxapkg/internal/config"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/events"
+	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	"github.com/keepbuild/seewxapkg/internal/service"
+	"github.com/keepbuild/seewxapkg/internal/version"
+)
+
+// Exit codes are part of the scripting contract: batch jobs distinguish a
+// usable-but-incomplete result from a hard failure.
+const (
+	exitOK      = 0
+	exitFailed  = 1
+	exitUsage   = 2
+	exitPartial = 3
+)
+
+const usageText = `seewxapkg %s — offline wxapkg recovery
+
+Usage:
+  seewxapkg decompile <input.wxapkg> -o <dir> [--sub pkg.wxapkg]... [--appid wx...] [--appid-candidates file] [--no-beautify]
+  seewxapkg batch <dir|archive> -o <dir> [--appid wx...] [--appid-candidates file] [--workers n]
+  seewxapkg pack <srcDir> -o <output.wxapkg> [--appid wx...]
+  seewxapkg inspect <input.wxapkg> [--appid wx...] [--json]
+  seewxapkg diff <base.wxapkg> <head.wxapkg> -o <dir> [--appid wx...]
+
+Commands:
+  decompile   run the full recovery pipeline against a local package
+  batch       decompile every .wxapkg below a directory or inside a zip/tar
+  pack        re-pack a source tree into a .wxapkg, optionally encrypted
+  inspect     list a package's header and index entries without extracting
+  diff        decompile two builds of one mini program and report what changed
+
+Without --appid, an encrypted package's AppID is taken from a wx... directory
+in its path; --appid-candidates additionally tries each AppID listed in a file.
+
+Exit codes: 0 completed, 1 failed, 2 usage error, 3 partial result.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintf(stderr, usageText, version.Version)
+		return exitUsage
+	}
+	switch args[0] {
+	case "decompile":
+		return runDecompile(ctx, args[1:], stdout, stderr)
+	case "batch":
+		return runBatch(ctx, args[1:], stdout, stderr)
+	case "pack":
+		return runPack(args[1:], stdout, stderr)
+	case "inspect":
+		return runInspect(args[1:], stdout, stderr)
+	case "diff":
+		return runDiff(ctx, args[1:], stdout, stderr)
+	case "-h", "--help", "help":
+		fmt.Fprintf(stdout, usageText, version.Version)
+		return exitOK
+	case "version", "--version":
+		fmt.Fprintln(stdout, version.Version)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+		fmt.Fprintf(stderr, usageText, version.Version)
+		return exitUsage
+	}
+}
+
+// pipelineOptions are the per-task options shared by every command.
+type pipelineOptions struct {
+	appID           string
+	appIDCandidates string
+	beautify        bool
+	decompile       bool
+	removeGuideHTML bool
+}
+
+type decompileOptions struct {
+	pipelineOptions
+	input       string
+	outputDir   string
+	subPackages stringList
+}
+
+// stringList collects a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func runDecompile(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	opts, err := parseDecompileArgs(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	cfg, cleanup, err := setupRuntime(opts.pipelineOptions)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailed
+	}
+	defer cleanup()
+
+	result, err := decompileLocal(ctx, cfg, opts, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "decompile failed: %v\n", err)
+		return exitFailed
+	}
+	printSummary(stdout, result, opts.outputDir)
+	return exitCodeFor(result.Status)
+}
+
+func exitCodeFor(status task.TaskStatus) int {
+	switch status {
+	case task.TaskCompleted:
+		return exitOK
+	case task.TaskPartial:
+		return exitPartial
+	default:
+		return exitFailed
+	}
+}
+
+func parseDecompileArgs(args []string, stderr io.Writer) (decompileOptions, error) {
+	opts := decompileOptions{}
+	fs := flag.NewFlagSet("decompile", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.outputDir, "o", "", "output directory for src/, reports/ and the archive")
+	fs.Var(&opts.subPackages, "sub", "subpackage to merge into the main package (repeatable)")
+	finish := registerPipelineFlags(fs, &opts.pipelineOptions)
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return opts, err
+	}
+	if len(positional) != 1 {
+		return opts, fmt.Errorf("decompile expects exactly one input package")
+	}
+	if opts.outputDir == "" {
+		return opts, fmt.Errorf("-o output directory is required")
+	}
+	opts.input = positional[0]
+	finish()
+	return opts, nil
+}
+
+// registerPipelineFlags binds the task option flags; the returned function
+// translates the negative flags once parsing is done.
+func registerPipelineFlags(fs *flag.FlagSet, opts *pipelineOptions) func() {
+	var noBeautify, shallow, keepGuideHTML bool
+	fs.StringVar(&opts.appID, "appid", "", "AppID used to decrypt V1MMWX packages")
+	fs.StringVar(&opts.appIDCandidates, "appid-candidates", "", "file of AppIDs to try when an encrypted package has none")
+	fs.BoolVar(&noBeautify, "no-beautify", false, "skip the final safe formatting stage")
+	fs.BoolVar(&shallow, "no-decompile", false, "only unpack and recover the manifest")
+	fs.BoolVar(&keepGuideHTML, "keep-guide-html", false, "keep WeChat 4.x runtime-guide .html scaffolds")
+	return func() {
+		opts.beautify = !noBeautify
+		opts.decompile = !shallow
+		opts.removeGuideHTML = !keepGuideHTML
+	}
+}
+
+// parseInterspersed lets flags follow the input path
+// (`decompile in.wxapkg -o out/`), which the standard flag package stops at.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// loadConfig reuses the server's environment configuration so Node, formatter
+// and recovery toggles behave identically, but pins task storage to a private
+// per-run workspace and keeps state in memory.
+func loadConfig(workspace string) (*config.Config, error) {
+	for key, dir := range map[string]string{
+		"TEMP_DIR":   filepath.Join(workspace, "tasks"),
+		"OUTPUT_DIR": filepath.Join(workspace, "output"),
+	} {
+		if err := os.Setenv(key, dir); err != nil {
+			return nil, err
+		}
+	}
+	cfg := config.Load()
+	cfg.TaskRepoDriver = "memory"
+	cfg.QueueDriver = "inmem"
+	// The workspace is removed after every run, so nothing cached would
+	// survive to be reused.
+	cfg.ResultCacheEnabled = false
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// setupRuntime prepares a private workspace, configuration and, when
+// requested, the formatter sidecar. The returned cleanup removes everything.
+func setupRuntime(opts pipelineOptions) (*config.Config, func(), error) {
+	workspace, err := os.MkdirTemp("", "seewxapkg-cli-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create workspace: %w", err)
+	}
+	cfg, err := loadConfig(workspace)
+	if err != nil {
+		os.RemoveAll(workspace)
+		return nil, nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if opts.appIDCandidates != "" {
+		cfg.AppIDCandidatesFile = opts.appIDCandidates
+	}
+	if !opts.beautify || !cfg.BeautifyEnabled {
+		return cfg, func() { os.RemoveAll(workspace) }, nil
+	}
+	if err := service.InitBeautifyService(cfg.BeautifyEnabled, cfg.BeautifyTimeout, cfg.BeautifyMaxFileSize, cfg.BeautifyFailureLimit, cfg.DeobfuscateEnabled); err != nil {
+		os.RemoveAll(workspace)
+		return nil, nil, fmt.Errorf("formatter unavailable (%v); rerun with --no-beautify", err)
+	}
+	return cfg, func() {
+		service.StopBeautifyService()
+		os.RemoveAll(workspace)
+	}, nil
+}
+
+// decompileLocal creates a task for the local package and runs the pipeline
+// synchronously, streaming stage events until the task reaches a terminal
+// state. The recovered tree, reports and archive are then copied out of the
+// temporary workspace.
+func decompileLocal(ctx context.Context, cfg *config.Config, opts decompileOptions, stdout io.Writer) (*task.Task, error) {
+	repo := persistence.NewMemoryTaskRepo()
+	broker := events.NewBroker()
+	compileService := app.NewCompileService(cfg, repo, broker, nil)
+
+	created, err := compileService.CreateTask(ctx, app.StartCompileCommand{
+		AppID:           opts.appID,
+		Beautify:        opts.beautify,
+		Decompile:       opts.decompile,
+		RemoveGuideHTML: opts.removeGuideHTML,
+		SearchAppID:     opts.appIDCandidates != "",
+		InputPath:       opts.input,
+		SubPackagePaths: opts.subPackages,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stage input: %w", err)
+	}
+	stream, history, unsubscribe, err := broker.Subscribe(created.ID)
+	if err != nil {
+		return nil, err
+	}
+	printed := make(chan struct{})
+	go func() {
+		defer close(printed)
+		for _, event := range history {
+			printEvent(stdout, event)
+		}
+		for event := range stream {
+			printEvent(stdout, event)
+		}
+	}()
+
+	runErr := compileService.RunTask(ctx, created.ID)
+	unsubscribe()
+	<-printed
+	// RunTask persists a terminal state for pipeline errors, so its error only
+	// matters when no task record is left to report on.
+	final, err := repo.Get(context.WithoutCancel(ctx), created.ID)
+	if err != nil {
+		return nil, errors.Join(runErr, err)
+	}
+	if err := exportResult(cfg, final, opts.outputDir, archiveName(opts.input)); err != nil {
+		return nil, fmt.Errorf("write output: %w", err)
+	}
+	return final, nil
+}
+
+func exportResult(cfg *config.Config, t *task.Task, outputDir, archive string) error {
+	dirs, err := storage.EnsureTaskDirs(cfg.TempDir, t.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outputDir, 0700); err != nil {
+		return err
+	}
+	if err := copyTree(dirs.ReportsDir, filepath.Join(outputDir, "reports")); err != nil {
+		return err
+	}
+	if t.Status == task.TaskFailed || t.Status == task.TaskCancelled {
+		return nil
+	}
+	if err := copyTree(dirs.SourceDir, filepath.Join(outputDir, "src")); err != nil {
+		return err
+	}
+	zipPath := filepath.Join(cfg.OutputDir, t.ID+".zip")
+	if _, err := os.Stat(zipPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return copyFile(zipPath, filepath.Join(outputDir, archive))
+}
+
+func archiveName(input string) string {
+	base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	if base == "" || base == "." {
+		base = "result"
+	}
+	return base + ".zip"
+}
+
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relative)
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0700)
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func printEvent(w io.Writer, event task.TaskEvent) {
+	switch event.Type {
+	case "progress":
+		fmt.Fprintf(w, "[%3d%%] %-20s %s\n", event.Percent, event.Stage, event.Message)
+	default:
+		line := fmt.Sprintf("[%3d%%] %-20s %s", event.Percent, event.Type, event.Message)
+		if event.ErrorCode != "" {
+			line += " (" + event.ErrorCode + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func printSummary(w io.Writer, t *task.Task, outputDir string) {
+	fmt.Fprintln(w)
+	for _, stage := range t.StageResults {
+		fmt.Fprintf(w, "  %-20s %-8s %6dms  %s\n", stage.Stage, stage.Status, stage.DurationMs, stage.Message)
+	}
+	fmt.Fprintf(w, "status: %s\n", t.Status)
+	if t.RecoveryScore != nil {
+		fmt.Fprintf(w, "score:  %d\n", t.RecoveryScore.Overall)
+	}
+	if t.ErrorCode != nil {
+		message := ""
+		if t.ErrorMessage != nil {
+			message = *t.ErrorMessage
+		}
+		fmt.Fprintf(w, "error:  %s %s\n", *t.ErrorCode, message)
+	}
+	if len(t.Diagnostics) > 0 {
+		fmt.Fprintf(w, "diagnostics: %d (see reports/diagnostics.json)\n", len(t.Diagnostics))
+	}
+	fmt.Fprintf(w, "output: %s\n", outputDir)
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/keepbuild/seewxapkg/tests/testutil"
+)
+
+func writeTestPackage(t *testing.T, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "__APP__.wxapkg")
+	if err := os.WriteFile(path, testutil.MustBuildWxapkg(files), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// disableNodeStages keeps CLI tests independent of the optional Node runtime.
+func disableNodeStages(t *testing.T) {
+	t.Helper()
+	t.Setenv("FALLBACK_RECOVER_ENABLED", "false")
+	t.Setenv("VERIFICATION_ENABLED", "false")
+	t.Setenv("BEAUTIFY_ENABLED", "false")
+}
+
+func TestDecompileWritesSourceReportsAndArchive(t *testing.T) {
+	disableNodeStages(t)
+	input := writeTestPackage(t, map[string]string{
+		"app.json":              `{"pages":["pages/home/index"]}`,
+		"app.js":                `App({})`,
+		"pages/home/index.js":   `Page({})`,
+		"pages/home/index.wxml": `<view>home</view>`,
+		"pages/home/index.wxss": `.home {}`,
+	})
+	outputDir := filepath.Join(t.TempDir(), "out")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"decompile", input, "-o", outputDir, "--no-beautify"}, &stdout, &stderr)
+	// Verification is disabled, so the pipeline delivers partial semantics.
+	if code != exitPartial {
+		t.Fatalf("exit code=%d stderr=%s stdout=%s", code, stderr.String(), stdout.String())
+	}
+	for _, relative := range []string{
+		"src/app.json",
+		"src/pages/home/index.wxml",
+		"reports/recovery-report.json",
+		"reports/zip-manifest.json",
+		"__APP__.zip",
+	} {
+		if _, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(relative))); err != nil {
+			t.Fatalf("expected %s in output: %v", relative, err)
+		}
+	}
+	for _, stage := range []string{"classifying", "unpacking", "packaging", "status: partial"} {
+		if !strings.Contains(stdout.String(), stage) {
+			t.Fatalf("progress output is missing %q:\n%s", stage, stdout.String())
+		}
+	}
+}
+
+func TestDecompileMergesSubPackages(t *testing.T) {
+	disableNodeStages(t)
+	input := writeTestPackage(t, map[string]string{
+		"app.json":              `{"pages":["pages/home/index","packageA/pages/detail/index"],"subPackages":[{"root":"packageA","pages":["pages/detail/index"]}]}`,
+		"pages/home/index.js":   `Page({})`,
+		"pages/home/index.wxml": `<view>home</view>`,
+	})
+	subPackage := filepath.Join(t.TempDir(), "packageA.wxapkg")
+	if err := os.WriteFile(subPackage, testutil.MustBuildWxapkg(map[string]string{
+		"packageA/pages/detail/index.js":   `Page({})`,
+		"packageA/pages/detail/index.wxml": `<view>detail</view>`,
+	}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	outputDir := filepath.Join(t.TempDir(), "out")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"decompile", input, "--sub", subPackage, "-o", outputDir, "--no-beautify"}, &stdout, &stderr)
+	if code != exitPartial {
+		t.Fatalf("exit code=%d stderr=%s stdout=%s", code, stderr.String(), stdout.String())
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "src", "packageA", "pages", "detail", "index.wxml")); err != nil {
+		t.Fatalf("subpackage page missing from merged tree: %v", err)
+	}
+	reportData, err := os.ReadFile(filepath.Join(outputDir, "reports", "recovery-report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(reportData), "unpack.subpackages.merged") {
+		t.Fatalf("recovery report does not record the subpackage merge:\n%s", reportData)
+	}
+}
+
+func TestDecompileReturnsFailureForInvalidPackage(t *testing.T) {
+	disableNodeStages(t)
+	input := filepath.Join(t.TempDir(), "broken.wxapkg")
+	if err := os.WriteFile(input, []byte("not a package"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	outputDir := filepath.Join(t.TempDir(), "out")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"decompile", "-o", outputDir, input}, &stdout, &stderr)
+	if code != exitFailed {
+		t.Fatalf("exit code=%d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "status: failed") {
+		t.Fatalf("summary did not report failure:\n%s", stdout.String())
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "src")); !os.IsNotExist(err) {
+		t.Fatalf("failed task must not export a source tree: %v", err)
+	}
+}
+
+func TestDecompileRejectsMissingArguments(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run(context.Background(), []string{"decompile", "in.wxapkg"}, &stdout, &stderr); code != exitUsage {
+		t.Fatalf("missing -o should be a usage error, got %d", code)
+	}
+	if code := run(context.Background(), []string{"unknown"}, &stdout, &stderr); code != exitUsage {
+		t.Fatalf("unknown command should be a usage error, got %d", code)
+	}
+}
+
+func TestBatchDecompilesDirectoryAndWritesAggregateReport(t *testing.T) {
+	disableNodeStages(t)
+	root := t.TempDir()
+	pkgData := testutil.MustBuildWxapkg(map[string]string{
+		"app.json":              `{"pages":["pages/home/index"]}`,
+		"pages/home/index.js":   `Page({})`,
+		"pages/home/index.wxml": `<view>home</view>`,
+	})
+	for _, relative := range []string{"wx0123456789abcdef/1/__APP__.wxapkg", "wx0123456789abcdef/2/__APP__.wxapkg"} {
+		path := filepath.Join(root, filepath.FromSlash(relative))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, pkgData, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "broken.wxapkg"), []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	outputDir := filepath.Join(t.TempDir(), "out")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"batch", root, "-o", outputDir, "--no-beautify", "--workers", "2"}, &stdout, &stderr)
+	if code != exitFailed {
+		t.Fatalf("a failed package must fail the batch, got %d stderr=%s", code, stderr.String())
+	}
+	reportData, err := os.ReadFile(filepath.Join(outputDir, "batch-report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"partial": 2`, `"failed": 1`, "wx************cdef/1/__APP__.wxapkg"} {
+		if !strings.Contains(string(reportData), want) {
+			t.Fatalf("batch report is missing %q:\n%s", want, reportData)
+		}
+	}
+	if strings.Contains(string(reportData), "wx0123456789abcdef") {
+		t.Fatal("batch report exposed the AppID")
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "002-wxcdef_1___APP__", "src", "app.json")); err != nil {
+		t.Fatalf("per-package output missing: %v", err)
+	}
+}
+
+func TestPackEncryptsTreeThatDecompileRecovers(t *testing.T) {
+	disableNodeStages(t)
+	srcDir := t.TempDir()
+	files := map[string]string{
+		"app.json":            `{"pages":["pages/home/index"]}`,
+		"pages/home/index.js": "Page({data:{text:\"" + strings.Repeat("packed ", 200) + "\"}})",
+	}
+	for name, content := range files {
+		path := filepath.Join(srcDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	packed := filepath.Join(t.TempDir(), "packed.wxapkg")
+	var stdout, stderr bytes.Buffer
+	if code := run(context.Background(), []string{"pack", srcDir, "-o", packed, "--appid", "wx0123456789abcdef"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("pack exit code=%d stderr=%s", code, stderr.String())
+	}
+	data, err := os.ReadFile(packed)
+	if err != nil || !bytes.HasPrefix(data, []byte("V1MMWX")) {
+		t.Fatalf("pack did not write an encrypted package: %v", err)
+	}
+
+	outputDir := filepath.Join(t.TempDir(), "out")
+	code := run(context.Background(), []string{"decompile", packed, "-o", outputDir, "--no-beautify", "--appid", "wx0123456789abcdef"}, &stdout, &stderr)
+	if code != exitPartial {
+		t.Fatalf("decompile exit code=%d stderr=%s stdout=%s", code, stderr.String(), stdout.String())
+	}
+	recovered, err := os.ReadFile(filepath.Join(outputDir, "src", "pages", "home", "index.js"))
+	if err != nil || string(recovered) != files["pages/home/index.js"] {
+		t.Fatalf("recovered page differs from the packed source: %v", err)
+	}
+}
+
+func TestInspectPrintsIndexWithoutWritingFiles(t *testing.T) {
+	input := writeTestPackage(t, map[string]string{
+		"app.json":            `{"pages":["pages/home/index"]}`,
+		"pages/home/index.js": `Page({})`,
+	})
+	var stdout, stderr bytes.Buffer
+	if code := run(context.Background(), []string{"inspect", input, "--json"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("inspect exit code=%d stderr=%s", code, stderr.String())
+	}
+	for _, want := range []string{`"name": "pages/home/index.js"`, `"sha256"`, `"suspectedVariant": "standard"`} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Fatalf("inspect output is missing %q:\n%s", want, stdout.String())
+		}
+	}
+	entries, err := os.ReadDir(filepath.Dir(input))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("inspect must not write next to the input: %v %v", entries, err)
+	}
+}
+
+func TestDiffReportsChangesBetweenBuilds(t *testing.T) {
+	disableNodeStages(t)
+	base := writeTestPackage(t, map[string]string{
+		"app.json":              `{"pages":["pages/home/index"]}`,
+		"pages/home/index.js":   "Page({})\n",
+		"pages/home/index.wxml": "<view>home</view>\n",
+	})
+	head := writeTestPackage(t, map[string]string{
+		"app.json":              `{"pages":["pages/home/index","pages/about/index"]}`,
+		"pages/home/index.js":   "Page({})\n",
+		"pages/home/index.wxml": "<view>home v2</view>\n",
+		"pages/about/index.js":  "Page({})\n",
+	})
+	outputDir := filepath.Join(t.TempDir(), "out")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"diff", base, head, "-o", outputDir, "--no-beautify"}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("exit code=%d stderr=%s stdout=%s", code, stderr.String(), stdout.String())
+	}
+	reportData, err := os.ReadFile(filepath.Join(outputDir, "diff-report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"pages/about/index.js"`, `"pagesAdded": [`, `"pages/about/index"`, `"beautified": false`} {
+		if !strings.Contains(string(reportData), want) {
+			t.Fatalf("diff report is missing %q:\n%s", want, reportData)
+		}
+	}
+	patch, err := os.ReadFile(filepath.Join(outputDir, "diff.patch"))
+	if err != nil || !strings.Contains(string(patch), "+<view>home v2</view>") {
+		t.Fatalf("diff.patch does not carry the WXML change: %q %v", patch, err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	dec "github.com/keepbuild/seewxapkg/internal/pipeline/decrypt"
+	"github.com/keepbuild/seewxapkg/internal/service"
+)
+
+type packOptions struct {
+	srcDir string
+	output string
+	appID  string
+}
+
+// runPack is the inverse of decompile's unpack stage. It does not need the
+// runtime configuration, so it skips setupRuntime entirely.
+func runPack(args []string, stdout, stderr io.Writer) int {
+	opts, err := parsePackArgs(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	data, err := service.PackWxapkg(opts.srcDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "pack failed: %v\n", err)
+		return exitFailed
+	}
+	if opts.appID != "" {
+		if data, err = dec.EncryptWxapkg(data, opts.appID); err != nil {
+			fmt.Fprintf(stderr, "encrypt failed: %v\n", err)
+			return exitFailed
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.output), 0700); err != nil {
+		fmt.Fprintf(stderr, "pack failed: %v\n", err)
+		return exitFailed
+	}
+	if err := os.WriteFile(opts.output, data, 0600); err != nil {
+		fmt.Fprintf(stderr, "pack failed: %v\n", err)
+		return exitFailed
+	}
+	fmt.Fprintf(stdout, "wrote %s (%d bytes)\n", opts.output, len(data))
+	return exitOK
+}
+
+func parsePackArgs(args []string, stderr io.Writer) (packOptions, error) {
+	opts := packOptions{}
+	fs := flag.NewFlagSet("pack", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.output, "o", "", "output .wxapkg file")
+	fs.StringVar(&opts.appID, "appid", "", "encrypt the package as V1MMWX with this AppID")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return opts, err
+	}
+	if len(positional) != 1 {
+		return opts, fmt.Errorf("pack expects exactly one source directory")
+	}
+	if opts.output == "" || !strings.HasSuffix(strings.ToLower(opts.output), ".wxapkg") {
+		return opts, fmt.Errorf("-o must name a .wxapkg file")
+	}
+	opts.srcDir = positional[0]
+	return opts, nil
+}
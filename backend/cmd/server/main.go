@@ -69,6 +69,9 @@ func run() error {
 		return fmt.Errorf("initialize task queue: %w", err)
 	}
 	compileService := app.NewCompileService(cfg, repo, broker, jobQueue)
+	if cfg.WebhookSecret != "" {
+		compileService.SetTaskNotifier(httpapi.NewTaskWebhook(cfg.WebhookSecret))
+	}
 	queryService := app.NewTaskQueryService(cfg, repo)
 	workerCtx, workerCancel := context.WithCancel(context.Background())
 	defer workerCancel()
@@ -85,6 +88,8 @@ func run() error {
 		httpapi.NewTaskHandler(queryService, broker),
 		httpapi.NewDownloadHandler(queryService),
 		httpapi.NewGitHubStarsHandler(app.NewGitHubStarsService()),
+		httpapi.NewBatchHandler(app.NewBatchService(cfg, repo, compileService), cfg.MaxBatchUploadSize),
+		httpapi.NewDiffHandler(app.NewDiffService(cfg, repo, compileService), cfg.MaxBatchUploadSize),
 	)
 	router.RegisterRoutes(r)
 
@@ -222,7 +227,7 @@ func corsMiddleware(allowedOrigins []string) gin.HandlerFunc {
 		}
 		if originAllowed {
 			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With")
-			c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 			c.Writer.Header().Set("Access-Control-Max-Age", "600")
 		}
 
@@ -207,7 +207,7 @@ func TestCORSMiddlewareHandlesPreflight(t *testing.T) {
 	if response.Code != http.StatusNoContent {
 		t.Fatalf("expected 204, got %d", response.Code)
 	}
-	if got := response.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, OPTIONS" {
+	if got := response.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, DELETE, OPTIONS" {
 		t.Fatalf("unexpected allowed methods: %q", got)
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"syscall"
 	"time"
 
+	httpapi "github.com/keepbuild/seewxapkg/internal/api/http"
 	"github.com/keepbuild/seewxapkg/internal/app"
 	"github.com/keepbuild/seewxapkg/internal/config"
 	"github.com/keepbuild/seewxapkg/internal/infra/events"
@@ -42,6 +43,9 @@ func main() {
 		log.Fatal("failed to initialize task queue: ", err)
 	}
 	compileService := app.NewCompileService(cfg, repo, events.NewBroker(), jobQueue)
+	if cfg.WebhookSecret != "" {
+		compileService.SetTaskNotifier(httpapi.NewTaskWebhook(cfg.WebhookSecret))
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
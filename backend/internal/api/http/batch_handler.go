@@ -0,0 +1,130 @@
+package httpapi
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keepbuild/seewxapkg/internal/app"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+)
+
+type BatchHandler struct {
+	service        *app.BatchService
+	maxUploadBytes int64
+}
+
+func NewBatchHandler(service *app.BatchService, maxUploadBytes int64) *BatchHandler {
+	return &BatchHandler{service: service, maxUploadBytes: maxUploadBytes}
+}
+
+func (h *BatchHandler) StartBatch(c *gin.Context) {
+	if err := h.service.Readiness(); err != nil {
+		log.Printf("[Batch] readiness check failed (%T)", err)
+		c.JSON(http.StatusServiceUnavailable, BatchResponseDTO{Success: false, Message: "服务依赖尚未就绪，请稍后重试"})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadBytes+1024)
+	defer func() {
+		if c.Request.MultipartForm != nil {
+			if err := c.Request.MultipartForm.RemoveAll(); err != nil {
+				log.Printf("[Batch] multipart temporary-file cleanup failed (%T)", err)
+			}
+		}
+	}()
+
+	dto, file, err := parseCompileRequest(c)
+	if err != nil {
+		log.Printf("[Batch] parse upload request failed (%T)", err)
+		c.JSON(http.StatusBadRequest, BatchResponseDTO{Success: false, Message: "上传请求格式错误，请重新选择文件后重试"})
+		return
+	}
+	if dto.AppID != "" && !appIDRegex.MatchString(dto.AppID) {
+		c.JSON(http.StatusBadRequest, BatchResponseDTO{Success: false, Message: "AppID 格式错误，应为 wx 开头加 16 位十六进制字符"})
+		return
+	}
+	if file.Size > h.maxUploadBytes {
+		c.JSON(http.StatusBadRequest, BatchResponseDTO{Success: false, Message: "文件过大，超过服务限制"})
+		return
+	}
+	if !isBatchArchiveName(file.Filename) {
+		c.JSON(http.StatusBadRequest, BatchResponseDTO{Success: false, Message: "批量文件必须是 .zip、.tar 或 .tar.gz 格式"})
+		return
+	}
+
+	batch, err := h.service.StartBatch(c.Request.Context(), app.StartBatchCommand{
+		AppID:           dto.AppID,
+		Beautify:        dto.Beautify,
+		Decompile:       dto.Decompile,
+		RemoveGuideHTML: dto.RemoveGuideHTML,
+		File:            file,
+	})
+	if err != nil {
+		if message, ok := batchInputErrorMessage(err); ok {
+			c.JSON(http.StatusBadRequest, BatchResponseDTO{Success: false, Message: message})
+			return
+		}
+		log.Printf("[Batch] batch creation failed (%T)", err)
+		c.JSON(http.StatusInternalServerError, BatchResponseDTO{Success: false, Message: "批量任务创建失败，请稍后重试"})
+		return
+	}
+
+	taskIDs := make([]string, 0, len(batch.Items))
+	for _, item := range batch.Items {
+		if item.TaskID != "" {
+			taskIDs = append(taskIDs, item.TaskID)
+		}
+	}
+	c.JSON(http.StatusOK, BatchResponseDTO{
+		Success:  true,
+		BatchID:  batch.ID,
+		TaskIDs:  taskIDs,
+		Packages: len(batch.Items),
+		Message:  "batch created",
+	})
+}
+
+func (h *BatchHandler) GetBatchReport(c *gin.Context) {
+	batchID := c.Param("batchId")
+	if !taskIDRegex.MatchString(batchID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的批量任务 ID"})
+		return
+	}
+	result, err := h.service.GetBatchReport(c.Request.Context(), batchID)
+	if err != nil {
+		if errors.Is(err, app.ErrBatchNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "批量任务不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取批量报告失败"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func isBatchArchiveName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, suffix := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func batchInputErrorMessage(err error) (string, bool) {
+	switch {
+	case errors.Is(err, storage.ErrBatchEmpty):
+		return "压缩包中没有 .wxapkg 文件", true
+	case errors.Is(err, storage.ErrBatchTooManyPackages):
+		return "压缩包中的 .wxapkg 文件数量超过服务限制", true
+	case errors.Is(err, storage.ErrBatchPackageTooLarge):
+		return "压缩包中存在超过大小限制的 .wxapkg 文件", true
+	case errors.Is(err, storage.ErrBatchFormat):
+		return "无法识别的压缩包格式", true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,111 @@
+package httpapi
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keepbuild/seewxapkg/internal/app"
+	"github.com/keepbuild/seewxapkg/internal/config"
+	"github.com/keepbuild/seewxapkg/internal/infra/events"
+	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
+	"github.com/keepbuild/seewxapkg/internal/infra/queue"
+	"github.com/keepbuild/seewxapkg/tests/testutil"
+)
+
+func newBatchTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	cfg := &config.Config{TempDir: t.TempDir(), OutputDir: t.TempDir(), MaxUploadSize: 1024, MaxBatchPackages: 4}
+	repo := persistence.NewMemoryTaskRepo()
+	compile := app.NewCompileService(cfg, repo, events.NewBroker(), queue.NewInMemoryQueue(8))
+	handler := NewBatchHandler(app.NewBatchService(cfg, repo, compile), 64*1024)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/batch", handler.StartBatch)
+	router.GET("/batch/:batchId", handler.GetBatchReport)
+	return router
+}
+
+func buildTestZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, content := range files {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func postBatch(t *testing.T, router *gin.Engine, filename string, data []byte, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder, body, contentType, err := testutil.BuildMultipartCompileRequest(filename, data, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := httptest.NewRequest(http.MethodPost, "/batch", body)
+	request.Header.Set("Content-Type", contentType)
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestStartBatchCreatesOneTaskPerPackage(t *testing.T) {
+	router := newBatchTestRouter(t)
+	archive := buildTestZip(t, map[string]string{"v1/__APP__.wxapkg": "a", "v2/__APP__.wxapkg": "b", "readme.md": "c"})
+
+	response := postBatch(t, router, "packages.zip", archive, map[string]string{"appId": "wx0123456789abcdef"})
+	if response.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", response.Code, response.Body.String())
+	}
+	var payload BatchResponseDTO
+	if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if !payload.Success || payload.Packages != 2 || len(payload.TaskIDs) != 2 {
+		t.Fatalf("unexpected response: %#v", payload)
+	}
+
+	reportResponse := httptest.NewRecorder()
+	router.ServeHTTP(reportResponse, httptest.NewRequest(http.MethodGet, "/batch/"+payload.BatchID, nil))
+	if reportResponse.Code != http.StatusOK {
+		t.Fatalf("report status = %d: %s", reportResponse.Code, reportResponse.Body.String())
+	}
+}
+
+func TestStartBatchRejectsInvalidInputs(t *testing.T) {
+	router := newBatchTestRouter(t)
+	for name, tc := range map[string]struct {
+		filename string
+		data     []byte
+		fields   map[string]string
+	}{
+		"not an archive": {filename: "single.wxapkg", data: []byte("x")},
+		"no packages":    {filename: "empty.zip", data: buildTestZip(t, map[string]string{"readme.md": "x"})},
+		"bad app id":     {filename: "batch.zip", data: buildTestZip(t, map[string]string{"a.wxapkg": "x"}), fields: map[string]string{"appId": "bad"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			response := postBatch(t, router, tc.filename, tc.data, tc.fields)
+			if response.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d: %s", response.Code, response.Body.String())
+			}
+		})
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/batch/00000000-0000-0000-0000-000000000000", nil))
+	if response.Code != http.StatusNotFound {
+		t.Fatalf("unknown batch status = %d", response.Code)
+	}
+}
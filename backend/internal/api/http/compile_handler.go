@@ -1,6 +1,8 @@
 package httpapi
 
 import (
+	"errors"
+	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
@@ -73,6 +75,11 @@ func (h *CompileHandler) Compile(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, CompileResponseDTO{Success: false, Message: err.Error()})
 		return
 	}
+	subPackages := subPackageFiles(c)
+	if err := validateSubPackages(file, subPackages, h.maxUploadBytes); err != nil {
+		c.JSON(http.StatusBadRequest, CompileResponseDTO{Success: false, Message: err.Error()})
+		return
+	}
 
 	task, err := h.service.StartTask(c.Request.Context(), app.StartCompileCommand{
 		AppID:           dto.AppID,
@@ -80,7 +87,19 @@ func (h *CompileHandler) Compile(c *gin.Context) {
 		Decompile:       dto.Decompile,
 		RemoveGuideHTML: dto.RemoveGuideHTML,
 		File:            file,
+		SourcePath:      dto.SourcePath,
+		SearchAppID:     dto.SearchAppID,
+		SubPackages:     subPackages,
+		CallbackURL:     dto.CallbackURL,
 	})
+	if errors.Is(err, app.ErrAppIDSearchUnavailable) {
+		c.JSON(http.StatusBadRequest, CompileResponseDTO{Success: false, Message: "服务未配置候选 AppID 列表，无法自动查找 AppID"})
+		return
+	}
+	if errors.Is(err, app.ErrCallbackURLNotAllowed) {
+		c.JSON(http.StatusBadRequest, CompileResponseDTO{Success: false, Message: callbackURLNotAllowedMessage})
+		return
+	}
 	if err != nil {
 		log.Printf("[Compile] task creation failed (%T)", err)
 		c.JSON(http.StatusInternalServerError, CompileResponseDTO{Success: false, Message: "任务创建失败，请稍后重试"})
@@ -94,13 +113,189 @@ func (h *CompileHandler) Compile(c *gin.Context) {
 	})
 }
 
+// CancelTask stops a queued or running task. It answers 202 when the worker
+// has not confirmed the cancellation yet; the task then stops at its next
+// check and the event stream reports it.
+func (h *CompileHandler) CancelTask(c *gin.Context) {
+	taskID := c.Param("taskId")
+	if !taskIDRegex.MatchString(taskID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务 ID"})
+		return
+	}
+	t, err := h.service.CancelTask(c.Request.Context(), taskID)
+	switch {
+	case errors.Is(err, app.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	case errors.Is(err, app.ErrTaskFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": "任务已结束，无法取消"})
+		return
+	case err != nil:
+		log.Printf("[Tasks] cancel failed (%T)", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取消任务失败，请稍后重试"})
+		return
+	}
+	statusCode := http.StatusOK
+	if !t.Status.IsTerminal() {
+		statusCode = http.StatusAccepted
+	}
+	c.JSON(statusCode, ToTaskResponseDTO(t))
+}
+
+// DeleteTask removes the task and everything it produced, cancelling it
+// first when it has not finished. A task that does not stop in time answers
+// 202 and is deleted when its pipeline ends.
+func (h *CompileHandler) DeleteTask(c *gin.Context) {
+	taskID := c.Param("taskId")
+	if !taskIDRegex.MatchString(taskID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务 ID"})
+		return
+	}
+	err := h.service.DeleteTask(c.Request.Context(), taskID)
+	if errors.Is(err, app.ErrTaskNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+	if errors.Is(err, app.ErrTaskStillStopping) {
+		c.JSON(http.StatusAccepted, gin.H{"error": "任务仍在停止，停止后将自动删除"})
+		return
+	}
+	if err != nil {
+		log.Printf("[Tasks] delete failed (%T)", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除任务失败，请稍后重试"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// rerunRequest carries the options a rerun overrides; omitted fields keep
+// the parent task's choice.
+type rerunRequest struct {
+	Beautify        *bool  `json:"beautify"`
+	Decompile       *bool  `json:"decompile"`
+	RemoveGuideHTML *bool  `json:"removeGuideHtml"`
+	CallbackURL     string `json:"callbackUrl"`
+}
+
+// RerunTask creates a child task that reprocesses a finished task's retained
+// decrypted package with new options, so the package need not be uploaded
+// again.
+func (h *CompileHandler) RerunTask(c *gin.Context) {
+	if _, err := h.service.Readiness(); err != nil {
+		log.Printf("[Compile] readiness check failed (%T)", err)
+		c.JSON(http.StatusServiceUnavailable, CompileResponseDTO{Success: false, Message: "服务依赖尚未就绪，请稍后重试"})
+		return
+	}
+	taskID := c.Param("taskId")
+	if !taskIDRegex.MatchString(taskID) {
+		c.JSON(http.StatusBadRequest, CompileResponseDTO{Success: false, Message: "无效的任务 ID"})
+		return
+	}
+	var req rerunRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, CompileResponseDTO{Success: false, Message: "请求格式错误"})
+			return
+		}
+	}
+	child, err := h.service.RerunTask(c.Request.Context(), taskID, app.RerunOptions{
+		Beautify:        req.Beautify,
+		Decompile:       req.Decompile,
+		RemoveGuideHTML: req.RemoveGuideHTML,
+		CallbackURL:     strings.TrimSpace(req.CallbackURL),
+	})
+	switch {
+	case errors.Is(err, app.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, CompileResponseDTO{Success: false, Message: "任务不存在"})
+		return
+	case errors.Is(err, app.ErrTaskNotFinished):
+		c.JSON(http.StatusConflict, CompileResponseDTO{Success: false, Message: "任务尚未结束，无法重新处理"})
+		return
+	case errors.Is(err, app.ErrRerunUnavailable):
+		c.JSON(http.StatusConflict, CompileResponseDTO{Success: false, Message: "原任务未保留解密后的包，请重新上传"})
+		return
+	case errors.Is(err, app.ErrCallbackURLNotAllowed):
+		c.JSON(http.StatusBadRequest, CompileResponseDTO{Success: false, Message: callbackURLNotAllowedMessage})
+		return
+	case err != nil:
+		log.Printf("[Compile] rerun task creation failed (%T)", err)
+		c.JSON(http.StatusInternalServerError, CompileResponseDTO{Success: false, Message: "任务创建失败，请稍后重试"})
+		return
+	}
+	c.JSON(http.StatusOK, CompileResponseDTO{
+		Success: true,
+		TaskID:  child.ID,
+		Message: "task created",
+	})
+}
+
+// Inspect parses an uploaded package's header and index without creating a
+// task. The package is read into memory and never written to disk.
+func (h *CompileHandler) Inspect(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadBytes+1024)
+	defer func() {
+		if c.Request.MultipartForm != nil {
+			if err := c.Request.MultipartForm.RemoveAll(); err != nil {
+				log.Printf("[Inspect] multipart temporary-file cleanup failed (%T)", err)
+			}
+		}
+	}()
+
+	dto, file, err := parseCompileRequest(c)
+	if err != nil {
+		log.Printf("[Inspect] parse upload request failed (%T)", err)
+		c.JSON(http.StatusBadRequest, InspectResponseDTO{Success: false, Message: "上传请求格式错误，请重新选择文件后重试"})
+		return
+	}
+	if err := validateCompileRequest(dto, file, h.maxUploadBytes); err != nil {
+		c.JSON(http.StatusBadRequest, InspectResponseDTO{Success: false, Message: err.Error()})
+		return
+	}
+	data, err := readUploadedFile(file)
+	if err != nil {
+		log.Printf("[Inspect] read upload failed (%T)", err)
+		c.JSON(http.StatusBadRequest, InspectResponseDTO{Success: false, Message: "上传文件读取失败，请重试"})
+		return
+	}
+
+	inspection, err := app.InspectPackage(data, dto.AppID)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, InspectResponseDTO{Success: false, Message: inspectErrorMessage(err)})
+		return
+	}
+	c.JSON(http.StatusOK, InspectResponseDTO{Success: true, Inspection: inspection})
+}
+
+func readUploadedFile(file *multipart.FileHeader) ([]byte, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
+
+func inspectErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, app.ErrUnreadablePackage):
+		return "无法解析包索引，加密包请确认 AppID 是否正确"
+	default:
+		return "文件不是有效的 wxapkg 包"
+	}
+}
+
 func parseCompileRequest(c *gin.Context) (CompileRequestDTO, *multipart.FileHeader, error) {
 	dto := CompileRequestDTO{
 		AppID:           c.PostForm("appId"),
 		Beautify:        c.PostForm("beautify") == "true",
 		Decompile:       c.PostForm("decompile") == "true",
 		RemoveGuideHTML: removeGuideHTML(c.PostForm("removeGuideHtml")),
+		SourcePath:      c.PostForm("sourcePath"),
+		SearchAppID:     c.PostForm("searchAppId") == "true",
+		CallbackURL:     strings.TrimSpace(c.PostForm("callbackUrl")),
 	}
+	// Multipart filenames are reduced to their base name, so the WeChat
+	// directory of the package has to arrive as its own field.
 	file, err := c.FormFile("file")
 	return dto, file, err
 }
@@ -126,3 +321,29 @@ func validateCompileRequest(dto CompileRequestDTO, file *multipart.FileHeader, m
 	}
 	return nil
 }
+
+// subPackageFiles returns the optional `subpackages` parts uploaded next to
+// the main package. They are merged into the main package's source tree.
+func subPackageFiles(c *gin.Context) []*multipart.FileHeader {
+	if c.Request.MultipartForm == nil {
+		return nil
+	}
+	return c.Request.MultipartForm.File["subpackages"]
+}
+
+func validateSubPackages(main *multipart.FileHeader, subPackages []*multipart.FileHeader, maxUploadBytes int64) error {
+	if len(subPackages) > app.MaxSubPackages {
+		return httpError("分包数量超过服务限制")
+	}
+	total := main.Size
+	for _, file := range subPackages {
+		if !strings.HasSuffix(strings.ToLower(file.Filename), ".wxapkg") {
+			return httpError("分包文件必须是 .wxapkg 格式")
+		}
+		total += file.Size
+	}
+	if total > maxUploadBytes {
+		return httpError("主包与分包合计过大，超过服务限制")
+	}
+	return nil
+}
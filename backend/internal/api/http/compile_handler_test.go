@@ -18,6 +18,11 @@ import (
 	"github.com/keepbuild/seewxapkg/internal/config"
 	"github.com/keepbuild/seewxapkg/internal/domain/task"
 	"github.com/keepbuild/seewxapkg/internal/infra/events"
+	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
+	"github.com/keepbuild/seewxapkg/internal/infra/queue"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	dec "github.com/keepbuild/seewxapkg/internal/pipeline/decrypt"
+	"github.com/keepbuild/seewxapkg/tests/testutil"
 )
 
 type createFailingRepository struct {
@@ -36,6 +41,14 @@ func (r createFailingRepository) Get(context.Context, string) (*task.Task, error
 	return nil, errors.New("task not found")
 }
 
+func (r createFailingRepository) Delete(context.Context, string) error {
+	return nil
+}
+
+func (r createFailingRepository) List(context.Context, task.ListFilter) (*task.ListPage, error) {
+	return &task.ListPage{}, nil
+}
+
 func TestHealthCheckDoesNotExposeReadinessPath(t *testing.T) {
 	missingDir := filepath.Join("/app", "private-health-"+filepath.Base(t.TempDir()))
 	service := app.NewCompileService(&config.Config{
@@ -150,6 +163,10 @@ func newCompileTestRouter(handler *CompileHandler) *gin.Engine {
 	router := gin.New()
 	router.GET("/health", handler.HealthCheck)
 	router.POST("/compile", handler.Compile)
+	router.POST("/inspect", handler.Inspect)
+	router.POST("/tasks/:taskId/cancel", handler.CancelTask)
+	router.DELETE("/tasks/:taskId", handler.DeleteTask)
+	router.POST("/tasks/:taskId/rerun", handler.RerunTask)
 	return router
 }
 
@@ -175,6 +192,110 @@ func assertGenericServerFailure(t *testing.T, body, messageField, expectedMessag
 	}
 }
 
+func TestCancelAndDeleteTaskEndpoints(t *testing.T) {
+	repo := persistence.NewMemoryTaskRepo()
+	service := app.NewCompileService(&config.Config{TempDir: t.TempDir(), OutputDir: t.TempDir()}, repo, events.NewBroker(), nil)
+	router := newCompileTestRouter(NewCompileHandler(service, 1024))
+	for _, current := range []*task.Task{
+		{ID: "0000-aaaa", Status: task.TaskQueued},
+		{ID: "0000-bbbb", Status: task.TaskCompleted},
+	} {
+		if err := repo.Create(context.Background(), current); err != nil {
+			t.Fatal(err)
+		}
+	}
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(method, path, nil))
+		return response
+	}
+
+	response := serve(http.MethodPost, "/tasks/0000-aaaa/cancel")
+	var cancelled TaskResponseDTO
+	if err := json.Unmarshal(response.Body.Bytes(), &cancelled); err != nil || response.Code != http.StatusOK {
+		t.Fatalf("cancel status = %d: %s", response.Code, response.Body.String())
+	}
+	if cancelled.Status != string(task.TaskCancelled) {
+		t.Fatalf("cancelled task status = %q", cancelled.Status)
+	}
+	for _, check := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/tasks/0000-bbbb/cancel", http.StatusConflict},
+		{http.MethodPost, "/tasks/0000-cccc/cancel", http.StatusNotFound},
+		{http.MethodPost, "/tasks/NOT_AN_ID/cancel", http.StatusBadRequest},
+		{http.MethodDelete, "/tasks/0000-aaaa", http.StatusNoContent},
+		{http.MethodDelete, "/tasks/0000-aaaa", http.StatusNotFound},
+		{http.MethodDelete, "/tasks/0000-bbbb", http.StatusNoContent},
+	} {
+		if got := serve(check.method, check.path); got.Code != check.want {
+			t.Fatalf("%s %s = %d, want %d: %s", check.method, check.path, got.Code, check.want, got.Body.String())
+		}
+	}
+	if _, err := repo.Get(context.Background(), "0000-bbbb"); err == nil {
+		t.Fatal("deleted task is still in the repository")
+	}
+}
+
+func TestRerunTaskEndpoint(t *testing.T) {
+	cfg := &config.Config{TempDir: t.TempDir(), OutputDir: t.TempDir()}
+	repo := persistence.NewMemoryTaskRepo()
+	service := app.NewCompileService(cfg, repo, events.NewBroker(), queue.NewInMemoryQueue(4))
+	router := newCompileTestRouter(NewCompileHandler(service, 1024))
+	for _, current := range []*task.Task{
+		{ID: "0000-aaaa", Status: task.TaskDecrypting},
+		{ID: "0000-bbbb", Status: task.TaskCompleted},
+		{ID: "0000-cccc", Status: task.TaskCompleted, RequestedOptions: task.RequestedOptions{Beautify: true}},
+	} {
+		if err := repo.Create(context.Background(), current); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dirs, err := storage.EnsureTaskDirs(cfg.TempDir, "0000-cccc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SaveRetainedPackages(dirs, strings.NewReader("package"), nil); err != nil {
+		t.Fatal(err)
+	}
+	serve := func(path, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	for _, check := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/tasks/0000-aaaa/rerun", "", http.StatusConflict},
+		{"/tasks/0000-bbbb/rerun", "", http.StatusConflict},
+		{"/tasks/0000-dddd/rerun", "", http.StatusNotFound},
+		{"/tasks/NOT_AN_ID/rerun", "", http.StatusBadRequest},
+		{"/tasks/0000-cccc/rerun", `{"decompile":`, http.StatusBadRequest},
+	} {
+		if got := serve(check.path, check.body); got.Code != check.want {
+			t.Fatalf("POST %s = %d, want %d: %s", check.path, got.Code, check.want, got.Body.String())
+		}
+	}
+
+	response := serve("/tasks/0000-cccc/rerun", `{"decompile":true}`)
+	var created CompileResponseDTO
+	if err := json.Unmarshal(response.Body.Bytes(), &created); err != nil || response.Code != http.StatusOK || !created.Success {
+		t.Fatalf("rerun status = %d: %s", response.Code, response.Body.String())
+	}
+	child, err := repo.Get(context.Background(), created.TaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.ParentTaskID != "0000-cccc" || !child.RequestedOptions.Beautify || !child.RequestedOptions.Decompile {
+		t.Fatalf("child task = %+v", child)
+	}
+}
+
 func TestRemoveGuideHTMLDefaultsOn(t *testing.T) {
 	if !removeGuideHTML("") {
 		t.Fatal("omitted field must default to removing guide html")
@@ -186,3 +307,71 @@ func TestRemoveGuideHTMLDefaultsOn(t *testing.T) {
 		t.Fatal("explicit false must keep guide html")
 	}
 }
+
+func TestValidateSubPackagesRejectsForeignFilesAndOversizedTotals(t *testing.T) {
+	main := &multipart.FileHeader{Filename: "__APP__.wxapkg", Size: 600}
+	if err := validateSubPackages(main, []*multipart.FileHeader{{Filename: "packageA.wxapkg", Size: 300}}, 1024); err != nil {
+		t.Fatalf("valid subpackage rejected: %v", err)
+	}
+	if err := validateSubPackages(main, []*multipart.FileHeader{{Filename: "notes.txt", Size: 10}}, 1024); err == nil {
+		t.Fatal("non-wxapkg subpackage must be rejected")
+	}
+	if err := validateSubPackages(main, []*multipart.FileHeader{{Filename: "packageA.wxapkg", Size: 500}}, 1024); err == nil {
+		t.Fatal("main package and subpackages share the upload limit")
+	}
+}
+
+func TestInspectReportsIndexWithoutCreatingTask(t *testing.T) {
+	outputDir := t.TempDir()
+	service := app.NewCompileService(&config.Config{
+		TempDir:   t.TempDir(),
+		OutputDir: outputDir,
+	}, createFailingRepository{err: errors.New("inspect must not create tasks")}, events.NewBroker(), nil)
+	router := newCompileTestRouter(NewCompileHandler(service, 1<<20))
+	plain := testutil.MustBuildWxapkg(map[string]string{
+		"app-config.json":     `{"pages":["pages/home/index"]}`,
+		"pages/home/index.js": strings.Repeat("Page({});", 200),
+	})
+	encrypted, err := dec.EncryptWxapkg(plain, "wx0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(fields map[string]string) map[string]interface{} {
+		t.Helper()
+		response, body, contentType, err := testutil.BuildMultipartCompileRequest("__APP__.wxapkg", encrypted, fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request := httptest.NewRequest(http.MethodPost, "/inspect", body)
+		request.Header.Set("Content-Type", contentType)
+		router.ServeHTTP(response, request)
+		if response.Code != http.StatusOK {
+			t.Fatalf("inspect status = %d: %s", response.Code, response.Body.String())
+		}
+		if strings.Contains(response.Body.String(), "wx0123456789abcdef") {
+			t.Fatal("inspect response echoed the AppID")
+		}
+		var payload struct {
+			Inspection map[string]interface{} `json:"inspection"`
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+			t.Fatal(err)
+		}
+		return payload.Inspection
+	}
+
+	headerOnly := post(nil)
+	if headerOnly["encrypted"] != true || headerOnly["entries"] != nil || headerOnly["indexLength"] != nil {
+		t.Fatalf("encrypted package without AppID must report only the header: %#v", headerOnly)
+	}
+	full := post(map[string]string{"appId": "wx0123456789abcdef"})
+	entries, _ := full["entries"].([]interface{})
+	profile, _ := full["profile"].(map[string]interface{})
+	if len(entries) != 2 || profile["suspectedVariant"] != "wechat4x" {
+		t.Fatalf("decrypted inspection is incomplete: %#v", full)
+	}
+	if files, _ := os.ReadDir(outputDir); len(files) != 0 {
+		t.Fatalf("inspect wrote output files: %v", files)
+	}
+}
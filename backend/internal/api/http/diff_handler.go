@@ -0,0 +1,133 @@
+package httpapi
+
+import (
+	"errors"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keepbuild/seewxapkg/internal/app"
+)
+
+type DiffHandler struct {
+	service        *app.DiffService
+	maxUploadBytes int64
+}
+
+func NewDiffHandler(service *app.DiffService, maxUploadBytes int64) *DiffHandler {
+	return &DiffHandler{service: service, maxUploadBytes: maxUploadBytes}
+}
+
+// StartDiff accepts either two uploads (`base`, `head`) or two task IDs
+// (`baseTaskId`, `headTaskId`) of completed tasks.
+func (h *DiffHandler) StartDiff(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadBytes+1024)
+	defer func() {
+		if c.Request.MultipartForm != nil {
+			if err := c.Request.MultipartForm.RemoveAll(); err != nil {
+				log.Printf("[Diff] multipart temporary-file cleanup failed (%T)", err)
+			}
+		}
+	}()
+
+	cmd, err := parseDiffRequest(c, h.maxUploadBytes)
+	if err != nil {
+		var validation badRequestError
+		if errors.As(err, &validation) {
+			c.JSON(http.StatusBadRequest, DiffResponseDTO{Success: false, Message: err.Error()})
+			return
+		}
+		log.Printf("[Diff] parse upload request failed (%T)", err)
+		c.JSON(http.StatusBadRequest, DiffResponseDTO{Success: false, Message: "上传请求格式错误，请重新选择文件后重试"})
+		return
+	}
+	if cmd.BaseTaskID == "" {
+		if err := h.service.Readiness(); err != nil {
+			log.Printf("[Diff] readiness check failed (%T)", err)
+			c.JSON(http.StatusServiceUnavailable, DiffResponseDTO{Success: false, Message: "服务依赖尚未就绪，请稍后重试"})
+			return
+		}
+	}
+
+	diff, err := h.service.StartDiff(c.Request.Context(), cmd)
+	if err != nil {
+		if errors.Is(err, app.ErrDiffTaskNotFound) {
+			c.JSON(http.StatusNotFound, DiffResponseDTO{Success: false, Message: "对比任务不存在"})
+			return
+		}
+		log.Printf("[Diff] diff creation failed (%T)", err)
+		c.JSON(http.StatusInternalServerError, DiffResponseDTO{Success: false, Message: "对比任务创建失败，请稍后重试"})
+		return
+	}
+	c.JSON(http.StatusOK, DiffResponseDTO{
+		Success:    true,
+		DiffID:     diff.ID,
+		BaseTaskID: diff.BaseTaskID,
+		HeadTaskID: diff.HeadTaskID,
+		Message:    "diff created",
+	})
+}
+
+func (h *DiffHandler) GetDiffReport(c *gin.Context) {
+	diffID := c.Param("diffId")
+	if !taskIDRegex.MatchString(diffID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的对比任务 ID"})
+		return
+	}
+	result, err := h.service.GetDiffReport(c.Request.Context(), diffID)
+	if err != nil {
+		if errors.Is(err, app.ErrDiffNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "对比任务不存在"})
+			return
+		}
+		log.Printf("[Diff] report build failed (%T)", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成差异报告失败"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func parseDiffRequest(c *gin.Context, maxUploadBytes int64) (app.StartDiffCommand, error) {
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		return app.StartDiffCommand{}, err
+	}
+	cmd := app.StartDiffCommand{
+		AppID:           c.PostForm("appId"),
+		Decompile:       c.PostForm("decompile") == "true",
+		RemoveGuideHTML: removeGuideHTML(c.PostForm("removeGuideHtml")),
+		BaseTaskID:      strings.TrimSpace(c.PostForm("baseTaskId")),
+		HeadTaskID:      strings.TrimSpace(c.PostForm("headTaskId")),
+	}
+	if cmd.BaseTaskID != "" || cmd.HeadTaskID != "" {
+		if !taskIDRegex.MatchString(cmd.BaseTaskID) || !taskIDRegex.MatchString(cmd.HeadTaskID) {
+			return cmd, httpError("baseTaskId 和 headTaskId 必须同时提供")
+		}
+		return cmd, nil
+	}
+	if cmd.AppID != "" && !appIDRegex.MatchString(cmd.AppID) {
+		return cmd, httpError("AppID 格式错误，应为 wx 开头加 16 位十六进制字符")
+	}
+	cmd.Base = firstFormFile(c, "base")
+	cmd.Head = firstFormFile(c, "head")
+	if cmd.Base == nil || cmd.Head == nil {
+		return cmd, httpError("需要同时上传 base 和 head 两个 .wxapkg 文件")
+	}
+	for _, file := range []*multipart.FileHeader{cmd.Base, cmd.Head} {
+		if !strings.HasSuffix(strings.ToLower(file.Filename), ".wxapkg") {
+			return cmd, httpError("文件必须是 .wxapkg 格式")
+		}
+	}
+	if cmd.Base.Size+cmd.Head.Size > maxUploadBytes {
+		return cmd, httpError("两个文件合计过大，超过服务限制")
+	}
+	return cmd, nil
+}
+
+func firstFormFile(c *gin.Context, field string) *multipart.FileHeader {
+	if c.Request.MultipartForm == nil || len(c.Request.MultipartForm.File[field]) == 0 {
+		return nil
+	}
+	return c.Request.MultipartForm.File[field][0]
+}
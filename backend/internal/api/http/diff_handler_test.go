@@ -0,0 +1,75 @@
+package httpapi
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keepbuild/seewxapkg/internal/app"
+	"github.com/keepbuild/seewxapkg/internal/config"
+	"github.com/keepbuild/seewxapkg/internal/infra/events"
+	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
+	"github.com/keepbuild/seewxapkg/internal/infra/queue"
+)
+
+func newDiffTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	cfg := &config.Config{TempDir: t.TempDir(), OutputDir: t.TempDir(), MaxUploadSize: 1024}
+	repo := persistence.NewMemoryTaskRepo()
+	compile := app.NewCompileService(cfg, repo, events.NewBroker(), queue.NewInMemoryQueue(8))
+	handler := NewDiffHandler(app.NewDiffService(cfg, repo, compile), 64*1024)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/diff", handler.StartDiff)
+	router.GET("/diff/:diffId", handler.GetDiffReport)
+	return router
+}
+
+func postDiffForm(t *testing.T, router *gin.Engine, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/diff", body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestStartDiffValidatesInputs(t *testing.T) {
+	router := newDiffTestRouter(t)
+
+	response := postDiffForm(t, router, map[string]string{"baseTaskId": "00000000-0000-0000-0000-000000000000"})
+	if response.Code != http.StatusBadRequest || !strings.Contains(response.Body.String(), "headTaskId") {
+		t.Fatalf("a single task ID must be rejected: %d %s", response.Code, response.Body.String())
+	}
+	response = postDiffForm(t, router, map[string]string{
+		"baseTaskId": "00000000-0000-0000-0000-000000000000",
+		"headTaskId": "00000000-0000-0000-0000-000000000001",
+	})
+	if response.Code != http.StatusNotFound {
+		t.Fatalf("unknown tasks status = %d: %s", response.Code, response.Body.String())
+	}
+	response = postDiffForm(t, router, nil)
+	if response.Code != http.StatusBadRequest || !strings.Contains(response.Body.String(), "base") {
+		t.Fatalf("missing uploads status = %d: %s", response.Code, response.Body.String())
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/diff/00000000-0000-0000-0000-000000000000", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("unknown diff status = %d", recorder.Code)
+	}
+}
@@ -3,6 +3,7 @@ package httpapi
 import (
 	"time"
 
+	"github.com/keepbuild/seewxapkg/internal/app"
 	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
 	"github.com/keepbuild/seewxapkg/internal/domain/task"
 	"github.com/keepbuild/seewxapkg/internal/report"
@@ -13,6 +14,9 @@ type CompileRequestDTO struct {
 	Beautify        bool   `form:"beautify"`
 	Decompile       bool   `form:"decompile"`
 	RemoveGuideHTML bool   `form:"removeGuideHtml"`
+	SourcePath      string `form:"sourcePath"`
+	SearchAppID     bool   `form:"searchAppId"`
+	CallbackURL     string `form:"callbackUrl"`
 }
 
 type CompileResponseDTO struct {
@@ -21,6 +25,12 @@ type CompileResponseDTO struct {
 	Message string `json:"message"`
 }
 
+type InspectResponseDTO struct {
+	Success    bool                   `json:"success"`
+	Message    string                 `json:"message,omitempty"`
+	Inspection *app.PackageInspection `json:"inspection,omitempty"`
+}
+
 type StageResponseDTO struct {
 	Stage           string                 `json:"stage"`
 	Success         bool                   `json:"success"`
@@ -40,6 +50,7 @@ type StageResponseDTO struct {
 type TaskResponseDTO struct {
 	ID               string                `json:"id"`
 	Status           string                `json:"status"`
+	ParentTaskID     string                `json:"parentTaskId,omitempty"`
 	Progress         int                   `json:"progress"`
 	CurrentStage     string                `json:"currentStage,omitempty"`
 	CurrentMessage   string                `json:"currentMessage,omitempty"`
@@ -52,6 +63,12 @@ type TaskResponseDTO struct {
 	ErrorCode        *string               `json:"errorCode,omitempty"`
 	ErrorMessage     *string               `json:"errorMessage,omitempty"`
 	ErrorDetail      *string               `json:"errorDetail,omitempty"`
+	CreatedAt        string                `json:"createdAt,omitempty"`
+}
+
+type TaskListResponseDTO struct {
+	Tasks      []TaskResponseDTO `json:"tasks"`
+	NextCursor string            `json:"nextCursor,omitempty"`
 }
 
 func ToTaskResponseDTO(t *task.Task) TaskResponseDTO {
@@ -69,11 +86,23 @@ func ToTaskResponseDTO(t *task.Task) TaskResponseDTO {
 				reports["js"] = t.ArtifactSummary.ReportURL + "?name=js-recovery-report"
 				reports["wxml"] = t.ArtifactSummary.ReportURL + "?name=wxml-recovery-report"
 				reports["wxss"] = t.ArtifactSummary.ReportURL + "?name=wxss-recovery-report"
+				reports["sourcemaps"] = t.ArtifactSummary.ReportURL + "?name=sourcemaps"
 			}
 			if t.RequestedOptions.Beautify {
 				reports["format"] = t.ArtifactSummary.ReportURL + "?name=format-report"
 			}
 			reports["zipManifest"] = t.ArtifactSummary.ReportURL + "?name=zip-manifest"
+			for _, stage := range t.StageResults {
+				switch {
+				case stage.Stage == string(task.TaskAnalyzing) && stage.Success:
+					reports["security"] = t.ArtifactSummary.ReportURL + "?name=security-report"
+				case stage.Stage == task.StageDependencyGraph && stage.Success:
+					reports["dependencyGraph"] = t.ArtifactSummary.ReportURL + "?name=dependency-graph"
+				case stage.Stage == task.StageAPIInventory && stage.Success:
+					reports["apiInventory"] = t.ArtifactSummary.ReportURL + "?name=api-inventory"
+					reports["openapi"] = t.ArtifactSummary.ReportURL + "?name=api-inventory-openapi"
+				}
+			}
 		}
 		if t.ArtifactSummary.DiagnosticsURL != "" {
 			reports["diagnostics"] = t.ArtifactSummary.DiagnosticsURL
@@ -98,9 +127,15 @@ func ToTaskResponseDTO(t *task.Task) TaskResponseDTO {
 		errorDetail = &safeDetail
 	}
 
+	var createdAt string
+	if !t.CreatedAt.IsZero() {
+		createdAt = t.CreatedAt.Format(time.RFC3339Nano)
+	}
+
 	return TaskResponseDTO{
 		ID:               t.ID,
 		Status:           string(t.Status),
+		ParentTaskID:     t.ParentTaskID,
 		Progress:         t.Progress,
 		CurrentStage:     t.CurrentStage,
 		CurrentMessage:   report.SanitizeText(t.CurrentMessage),
@@ -113,6 +148,7 @@ func ToTaskResponseDTO(t *task.Task) TaskResponseDTO {
 		ErrorCode:        t.ErrorCode,
 		ErrorMessage:     errorMessage,
 		ErrorDetail:      errorDetail,
+		CreatedAt:        createdAt,
 	}
 }
 
@@ -134,3 +170,19 @@ func ToStageResponseDTO(stage task.StageResult) StageResponseDTO {
 		Diagnostics:     stage.Diagnostics,
 	}
 }
+
+type DiffResponseDTO struct {
+	Success    bool   `json:"success"`
+	DiffID     string `json:"diffId,omitempty"`
+	BaseTaskID string `json:"baseTaskId,omitempty"`
+	HeadTaskID string `json:"headTaskId,omitempty"`
+	Message    string `json:"message"`
+}
+
+type BatchResponseDTO struct {
+	Success  bool     `json:"success"`
+	BatchID  string   `json:"batchId,omitempty"`
+	TaskIDs  []string `json:"taskIds,omitempty"`
+	Packages int      `json:"packages,omitempty"`
+	Message  string   `json:"message"`
+}
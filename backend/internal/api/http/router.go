@@ -7,14 +7,18 @@ type Router struct {
 	task     *TaskHandler
 	download *DownloadHandler
 	stars    *GitHubStarsHandler
+	batch    *BatchHandler
+	diff     *DiffHandler
 }
 
-func NewRouter(compile *CompileHandler, task *TaskHandler, download *DownloadHandler, stars *GitHubStarsHandler) *Router {
+func NewRouter(compile *CompileHandler, task *TaskHandler, download *DownloadHandler, stars *GitHubStarsHandler, batch *BatchHandler, diff *DiffHandler) *Router {
 	return &Router{
 		compile:  compile,
 		task:     task,
 		download: download,
 		stars:    stars,
+		batch:    batch,
+		diff:     diff,
 	}
 }
 
@@ -25,13 +29,26 @@ func (r *Router) RegisterRoutes(engine *gin.Engine) {
 		api.GET("/health", r.compile.HealthCheck)
 		api.GET("/github/stars", r.stars.Get)
 		api.POST("/compile", r.compile.Compile)
+		api.POST("/inspect", r.compile.Inspect)
+		api.POST("/batch", r.batch.StartBatch)
+		api.GET("/batch/:batchId", r.batch.GetBatchReport)
+		api.POST("/diff", r.diff.StartDiff)
+		api.GET("/diff/:diffId", r.diff.GetDiffReport)
 		api.GET("/events", r.task.StreamTaskEvents)
 		api.GET("/download/:taskId", r.download.DownloadArtifacts)
 		api.HEAD("/download/:taskId", r.download.DownloadArtifacts)
+		api.GET("/tasks", r.task.ListTasks)
 		api.GET("/tasks/:taskId", r.task.GetTask)
+		api.DELETE("/tasks/:taskId", r.compile.DeleteTask)
+		api.POST("/tasks/:taskId/cancel", r.compile.CancelTask)
+		api.POST("/tasks/:taskId/rerun", r.compile.RerunTask)
 		api.GET("/tasks/:taskId/report", r.task.GetTaskReport)
 		api.GET("/tasks/:taskId/diagnostics", r.task.GetTaskDiagnostics)
 		api.GET("/tasks/:taskId/artifacts", r.task.GetTaskArtifacts)
+		api.GET("/tasks/:taskId/files", r.task.GetTaskFile)
+		api.GET("/tasks/:taskId/tree", r.task.GetTaskFileTree)
+		api.GET("/tasks/:taskId/graph", r.task.GetTaskGraph)
+		api.GET("/tasks/:taskId/sourcemap", r.task.GetTaskSourceMap)
 	}
 
 	engine.Static("/assets", "./frontend/dist/assets")
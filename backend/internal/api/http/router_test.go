@@ -11,7 +11,7 @@ import (
 func TestAPIRoutesDisableSensitiveResponseCaching(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	engine := gin.New()
-	router := NewRouter(&CompileHandler{}, &TaskHandler{}, &DownloadHandler{}, &GitHubStarsHandler{})
+	router := NewRouter(&CompileHandler{}, &TaskHandler{}, &DownloadHandler{}, &GitHubStarsHandler{}, &BatchHandler{}, &DiffHandler{})
 	router.RegisterRoutes(engine)
 
 	response := httptest.NewRecorder()
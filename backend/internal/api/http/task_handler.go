@@ -2,8 +2,12 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -41,6 +45,81 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 	c.JSON(http.StatusOK, ToTaskResponseDTO(t))
 }
 
+// ListTasks pages through the repository, newest first. Every task goes
+// through the same DTO sanitization as GET /api/tasks/:taskId. The route
+// answers 404 unless the operator enabled it.
+func (h *TaskHandler) ListTasks(c *gin.Context) {
+	filter, err := parseTaskListFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := h.query.ListTasks(c.Request.Context(), filter)
+	if errors.Is(err, app.ErrTaskListingDisabled) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务列表未开启"})
+		return
+	}
+	if errors.Is(err, task.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分页游标无效，请从第一页重新查询"})
+		return
+	}
+	if err != nil {
+		log.Printf("[Tasks] list failed (%T)", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "任务列表暂不可用"})
+		return
+	}
+	response := TaskListResponseDTO{Tasks: make([]TaskResponseDTO, 0, len(page.Tasks)), NextCursor: page.NextCursor}
+	for _, current := range page.Tasks {
+		response.Tasks = append(response.Tasks, ToTaskResponseDTO(current))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func parseTaskListFilter(c *gin.Context) (task.ListFilter, error) {
+	filter := task.ListFilter{Variant: c.Query("variant"), Cursor: c.Query("cursor")}
+	statuses, err := task.ParseStatuses(c.Query("status"))
+	if err != nil {
+		return filter, httpError("status 包含未知的任务状态")
+	}
+	filter.Statuses = statuses
+	if len(filter.Variant) > 64 {
+		return filter, httpError("variant 过长")
+	}
+	for name, target := range map[string]**int{"minScore": &filter.MinScore, "maxScore": &filter.MaxScore} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		score, err := strconv.Atoi(value)
+		if err != nil || score < 0 || score > 100 {
+			return filter, httpError(name + " 应为 0 到 100 之间的整数")
+		}
+		*target = &score
+	}
+	if filter.MinScore != nil && filter.MaxScore != nil && *filter.MinScore > *filter.MaxScore {
+		return filter, httpError("minScore 不能大于 maxScore")
+	}
+	for name, target := range map[string]*time.Time{"createdAfter": &filter.CreatedAfter, "createdBefore": &filter.CreatedBefore} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, httpError(name + " 应为 RFC 3339 时间，例如 2026-01-02T15:04:05Z")
+		}
+		*target = parsed
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > task.MaxListLimit {
+			return filter, httpError(fmt.Sprintf("limit 应为 1 到 %d 之间的整数", task.MaxListLimit))
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
 func (h *TaskHandler) StreamTaskEvents(c *gin.Context) {
 	taskID := c.Query("taskId")
 	if !taskIDRegex.MatchString(taskID) {
@@ -180,6 +259,8 @@ func taskEventFromTask(t *task.Task) task.TaskEvent {
 		eventType = "partial"
 	} else if t.Status == task.TaskFailed {
 		eventType = "error"
+	} else if t.Status == task.TaskCancelled {
+		eventType = "cancelled"
 	}
 	event := task.TaskEvent{
 		Type:             eventType,
@@ -216,11 +297,11 @@ func eventSignature(status string, percent int, message string) string {
 }
 
 func isTerminalTaskStatus(status task.TaskStatus) bool {
-	return status == task.TaskCompleted || status == task.TaskPartial || status == task.TaskFailed
+	return status.IsTerminal()
 }
 
 func isTerminalTaskEvent(event task.TaskEvent) bool {
-	return event.Type == "complete" || event.Type == "partial" || event.Type == "error" ||
+	return event.Type == "complete" || event.Type == "partial" || event.Type == "error" || event.Type == "cancelled" ||
 		isTerminalTaskStatus(task.TaskStatus(event.Status))
 }
 
@@ -279,3 +360,109 @@ func (h *TaskHandler) GetTaskArtifacts(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, report.SanitizeArtifactSummary(t.ArtifactSummary))
 }
+
+// GetTaskFile serves one recovered file from the task's source tree. The
+// response is always text/plain or application/octet-stream so recovered
+// HTML or WXML is never rendered. Responses stay no-store like the rest of
+// the API; clients that keep their own copy revalidate with If-None-Match.
+func (h *TaskHandler) GetTaskFile(c *gin.Context) {
+	taskID := c.Param("taskId")
+	if !taskIDRegex.MatchString(taskID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务 ID"})
+		return
+	}
+	name := c.Query("path")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少文件路径"})
+		return
+	}
+
+	file, err := h.query.GetTaskFile(c.Request.Context(), taskID, name)
+	switch {
+	case errors.Is(err, app.ErrTaskFileTooLarge):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("文件超过 %d MB，请下载 ZIP 查看", app.MaxTaskFileBytes/(1024*1024))})
+		return
+	case errors.Is(err, app.ErrTaskFileNotFound), errors.Is(err, app.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	case err != nil:
+		log.Printf("[Tasks] read task file failed (%T)", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取文件失败，请稍后重试"})
+		return
+	}
+	c.Header("ETag", file.ETag)
+	if match := c.GetHeader("If-None-Match"); match != "" && strings.Contains(match, file.ETag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	contentType := "application/octet-stream"
+	if strings.HasPrefix(http.DetectContentType(file.Content), "text/") {
+		contentType = "text/plain; charset=utf-8"
+	}
+	c.Data(http.StatusOK, contentType, file.Content)
+}
+
+// GetTaskSourceMap serves the Source Map v3 file tracing one recovered file
+// back to the packed entry it was split from.
+func (h *TaskHandler) GetTaskSourceMap(c *gin.Context) {
+	taskID := c.Param("taskId")
+	if !taskIDRegex.MatchString(taskID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务 ID"})
+		return
+	}
+	name := c.Query("path")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少文件路径"})
+		return
+	}
+
+	data, err := h.query.GetTaskSourceMap(c.Request.Context(), taskID, name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "source map 不存在"})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// GetTaskFileTree lists the recovered files with their recovery source and
+// diagnostics for the in-browser code viewer.
+func (h *TaskHandler) GetTaskFileTree(c *gin.Context) {
+	taskID := c.Param("taskId")
+	if !taskIDRegex.MatchString(taskID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务 ID"})
+		return
+	}
+
+	files, err := h.query.GetTaskFileTree(c.Request.Context(), taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务产物不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"files": files, "maxFileBytes": app.MaxTaskFileBytes})
+}
+
+// GetTaskGraph serves the dependency graph as JSON, or for Graphviz with
+// format=dot.
+func (h *TaskHandler) GetTaskGraph(c *gin.Context) {
+	taskID := c.Param("taskId")
+	if !taskIDRegex.MatchString(taskID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务 ID"})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "dot" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 仅支持 json 或 dot"})
+		return
+	}
+
+	graph, err := h.query.GetDependencyGraph(c.Request.Context(), taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "依赖图不存在"})
+		return
+	}
+	if format == "dot" {
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(graph.DOT()))
+		return
+	}
+	c.JSON(http.StatusOK, graph)
+}
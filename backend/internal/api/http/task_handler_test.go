@@ -2,9 +2,13 @@ package httpapi
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -131,6 +135,72 @@ func TestStreamTaskEventsPollsFileWorkerTerminalStateAndRemovesStream(t *testing
 	assertBrokerStreamRemoved(t, broker, taskID)
 }
 
+func TestListTasksFiltersPagesAndSanitizes(t *testing.T) {
+	repo := persistence.NewMemoryTaskRepo()
+	now := time.Now()
+	failure := "解密失败：/data/tasks/private/input.wxapkg"
+	for i, status := range []task.TaskStatus{task.TaskCompleted, task.TaskFailed, task.TaskFailed} {
+		current := &task.Task{
+			ID:           fmt.Sprintf("3333333%d-3333-4333-8333-333333333333", i),
+			Status:       status,
+			ErrorMessage: &failure,
+			CreatedAt:    now.Add(time.Duration(i) * time.Minute),
+		}
+		if err := repo.Create(context.Background(), current); err != nil {
+			t.Fatal(err)
+		}
+	}
+	router := newTaskHandlerTestRouter(app.NewTaskQueryService(&config.Config{TaskListingEnabled: true}, repo), events.NewBroker())
+
+	var page TaskListResponseDTO
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/tasks?status=failed&limit=1", nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("ListTasks status = %d: %s", response.Code, response.Body.String())
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tasks) != 1 || page.Tasks[0].ID != "33333332-3333-4333-8333-333333333333" || page.NextCursor == "" {
+		t.Fatalf("first page = %+v", page)
+	}
+	if strings.Contains(response.Body.String(), "/data/tasks") {
+		t.Fatalf("listing leaked a host path: %s", response.Body.String())
+	}
+
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/tasks?status=failed&limit=1&cursor="+page.NextCursor, nil))
+	page = TaskListResponseDTO{}
+	if err := json.Unmarshal(response.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tasks) != 1 || page.Tasks[0].ID != "33333331-3333-4333-8333-333333333333" || page.NextCursor != "" {
+		t.Fatalf("second page = %+v", page)
+	}
+
+	for _, query := range []string{"status=done", "minScore=101", "minScore=80&maxScore=20", "createdAfter=yesterday", "limit=0", "cursor=%21"} {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/tasks?"+query, nil))
+		if response.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400", query, response.Code)
+		}
+	}
+}
+
+func TestListTasksIsNotFoundUnlessEnabled(t *testing.T) {
+	repo := persistence.NewMemoryTaskRepo()
+	if err := repo.Create(context.Background(), &task.Task{ID: "44444444-4444-4444-8444-444444444444", Status: task.TaskCompleted, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	router := newTaskHandlerTestRouter(app.NewTaskQueryService(&config.Config{}, repo), events.NewBroker())
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	if response.Code != http.StatusNotFound || strings.Contains(response.Body.String(), "44444444") {
+		t.Fatalf("disabled listing = %d: %s", response.Code, response.Body.String())
+	}
+}
+
 type firstGetSignalingRepository struct {
 	task.Repository
 	firstGet chan struct{}
@@ -143,12 +213,156 @@ func (r *firstGetSignalingRepository) Get(ctx context.Context, taskID string) (*
 	return current, err
 }
 
+func TestTaskFileEndpointsServeSourceWithETag(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := persistence.NewMemoryTaskRepo()
+	taskID := "33333333-3333-4333-8333-333333333333"
+	current := &task.Task{
+		ID:              taskID,
+		Status:          task.TaskCompleted,
+		ArtifactSummary: &task.ArtifactSummary{Files: []task.ArtifactFile{{Path: "src/pages/index/index.wxml", Kind: "wxml", Source: "native"}}},
+	}
+	if err := repo.Create(context.Background(), current); err != nil {
+		t.Fatal(err)
+	}
+	pageDir := filepath.Join(tempDir, taskID, "result", "src", "pages", "index")
+	if err := os.MkdirAll(pageDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pageDir, "index.wxml"), []byte("<script>alert(1)</script>"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	router := newTaskHandlerTestRouter(app.NewTaskQueryService(&config.Config{TempDir: tempDir}, repo), events.NewBroker())
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			request.Header.Set("If-None-Match", etag)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	response := get("/tasks/"+taskID+"/files?path=pages/index/index.wxml", "")
+	if response.Code != http.StatusOK || response.Body.String() != "<script>alert(1)</script>" {
+		t.Fatalf("file status = %d: %s", response.Code, response.Body.String())
+	}
+	if got := response.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Fatalf("Content-Type = %q, recovered markup must not render", got)
+	}
+	etag := response.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("file response carries no ETag")
+	}
+	if revalidated := get("/tasks/"+taskID+"/files?path=pages/index/index.wxml", etag); revalidated.Code != http.StatusNotModified || revalidated.Body.Len() != 0 {
+		t.Fatalf("If-None-Match status = %d", revalidated.Code)
+	}
+	for path, want := range map[string]int{
+		"/tasks/" + taskID + "/files?path=../../input/input.wxapkg":       http.StatusNotFound,
+		"/tasks/" + taskID + "/files":                                     http.StatusBadRequest,
+		"/tasks/NOT_AN_ID/files?path=app.json":                            http.StatusBadRequest,
+		"/tasks/44444444-4444-4444-8444-444444444444/files?path=app.json": http.StatusNotFound,
+	} {
+		if got := get(path, ""); got.Code != want {
+			t.Fatalf("GET %s = %d, want %d", path, got.Code, want)
+		}
+	}
+
+	response = get("/tasks/"+taskID+"/tree", "")
+	var tree struct {
+		Files []app.TaskFileEntry `json:"files"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &tree); err != nil || response.Code != http.StatusOK {
+		t.Fatalf("tree status = %d: %s", response.Code, response.Body.String())
+	}
+	if len(tree.Files) != 1 || tree.Files[0].Path != "pages/index/index.wxml" || tree.Files[0].Source != "native" {
+		t.Fatalf("tree = %+v", tree.Files)
+	}
+}
+
+func TestTaskGraphEndpointServesJSONAndDOT(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := persistence.NewMemoryTaskRepo()
+	taskID := "44444444-4444-4444-8444-444444444444"
+	if err := repo.Create(context.Background(), &task.Task{ID: taskID, Status: task.TaskCompleted, ArtifactSummary: &task.ArtifactSummary{}}); err != nil {
+		t.Fatal(err)
+	}
+	reportsDir := filepath.Join(tempDir, taskID, "result", "reports")
+	if err := os.MkdirAll(reportsDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	graph := `{"nodes":[{"id":"app","kind":"app"},{"id":"pages/index/index","kind":"page"},{"id":"components/card/card","kind":"component","orphan":true}],` +
+		`"edges":[{"from":"app","to":"pages/index/index","kind":"require"}],"orphanComponents":["components/card/card"],"diagnostics":[]}`
+	if err := os.WriteFile(filepath.Join(reportsDir, "dependency-graph.json"), []byte(graph), 0600); err != nil {
+		t.Fatal(err)
+	}
+	router := newTaskHandlerTestRouter(app.NewTaskQueryService(&config.Config{TempDir: tempDir}, repo), events.NewBroker())
+	get := func(path string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+		return response
+	}
+
+	response := get("/tasks/" + taskID + "/graph")
+	var decoded struct {
+		Nodes            []map[string]interface{} `json:"nodes"`
+		OrphanComponents []string                 `json:"orphanComponents"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &decoded); err != nil || response.Code != http.StatusOK {
+		t.Fatalf("json graph status = %d: %s", response.Code, response.Body.String())
+	}
+	if len(decoded.Nodes) != 3 || len(decoded.OrphanComponents) != 1 {
+		t.Fatalf("json graph = %s", response.Body.String())
+	}
+
+	response = get("/tasks/" + taskID + "/graph?format=dot")
+	if response.Code != http.StatusOK || !strings.HasPrefix(response.Header().Get("Content-Type"), "text/vnd.graphviz") ||
+		!strings.Contains(response.Body.String(), `"app" -> "pages/index/index"`) {
+		t.Fatalf("dot graph status = %d (%s): %s", response.Code, response.Header().Get("Content-Type"), response.Body.String())
+	}
+	for path, want := range map[string]int{
+		"/tasks/" + taskID + "/graph?format=svg":                       http.StatusBadRequest,
+		"/tasks/55555555-5555-4555-8555-555555555555/graph?format=dot": http.StatusNotFound,
+	} {
+		if got := get(path); got.Code != want {
+			t.Fatalf("GET %s = %d, want %d", path, got.Code, want)
+		}
+	}
+}
+
+func TestTaskFileEndpointRejectsFilesTooLargeToView(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := persistence.NewMemoryTaskRepo()
+	taskID := "33333333-3333-4333-8333-333333333333"
+	if err := repo.Create(context.Background(), &task.Task{ID: taskID, Status: task.TaskCompleted, ArtifactSummary: &task.ArtifactSummary{}}); err != nil {
+		t.Fatal(err)
+	}
+	sourceDir := filepath.Join(tempDir, taskID, "result", "src")
+	if err := os.MkdirAll(sourceDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "big.js"), []byte(strings.Repeat("x", app.MaxTaskFileBytes+1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	router := newTaskHandlerTestRouter(app.NewTaskQueryService(&config.Config{TempDir: tempDir}, repo), events.NewBroker())
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/tasks/"+taskID+"/files?path=big.js", nil))
+	if response.Code != http.StatusUnprocessableEntity || !strings.Contains(response.Body.String(), "ZIP") {
+		t.Fatalf("large file status = %d: %s", response.Code, response.Body.String())
+	}
+}
+
 func newTaskHandlerTestRouter(query *app.TaskQueryService, broker *events.Broker) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	handler := NewTaskHandler(query, broker)
 	router := gin.New()
+	router.GET("/tasks", handler.ListTasks)
 	router.GET("/tasks/:taskId", handler.GetTask)
 	router.GET("/events", handler.StreamTaskEvents)
+	router.GET("/tasks/:taskId/files", handler.GetTaskFile)
+	router.GET("/tasks/:taskId/tree", handler.GetTaskFileTree)
+	router.GET("/tasks/:taskId/graph", handler.GetTaskGraph)
 	return router
 }
 
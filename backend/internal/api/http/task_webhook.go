@@ -0,0 +1,29 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/webhook"
+)
+
+const callbackURLNotAllowedMessage = "回调地址未在服务允许列表中"
+
+// TaskWebhook posts the same sanitized task view GET /api/tasks/:taskId
+// returns to a finished task's callback URL.
+type TaskWebhook struct {
+	sender *webhook.Sender
+}
+
+func NewTaskWebhook(secret string) *TaskWebhook {
+	return &TaskWebhook{sender: webhook.NewSender(secret)}
+}
+
+func (w *TaskWebhook) NotifyTaskFinished(ctx context.Context, callbackURL string, t *task.Task) error {
+	body, err := json.Marshal(ToTaskResponseDTO(t))
+	if err != nil {
+		return err
+	}
+	return w.sender.Deliver(ctx, callbackURL, "task."+string(t.Status), body)
+}
@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/keepbuild/seewxapkg/internal/app"
+	"github.com/keepbuild/seewxapkg/internal/config"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/events"
+	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
+	"github.com/keepbuild/seewxapkg/internal/infra/webhook"
+)
+
+func TestTaskWebhookPostsSignedSanitizedTask(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer receiver.Close()
+
+	message := "解密失败 /tmp/seewxapkg/0000-aaaa/input/input.wxapkg"
+	failed := &task.Task{ID: "0000-aaaa", Status: task.TaskFailed, ErrorMessage: &message}
+	if err := NewTaskWebhook("secret").NotifyTaskFinished(context.Background(), receiver.URL, failed); err != nil {
+		t.Fatal(err)
+	}
+	request, body := <-received, <-bodies
+	if got := request.Header.Get(webhook.SignatureHeader); got != webhook.Sign([]byte("secret"), request.Header.Get(webhook.TimestampHeader), body) {
+		t.Fatalf("signature header = %q", got)
+	}
+	if got := request.Header.Get(webhook.EventHeader); got != "task.failed" {
+		t.Fatalf("event header = %q", got)
+	}
+	var payload TaskResponseDTO
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ID != failed.ID || payload.Status != "failed" || payload.ErrorMessage == nil || strings.Contains(*payload.ErrorMessage, "/tmp/seewxapkg") {
+		t.Fatalf("payload is not the sanitized task view: %s", body)
+	}
+}
+
+func TestCompileRejectsCallbackOutsideAllowList(t *testing.T) {
+	service := app.NewCompileService(&config.Config{
+		TempDir:             t.TempDir(),
+		OutputDir:           t.TempDir(),
+		WebhookSecret:       "secret",
+		WebhookAllowedHosts: []string{"ci.example"},
+	}, persistence.NewMemoryTaskRepo(), events.NewBroker(), nil)
+	router := newCompileTestRouter(NewCompileHandler(service, 1024))
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "__APP__.wxapkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write([]byte("package"))
+	_ = writer.WriteField("callbackUrl", "https://attacker.example/hook")
+	_ = writer.Close()
+	request := httptest.NewRequest(http.MethodPost, "/compile", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	if response.Code != http.StatusBadRequest || !strings.Contains(response.Body.String(), callbackURLNotAllowedMessage) {
+		t.Fatalf("compile with foreign callback = %d: %s", response.Code, response.Body.String())
+	}
+}
@@ -0,0 +1,190 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/keepbuild/seewxapkg/internal/config"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	dec "github.com/keepbuild/seewxapkg/internal/pipeline/decrypt"
+	"github.com/keepbuild/seewxapkg/internal/report"
+)
+
+var ErrBatchNotFound = errors.New("batch not found")
+
+type StartBatchCommand struct {
+	// AppID is shared by the packages in the batch whose path names no
+	// AppID, so a directory of versions of one encrypted mini program needs
+	// it only once; an Applet/wx…/ directory keeps its own.
+	AppID           string
+	Beautify        bool
+	Decompile       bool
+	RemoveGuideHTML bool
+	// SearchAppID lets packages with neither an AppID nor one in their
+	// archive path try the configured candidate list.
+	SearchAppID bool
+	File        *multipart.FileHeader
+	// Path is a local archive or directory, used instead of File by the CLI.
+	Path string
+}
+
+type BatchService struct {
+	cfg     *config.Config
+	repo    task.Repository
+	compile *CompileService
+}
+
+func NewBatchService(cfg *config.Config, repo task.Repository, compile *CompileService) *BatchService {
+	return &BatchService{cfg: cfg, repo: repo, compile: compile}
+}
+
+func (s *BatchService) Readiness() error {
+	_, err := s.compile.Readiness()
+	return err
+}
+
+// StartBatch stages every package from the batch input and starts one compile
+// task per package through CompileService.StartTask, so tasks go through the
+// configured JobQueue exactly like single uploads. A package that cannot be
+// started is recorded with an error code instead of aborting its siblings.
+func (s *BatchService) StartBatch(ctx context.Context, cmd StartBatchCommand) (*task.Batch, error) {
+	batch := &task.Batch{
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now(),
+		Options: task.RequestedOptions{
+			Beautify:        cmd.Beautify,
+			Decompile:       cmd.Decompile,
+			RemoveGuideHTML: cmd.RemoveGuideHTML,
+		},
+	}
+	// The staging directory uses the batch UUID so the retention janitor can
+	// reclaim it if the process dies before the deferred cleanup runs.
+	stagingDir := filepath.Join(s.cfg.TempDir, batch.ID)
+	if err := os.MkdirAll(stagingDir, 0700); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.RemoveAll(stagingDir); err != nil {
+			log.Printf("[Batch] staging cleanup failed (%T)", err)
+		}
+	}()
+
+	packages, err := s.collectPackages(cmd, stagingDir)
+	if err != nil {
+		return nil, err
+	}
+	for index, item := range packages {
+		entry := task.BatchItem{Index: index, Name: maskAppIDs(item.Name)}
+		// Leaving AppID empty lets CreateTask take the item's own AppID from
+		// its path, so a batch can mix several mini programs.
+		appID := cmd.AppID
+		if dec.AppIDFromPath(item.Name) != "" {
+			appID = ""
+		}
+		started, err := s.compile.StartTask(ctx, StartCompileCommand{
+			AppID:           appID,
+			Beautify:        cmd.Beautify,
+			Decompile:       cmd.Decompile,
+			RemoveGuideHTML: cmd.RemoveGuideHTML,
+			InputPath:       item.Path,
+			SourcePath:      item.Name,
+			SearchAppID:     cmd.SearchAppID,
+		})
+		if err != nil {
+			log.Printf("[Batch] task creation failed (%T)", err)
+			entry.ErrorCode = "task_create_failed"
+		} else {
+			entry.TaskID = started.ID
+		}
+		batch.Items = append(batch.Items, entry)
+	}
+	if err := os.MkdirAll(filepath.Dir(storage.BatchRecordPath(s.cfg.TempDir, batch.ID)), 0700); err != nil {
+		return nil, err
+	}
+	if err := storage.WriteJSON(storage.BatchRecordPath(s.cfg.TempDir, batch.ID), batch); err != nil {
+		return nil, fmt.Errorf("persist batch: %w", err)
+	}
+	return batch, nil
+}
+
+func (s *BatchService) collectPackages(cmd StartBatchCommand, stagingDir string) ([]storage.BatchPackage, error) {
+	limits := storage.BatchLimits{MaxPackages: s.cfg.MaxBatchPackages, MaxPackageSize: s.cfg.MaxUploadSize}
+	if cmd.File != nil {
+		archivePath, err := storage.StageBatchUpload(stagingDir, cmd.File)
+		if err != nil {
+			return nil, err
+		}
+		return storage.ExtractBatchArchive(archivePath, stagingDir, limits)
+	}
+	if cmd.Path == "" {
+		return nil, fmt.Errorf("batch input is required")
+	}
+	info, err := os.Stat(cmd.Path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return storage.CollectBatchPackages(cmd.Path, limits)
+	}
+	return storage.ExtractBatchArchive(cmd.Path, stagingDir, limits)
+}
+
+func (s *BatchService) GetBatch(batchID string) (*task.Batch, error) {
+	if !isCanonicalUUID(batchID) {
+		return nil, ErrBatchNotFound
+	}
+	data, err := os.ReadFile(storage.BatchRecordPath(s.cfg.TempDir, batchID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrBatchNotFound
+		}
+		return nil, err
+	}
+	var batch task.Batch
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// GetBatchReport combines the batch record with the live task states.
+func (s *BatchService) GetBatchReport(ctx context.Context, batchID string) (*report.BatchReport, error) {
+	batch, err := s.GetBatch(batchID)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make(map[string]*task.Task, len(batch.Items))
+	for _, item := range batch.Items {
+		if item.TaskID == "" {
+			continue
+		}
+		current, err := s.repo.Get(ctx, item.TaskID)
+		if err != nil {
+			continue
+		}
+		tasks[item.TaskID] = current
+	}
+	return report.BuildBatchReport(batch, tasks), nil
+}
+
+// maskAppIDs keeps batch labels distinguishable without persisting the
+// credential-like AppID that WeChat embeds in package directories.
+func maskAppIDs(name string) string {
+	return dec.AppIDPattern.ReplaceAllStringFunc(name, func(appID string) string {
+		return "wx************" + appID[len(appID)-4:]
+	})
+}
+
+func isCanonicalUUID(value string) bool {
+	parsed, err := uuid.Parse(value)
+	return err == nil && parsed.String() == value
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/keepbuild/seewxapkg/internal/config"
+	"github.com/keepbuild/seewxapkg/internal/infra/events"
+	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+)
+
+type recordingQueue struct {
+	taskIDs []string
+}
+
+func (q *recordingQueue) Enqueue(_ context.Context, taskID string) error {
+	q.taskIDs = append(q.taskIDs, taskID)
+	return nil
+}
+
+func (q *recordingQueue) StartWorkers(context.Context, int, func(context.Context, string) error) {}
+
+func (q *recordingQueue) Wait() {}
+
+func (q *recordingQueue) Remove(context.Context, string) error { return nil }
+
+func TestStartBatchQueuesEveryPackageWithSharedAppID(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{TempDir: tempDir, OutputDir: t.TempDir(), MaxUploadSize: 1024, MaxBatchPackages: 10}
+	repo := persistence.NewMemoryTaskRepo()
+	jobQueue := &recordingQueue{}
+	service := NewBatchService(cfg, repo, NewCompileService(cfg, repo, events.NewBroker(), jobQueue))
+	input := t.TempDir()
+	for _, relative := range []string{"wx0123456789abcdef/1/__APP__.wxapkg", "wx0123456789abcdef/2/__APP__.wxapkg"} {
+		path := filepath.Join(input, filepath.FromSlash(relative))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const appID = "wx0123456789abcdef"
+
+	batch, err := service.StartBatch(context.Background(), StartBatchCommand{AppID: appID, Path: input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Items) != 2 || len(jobQueue.taskIDs) != 2 {
+		t.Fatalf("items=%d queued=%d", len(batch.Items), len(jobQueue.taskIDs))
+	}
+	for index, item := range batch.Items {
+		if item.TaskID != jobQueue.taskIDs[index] {
+			t.Fatalf("item %d task %q was not queued in order", index, item.TaskID)
+		}
+		if strings.Contains(item.Name, appID) || !strings.Contains(item.Name, "cdef") {
+			t.Fatalf("batch label must mask the AppID: %q", item.Name)
+		}
+		dirs, err := storage.EnsureTaskDirs(tempDir, item.TaskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret, err := storage.ReadAppIDSecret(dirs); err != nil || secret != appID {
+			t.Fatalf("task %d AppID secret = %q err=%v", index, secret, err)
+		}
+	}
+	record, err := os.ReadFile(storage.BatchRecordPath(tempDir, batch.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(record), appID) {
+		t.Fatal("batch record persisted the AppID")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, batch.ID)); !os.IsNotExist(err) {
+		t.Fatalf("batch staging directory was not removed: %v", err)
+	}
+
+	result, err := service.GetBatchReport(context.Background(), batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Done || result.Summary["queued"] != 2 {
+		t.Fatalf("unexpected live report: %#v", result)
+	}
+}
+
+func TestStartBatchPrefersEachPackagesPathAppID(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{TempDir: tempDir, OutputDir: t.TempDir(), MaxUploadSize: 1024, MaxBatchPackages: 10}
+	repo := persistence.NewMemoryTaskRepo()
+	jobQueue := &recordingQueue{}
+	service := NewBatchService(cfg, repo, NewCompileService(cfg, repo, events.NewBroker(), jobQueue))
+	input := t.TempDir()
+	relatives := []string{"Applet/wx1111111111111111/1/__APP__.wxapkg", "Applet/wx2222222222222222/1/__APP__.wxapkg", "loose/__APP__.wxapkg"}
+	for _, relative := range relatives {
+		path := filepath.Join(input, filepath.FromSlash(relative))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	batch, err := service.StartBatch(context.Background(), StartBatchCommand{AppID: "wx0123456789abcdef", Path: input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"wx1111111111111111", "wx2222222222222222", "wx0123456789abcdef"}
+	if len(batch.Items) != len(want) {
+		t.Fatalf("items = %#v", batch.Items)
+	}
+	for index, item := range batch.Items {
+		dirs, err := storage.EnsureTaskDirs(tempDir, item.TaskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret, err := storage.ReadAppIDSecret(dirs); err != nil || secret != want[index] {
+			t.Fatalf("item %d (%s) AppID = %q err=%v, want %s", index, item.Name, secret, err, want[index])
+		}
+	}
+}
+
+func TestGetBatchRejectsUnknownAndNonCanonicalIDs(t *testing.T) {
+	service := NewBatchService(&config.Config{TempDir: t.TempDir()}, persistence.NewMemoryTaskRepo(), nil)
+	for _, id := range []string{"../task-state/x", "not-a-batch", "00000000-0000-0000-0000-000000000000"} {
+		if _, err := service.GetBatch(id); !errors.Is(err, ErrBatchNotFound) {
+			t.Fatalf("GetBatch(%q) err = %v, want ErrBatchNotFound", id, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"mime/multipart"
 	"os"
@@ -20,6 +21,8 @@ import (
 	"github.com/keepbuild/seewxapkg/internal/infra/process"
 	"github.com/keepbuild/seewxapkg/internal/infra/queue"
 	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	"github.com/keepbuild/seewxapkg/internal/model"
+	"github.com/keepbuild/seewxapkg/internal/pipeline/analyze"
 	"github.com/keepbuild/seewxapkg/internal/pipeline/classifier"
 	dec "github.com/keepbuild/seewxapkg/internal/pipeline/decrypt"
 	"github.com/keepbuild/seewxapkg/internal/pipeline/normalize"
@@ -29,12 +32,35 @@ import (
 	legacyservice "github.com/keepbuild/seewxapkg/internal/service"
 )
 
+// ErrAppIDSearchUnavailable rejects AppID search when the server has no
+// candidate list configured.
+var ErrAppIDSearchUnavailable = errors.New("appID search requires APPID_CANDIDATES_FILE")
+
 type StartCompileCommand struct {
 	AppID           string
 	Beautify        bool
 	Decompile       bool
 	RemoveGuideHTML bool
 	File            *multipart.FileHeader
+	// InputPath is used instead of File by local entry points (CLI, batch
+	// extraction); the package is copied into the task workspace either way.
+	InputPath string
+	// SourcePath is the package's original path, such as
+	// Applet/wx0123456789abcdef/12/__APP__.wxapkg. Without an AppID, the
+	// AppID is taken from it, or from InputPath, when it names one.
+	SourcePath string
+	// SearchAppID opts into trying the configured candidate AppIDs when the
+	// package is encrypted and no AppID is known.
+	SearchAppID bool
+	// SubPackages (or SubPackagePaths for local entry points) are unpacked
+	// after the main package and merged into the same source tree.
+	SubPackages     []*multipart.FileHeader
+	SubPackagePaths []string
+	// ParentTaskID links a rerun to the task whose package it reprocesses.
+	ParentTaskID string
+	// CallbackURL receives a signed POST when the task finishes. Its host
+	// must be in WEBHOOK_ALLOWED_HOSTS.
+	CallbackURL string
 }
 
 type CompileService struct {
@@ -43,6 +69,9 @@ type CompileService struct {
 	broker     *events.Broker
 	queue      queue.JobQueue
 	nodeRunner *process.NodeRunner
+	cache      *storage.ResultCache
+	running    *runningTasks
+	notifier   TaskNotifier
 }
 
 func NewCompileService(cfg *config.Config, repo task.Repository, broker *events.Broker, jobQueue queue.JobQueue) *CompileService {
@@ -56,6 +85,8 @@ func NewCompileService(cfg *config.Config, repo task.Repository, broker *events.
 			Timeout:  time.Duration(cfg.NodeExecTimeoutSeconds) * time.Second,
 			MemoryMB: cfg.NodeExecMemoryMB,
 		},
+		cache:   newResultCache(cfg),
+		running: newRunningTasks(),
 	}
 }
 
@@ -131,6 +162,51 @@ func (s *CompileService) Readiness() (map[string]interface{}, error) {
 }
 
 func (s *CompileService) StartTask(ctx context.Context, cmd StartCompileCommand) (*task.Task, error) {
+	t, err := s.CreateTask(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.queue != nil {
+		if err := s.queue.Enqueue(ctx, t.ID); err != nil {
+			return nil, s.markFailed(ctx, t, "queue_enqueue_failed", "任务暂时无法进入处理队列", err)
+		}
+	} else {
+		go func() {
+			if err := s.RunTask(context.Background(), t.ID); err != nil {
+				log.Printf("[Task] pipeline failed: %v", err)
+			}
+		}()
+	}
+
+	return t, nil
+}
+
+// CreateTask stages the input and AppID and persists a queued task without
+// scheduling it. StartTask hands the task to the queue; the offline CLI calls
+// RunTask itself so it can follow progress synchronously.
+func (s *CompileService) CreateTask(ctx context.Context, cmd StartCompileCommand) (*task.Task, error) {
+	if cmd.File == nil && cmd.InputPath == "" {
+		return nil, fmt.Errorf("compile input is required")
+	}
+	if len(cmd.SubPackages)+len(cmd.SubPackagePaths) > MaxSubPackages {
+		return nil, fmt.Errorf("at most %d subpackages are supported", MaxSubPackages)
+	}
+	if cmd.SearchAppID && s.cfg.AppIDCandidatesFile == "" {
+		return nil, ErrAppIDSearchUnavailable
+	}
+	if err := s.validateCallbackURL(cmd.CallbackURL); err != nil {
+		return nil, err
+	}
+	appID, fromPath := cmd.AppID, false
+	if appID == "" {
+		for _, candidate := range []string{cmd.SourcePath, cmd.InputPath} {
+			if appID = dec.AppIDFromPath(candidate); appID != "" {
+				fromPath = true
+				break
+			}
+		}
+	}
 	createdAt := time.Now()
 	t := &task.Task{
 		ID:     uuid.New().String(),
@@ -139,16 +215,24 @@ func (s *CompileService) StartTask(ctx context.Context, cmd StartCompileCommand)
 			Beautify:        cmd.Beautify,
 			Decompile:       cmd.Decompile,
 			RemoveGuideHTML: cmd.RemoveGuideHTML,
+			AppIDSearch:     cmd.SearchAppID,
+			AppIDFromPath:   fromPath,
 		},
-		CreatedAt: createdAt,
-		UpdatedAt: createdAt,
+		ParentTaskID: cmd.ParentTaskID,
+		CreatedAt:    createdAt,
+		UpdatedAt:    createdAt,
 	}
 
 	dirs, err := storage.EnsureTaskDirs(s.cfg.TempDir, t.ID)
 	if err != nil {
 		return nil, err
 	}
-	if _, err := storage.SaveUploadedFile(dirs, cmd.File); err != nil {
+	if cmd.File != nil {
+		_, err = storage.SaveUploadedFile(dirs, cmd.File)
+	} else {
+		_, err = storage.SaveLocalFile(dirs, cmd.InputPath)
+	}
+	if err != nil {
 		return nil, err
 	}
 	keepSecret := false
@@ -158,9 +242,24 @@ func (s *CompileService) StartTask(ctx context.Context, cmd StartCompileCommand)
 			_ = storage.DeleteTaskInput(dirs)
 		}
 	}()
-	if err := storage.SaveAppIDSecret(dirs, cmd.AppID); err != nil {
+	for index, file := range cmd.SubPackages {
+		if _, err := storage.SaveUploadedSubPackage(dirs, index, file); err != nil {
+			return nil, err
+		}
+	}
+	for index, path := range cmd.SubPackagePaths {
+		if _, err := storage.SaveLocalSubPackage(dirs, len(cmd.SubPackages)+index, path); err != nil {
+			return nil, err
+		}
+	}
+	if err := storage.SaveAppIDSecret(dirs, appID); err != nil {
 		return nil, err
 	}
+	if cmd.CallbackURL != "" {
+		if err := storage.SaveCallbackURL(dirs, cmd.CallbackURL); err != nil {
+			return nil, err
+		}
+	}
 
 	s.broker.Create(t.ID)
 	if err := s.repo.Create(ctx, t); err != nil {
@@ -173,18 +272,6 @@ func (s *CompileService) StartTask(ctx context.Context, cmd StartCompileCommand)
 		Percent: 0,
 		Message: "任务已创建，等待处理",
 	})
-
-	if s.queue != nil {
-		if err := s.queue.Enqueue(ctx, t.ID); err != nil {
-			return nil, s.markFailed(ctx, t, "queue_enqueue_failed", "任务暂时无法进入处理队列", err)
-		}
-	} else {
-		go func() {
-			if err := s.RunTask(context.Background(), t.ID); err != nil {
-				log.Printf("[Task] pipeline failed: %v", err)
-			}
-		}()
-	}
 	keepSecret = true
 
 	return t.Clone(), nil
@@ -225,9 +312,17 @@ func (s *CompileService) RunTask(ctx context.Context, taskID string) (runErr err
 	var err error
 	t, err = s.repo.Get(ctx, taskID)
 	if err != nil {
+		if !storage.TaskWorkspaceExists(storage.TaskDirsFor(s.cfg.TempDir, taskID)) {
+			// Deleted while its job was waiting; there is nothing to run.
+			return nil
+		}
 		return err
 	}
-	if t.Status == task.TaskCompleted || t.Status == task.TaskPartial || t.Status == task.TaskFailed {
+	if storage.TaskDeleted(s.cfg.TempDir, taskID) {
+		s.finishDeleted(ctx, t)
+		return nil
+	}
+	if t.Status.IsTerminal() {
 		dirs, dirsErr := storage.EnsureTaskDirs(s.cfg.TempDir, taskID)
 		if dirsErr != nil {
 			return dirsErr
@@ -239,6 +334,11 @@ func (s *CompileService) RunTask(ctx context.Context, taskID string) (runErr err
 	if err != nil {
 		return s.markFailed(ctx, t, "task_dirs_failed", "创建任务目录失败", err)
 	}
+	if storage.TaskCancelRequested(dirs) {
+		return s.finishCancelled(ctx, t, dirs)
+	}
+	ctx, stopRun := s.running.start(ctx, taskID, dirs)
+	defer stopRun()
 	if t.Status != task.TaskQueued {
 		if err := storage.ResetTaskWorkspace(dirs); err != nil {
 			return s.markFailed(ctx, t, "retry_workspace_failed", "清理上次未完成的处理结果失败", err)
@@ -252,31 +352,43 @@ func (s *CompileService) RunTask(ctx context.Context, taskID string) (runErr err
 		}
 	}
 
-	inputPath := storage.InputFilePath(dirs)
-	data, err := os.ReadFile(inputPath)
+	// The package is read in place: decryption and extraction stream from
+	// the file, so memory does not scale with the upload size.
+	inputFile, inputSize, err := openPackageFile(storage.InputFilePath(dirs))
 	if err != nil {
 		return s.markFailed(ctx, t, "input_read_failed", "读取上传文件失败", err)
 	}
+	defer inputFile.Close()
+	input := io.NewSectionReader(inputFile, 0, inputSize)
 
-	profile, err := s.classify(ctx, t, data, "")
+	profile, err := s.classify(ctx, t, packageHead(input), "")
 	if err != nil {
 		return s.markFailed(ctx, t, "classify_failed", "包类型识别失败", err)
 	}
 	inputWasEncrypted := profile.IsEncrypted
 	t.PackageProfile = profile
 	if err := s.repo.Update(ctx, t); err != nil {
+		if cancelRequested(ctx) {
+			return s.finishCancelled(ctx, t, dirs)
+		}
 		return err
 	}
 	appID, err := storage.ReadAppIDSecret(dirs)
 	if err != nil {
 		return s.markFailed(ctx, t, "app_id_read_failed", "读取解密凭据失败", err)
 	}
-	decryptedData, decryptErr := s.decrypt(ctx, t, data, appID)
+	plain, appID, decryptErr := s.decrypt(ctx, t, input, appID)
+	var subPackages []legacyservice.PackageSource
+	closeSubPackages := func() {}
+	defer func() { closeSubPackages() }()
+	if decryptErr == nil {
+		subPackages, closeSubPackages, decryptErr = openSubPackages(dirs, appID)
+	}
 	// With sample collection enabled, keep the package (decrypted bytes when
 	// available) and the one-shot AppID for offline analysis before the
 	// credential is destroyed. Best-effort: a storage failure must not change
 	// task outcomes.
-	if sampleErr := s.saveDiagnosticSample(t, dirs, data, decryptedData, appID); sampleErr != nil {
+	if sampleErr := s.saveDiagnosticSample(t, input, plain, appID); sampleErr != nil {
 		log.Printf("[Task] save diagnostic sample failed (%T)", sampleErr)
 	}
 	deleteSecretErr := storage.DeleteAppIDSecret(dirs)
@@ -287,13 +399,34 @@ func (s *CompileService) RunTask(ctx context.Context, taskID string) (runErr err
 		if errors.Is(decryptErr, dec.ErrNeedAppID) {
 			return s.markFailed(ctx, t, "app_id_required", "这是加密包，需要提供正确的小程序 AppID 才能解密", decryptErr)
 		}
+		if errors.Is(decryptErr, dec.ErrAppIDNotFound) {
+			return s.markFailed(ctx, t, "app_id_not_found", "候选 AppID 均无法解密此包，请手动提供正确的 AppID", decryptErr)
+		}
 		if errors.Is(decryptErr, dec.ErrBadAppID) {
 			return s.markFailed(ctx, t, "app_id_invalid", "AppID 格式错误，应为 wx 开头的 18 位标识", decryptErr)
 		}
 		return s.markFailed(ctx, t, "decrypt_failed", "解密失败", decryptErr)
 	}
+	if s.cfg.RetainDecryptedPackages {
+		// Best-effort like sample collection: without the copy the task only
+		// loses the option to be rerun.
+		if err := retainDecryptedPackages(dirs, plain, subPackages); err != nil {
+			log.Printf("[Task] retain decrypted package failed (%T)", err)
+		}
+	}
 
-	_, err = s.unpack(ctx, t, decryptedData, dirs.SourceDir)
+	if cancelRequested(ctx) {
+		return s.finishCancelled(ctx, t, dirs)
+	}
+	cacheKey, cached := s.lookupCachedResult(t, plain, subPackages, dirs)
+	if cached != nil {
+		closeSubPackages()
+		_ = inputFile.Close()
+		return s.finishFromCache(ctx, t, dirs, cached)
+	}
+
+	unpacked, err := s.unpack(ctx, t, plain, subPackages, dirs)
+	closeSubPackages()
 	if err != nil {
 		return s.markFailed(ctx, t, "unpack_failed", "解包失败", err)
 	}
@@ -306,7 +439,7 @@ func (s *CompileService) RunTask(ctx context.Context, taskID string) (runErr err
 		}
 	}
 
-	profile, err = s.classify(ctx, t, decryptedData, dirs.SourceDir)
+	profile, err = s.classify(ctx, t, packageHead(plain), dirs.SourceDir)
 	if err != nil {
 		return s.markFailed(ctx, t, "classify_failed", "解包后包类型识别失败", err)
 	}
@@ -316,6 +449,9 @@ func (s *CompileService) RunTask(ctx context.Context, taskID string) (runErr err
 	profile.IsEncrypted = inputWasEncrypted
 	t.PackageProfile = profile
 
+	if cancelRequested(ctx) {
+		return s.finishCancelled(ctx, t, dirs)
+	}
 	normalized, err := s.normalize(ctx, t, dirs.SourceDir, profile)
 	if err != nil {
 		return s.markFailed(ctx, t, "normalize_failed", "规范化包结构失败", err)
@@ -325,20 +461,33 @@ func (s *CompileService) RunTask(ctx context.Context, taskID string) (runErr err
 	if err != nil {
 		return s.markFailed(ctx, t, "manifest_recover_failed", "manifest 恢复失败", err)
 	}
+	manifestPath, err := filepath.Rel(dirs.SourceDir, manifestResult.OutputPath)
+	if err != nil {
+		manifestPath = filepath.Base(manifestResult.OutputPath)
+	}
 	artifactFiles := []task.ArtifactFile{
 		{
-			Path:   filepath.ToSlash(filepath.Join("src", filepath.Base(manifestResult.OutputPath))),
+			Path:   filepath.ToSlash(filepath.Join("src", manifestPath)),
 			Kind:   "json",
 			Source: "manifest",
 		},
 	}
 
-	decompileArtifacts, fallbackUsed, decompilePartial, err := s.recoverDecompile(ctx, t, normalized, decryptedData, dirs)
+	if cancelRequested(ctx) {
+		return s.finishCancelled(ctx, t, dirs)
+	}
+	decompileArtifacts, fallbackUsed, decompilePartial, err := s.recoverDecompile(ctx, t, normalized, plain, unpacked.Files, dirs)
 	if err != nil {
 		return s.markFailed(ctx, t, "decompile_failed", "深度恢复阶段失败", err)
 	}
+	// Nothing reads the package after this point; release it before the
+	// input directory is removed at finalize.
+	_ = inputFile.Close()
 	artifactFiles = append(artifactFiles, decompileArtifacts...)
 
+	if cancelRequested(ctx) {
+		return s.finishCancelled(ctx, t, dirs)
+	}
 	if t.RequestedOptions.Beautify {
 		s.beginStage(ctx, t, task.TaskFormatting, 84, "正在执行语义安全的最终格式化...")
 		formatResult, err := legacyservice.FormatSourceTree(dirs.SourceDir)
@@ -385,12 +534,21 @@ func (s *CompileService) RunTask(ctx context.Context, taskID string) (runErr err
 		decompilePartial = decompilePartial || formatResult.Partial
 	}
 
+	if cancelRequested(ctx) {
+		return s.finishCancelled(ctx, t, dirs)
+	}
 	manifestVerifyResult, artifactVerifyResult, err := s.verify(ctx, t, normalized, dirs.SourceDir)
 	if err != nil {
 		return s.markFailed(ctx, t, "verify_failed", "恢复结果验证失败", err)
 	}
 
 	t.RecoveryScore = verify.ComputeRecoveryScore(manifestVerifyResult, artifactVerifyResult, t.RequestedOptions.Decompile, fallbackUsed)
+	if s.cfg.ReportEnabled {
+		if cancelRequested(ctx) {
+			return s.finishCancelled(ctx, t, dirs)
+		}
+		s.analyzeSource(ctx, t, normalized, dirs)
+	}
 	t.Diagnostics = dedupeDiagnostics(t.Diagnostics)
 
 	reportPath := filepath.Join(dirs.ReportsDir, "recovery-report.json")
@@ -412,14 +570,135 @@ func (s *CompileService) RunTask(ctx context.Context, taskID string) (runErr err
 		message = fmt.Sprintf("处理完成，共整理 %d 个源码文件", finalFileCount)
 	}
 
+	if cancelRequested(ctx) {
+		return s.finishCancelled(ctx, t, dirs)
+	}
 	if err := s.packageResult(ctx, t, dirs); err != nil {
 		return s.markFailed(ctx, t, "package_failed", "打包结果失败", err)
 	}
 	s.refreshArtifactSummary(t)
+	s.storeCachedResult(cacheKey, t, dirs, status, code, message)
 
 	return s.finalizeTask(ctx, t, status, code, message, nil)
 }
 
+// analyzeSource writes security-report.json, then runs the dependency
+// graph and the API inventory as sub-steps of their own. Only summaries and
+// samples of the secret findings reach the task's diagnostics; the report
+// keeps the rest. Analysis never fails the task: a step that cannot finish
+// ends partial with a warning.
+func (s *CompileService) analyzeSource(ctx context.Context, t *task.Task, normalized *pkg.NormalizedPackage, dirs storage.TaskDirs) {
+	s.beginStage(ctx, t, task.TaskAnalyzing, 90, "正在扫描密钥、网络地址与隐私接口...")
+	const maxSecretSamples = 10
+	result, err := analyze.AnalyzeSecurity(ctx, normalized, dirs.SourceDir)
+	if err == nil {
+		err = storage.WriteJSON(filepath.Join(dirs.ReportsDir, "security-report.json"), result)
+	}
+	if err != nil {
+		log.Printf("[Analyze] security analysis failed (%T)", err)
+		s.finishStage(ctx, t, string(task.TaskAnalyzing), false, true, "安全与隐私分析未完成", nil, []pkg.Diagnostic{
+			pkg.Warn("security.analysis_failed", "安全与隐私分析失败，未生成 security-report.json", "analyzing", ""),
+		})
+	} else {
+		diagnostics := []pkg.Diagnostic{pkg.Info("security.report", fmt.Sprintf("安全与隐私分析发现 %d 处疑似密钥、%d 个网络地址、%d 类隐私接口，详见 security-report.json", result.SecretCount, len(result.Endpoints), len(result.PrivacyAPIs)), "analyzing", "")}
+		samples := 0
+		for _, finding := range result.Findings {
+			if samples >= maxSecretSamples {
+				break
+			}
+			if finding.Severity == pkg.SeverityWarn && strings.HasPrefix(finding.Code, "security.secret.") {
+				diagnostics = append(diagnostics, finding)
+				samples++
+			}
+		}
+		s.finishStage(ctx, t, string(task.TaskAnalyzing), true, false, "安全与隐私分析完成", map[string]interface{}{
+			"scannedFiles": result.ScannedFiles,
+			"skippedFiles": result.SkippedFiles,
+			"secrets":      result.SecretCount,
+			"endpoints":    len(result.Endpoints),
+			"domains":      len(result.Domains),
+			"privacyApis":  len(result.PrivacyAPIs),
+			"permissions":  len(result.Permissions),
+			"findings":     len(result.Findings),
+			"report":       "security-report.json",
+		}, diagnostics)
+	}
+
+	s.analyzeDependencyGraph(ctx, t, normalized, dirs)
+	s.analyzeAPIInventory(ctx, t, normalized, dirs)
+}
+
+// analyzeDependencyGraph writes dependency-graph.json. A sample of the
+// unresolved references reaches the task's diagnostics; the graph keeps
+// the rest.
+func (s *CompileService) analyzeDependencyGraph(ctx context.Context, t *task.Task, normalized *pkg.NormalizedPackage, dirs storage.TaskDirs) {
+	s.beginSubStep(ctx, t, task.StageDependencyGraph, 92, "正在构建页面与组件依赖图...")
+	const maxUnresolvedSamples = 20
+	graph, err := analyze.BuildDependencyGraph(ctx, normalized.Manifest, dirs.SourceDir)
+	if err == nil {
+		err = storage.WriteJSON(filepath.Join(dirs.ReportsDir, "dependency-graph.json"), graph)
+	}
+	if err != nil {
+		log.Printf("[Analyze] dependency graph failed (%T)", err)
+		s.finishStage(ctx, t, task.StageDependencyGraph, false, true, "依赖图构建失败", nil, []pkg.Diagnostic{
+			pkg.Warn("graph.build_failed", "依赖图构建失败，未生成 dependency-graph.json", "analyzing", ""),
+		})
+		return
+	}
+	var samples []pkg.Diagnostic
+	unresolvedReferences := 0
+	for _, diagnostic := range graph.Diagnostics {
+		if diagnostic.Severity == pkg.SeverityWarn {
+			unresolvedReferences++
+			if len(samples) < maxUnresolvedSamples {
+				samples = append(samples, diagnostic)
+			}
+		}
+	}
+	summary := pkg.Info("graph.report", fmt.Sprintf("依赖图包含 %d 个节点、%d 条引用，%d 处引用无法解析，%d 个组件未被页面引用，详见 dependency-graph.json", len(graph.Nodes), len(graph.Edges), unresolvedReferences, len(graph.OrphanComponents)), "analyzing", "")
+	s.finishStage(ctx, t, task.StageDependencyGraph, true, false, "依赖图构建完成", map[string]interface{}{
+		"graphNodes":     len(graph.Nodes),
+		"graphEdges":     len(graph.Edges),
+		"orphans":        len(graph.OrphanComponents),
+		"unresolvedRefs": unresolvedReferences,
+		"report":         "dependency-graph.json",
+	}, append([]pkg.Diagnostic{summary}, samples...))
+}
+
+// analyzeAPIInventory writes api-inventory.json and its OpenAPI skeleton.
+// A failure leaves the sub-step partial with a warning; the task goes on.
+func (s *CompileService) analyzeAPIInventory(ctx context.Context, t *task.Task, normalized *pkg.NormalizedPackage, dirs storage.TaskDirs) {
+	s.beginSubStep(ctx, t, task.StageAPIInventory, 93, "正在整理接口清单...")
+	inventory, err := analyze.AnalyzeAPIInventory(ctx, normalized)
+	if err == nil {
+		err = storage.WriteJSON(filepath.Join(dirs.ReportsDir, "api-inventory.json"), inventory)
+	}
+	if err == nil {
+		title := "小程序接口清单"
+		if name, ok := normalized.Manifest.Window["navigationBarTitleText"].(string); ok && strings.TrimSpace(name) != "" {
+			title = name
+		}
+		err = storage.WriteJSON(filepath.Join(dirs.ReportsDir, "api-inventory.openapi.json"), analyze.BuildOpenAPI(inventory, title))
+	}
+	if err != nil {
+		log.Printf("[Analyze] API inventory failed (%T)", err)
+		s.finishStage(ctx, t, task.StageAPIInventory, false, true, "接口清单整理失败", nil, []pkg.Diagnostic{
+			pkg.Warn("api.inventory_failed", "接口清单整理失败，未生成 api-inventory.json", "analyzing", ""),
+		})
+		return
+	}
+	summary := pkg.Info("api.inventory", fmt.Sprintf("接口清单收录 %d 个主机的 %d 个接口、%d 个云函数，%d 处调用无法静态确定地址，详见 api-inventory.json", len(inventory.Hosts), inventory.EndpointCount(), len(inventory.CloudFunctions), inventory.UnresolvedCalls), "analyzing", "")
+	s.finishStage(ctx, t, task.StageAPIInventory, true, false, "接口清单整理完成", map[string]interface{}{
+		"scannedScripts":  inventory.ScannedScripts,
+		"partialScripts":  inventory.PartialScripts,
+		"apiHosts":        len(inventory.Hosts),
+		"apiEndpoints":    inventory.EndpointCount(),
+		"cloudFunctions":  len(inventory.CloudFunctions),
+		"unresolvedCalls": inventory.UnresolvedCalls,
+		"report":          "api-inventory.json",
+	}, []pkg.Diagnostic{summary})
+}
+
 func (s *CompileService) classify(ctx context.Context, t *task.Task, data []byte, extractedDir string) (*pkg.PackageProfile, error) {
 	s.beginStage(ctx, t, task.TaskClassifying, 5, "正在识别包类型与版本特征...")
 	profile, err := classifier.DetectPackageProfile(data, extractedDir)
@@ -439,36 +718,122 @@ func (s *CompileService) classify(ctx context.Context, t *task.Task, data []byte
 	return profile, nil
 }
 
-func (s *CompileService) decrypt(ctx context.Context, t *task.Task, data []byte, appID string) ([]byte, error) {
+// decrypt returns a streaming view of the plaintext package and the AppID
+// that decrypted it, which is the one subpackages are decrypted with.
+func (s *CompileService) decrypt(ctx context.Context, t *task.Task, src legacyservice.PackageSource, appID string) (legacyservice.PackageSource, string, error) {
 	s.beginStage(ctx, t, task.TaskDecrypting, 15, "正在解密或校验 wxapkg 数据...")
-	decrypted, err := dec.DecryptWxapkg(data, appID)
+	appIDSource := "request"
+	if t.RequestedOptions.AppIDFromPath {
+		appIDSource = "path"
+	}
+	data := packageHead(src)
+	var diagnostics []pkg.Diagnostic
+	if appIDSource == "path" && t.RequestedOptions.AppIDSearch && !dec.MatchesAppID(data, src.Size(), appID) {
+		// A directory that merely looks like an AppID must not block the search.
+		appID = ""
+	}
+	if appID == "" && dec.IsEncrypted(data) && t.RequestedOptions.AppIDSearch {
+		candidates, err := s.appIDCandidates()
+		if err != nil {
+			return nil, "", err
+		}
+		appID, err = dec.SearchAppID(data, src.Size(), candidates)
+		if err != nil {
+			return nil, "", err
+		}
+		appIDSource = "search"
+		diagnostic := pkg.Info("decrypt.appid.searched", "已从候选 AppID 列表中找到可解密此包的 AppID", "decrypting", "")
+		diagnostic.Metadata = map[string]interface{}{"candidates": len(candidates)}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	decrypted, err := dec.NewPackageReader(src, src.Size(), appID)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	mode := dec.DetectEncryptionMode(data)
 	message := "检测到未加密包，直接进入解包"
+	metrics := map[string]interface{}{
+		"mode": string(mode),
+	}
 	if mode == dec.EncryptionEncrypted {
 		message = "解密完成"
+		metrics["appIdSource"] = appIDSource
 	}
-	s.finishStage(ctx, t, string(task.TaskDecrypting), true, false, message, map[string]interface{}{
-		"mode": string(mode),
-	}, nil)
-	return decrypted, nil
+	s.finishStage(ctx, t, string(task.TaskDecrypting), true, false, message, metrics, diagnostics)
+	return decrypted, appID, nil
+}
+
+// openPackageFile opens a stored package for random-access reads.
+func openPackageFile(path string) (*os.File, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// packageHead returns the leading bytes classification and AppID matching
+// look at: the V1MMWX marker with the AES-encrypted block, or the plaintext
+// header with the file count.
+func packageHead(src legacyservice.PackageSource) []byte {
+	head := make([]byte, min(src.Size(), int64(len(dec.FileHeader)+1024)))
+	n, _ := src.ReadAt(head, 0)
+	return head[:n]
+}
+
+// copyPackageToFile writes the whole package to path for tools that need it
+// as a file, streaming so the copy never sits in memory.
+func copyPackageToFile(path string, src legacyservice.PackageSource) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, io.NewSectionReader(src, 0, src.Size())); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (s *CompileService) appIDCandidates() ([]string, error) {
+	file, err := os.Open(s.cfg.AppIDCandidatesFile)
+	if err != nil {
+		return nil, fmt.Errorf("open AppID candidates: %w", err)
+	}
+	defer file.Close()
+	return dec.ReadAppIDCandidates(file)
 }
 
-func (s *CompileService) unpack(ctx context.Context, t *task.Task, data []byte, outputDir string) (*legacyservice.UnpackResult, error) {
+func (s *CompileService) unpack(ctx context.Context, t *task.Task, src legacyservice.PackageSource, subPackages []legacyservice.PackageSource, dirs storage.TaskDirs) (*legacyservice.UnpackResult, error) {
 	s.beginStage(ctx, t, task.TaskUnpacking, 32, "正在解包 wxapkg...")
 	// Extraction must remain byte-for-byte faithful. Formatting is a final,
 	// explicitly reported stage after all recovery engines have completed.
-	result, err := legacyservice.UnpackWxapkg(data, outputDir, false)
+	result, err := legacyservice.UnpackPackage(src, dirs.SourceDir, false)
 	if err != nil {
 		return nil, err
 	}
 
-	s.finishStage(ctx, t, string(task.TaskUnpacking), true, false, "基础解包完成", map[string]interface{}{
+	metrics := map[string]interface{}{
 		"fileCount": result.FileCount,
-	}, nil)
+	}
+	var diagnostics []pkg.Diagnostic
+	if len(subPackages) > 0 {
+		merged, mergeDiagnostics, err := mergeSubPackages(subPackages, dirs)
+		if err != nil {
+			return nil, err
+		}
+		metrics["subPackages"] = len(subPackages)
+		metrics["subPackageFiles"] = merged.Added
+		metrics["mergeConflicts"] = len(merged.Conflicts)
+		diagnostics = mergeDiagnostics
+	}
+	s.finishStage(ctx, t, string(task.TaskUnpacking), true, false, "基础解包完成", metrics, diagnostics)
 	return result, nil
 }
 
@@ -502,16 +867,23 @@ func (s *CompileService) recoverManifest(ctx context.Context, t *task.Task, norm
 	return result, nil
 }
 
-func (s *CompileService) recoverDecompile(ctx context.Context, t *task.Task, normalized *pkg.NormalizedPackage, decryptedData []byte, dirs storage.TaskDirs) ([]task.ArtifactFile, bool, bool, error) {
+// recoverDecompile runs the recovery engines and then writes
+// reports/sourcemaps, tracing the files they split out back to byte ranges
+// of the packed entries listed in entries.
+func (s *CompileService) recoverDecompile(ctx context.Context, t *task.Task, normalized *pkg.NormalizedPackage, plain legacyservice.PackageSource, entries []model.FileEntry, dirs storage.TaskDirs) ([]task.ArtifactFile, bool, bool, error) {
 	if !t.RequestedOptions.Decompile {
 		return nil, false, false, nil
 	}
+	if normalized.Profile.IsGamePackage {
+		return s.recoverGameDecompile(ctx, t, normalized, entries, dirs)
+	}
 
 	var artifactFiles []task.ArtifactFile
 	fallbackUsed := false
 	decompilePartial := false
 	needsFallback := false
 	usedInferredOutput := false
+	var sourceRanges []recovery.SourceRange
 
 	s.beginStage(ctx, t, task.TaskRecoveringJS, 66, "正在执行深度恢复引擎...")
 	if s.cfg.NativeRecoverEnabled {
@@ -520,6 +892,7 @@ func (s *CompileService) recoverDecompile(ctx context.Context, t *task.Task, nor
 			return nil, false, false, err
 		}
 		artifactFiles = append(artifactFiles, toArtifactFiles(jsResult.Files)...)
+		sourceRanges = append(sourceRanges, jsResult.SourceRanges...)
 		s.finishStage(ctx, t, string(task.TaskRecoveringJS), jsResult.Success, jsResult.Partial, chooseRecoveryMessage("JS", jsResult.Success), map[string]interface{}{
 			"recovered":       jsResult.Recovered,
 			"generated":       jsResult.Generated,
@@ -602,7 +975,7 @@ func (s *CompileService) recoverDecompile(ctx context.Context, t *task.Task, nor
 			return nil, false, false, err
 		}
 		inputCopy := filepath.Join(fallbackDir, "input.wxapkg")
-		if err := os.WriteFile(inputCopy, decryptedData, 0600); err != nil {
+		if err := copyPackageToFile(inputCopy, plain); err != nil {
 			return nil, false, false, err
 		}
 		defer func() { _ = os.RemoveAll(fallbackDir) }()
@@ -624,9 +997,11 @@ func (s *CompileService) recoverDecompile(ctx context.Context, t *task.Task, nor
 		fallbackUsed = fallbackResult.Success
 		decompilePartial = decompilePartial || fallbackResult.Partial || !fallbackResult.Success
 		if fallbackResult.Success {
-			if err := recovery.MergeFallbackArtifacts(dirs.SourceDir, fallbackResult.OutputDir); err != nil {
+			merged, err := recovery.MergeFallbackArtifactsWithPolicy(dirs.SourceDir, fallbackResult.OutputDir)
+			if err != nil {
 				return nil, false, false, err
 			}
+			sourceRanges = append(sourceRanges, recovery.FallbackSourceRanges(normalized, merged.AddedFiles)...)
 			artifactFiles = append(artifactFiles, toArtifactFiles(fallbackResult.Files)...)
 		}
 		s.finishStage(ctx, t, string(task.TaskFallbackRecovering), fallbackResult.Success && !fallbackResult.Partial, fallbackResult.Partial || !fallbackResult.Success, "fallback 恢复阶段结束", map[string]interface{}{
@@ -647,9 +1022,68 @@ func (s *CompileService) recoverDecompile(ctx context.Context, t *task.Task, nor
 	jsReady := hasExtFiles(dirs.SourceDir, ".js")
 	wxmlReady := hasExtFiles(dirs.SourceDir, ".wxml")
 	decompilePartial = decompilePartial || usedInferredOutput || !(jsReady && wxmlReady)
+	writeSourceMaps(t, normalized, dirs, sourceRanges, entries)
 	return dedupeArtifactFiles(artifactFiles), fallbackUsed, decompilePartial, nil
 }
 
+// recoverGameDecompile runs the mini-game path. Games have no WXML or WXSS
+// and wxappUnpacker only understands app layouts, so the template stages are
+// skipped and there is no fallback.
+func (s *CompileService) recoverGameDecompile(ctx context.Context, t *task.Task, normalized *pkg.NormalizedPackage, entries []model.FileEntry, dirs storage.TaskDirs) ([]task.ArtifactFile, bool, bool, error) {
+	var artifactFiles []task.ArtifactFile
+	var sourceRanges []recovery.SourceRange
+	decompilePartial := false
+
+	s.beginStage(ctx, t, task.TaskRecoveringJS, 66, "正在拆分小游戏模块...")
+	if s.cfg.NativeRecoverEnabled {
+		gameResult, err := recovery.RecoverGame(normalized, dirs.SourceDir, dirs.ReportsDir)
+		if err != nil {
+			return nil, false, false, err
+		}
+		artifactFiles = append(artifactFiles, toArtifactFiles(gameResult.Files)...)
+		sourceRanges = gameResult.SourceRanges
+		s.finishStage(ctx, t, string(task.TaskRecoveringJS), gameResult.Success, gameResult.Partial, chooseRecoveryMessage("小游戏 JS", gameResult.Success), map[string]interface{}{
+			"recovered":       gameResult.Recovered,
+			"native":          gameResult.Native,
+			"report":          filepath.Base(gameResult.ReportPath),
+			"engine":          "native",
+			"gameEngine":      gameResult.Engine,
+			"assets":          len(gameResult.Assets),
+			"assetKinds":      gameResult.AssetKinds,
+			"sourceBreakdown": countRecoveredSources(gameResult.Files),
+		}, gameResult.Diagnostics)
+		decompilePartial = gameResult.Partial || !gameResult.Success
+	} else {
+		decompilePartial = true
+		s.finishStage(ctx, t, string(task.TaskRecoveringJS), false, true, "原生 JS 恢复已禁用", nil, []pkg.Diagnostic{
+			pkg.Warn("recover.js.disabled", "原生 JS 恢复被配置禁用", "recovering_js", dirs.SourceDir),
+		})
+	}
+
+	s.beginStage(ctx, t, task.TaskRecoveringWXML, 72, "小游戏包不含 WXML，跳过模板恢复")
+	s.finishStage(ctx, t, string(task.TaskRecoveringWXML), true, false, "小游戏包不含 WXML，已跳过", map[string]interface{}{"skipped": true}, nil)
+	s.beginStage(ctx, t, task.TaskRecoveringWXSS, 78, "小游戏包不含 WXSS，跳过样式恢复")
+	s.finishStage(ctx, t, string(task.TaskRecoveringWXSS), true, false, "小游戏包不含 WXSS，已跳过", map[string]interface{}{"skipped": true}, nil)
+
+	decompilePartial = decompilePartial || !hasExtFiles(dirs.SourceDir, ".js")
+	writeSourceMaps(t, normalized, dirs, sourceRanges, entries)
+	return dedupeArtifactFiles(artifactFiles), false, decompilePartial, nil
+}
+
+// writeSourceMaps maps recovered files to the packed entries they were cut
+// from. The maps live under reports/, so the src-only zip never carries them.
+// They are an aid for reading the output, so a failure only adds a warning.
+func writeSourceMaps(t *task.Task, normalized *pkg.NormalizedPackage, dirs storage.TaskDirs, ranges []recovery.SourceRange, entries []model.FileEntry) {
+	sources := make(map[string]string, len(normalized.Scripts))
+	for _, script := range normalized.Scripts {
+		sources[script.Path] = script.Content
+	}
+	if _, err := recovery.WriteSourceMaps(dirs.SourceDir, filepath.Join(dirs.ReportsDir, "sourcemaps"), ranges, entries, sources); err != nil {
+		log.Printf("[Recover] write source maps failed (%T)", err)
+		t.Diagnostics = append(t.Diagnostics, pkg.Warn("recover.sourcemaps_failed", "Source Map 生成失败，reports/sourcemaps 可能不完整", "recovering_js", ""))
+	}
+}
+
 func (s *CompileService) verify(ctx context.Context, t *task.Task, normalized *pkg.NormalizedPackage, sourceDir string) (*verify.ManifestVerifyResult, *verify.ArtifactVerifyResult, error) {
 	s.beginStage(ctx, t, task.TaskVerifying, 86, "正在验证恢复结果完整性...")
 	if !s.cfg.VerificationEnabled {
@@ -660,6 +1094,13 @@ func (s *CompileService) verify(ctx context.Context, t *task.Task, normalized *p
 				pkg.Warn("verify.disabled", "验证阶段已禁用，任务将按 partial 语义交付", "verifying", sourceDir),
 			},
 		}
+		if normalized.Profile.IsGamePackage {
+			manifestResult.PageCount = 0
+			manifestResult.Game = &verify.GameVerifyResult{}
+		}
+		if normalized.Plugin != nil {
+			manifestResult.PageCount += len(normalized.Plugin.PublicComponents)
+		}
 		artifactResult := &verify.ArtifactVerifyResult{
 			Success:         false,
 			TotalPages:      len(normalized.Manifest.Pages),
@@ -680,7 +1121,7 @@ func (s *CompileService) verify(ctx context.Context, t *task.Task, normalized *p
 	if err != nil {
 		return nil, nil, err
 	}
-	artifactResult, err := verify.VerifyArtifacts(s.nodeRunner, normalized, sourceDir)
+	artifactResult, err := verify.VerifyArtifactsContext(ctx, s.nodeRunner, normalized, sourceDir)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -717,7 +1158,7 @@ func (s *CompileService) packageResult(ctx context.Context, t *task.Task, dirs s
 	if err != nil {
 		return err
 	}
-	zipManifest, err := report.BuildZipManifest(t.ID, archiveEntries, "src")
+	zipManifest, err := report.BuildZipManifest(t.ID, zipPath, archiveEntries, "src")
 	if err != nil {
 		return err
 	}
@@ -756,14 +1197,21 @@ func (s *CompileService) buildArtifactSummary(t *task.Task, dirs storage.TaskDir
 	}
 }
 
+// markFailed records a failed stage. A stage that failed because the task
+// was cancelled ends the task as cancelled instead.
 func (s *CompileService) markFailed(ctx context.Context, t *task.Task, code, msg string, cause error) error {
+	if cancelRequested(ctx) {
+		if dirs, err := storage.EnsureTaskDirs(s.cfg.TempDir, t.ID); err == nil {
+			return s.finishCancelled(ctx, t, dirs)
+		}
+	}
 	return s.finalizeTask(ctx, t, task.TaskFailed, code, msg, cause)
 }
 
 // saveDiagnosticSample retains the package (decrypted bytes when available)
 // and the one-shot AppID for offline analysis. Only invoked before the
 // credential is destroyed; successful tasks delete their sample at finalize.
-func (s *CompileService) saveDiagnosticSample(t *task.Task, dirs storage.TaskDirs, original, decrypted []byte, appID string) error {
+func (s *CompileService) saveDiagnosticSample(t *task.Task, original, decrypted legacyservice.PackageSource, appID string) error {
 	if s.cfg.DiagnosticSamplesDir == "" {
 		return nil
 	}
@@ -771,7 +1219,7 @@ func (s *CompileService) saveDiagnosticSample(t *task.Task, dirs storage.TaskDir
 	if decrypted != nil {
 		data = decrypted
 	}
-	if err := storage.SaveDiagnosticSample(s.cfg.DiagnosticSamplesDir, t.ID, data, appID); err != nil {
+	if err := storage.SaveDiagnosticSample(s.cfg.DiagnosticSamplesDir, t.ID, io.NewSectionReader(data, 0, data.Size()), appID); err != nil {
 		return err
 	}
 	return nil
@@ -789,6 +1237,12 @@ func (s *CompileService) discardDiagnosticSample(t *task.Task) {
 }
 
 func (s *CompileService) finalizeTask(ctx context.Context, t *task.Task, status task.TaskStatus, code, msg string, cause error) error {
+	if storage.TaskDeleted(s.cfg.TempDir, t.ID) {
+		// Deleted while running: recreating the workspace or the record
+		// would undo the deletion.
+		s.finishDeleted(ctx, t)
+		return nil
+	}
 	msg = report.SanitizeText(msg)
 	now := time.Now()
 	dirs, dirsErr := storage.EnsureTaskDirs(s.cfg.TempDir, t.ID)
@@ -835,6 +1289,9 @@ func (s *CompileService) finalizeTask(ctx context.Context, t *task.Task, status
 	if status == task.TaskFailed {
 		eventType = "error"
 	}
+	if status == task.TaskCancelled {
+		eventType = "cancelled"
+	}
 
 	event := task.TaskEvent{
 		Type:             eventType,
@@ -860,6 +1317,9 @@ func (s *CompileService) finalizeTask(ctx context.Context, t *task.Task, status
 		}
 	}
 	s.publish(t, event)
+	if dirsErr == nil {
+		s.notifyTaskFinished(t, dirs)
+	}
 	if status == task.TaskFailed {
 		if cause != nil {
 			return cause
@@ -941,8 +1401,14 @@ func removeIfExists(path string) error {
 
 func (s *CompileService) beginStage(ctx context.Context, t *task.Task, status task.TaskStatus, percent int, msg string) {
 	t.Status = status
-	t.Progress = percent
 	t.CurrentStage = string(status)
+	s.beginSubStep(ctx, t, string(status), percent, msg)
+}
+
+// beginSubStep starts timing a stage result named stage without leaving
+// the task's current status; progress events report the enclosing stage.
+func (s *CompileService) beginSubStep(ctx context.Context, t *task.Task, stage string, percent int, msg string) {
+	t.Progress = percent
 	t.CurrentMessage = msg
 	startedAt := time.Now()
 	if t.StageStartedAt == nil {
@@ -951,16 +1417,16 @@ func (s *CompileService) beginStage(ctx context.Context, t *task.Task, status ta
 	if t.StageAttempts == nil {
 		t.StageAttempts = make(map[string]int)
 	}
-	t.StageStartedAt[string(status)] = startedAt
-	t.StageAttempts[string(status)]++
+	t.StageStartedAt[stage] = startedAt
+	t.StageAttempts[stage]++
 	t.UpdatedAt = startedAt
 	if err := s.repo.Update(ctx, t); err != nil {
-		log.Printf("[Task] persist stage start %s failed (%T)", status, err)
+		log.Printf("[Task] persist stage start %s failed (%T)", stage, err)
 	}
 	s.publish(t, task.TaskEvent{
 		Type:    "progress",
-		Stage:   string(status),
-		Status:  string(status),
+		Stage:   t.CurrentStage,
+		Status:  string(t.Status),
 		Percent: percent,
 		Message: msg,
 		TaskID:  t.ID,
@@ -1196,7 +1662,7 @@ func metricIntMap(metrics map[string]interface{}, key string) map[string]int {
 }
 
 func determineFinalStatus(manifest *verify.ManifestVerifyResult, artifacts *verify.ArtifactVerifyResult, decompilePartial bool) (task.TaskStatus, string, string) {
-	if manifest == nil || manifest.PageCount == 0 {
+	if manifest == nil || manifest.PageCount == 0 && manifest.Game == nil {
 		return task.TaskFailed, "manifest_incomplete", "manifest 恢复结果未通过核心校验"
 	}
 	if artifacts != nil && artifacts.CriticalFailure {
@@ -1,7 +1,10 @@
 package app
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"os"
 	"path/filepath"
@@ -14,7 +17,10 @@ import (
 	"github.com/keepbuild/seewxapkg/internal/infra/events"
 	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
 	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	dec "github.com/keepbuild/seewxapkg/internal/pipeline/decrypt"
+	recovery "github.com/keepbuild/seewxapkg/internal/pipeline/recover"
 	"github.com/keepbuild/seewxapkg/internal/pipeline/verify"
+	"github.com/keepbuild/seewxapkg/tests/testutil"
 )
 
 func TestDetermineFinalStatusCompleted(t *testing.T) {
@@ -307,3 +313,488 @@ func TestFinalizeDeletesAppIDSecretEvenWhenRequestContextIsCanceled(t *testing.T
 		t.Fatalf("canceled finalization retained AppID secret: %v", err)
 	}
 }
+
+func TestCreateTaskDerivesAppIDFromSourcePath(t *testing.T) {
+	tempDir := t.TempDir()
+	input := filepath.Join(t.TempDir(), "__APP__.wxapkg")
+	if err := os.WriteFile(input, []byte("V1MMWX"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	service := NewCompileService(&config.Config{TempDir: tempDir, OutputDir: t.TempDir()}, persistence.NewMemoryTaskRepo(), events.NewBroker(), nil)
+
+	if _, err := service.CreateTask(context.Background(), StartCompileCommand{InputPath: input, SearchAppID: true}); !errors.Is(err, ErrAppIDSearchUnavailable) {
+		t.Fatalf("search without candidates error = %v, want ErrAppIDSearchUnavailable", err)
+	}
+
+	created, err := service.CreateTask(context.Background(), StartCompileCommand{
+		InputPath:  input,
+		SourcePath: `Applet\wx0123456789abcdef\12\__APP__.wxapkg`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created.RequestedOptions.AppIDFromPath {
+		t.Fatal("task did not record that the AppID came from the path")
+	}
+	dirs, err := storage.EnsureTaskDirs(tempDir, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appID, err := storage.ReadAppIDSecret(dirs); err != nil || appID != "wx0123456789abcdef" {
+		t.Fatalf("stored AppID = %q, %v", appID, err)
+	}
+}
+
+func TestCancelAndDeleteQueuedTask(t *testing.T) {
+	tempDir, outputDir := t.TempDir(), t.TempDir()
+	input := filepath.Join(t.TempDir(), "__APP__.wxapkg")
+	if err := os.WriteFile(input, []byte("V1MMWX"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	repo := persistence.NewMemoryTaskRepo()
+	service := NewCompileService(&config.Config{TempDir: tempDir, OutputDir: outputDir}, repo, events.NewBroker(), &recordingQueue{})
+	ctx := context.Background()
+	created, err := service.CreateTask(ctx, StartCompileCommand{InputPath: input, AppID: "wx0123456789abcdef"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, err := service.CancelTask(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != task.TaskCancelled || cancelled.ErrorCode == nil || *cancelled.ErrorCode != "task_cancelled" {
+		t.Fatalf("cancelled task = %s code=%v", cancelled.Status, cancelled.ErrorCode)
+	}
+	dirs := storage.TaskDirsFor(tempDir, created.ID)
+	for _, path := range []string{storage.InputFilePath(dirs), storage.AppIDSecretPath(dirs)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("cancelled task kept %s: %v", filepath.Base(path), err)
+		}
+	}
+	if again, err := service.CancelTask(ctx, created.ID); err != nil || again.Status != task.TaskCancelled {
+		t.Fatalf("repeated cancel = %v, %v", again, err)
+	}
+	// A worker that picks up the job afterwards leaves the task cancelled.
+	if err := service.RunTask(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(outputDir, created.ID+".zip"), []byte("zip"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.DeleteTask(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(ctx, created.ID); err == nil {
+		t.Fatal("deleted task is still in the repository")
+	}
+	for _, path := range []string{dirs.RootDir, filepath.Join(outputDir, created.ID+".zip")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("deleted task left %s behind: %v", path, err)
+		}
+	}
+	if err := service.DeleteTask(ctx, created.ID); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("second delete error = %v, want ErrTaskNotFound", err)
+	}
+	if err := service.RunTask(ctx, created.ID); err != nil {
+		t.Fatalf("stale job for a deleted task should be dropped, got %v", err)
+	}
+}
+
+func TestCancelTaskStopsRunningPipeline(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := persistence.NewMemoryTaskRepo()
+	service := NewCompileService(&config.Config{TempDir: tempDir, OutputDir: t.TempDir()}, repo, events.NewBroker(), nil)
+	ctx := context.Background()
+	now := time.Now()
+	running := &task.Task{ID: "running-task", Status: task.TaskRecoveringJS, CreatedAt: now, UpdatedAt: now}
+	if err := repo.Create(ctx, running); err != nil {
+		t.Fatal(err)
+	}
+	dirs, err := storage.EnsureTaskDirs(tempDir, running.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stand in for a stage that fails once its context is cancelled.
+	runCtx, stopRun := service.running.start(ctx, running.ID, dirs)
+	stageErr := make(chan error, 1)
+	go func() {
+		defer stopRun()
+		<-runCtx.Done()
+		stageErr <- service.markFailed(runCtx, running, "decompile_failed", "深度恢复阶段失败", runCtx.Err())
+	}()
+
+	cancelled, err := service.CancelTask(ctx, running.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-stageErr; err != nil {
+		t.Fatalf("cancelled stage returned %v; the queue would retry it", err)
+	}
+	if cancelled.Status != task.TaskCancelled {
+		t.Fatalf("status = %s, want cancelled", cancelled.Status)
+	}
+
+	finished := &task.Task{ID: "finished-task", Status: task.TaskCompleted, CreatedAt: now, UpdatedAt: now}
+	if err := repo.Create(ctx, finished); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CancelTask(ctx, finished.ID); !errors.Is(err, ErrTaskFinished) {
+		t.Fatalf("cancel finished task error = %v, want ErrTaskFinished", err)
+	}
+	if _, err := service.CancelTask(ctx, "missing-task"); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("cancel missing task error = %v, want ErrTaskNotFound", err)
+	}
+}
+
+func TestDeleteTaskLeavesAStuckPipelineToFinishTheDeletion(t *testing.T) {
+	defer func(timeout time.Duration) { cancelWaitTimeout = timeout }(cancelWaitTimeout)
+	cancelWaitTimeout = 300 * time.Millisecond
+	tempDir := t.TempDir()
+	repo := persistence.NewMemoryTaskRepo()
+	service := NewCompileService(&config.Config{TempDir: tempDir, OutputDir: t.TempDir()}, repo, events.NewBroker(), nil)
+	ctx := context.Background()
+	now := time.Now()
+	stuck := &task.Task{ID: "stuck-task", Status: task.TaskRecoveringJS, CreatedAt: now, UpdatedAt: now}
+	if err := repo.Create(ctx, stuck); err != nil {
+		t.Fatal(err)
+	}
+	dirs, err := storage.EnsureTaskDirs(tempDir, stuck.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stand in for a stage that ignores cancellation until after the wait.
+	runCtx, stopRun := service.running.start(ctx, stuck.ID, dirs)
+	release := make(chan struct{})
+	stageErr := make(chan error, 1)
+	go func() {
+		defer stopRun()
+		<-release
+		stageErr <- service.finalizeTask(runCtx, stuck, task.TaskCompleted, "", "任务完成", nil)
+	}()
+
+	if err := service.DeleteTask(ctx, stuck.ID); !errors.Is(err, ErrTaskStillStopping) {
+		t.Fatalf("delete of a stuck task error = %v, want ErrTaskStillStopping", err)
+	}
+	if _, err := repo.Get(ctx, stuck.ID); err != nil {
+		t.Fatalf("a task that is still stopping must not be half-deleted: %v", err)
+	}
+
+	close(release)
+	if err := <-stageErr; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(ctx, stuck.ID); err == nil {
+		t.Fatal("the finished pipeline wrote the deleted task back")
+	}
+	if storage.TaskWorkspaceExists(dirs) {
+		t.Fatal("the finished pipeline recreated the deleted workspace")
+	}
+}
+
+func TestRerunProcessesRetainedPackageWithNewOptions(t *testing.T) {
+	cfg := &config.Config{TempDir: t.TempDir(), OutputDir: t.TempDir(), NativeRecoverEnabled: true, RetainDecryptedPackages: true}
+	repo := persistence.NewMemoryTaskRepo()
+	service := NewCompileService(cfg, repo, events.NewBroker(), &recordingQueue{})
+	ctx := context.Background()
+	input := filepath.Join(t.TempDir(), "__APP__.wxapkg")
+	packageBytes := testutil.MustBuildWxapkg(map[string]string{
+		"app-config.json":  `{"pages":["pages/index/index"]}`,
+		"app-service.js":   `define("pages/index/index.js", function(){});`,
+		"page-frame.html":  `<html></html>`,
+		"pages/index.html": `<html></html>`,
+	})
+	if err := os.WriteFile(input, packageBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	parent, err := service.CreateTask(ctx, StartCompileCommand{InputPath: input, RemoveGuideHTML: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.RerunTask(ctx, parent.ID, RerunOptions{}); !errors.Is(err, ErrTaskNotFinished) {
+		t.Fatalf("rerun of queued task error = %v, want ErrTaskNotFinished", err)
+	}
+	_ = service.RunTask(ctx, parent.ID)
+	finished, err := repo.Get(ctx, parent.ID)
+	if err != nil || !finished.Status.IsTerminal() {
+		t.Fatalf("parent did not finish: %v, %v", finished, err)
+	}
+
+	decompile := true
+	child, err := service.RerunTask(ctx, parent.ID, RerunOptions{Decompile: &decompile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.ParentTaskID != parent.ID || child.ID == parent.ID {
+		t.Fatalf("child %s not linked to parent %s", child.ID, parent.ID)
+	}
+	if !child.RequestedOptions.Decompile || !child.RequestedOptions.RemoveGuideHTML {
+		t.Fatalf("child options = %+v, want decompile with the parent's guide setting", child.RequestedOptions)
+	}
+	staged, err := os.ReadFile(storage.InputFilePath(storage.TaskDirsFor(cfg.TempDir, child.ID)))
+	if err != nil || !bytes.Equal(staged, packageBytes) {
+		t.Fatalf("child input differs from the parent's package: %v", err)
+	}
+
+	cfg.RetainDecryptedPackages = false
+	_ = service.RunTask(ctx, child.ID)
+	if _, err := service.RerunTask(ctx, child.ID, RerunOptions{}); !errors.Is(err, ErrRerunUnavailable) {
+		t.Fatalf("rerun without a retained package error = %v, want ErrRerunUnavailable", err)
+	}
+	if _, err := service.RerunTask(ctx, "missing-task", RerunOptions{}); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("rerun of missing task error = %v, want ErrTaskNotFound", err)
+	}
+}
+
+type channelNotifier struct {
+	calls chan string
+}
+
+func (n channelNotifier) NotifyTaskFinished(_ context.Context, callbackURL string, t *task.Task) error {
+	n.calls <- callbackURL + " " + string(t.Status)
+	return nil
+}
+
+func TestFinishedTaskNotifiesAllowListedCallback(t *testing.T) {
+	cfg := &config.Config{
+		TempDir:             t.TempDir(),
+		OutputDir:           t.TempDir(),
+		WebhookSecret:       "secret",
+		WebhookURL:          "https://default.example/hook",
+		WebhookAllowedHosts: []string{"ci.example"},
+	}
+	service := NewCompileService(cfg, persistence.NewMemoryTaskRepo(), events.NewBroker(), &recordingQueue{})
+	notifier := channelNotifier{calls: make(chan string, 2)}
+	service.SetTaskNotifier(notifier)
+	ctx := context.Background()
+	input := filepath.Join(t.TempDir(), "broken.wxapkg")
+	if err := os.WriteFile(input, []byte("not a package"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.CreateTask(ctx, StartCompileCommand{InputPath: input, CallbackURL: "https://elsewhere.example/hook"}); !errors.Is(err, ErrCallbackURLNotAllowed) {
+		t.Fatalf("callback outside the allow-list error = %v", err)
+	}
+	for _, callbackURL := range []string{"https://ci.example/hook?token=1", ""} {
+		created, err := service.CreateTask(ctx, StartCompileCommand{InputPath: input, CallbackURL: callbackURL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = service.RunTask(ctx, created.ID)
+		want := callbackURL
+		if want == "" {
+			want = cfg.WebhookURL
+		}
+		select {
+		case got := <-notifier.calls:
+			if got != want+" failed" {
+				t.Fatalf("notification = %q, want %q", got, want+" failed")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("finished task sent no callback")
+		}
+	}
+}
+
+func TestPipelineWritesSourceMapsOutsideZip(t *testing.T) {
+	cfg := &config.Config{TempDir: t.TempDir(), OutputDir: t.TempDir(), NativeRecoverEnabled: true}
+	repo := persistence.NewMemoryTaskRepo()
+	service := NewCompileService(cfg, repo, events.NewBroker(), &recordingQueue{})
+	ctx := context.Background()
+	input := filepath.Join(t.TempDir(), "__APP__.wxapkg")
+	packageBytes := testutil.MustBuildWxapkg(map[string]string{
+		"app-config.json": `{"pages":["pages/index/index"]}`,
+		"app-service.js":  `define("pages/index/index.js", function(){ Page({ onLoad: function () {} }); });`,
+		"page-frame.html": `<html></html>`,
+	})
+	if err := os.WriteFile(input, packageBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	created, err := service.CreateTask(ctx, StartCompileCommand{InputPath: input, Decompile: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = service.RunTask(ctx, created.ID)
+
+	query := NewTaskQueryService(cfg, repo)
+	payload, err := query.GetNamedReport(ctx, created.ID, "sourcemaps")
+	if err != nil {
+		t.Fatalf("source map index unavailable: %v", err)
+	}
+	var index recovery.SourceMapIndex
+	if err := json.Unmarshal(payload, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Maps) != 1 || index.Maps[0].Output != "pages/index/index.js" || index.Maps[0].PackageOffset == nil {
+		t.Fatalf("source map index = %s", payload)
+	}
+	offset := *index.Maps[0].PackageOffset
+	if !bytes.HasPrefix(packageBytes[offset:], []byte("Page({")) {
+		t.Fatalf("package offset %d points at %q", offset, packageBytes[offset:])
+	}
+	if data, err := query.GetTaskSourceMap(ctx, created.ID, "pages/index/index.js"); err != nil || !strings.Contains(string(data), `"version": 3`) {
+		t.Fatalf("source map = %s, %v", data, err)
+	}
+	if _, err := query.GetTaskSourceMap(ctx, created.ID, "../reports/sourcemaps/index.json"); err == nil {
+		t.Fatal("source map lookup escaped the sourcemaps directory")
+	}
+
+	archive, err := zip.OpenReader(query.ResolveZipPath(created.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	for _, file := range archive.File {
+		if !strings.HasPrefix(file.Name, "src/") || strings.HasSuffix(file.Name, ".map") {
+			t.Fatalf("zip must only carry src/: %s", file.Name)
+		}
+	}
+}
+
+func TestPipelineWritesSecurityReport(t *testing.T) {
+	cfg := &config.Config{TempDir: t.TempDir(), OutputDir: t.TempDir(), NativeRecoverEnabled: true, ReportEnabled: true}
+	repo := persistence.NewMemoryTaskRepo()
+	service := NewCompileService(cfg, repo, events.NewBroker(), &recordingQueue{})
+	ctx := context.Background()
+	input := filepath.Join(t.TempDir(), "__APP__.wxapkg")
+	packageBytes := testutil.MustBuildWxapkg(map[string]string{
+		"app-config.json":  `{"pages":["pages/index/index"],"permission":{"scope.userLocation":{"desc":"定位"}}}`,
+		"app-service.js":   `define("pages/index/index.js", function(){ wx.getLocation({}); wx.request({url: "https://api.example.com/user"}); });`,
+		"page-frame.html":  `<html></html>`,
+		"pages/index.html": `<html></html>`,
+	})
+	if err := os.WriteFile(input, packageBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	created, err := service.CreateTask(ctx, StartCompileCommand{InputPath: input, Decompile: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = service.RunTask(ctx, created.ID)
+
+	payload, err := NewTaskQueryService(cfg, repo).GetNamedReport(ctx, created.ID, "security-report")
+	if err != nil {
+		t.Fatalf("security report unavailable: %v", err)
+	}
+	var securityReport struct {
+		Domains     []string       `json:"domains"`
+		PrivacyAPIs map[string]int `json:"privacyApis"`
+		Permissions []string       `json:"permissions"`
+	}
+	if err := json.Unmarshal(payload, &securityReport); err != nil {
+		t.Fatal(err)
+	}
+	if len(securityReport.Permissions) != 1 || securityReport.PrivacyAPIs["wx.getLocation"] == 0 || len(securityReport.Domains) == 0 {
+		t.Fatalf("security report = %s", payload)
+	}
+
+	payload, err = NewTaskQueryService(cfg, repo).GetNamedReport(ctx, created.ID, "api-inventory")
+	if err != nil {
+		t.Fatalf("api inventory unavailable: %v", err)
+	}
+	var inventory struct {
+		Hosts []struct {
+			Host      string `json:"host"`
+			Endpoints []struct {
+				URL   string   `json:"url"`
+				Pages []string `json:"pages"`
+			} `json:"endpoints"`
+		} `json:"hosts"`
+	}
+	if err := json.Unmarshal(payload, &inventory); err != nil {
+		t.Fatal(err)
+	}
+	if len(inventory.Hosts) != 1 || inventory.Hosts[0].Host != "api.example.com" || len(inventory.Hosts[0].Endpoints) != 1 ||
+		strings.Join(inventory.Hosts[0].Endpoints[0].Pages, ",") != "pages/index/index" {
+		t.Fatalf("api inventory = %s", payload)
+	}
+	if payload, err = NewTaskQueryService(cfg, repo).GetNamedReport(ctx, created.ID, "api-inventory-openapi"); err != nil || !strings.Contains(string(payload), `"/user"`) {
+		t.Fatalf("openapi skeleton = %s, %v", payload, err)
+	}
+	stored, err := repo.Get(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inventoryStep, graphStep := false, false
+	for _, stage := range stored.StageResults {
+		inventoryStep = inventoryStep || (stage.Stage == task.StageAPIInventory && stage.Success && stage.Metrics["apiEndpoints"] == 1)
+		graphStep = graphStep || (stage.Stage == task.StageDependencyGraph && stage.Success && stage.Metrics["report"] == "dependency-graph.json")
+	}
+	if !inventoryStep || !graphStep {
+		t.Fatalf("stage results = %+v, want successful API inventory and dependency graph sub-steps", stored.StageResults)
+	}
+	graph, err := NewTaskQueryService(cfg, repo).GetDependencyGraph(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("dependency graph unavailable: %v", err)
+	}
+	pageFound := false
+	for _, node := range graph.Nodes {
+		pageFound = pageFound || (node.ID == "pages/index/index" && node.Kind == "page")
+	}
+	if !pageFound {
+		t.Fatalf("dependency graph nodes = %+v", graph.Nodes)
+	}
+}
+
+func TestPipelineMatchesAppIDsAgainstTheWholePackage(t *testing.T) {
+	const appID = "wx0123456789abcdef"
+	plain := testutil.MustBuildWxapkg(map[string]string{
+		"app-config.json": `{"pages":["pages/index/index"]}`,
+		"app-service.js":  `define("pages/index/index.js", function(){ Page({ data: { text: "` + strings.Repeat("0123456789abcdef", 128) + `" } }); });`,
+		"page-frame.html": `<html></html>`,
+	})
+	encrypted, err := dec.EncryptWxapkg(plain, appID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encrypted) <= len(dec.FileHeader)+1024 {
+		t.Fatalf("package of %d bytes fits in the head classification reads", len(encrypted))
+	}
+	for name, cmd := range map[string]StartCompileCommand{
+		// The right AppID is only in the candidate list.
+		"search": {SearchAppID: true},
+		// The candidate list misses it, so dropping the path AppID fails the task.
+		"path": {SearchAppID: true, SourcePath: "Applet/" + appID + "/12/__APP__.wxapkg"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			candidates := "wxffffffffffffffff\n"
+			if name == "search" {
+				candidates += appID + "\n"
+			}
+			candidatesFile := filepath.Join(t.TempDir(), "appids.txt")
+			if err := os.WriteFile(candidatesFile, []byte(candidates), 0600); err != nil {
+				t.Fatal(err)
+			}
+			input := filepath.Join(t.TempDir(), "__APP__.wxapkg")
+			if err := os.WriteFile(input, encrypted, 0600); err != nil {
+				t.Fatal(err)
+			}
+			cfg := &config.Config{TempDir: t.TempDir(), OutputDir: t.TempDir(), AppIDCandidatesFile: candidatesFile}
+			repo := persistence.NewMemoryTaskRepo()
+			service := NewCompileService(cfg, repo, events.NewBroker(), &recordingQueue{})
+			ctx := context.Background()
+			cmd.InputPath = input
+			created, err := service.CreateTask(ctx, cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = service.RunTask(ctx, created.ID)
+
+			stored, err := repo.Get(ctx, created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decrypted := false
+			for _, stage := range stored.StageResults {
+				decrypted = decrypted || (stage.Stage == string(task.TaskDecrypting) && stage.Success && stage.Metrics["appIdSource"] == name)
+			}
+			if !decrypted || stored.Status == task.TaskFailed {
+				t.Fatalf("status = %s, stage results = %+v", stored.Status, stored.StageResults)
+			}
+		})
+	}
+}
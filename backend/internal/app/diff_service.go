@@ -0,0 +1,260 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/keepbuild/seewxapkg/internal/config"
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	"github.com/keepbuild/seewxapkg/internal/pipeline/normalize"
+	"github.com/keepbuild/seewxapkg/internal/report"
+)
+
+var (
+	ErrDiffNotFound     = errors.New("diff not found")
+	ErrDiffTaskNotFound = errors.New("diff task not found")
+)
+
+// StartDiffCommand names the two builds either as packages, which are
+// compiled first, or as existing task IDs.
+type StartDiffCommand struct {
+	// AppID is shared by both packages: they are builds of one mini program.
+	AppID           string
+	Decompile       bool
+	RemoveGuideHTML bool
+	Base            *multipart.FileHeader
+	Head            *multipart.FileHeader
+	// BasePath and HeadPath are used instead of the uploads by local callers.
+	BasePath   string
+	HeadPath   string
+	BaseTaskID string
+	HeadTaskID string
+}
+
+type DiffService struct {
+	cfg     *config.Config
+	repo    task.Repository
+	compile *CompileService
+	// building serializes report computation so concurrent polls of a
+	// freshly finished diff compute it once.
+	building sync.Mutex
+}
+
+func NewDiffService(cfg *config.Config, repo task.Repository, compile *CompileService) *DiffService {
+	return &DiffService{cfg: cfg, repo: repo, compile: compile}
+}
+
+func (s *DiffService) Readiness() error {
+	_, err := s.compile.Readiness()
+	return err
+}
+
+// StartDiff records a diff between two builds. Packages are compiled with
+// the final formatting stage forced on, so the text diffs compare beautified
+// sources; existing tasks are used as they are.
+func (s *DiffService) StartDiff(ctx context.Context, cmd StartDiffCommand) (*task.Diff, error) {
+	diff := &task.Diff{ID: uuid.New().String(), CreatedAt: time.Now()}
+	if cmd.BaseTaskID != "" || cmd.HeadTaskID != "" {
+		for _, taskID := range []string{cmd.BaseTaskID, cmd.HeadTaskID} {
+			if !isCanonicalUUID(taskID) {
+				return nil, ErrDiffTaskNotFound
+			}
+			if _, err := s.repo.Get(ctx, taskID); err != nil {
+				return nil, ErrDiffTaskNotFound
+			}
+		}
+		diff.BaseTaskID, diff.HeadTaskID = cmd.BaseTaskID, cmd.HeadTaskID
+	} else {
+		base, err := s.startSide(ctx, cmd, cmd.Base, cmd.BasePath)
+		if err != nil {
+			return nil, fmt.Errorf("start base task: %w", err)
+		}
+		head, err := s.startSide(ctx, cmd, cmd.Head, cmd.HeadPath)
+		if err != nil {
+			return nil, fmt.Errorf("start head task: %w", err)
+		}
+		diff.BaseTaskID, diff.HeadTaskID = base.ID, head.ID
+	}
+
+	recordPath := storage.DiffRecordPath(s.cfg.TempDir, diff.ID)
+	if err := os.MkdirAll(filepath.Dir(recordPath), 0700); err != nil {
+		return nil, err
+	}
+	if err := storage.WriteJSON(recordPath, diff); err != nil {
+		return nil, fmt.Errorf("persist diff: %w", err)
+	}
+	return diff, nil
+}
+
+func (s *DiffService) startSide(ctx context.Context, cmd StartDiffCommand, file *multipart.FileHeader, path string) (*task.Task, error) {
+	return s.compile.StartTask(ctx, StartCompileCommand{
+		AppID:           cmd.AppID,
+		Beautify:        true,
+		Decompile:       cmd.Decompile,
+		RemoveGuideHTML: cmd.RemoveGuideHTML,
+		File:            file,
+		InputPath:       path,
+	})
+}
+
+func (s *DiffService) GetDiff(diffID string) (*task.Diff, error) {
+	if !isCanonicalUUID(diffID) {
+		return nil, ErrDiffNotFound
+	}
+	data, err := os.ReadFile(storage.DiffRecordPath(s.cfg.TempDir, diffID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrDiffNotFound
+		}
+		return nil, err
+	}
+	var diff task.Diff
+	if err := json.Unmarshal(data, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// GetDiffReport reports "pending" until both tasks are terminal. The first
+// call after that computes the comparison from their delivered results and
+// stores it; later calls serve the stored report.
+func (s *DiffService) GetDiffReport(ctx context.Context, diffID string) (*report.DiffReport, error) {
+	diff, err := s.GetDiff(diffID)
+	if err != nil {
+		return nil, err
+	}
+	if stored, err := s.storedReport(diff.ID); err != nil || stored != nil {
+		return stored, err
+	}
+	pending := &report.DiffReport{
+		DiffID:     diff.ID,
+		CreatedAt:  diff.CreatedAt,
+		BaseTaskID: diff.BaseTaskID,
+		HeadTaskID: diff.HeadTaskID,
+	}
+	sides := make([]*task.Task, 0, 2)
+	for _, taskID := range []string{diff.BaseTaskID, diff.HeadTaskID} {
+		current, err := s.repo.Get(ctx, taskID)
+		if err != nil {
+			pending.Status = "expired"
+			pending.Message = "对比任务已过期"
+			return pending, nil
+		}
+		sides = append(sides, current)
+	}
+	for _, current := range sides {
+		switch current.Status {
+		case task.TaskFailed:
+			pending.Status = "failed"
+			pending.Message = "对比任务恢复失败，无法生成差异报告"
+			return pending, nil
+		case task.TaskCancelled:
+			pending.Status = "failed"
+			pending.Message = "对比任务已取消，无法生成差异报告"
+			return pending, nil
+		case task.TaskCompleted, task.TaskPartial:
+		default:
+			pending.Status = "pending"
+			return pending, nil
+		}
+	}
+
+	s.building.Lock()
+	defer s.building.Unlock()
+	if stored, err := s.storedReport(diff.ID); err != nil || stored != nil {
+		return stored, err
+	}
+	base, err := s.loadSide(sides[0])
+	if err != nil {
+		return nil, err
+	}
+	head, err := s.loadSide(sides[1])
+	if err != nil {
+		return nil, err
+	}
+	result, err := report.BuildDiffReport(*base, *head)
+	if err != nil {
+		return nil, err
+	}
+	result.DiffID = diff.ID
+	result.CreatedAt = diff.CreatedAt
+	reportPath := storage.DiffReportPath(s.cfg.TempDir, diff.ID)
+	if err := os.MkdirAll(filepath.Dir(reportPath), 0700); err != nil {
+		return nil, err
+	}
+	if err := storage.WriteJSON(reportPath, result); err != nil {
+		return nil, fmt.Errorf("persist diff report: %w", err)
+	}
+	return result, nil
+}
+
+// storedReport returns the report written for diffID, or nil before one
+// has been computed.
+func (s *DiffService) storedReport(diffID string) (*report.DiffReport, error) {
+	data, err := os.ReadFile(storage.DiffReportPath(s.cfg.TempDir, diffID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored report.DiffReport
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (s *DiffService) loadSide(t *task.Task) (*report.DiffSide, error) {
+	if !safePathComponent(t.ID) {
+		return nil, ErrDiffTaskNotFound
+	}
+	root := filepath.Join(s.cfg.TempDir, t.ID, "result")
+	return LoadDiffSide(t, filepath.Join(root, "src"), filepath.Join(root, "reports"))
+}
+
+// LoadDiffSide reads one finished task's zip-manifest and re-normalizes its
+// delivered source tree for the route and component comparison. The CLI
+// calls it on exported results.
+func LoadDiffSide(t *task.Task, sourceDir, reportsDir string) (*report.DiffSide, error) {
+	data, err := os.ReadFile(filepath.Join(reportsDir, "zip-manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("read zip-manifest: %w", err)
+	}
+	var manifest report.ZipManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parse zip-manifest: %w", err)
+	}
+	files := make([]string, 0, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		files = append(files, strings.TrimPrefix(entry, "src/"))
+	}
+
+	profile := t.PackageProfile
+	if profile == nil {
+		profile = &pkg.PackageProfile{}
+	}
+	normalized, err := normalize.NormalizePackage(sourceDir, profile)
+	if err != nil {
+		return nil, fmt.Errorf("normalize source tree: %w", err)
+	}
+	return &report.DiffSide{
+		TaskID:     t.ID,
+		Files:      files,
+		SourceDir:  sourceDir,
+		Manifest:   normalized.Manifest,
+		Pages:      normalized.Pages,
+		Beautified: t.RequestedOptions.Beautify,
+	}, nil
+}
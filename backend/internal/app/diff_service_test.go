@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/keepbuild/seewxapkg/internal/config"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/events"
+	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+)
+
+func TestStartDiffQueuesBothBuildsWithFormattingAndReportsPending(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{TempDir: tempDir, OutputDir: t.TempDir(), MaxUploadSize: 1024}
+	repo := persistence.NewMemoryTaskRepo()
+	jobQueue := &recordingQueue{}
+	service := NewDiffService(cfg, repo, NewCompileService(cfg, repo, events.NewBroker(), jobQueue))
+	input := t.TempDir()
+	paths := []string{filepath.Join(input, "v1.wxapkg"), filepath.Join(input, "v2.wxapkg")}
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte("package"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const appID = "wx0123456789abcdef"
+
+	diff, err := service.StartDiff(context.Background(), StartDiffCommand{AppID: appID, BasePath: paths[0], HeadPath: paths[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobQueue.taskIDs) != 2 || jobQueue.taskIDs[0] != diff.BaseTaskID || jobQueue.taskIDs[1] != diff.HeadTaskID {
+		t.Fatalf("diff tasks were not queued in order: %#v %#v", diff, jobQueue.taskIDs)
+	}
+	for _, taskID := range jobQueue.taskIDs {
+		current, err := repo.Get(context.Background(), taskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !current.RequestedOptions.Beautify {
+			t.Fatal("diff tasks must run the final formatting stage")
+		}
+	}
+	record, err := os.ReadFile(storage.DiffRecordPath(tempDir, diff.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(record), appID) {
+		t.Fatal("diff record persisted the AppID")
+	}
+
+	result, err := service.GetDiffReport(context.Background(), diff.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != "pending" || result.Files != nil {
+		t.Fatalf("unfinished tasks must yield a pending report: %#v", result)
+	}
+}
+
+func TestStartDiffRejectsUnknownTaskIDs(t *testing.T) {
+	service := NewDiffService(&config.Config{TempDir: t.TempDir()}, persistence.NewMemoryTaskRepo(), nil)
+	_, err := service.StartDiff(context.Background(), StartDiffCommand{
+		BaseTaskID: "00000000-0000-0000-0000-000000000000",
+		HeadTaskID: "../task-state/x",
+	})
+	if !errors.Is(err, ErrDiffTaskNotFound) {
+		t.Fatalf("err = %v, want ErrDiffTaskNotFound", err)
+	}
+	if _, err := service.GetDiff("not-a-diff"); !errors.Is(err, ErrDiffNotFound) {
+		t.Fatalf("GetDiff err = %v, want ErrDiffNotFound", err)
+	}
+}
+
+func TestGetDiffReportStoresTheFinishedReport(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{TempDir: tempDir}
+	repo := persistence.NewMemoryTaskRepo()
+	service := NewDiffService(cfg, repo, nil)
+	ctx := context.Background()
+	ids := []string{"00000000-0000-4000-8000-00000000000a", "00000000-0000-4000-8000-00000000000b"}
+	for index, id := range ids {
+		root := filepath.Join(tempDir, id, "result")
+		files := map[string]string{
+			"src/app.json":              `{"pages":["pages/index/index"]}`,
+			"src/pages/index/index.js":  []string{"Page({})", "Page({ data: {} })"}[index],
+			"reports/zip-manifest.json": `{"files":["src/app.json","src/pages/index/index.js"]}`,
+		}
+		for name, content := range files {
+			path := filepath.Join(root, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := repo.Create(ctx, &task.Task{ID: id, Status: task.TaskCompleted}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	diff, err := service.StartDiff(ctx, StartDiffCommand{BaseTaskID: ids[0], HeadTaskID: ids[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := service.GetDiffReport(ctx, diff.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != "ready" || first.Summary == nil || first.Summary.FilesModified != 1 {
+		t.Fatalf("report = %#v", first)
+	}
+	if _, err := os.Stat(storage.DiffReportPath(tempDir, diff.ID)); err != nil {
+		t.Fatalf("finished report was not stored: %v", err)
+	}
+	// Polls after the first must not touch the result trees again.
+	if err := os.RemoveAll(filepath.Join(tempDir, ids[1], "result")); err != nil {
+		t.Fatal(err)
+	}
+	second, err := service.GetDiffReport(ctx, diff.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Status != "ready" || second.Summary.FilesModified != 1 || len(second.TextDiffs) != len(first.TextDiffs) {
+		t.Fatalf("stored report = %#v", second)
+	}
+}
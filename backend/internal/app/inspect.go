@@ -0,0 +1,54 @@
+package app
+
+import (
+	"errors"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/pipeline/classifier"
+	dec "github.com/keepbuild/seewxapkg/internal/pipeline/decrypt"
+	legacyservice "github.com/keepbuild/seewxapkg/internal/service"
+)
+
+// ErrUnreadablePackage means the header or index could not be parsed; for an
+// encrypted package this usually points at a wrong AppID.
+var ErrUnreadablePackage = errors.New("package index is unreadable")
+
+// PackageInspection is the read-only view behind POST /api/inspect and
+// `seewxapkg inspect`.
+type PackageInspection struct {
+	*legacyservice.InspectResult
+	Profile *pkg.PackageProfile `json:"profile"`
+}
+
+// InspectPackage parses a package's header and index in memory. Nothing is
+// written to disk and no Node stage runs. An encrypted package is decrypted
+// only when an AppID is given; otherwise only its header is reported.
+func InspectPackage(data []byte, appID string) (*PackageInspection, error) {
+	encrypted := dec.IsEncrypted(data)
+	if !encrypted && !dec.IsDecrypted(data) {
+		return nil, dec.ErrInvalidHeader
+	}
+	if encrypted && appID == "" {
+		return &PackageInspection{
+			InspectResult: &legacyservice.InspectResult{Format: "V1MMWX", Encrypted: true, Size: len(data)},
+			Profile:       classifier.DetectPackageProfileFromIndex(data, nil),
+		}, nil
+	}
+
+	plain := data
+	if encrypted {
+		var err error
+		if plain, err = dec.DecryptWxapkg(data, appID); err != nil {
+			return nil, err
+		}
+	}
+	result, err := legacyservice.InspectWxapkg(plain)
+	if err != nil {
+		return nil, errors.Join(ErrUnreadablePackage, err)
+	}
+	result.Encrypted = encrypted
+	result.Size = len(data)
+	profile := classifier.DetectPackageProfileFromIndex(plain, result.EntryNames())
+	profile.IsEncrypted = encrypted
+	return &PackageInspection{InspectResult: result, Profile: profile}, nil
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/keepbuild/seewxapkg/internal/config"
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	"github.com/keepbuild/seewxapkg/internal/report"
+	legacyservice "github.com/keepbuild/seewxapkg/internal/service"
+	"github.com/keepbuild/seewxapkg/internal/version"
+)
+
+// cachedResult is the record kept next to a cached result: what the
+// finished task reported, minus its ID, stages and anything about how its
+// package was decrypted.
+type cachedResult struct {
+	Status      task.TaskStatus     `json:"status"`
+	Code        string              `json:"code,omitempty"`
+	Message     string              `json:"message"`
+	Profile     *pkg.PackageProfile `json:"profile,omitempty"`
+	Score       *task.RecoveryScore `json:"score,omitempty"`
+	Diagnostics []pkg.Diagnostic    `json:"diagnostics,omitempty"`
+	Files       []task.ArtifactFile `json:"files,omitempty"`
+}
+
+func newResultCache(cfg *config.Config) *storage.ResultCache {
+	if !cfg.ResultCacheEnabled {
+		return nil
+	}
+	return storage.NewResultCache(cfg.TempDir, time.Duration(cfg.RetainArtifactsHours)*time.Hour)
+}
+
+// resultCacheKey covers the decrypted main package and subpackages, in
+// order, together with the requested options, the server switches that
+// change the output and the tool version.
+func (s *CompileService) resultCacheKey(t *task.Task, plain legacyservice.PackageSource, subPackages []legacyservice.PackageSource) (string, error) {
+	options := t.RequestedOptions
+	parts := []string{
+		version.Version,
+		fmt.Sprintf("beautify=%t decompile=%t removeGuideHtml=%t", options.Beautify, options.Decompile, options.RemoveGuideHTML),
+		fmt.Sprintf("formatter=%t deobfuscate=%t native=%t fallback=%t verify=%t report=%t",
+			s.cfg.BeautifyEnabled, s.cfg.DeobfuscateEnabled, s.cfg.NativeRecoverEnabled,
+			s.cfg.FallbackRecoverEnabled, s.cfg.VerificationEnabled, s.cfg.ReportEnabled),
+	}
+	for _, src := range append([]legacyservice.PackageSource{plain}, subPackages...) {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, io.NewSectionReader(src, 0, src.Size())); err != nil {
+			return "", err
+		}
+		parts = append(parts, hex.EncodeToString(hash.Sum(nil)))
+	}
+	return storage.ResultCacheKey(parts...), nil
+}
+
+// lookupCachedResult restores an earlier result for the same input into the
+// task workspace. On a miss it returns the key this task's result should be
+// stored under; an empty key leaves the task uncached.
+func (s *CompileService) lookupCachedResult(t *task.Task, plain legacyservice.PackageSource, subPackages []legacyservice.PackageSource, dirs storage.TaskDirs) (string, *cachedResult) {
+	if s.cache == nil {
+		return "", nil
+	}
+	key, err := s.resultCacheKey(t, plain, subPackages)
+	if err != nil {
+		log.Printf("[Cache] hash input failed (%T)", err)
+		return "", nil
+	}
+	if err := storage.SaveResultCacheKey(dirs, key); err != nil {
+		// Without the key, deleting the task leaves its cached copy to expire.
+		log.Printf("[Cache] record cache key failed (%T)", err)
+	}
+	cached := &cachedResult{}
+	zipPath := filepath.Join(s.cfg.OutputDir, t.ID+".zip")
+	hit, err := s.cache.Load(key, dirs, zipPath, cached)
+	if err == nil && hit {
+		return key, cached
+	}
+	if err != nil {
+		// A half-restored entry must not leak into the regular run.
+		log.Printf("[Cache] restore failed (%T)", err)
+		if resetErr := errors.Join(storage.ResetTaskWorkspace(dirs), removeIfExists(zipPath)); resetErr != nil {
+			log.Printf("[Cache] reset after failed restore failed (%T)", resetErr)
+			return "", nil
+		}
+	}
+	return key, nil
+}
+
+// finishFromCache completes a task whose result was restored from the
+// cache. The task keeps its own classify and decrypt stages; packaging
+// records the hit.
+func (s *CompileService) finishFromCache(ctx context.Context, t *task.Task, dirs storage.TaskDirs, cached *cachedResult) error {
+	s.beginStage(ctx, t, task.TaskPackaging, 94, "已找到相同输入的处理结果，正在复用...")
+	if cached.Profile != nil {
+		profile := *cached.Profile
+		if t.PackageProfile != nil {
+			profile.IsEncrypted = t.PackageProfile.IsEncrypted
+		}
+		t.PackageProfile = &profile
+	}
+	t.RecoveryScore = cached.Score
+	t.Diagnostics = dedupeDiagnostics(append(t.Diagnostics, cached.Diagnostics...))
+
+	zipManifestPath := filepath.Join(dirs.ReportsDir, "zip-manifest.json")
+	var zipManifest report.ZipManifest
+	data, err := os.ReadFile(zipManifestPath)
+	if err == nil {
+		err = json.Unmarshal(data, &zipManifest)
+	}
+	if err != nil {
+		return s.markFailed(ctx, t, "cache_restore_failed", "复用缓存结果失败", err)
+	}
+	zipManifest.TaskID = t.ID
+	if err := report.WriteZipManifest(zipManifestPath, &zipManifest); err != nil {
+		return s.markFailed(ctx, t, "cache_restore_failed", "复用缓存结果失败", err)
+	}
+
+	reportPath := filepath.Join(dirs.ReportsDir, "recovery-report.json")
+	diagnosticsPath := filepath.Join(dirs.ReportsDir, "diagnostics.json")
+	t.ArtifactSummary = s.buildArtifactSummary(t, dirs, reportPath, diagnosticsPath, cached.Files)
+	s.refreshArtifactSummary(t)
+	s.finishStage(ctx, t, string(task.TaskPackaging), true, false, "已复用相同输入的处理结果", map[string]interface{}{
+		"cacheHit":      true,
+		"archiveFile":   filepath.Base(t.ArtifactSummary.ZipPath),
+		"archiveSize":   t.ArtifactSummary.ArchiveSize,
+		"downloadReady": t.ArtifactSummary.DownloadReady,
+		"zipManifest":   "report?name=zip-manifest",
+		"archiveRoot":   "src/",
+	}, nil)
+	return s.finalizeTask(ctx, t, cached.Status, cached.Code, cached.Message, nil)
+}
+
+// storeCachedResult keeps a finished, packaged result for later tasks with
+// the same key. Failed tasks are never cached, and a cache failure never
+// changes the outcome of the task.
+func (s *CompileService) storeCachedResult(key string, t *task.Task, dirs storage.TaskDirs, status task.TaskStatus, code, message string) {
+	if s.cache == nil || key == "" || status == task.TaskFailed || t.ArtifactSummary == nil {
+		return
+	}
+	diagnostics := make([]pkg.Diagnostic, 0, len(t.Diagnostics))
+	for _, diagnostic := range t.Diagnostics {
+		if diagnostic.Stage != "decrypting" {
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	record := &cachedResult{
+		Status:      status,
+		Code:        code,
+		Message:     message,
+		Profile:     t.PackageProfile,
+		Score:       t.RecoveryScore,
+		Diagnostics: report.SanitizeDiagnostics(diagnostics),
+		Files:       t.ArtifactSummary.Files,
+	}
+	if err := s.cache.Store(key, dirs, filepath.Join(s.cfg.OutputDir, t.ID+".zip"), record); err != nil {
+		log.Printf("[Cache] store result failed (%T)", err)
+	}
+}
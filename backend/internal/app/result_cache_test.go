@@ -0,0 +1,108 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/keepbuild/seewxapkg/internal/config"
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/events"
+	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	"github.com/keepbuild/seewxapkg/internal/report"
+)
+
+func TestResultCacheServesSameInputToNewTask(t *testing.T) {
+	cfg := &config.Config{TempDir: t.TempDir(), OutputDir: t.TempDir(), ResultCacheEnabled: true, RetainArtifactsHours: 24}
+	repo := persistence.NewMemoryTaskRepo()
+	service := NewCompileService(cfg, repo, events.NewBroker(), nil)
+	packageBytes := []byte("plaintext package bytes")
+	options := task.RequestedOptions{Beautify: true, Decompile: true, RemoveGuideHTML: true}
+
+	now := time.Now()
+	first := &task.Task{ID: "first-task", Status: task.TaskPackaging, RequestedOptions: options, CreatedAt: now, UpdatedAt: now}
+	firstDirs, err := storage.EnsureTaskDirs(cfg.TempDir, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, cached := service.lookupCachedResult(first, bytes.NewReader(packageBytes), nil, firstDirs)
+	if key == "" || cached != nil {
+		t.Fatalf("empty cache lookup = %q, %#v", key, cached)
+	}
+	if err := os.WriteFile(filepath.Join(firstDirs.SourceDir, "app.json"), []byte(`{"pages":["pages/index"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := report.WriteZipManifest(filepath.Join(firstDirs.ReportsDir, "zip-manifest.json"), &report.ZipManifest{TaskID: first.ID, Files: []string{"src/app.json"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.OutputDir, first.ID+".zip"), []byte("zip"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	first.PackageProfile = &pkg.PackageProfile{SuspectedVariant: "wechat4x", IsEncrypted: true}
+	first.RecoveryScore = &task.RecoveryScore{Overall: 87}
+	first.ArtifactSummary = &task.ArtifactSummary{Files: []task.ArtifactFile{{Path: "src/app.json", Kind: "json", Source: "manifest"}}}
+	first.Diagnostics = []pkg.Diagnostic{
+		pkg.Info("decrypt.appid.searched", "已从候选 AppID 列表中找到可解密此包的 AppID", "decrypting", ""),
+		pkg.Warn("recover.wxml.partial", "部分模板未能恢复", "recovering_wxml", "pages/index.wxml"),
+	}
+	service.storeCachedResult(key, first, firstDirs, task.TaskPartial, "", "已整理 1 个源码文件")
+
+	other := &task.Task{ID: "other-task", RequestedOptions: task.RequestedOptions{Beautify: true}}
+	if otherKey, cached := service.lookupCachedResult(other, bytes.NewReader(packageBytes), nil, firstDirs); otherKey == key || cached != nil {
+		t.Fatal("different options must not share a cache entry")
+	}
+
+	second := &task.Task{ID: "second-task", Status: task.TaskDecrypting, RequestedOptions: options, CreatedAt: now, UpdatedAt: now,
+		PackageProfile: &pkg.PackageProfile{SuspectedVariant: "standard"}}
+	if err := repo.Create(context.Background(), second); err != nil {
+		t.Fatal(err)
+	}
+	secondDirs, err := storage.EnsureTaskDirs(cfg.TempDir, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondKey, cached := service.lookupCachedResult(second, bytes.NewReader(packageBytes), nil, secondDirs)
+	if secondKey != key || cached == nil {
+		t.Fatal("same input and options must hit the cache")
+	}
+	if err := service.finishFromCache(context.Background(), second, secondDirs, cached); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := repo.Get(context.Background(), second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != task.TaskPartial || stored.RecoveryScore == nil || stored.RecoveryScore.Overall != 87 {
+		t.Fatalf("cached outcome not applied: %s %#v", stored.Status, stored.RecoveryScore)
+	}
+	if stored.PackageProfile.SuspectedVariant != "wechat4x" || stored.PackageProfile.IsEncrypted {
+		t.Fatalf("profile = %#v; want cached variant with this task's encryption flag", stored.PackageProfile)
+	}
+	if !stored.ArtifactSummary.DownloadReady || stored.ArtifactSummary.FileCount != 1 {
+		t.Fatalf("artifacts = %#v", stored.ArtifactSummary)
+	}
+	last := stored.StageResults[len(stored.StageResults)-1]
+	if last.Stage != string(task.TaskPackaging) || last.Metrics["cacheHit"] != true {
+		t.Fatalf("packaging stage did not record the hit: %#v", last)
+	}
+	for _, diagnostic := range stored.Diagnostics {
+		if diagnostic.Stage == "decrypting" {
+			t.Fatal("the first task's decrypt diagnostics were replayed")
+		}
+	}
+	var manifest report.ZipManifest
+	data, err := os.ReadFile(filepath.Join(secondDirs.ReportsDir, "zip-manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.TaskID != second.ID {
+		t.Fatalf("zip manifest task = %q, %v", manifest.TaskID, err)
+	}
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	"github.com/keepbuild/seewxapkg/internal/pipeline/classifier"
+	dec "github.com/keepbuild/seewxapkg/internal/pipeline/decrypt"
+	legacyservice "github.com/keepbuild/seewxapkg/internal/service"
+)
+
+// MaxSubPackages bounds how many subpackages one compile task accepts.
+const MaxSubPackages = 100
+
+const maxMergeConflictDiagnostics = 10
+
+type subPackageMergeResult struct {
+	Added     int
+	Identical int
+	Conflicts []string
+	Plugins   []string
+}
+
+// openSubPackages opens every staged subpackage for streaming decryption
+// with the AppID of the main package; subpackages of one mini program are
+// encrypted with the same key. closeAll releases the files and is safe to
+// call more than once.
+func openSubPackages(dirs storage.TaskDirs, appID string) (sources []legacyservice.PackageSource, closeAll func(), err error) {
+	var files []*os.File
+	closeAll = func() {
+		for _, file := range files {
+			_ = file.Close()
+		}
+		files = nil
+	}
+	paths, err := storage.SubPackageInputPaths(dirs)
+	if err != nil {
+		return nil, closeAll, err
+	}
+	sources = make([]legacyservice.PackageSource, 0, len(paths))
+	for index, path := range paths {
+		file, size, err := openPackageFile(path)
+		if err != nil {
+			closeAll()
+			return nil, closeAll, fmt.Errorf("read subpackage %d: %w", index+1, err)
+		}
+		files = append(files, file)
+		plain, err := dec.NewPackageReader(file, size, appID)
+		if err != nil {
+			closeAll()
+			return nil, closeAll, fmt.Errorf("decrypt subpackage %d: %w", index+1, err)
+		}
+		sources = append(sources, plain)
+	}
+	return sources, closeAll, nil
+}
+
+// mergeSubPackages unpacks each subpackage into a scratch directory and folds
+// it into the main source tree. Subpackage wxapkg entries already carry their
+// `subPackages[].root` prefix, so a plain overlay lines their pages up with
+// app.json. Files from the main package win; differing duplicates are kept
+// out and reported. A plugin package supplied next to its host app goes under
+// plugin/ instead, the plugin root of a developer tools project.
+func mergeSubPackages(subPackages []legacyservice.PackageSource, dirs storage.TaskDirs) (*subPackageMergeResult, []pkg.Diagnostic, error) {
+	scratchRoot := filepath.Join(dirs.RootDir, "subpackages")
+	defer func() { _ = os.RemoveAll(scratchRoot) }()
+
+	result := &subPackageMergeResult{}
+	for index, src := range subPackages {
+		scratchDir := filepath.Join(scratchRoot, fmt.Sprintf("%03d", index))
+		if _, err := legacyservice.UnpackPackage(src, scratchDir, false); err != nil {
+			return nil, nil, fmt.Errorf("unpack subpackage %d: %w", index+1, err)
+		}
+		targetDir, overlayDir := dirs.SourceDir, scratchDir
+		if profile, err := classifier.DetectPackageProfile(packageHead(src), scratchDir); err == nil && profile.IsPluginPackage {
+			targetDir, overlayDir = filepath.Join(dirs.SourceDir, "plugin"), pluginCodeDir(scratchDir, profile.PluginAppID)
+			result.Plugins = append(result.Plugins, profile.PluginAppID)
+		}
+		if err := overlayMissingFiles(targetDir, overlayDir, result); err != nil {
+			return nil, nil, fmt.Errorf("merge subpackage %d: %w", index+1, err)
+		}
+		if err := os.RemoveAll(scratchDir); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	diagnostics := []pkg.Diagnostic{
+		pkg.Info("unpack.subpackages.merged", fmt.Sprintf("已将 %d 个分包合并到主包源码目录", len(subPackages)), "unpacking", ""),
+	}
+	for _, appID := range result.Plugins {
+		diagnostic := pkg.Info("unpack.plugin.merged", "已将插件包源码放入 plugin/ 目录", "unpacking", "plugin")
+		diagnostic.Metadata = map[string]interface{}{"pluginAppId": appID}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	for index, conflict := range result.Conflicts {
+		if index >= maxMergeConflictDiagnostics {
+			break
+		}
+		diagnostics = append(diagnostics, pkg.Warn("unpack.subpackages.conflict", "分包文件与主包同名文件内容不同，已保留主包版本", "unpacking", conflict))
+	}
+	return result, diagnostics, nil
+}
+
+// pluginCodeDir is the directory of an unpacked plugin package that holds
+// its code: __plugin__/<appid> when the package nests it there.
+func pluginCodeDir(unpackedDir, appID string) string {
+	if appID != "" {
+		nested := filepath.Join(unpackedDir, "__plugin__", appID)
+		if info, err := os.Stat(nested); err == nil && info.IsDir() {
+			return nested
+		}
+	}
+	return unpackedDir
+}
+
+func overlayMissingFiles(targetDir, overlayDir string, result *subPackageMergeResult) error {
+	return filepath.WalkDir(overlayDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(overlayDir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(targetDir, rel)
+		existing, err := os.ReadFile(target)
+		switch {
+		case err == nil && bytes.Equal(existing, data):
+			result.Identical++
+			return nil
+		case err == nil:
+			result.Conflicts = append(result.Conflicts, filepath.ToSlash(rel))
+			return nil
+		case !errors.Is(err, fs.ErrNotExist):
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0600); err != nil {
+			return err
+		}
+		result.Added++
+		return nil
+	})
+}
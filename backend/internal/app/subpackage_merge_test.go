@@ -0,0 +1,88 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	legacyservice "github.com/keepbuild/seewxapkg/internal/service"
+	"github.com/keepbuild/seewxapkg/tests/testutil"
+)
+
+func TestMergeSubPackagesKeepsMainPackageFilesOnConflict(t *testing.T) {
+	dirs, err := storage.EnsureTaskDirs(t.TempDir(), "task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"app.json":            `{"pages":["pages/home/index"]}`,
+		"pages/home/index.js": `Page({})`,
+	} {
+		path := filepath.Join(dirs.SourceDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	subPackage := testutil.MustBuildWxapkg(map[string]string{
+		"app.json":                       `{"pages":[]}`,
+		"pages/home/index.js":            `Page({})`,
+		"packageA/pages/detail/index.js": `Page({detail: true})`,
+	})
+
+	result, diagnostics, err := mergeSubPackages([]legacyservice.PackageSource{bytes.NewReader(subPackage)}, dirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 1 || result.Identical != 1 || len(result.Conflicts) != 1 || result.Conflicts[0] != "app.json" {
+		t.Fatalf("unexpected merge result: %#v", result)
+	}
+	if len(diagnostics) != 2 || diagnostics[1].Code != "unpack.subpackages.conflict" {
+		t.Fatalf("conflict was not reported: %#v", diagnostics)
+	}
+	appJSON, err := os.ReadFile(filepath.Join(dirs.SourceDir, "app.json"))
+	if err != nil || string(appJSON) != `{"pages":["pages/home/index"]}` {
+		t.Fatalf("main package app.json was overwritten: %q %v", appJSON, err)
+	}
+	if _, err := os.Stat(filepath.Join(dirs.SourceDir, "packageA", "pages", "detail", "index.js")); err != nil {
+		t.Fatalf("subpackage page was not merged: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dirs.RootDir, "subpackages")); !os.IsNotExist(err) {
+		t.Fatalf("scratch directory must be removed: %v", err)
+	}
+}
+
+func TestMergeSubPackagesPlacesPluginUnderPluginRoot(t *testing.T) {
+	dirs, err := storage.EnsureTaskDirs(t.TempDir(), "task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin := testutil.MustBuildWxapkg(map[string]string{
+		"__plugin__/wxplugin/plugin.json":               `{"publicComponents":{"list":"components/list/list"}}`,
+		"__plugin__/wxplugin/components/list/list.js":   `Component({})`,
+		"__plugin__/wxplugin/components/list/list.wxml": `<view/>`,
+	})
+
+	result, diagnostics, err := mergeSubPackages([]legacyservice.PackageSource{bytes.NewReader(plugin)}, dirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Plugins) != 1 || result.Plugins[0] != "wxplugin" || result.Added != 3 {
+		t.Fatalf("unexpected merge result: %#v", result)
+	}
+	if len(diagnostics) != 2 || diagnostics[1].Code != "unpack.plugin.merged" {
+		t.Fatalf("plugin merge was not reported: %#v", diagnostics)
+	}
+	for _, name := range []string{"plugin/plugin.json", "plugin/components/list/list.js"} {
+		if _, err := os.Stat(filepath.Join(dirs.SourceDir, filepath.FromSlash(name))); err != nil {
+			t.Fatalf("%s was not placed under the plugin root: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dirs.SourceDir, "__plugin__")); !os.IsNotExist(err) {
+		t.Fatalf("plugin sources must not be merged into the host root: %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	"github.com/keepbuild/seewxapkg/internal/report"
+)
+
+// MaxTaskFileBytes caps a single file served by the source browser; larger
+// files are only available in the ZIP.
+const MaxTaskFileBytes = 2 * 1024 * 1024
+
+var (
+	ErrTaskFileNotFound = errors.New("task file not found")
+	ErrTaskFileTooLarge = errors.New("task file exceeds the browser size limit")
+)
+
+// TaskFile is one recovered source file with a content-derived ETag.
+type TaskFile struct {
+	Path    string
+	Content []byte
+	ETag    string
+}
+
+// TaskFileEntry describes one recovered file for the source browser: where
+// its content came from and the diagnostics that name it.
+type TaskFileEntry struct {
+	Path        string           `json:"path"`
+	Size        int64            `json:"size"`
+	Kind        string           `json:"kind,omitempty"`
+	Source      string           `json:"source,omitempty"`
+	Diagnostics []pkg.Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// GetTaskFile reads one file below the task's recovered source tree. The
+// name is a package path such as pages/index/index.wxml.
+func (s *TaskQueryService) GetTaskFile(ctx context.Context, taskID, name string) (*TaskFile, error) {
+	_, sourceDir, err := s.browsableSourceDir(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := storage.SafePackageOutputPath(sourceDir, name)
+	if err != nil {
+		return nil, ErrTaskFileNotFound
+	}
+	// Recovered trees never contain links; refuse to follow one out of the
+	// workspace if it ever appears.
+	resolved, err := filepath.EvalSymlinks(target)
+	if err != nil || !withinDir(sourceDir, resolved) {
+		return nil, ErrTaskFileNotFound
+	}
+	file, err := os.Open(resolved)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrTaskFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, ErrTaskFileNotFound
+	}
+	if info.Size() > MaxTaskFileBytes {
+		return nil, ErrTaskFileTooLarge
+	}
+	content, err := io.ReadAll(io.LimitReader(file, MaxTaskFileBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > MaxTaskFileBytes {
+		return nil, ErrTaskFileTooLarge
+	}
+	sum := sha256.Sum256(content)
+	rel, _ := filepath.Rel(sourceDir, resolved)
+	return &TaskFile{
+		Path:    filepath.ToSlash(rel),
+		Content: content,
+		ETag:    `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}, nil
+}
+
+// GetTaskSourceMap reads the source map written for one recovered file,
+// named by its path in the source tree.
+func (s *TaskQueryService) GetTaskSourceMap(ctx context.Context, taskID, name string) ([]byte, error) {
+	if _, _, err := s.browsableSourceDir(ctx, taskID); err != nil {
+		return nil, err
+	}
+	mapsDir := filepath.Join(storage.TaskDirsFor(s.cfg.TempDir, taskID).ReportsDir, "sourcemaps")
+	target, err := storage.SafePackageOutputPath(mapsDir, name+".map")
+	if err != nil {
+		return nil, ErrTaskFileNotFound
+	}
+	resolved, err := filepath.EvalSymlinks(target)
+	if err != nil || !withinDir(mapsDir, resolved) {
+		return nil, ErrTaskFileNotFound
+	}
+	info, err := os.Stat(resolved)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, ErrTaskFileNotFound
+	}
+	return os.ReadFile(resolved)
+}
+
+// GetTaskFileTree lists the recovered source tree, sorted by path. Kind and
+// source come from the artifact summary; files the summary does not list,
+// such as copied package resources, have neither.
+func (s *TaskQueryService) GetTaskFileTree(ctx context.Context, taskID string) ([]TaskFileEntry, error) {
+	t, sourceDir, err := s.browsableSourceDir(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	artifacts := make(map[string]task.ArtifactFile, len(t.ArtifactSummary.Files))
+	for _, file := range t.ArtifactSummary.Files {
+		artifacts[strings.TrimPrefix(file.Path, "src/")] = file
+	}
+	// Match on the raw file: sanitizing reduces a rooted package path such
+	// as /pages/index/index.wxml to its base name.
+	diagnostics := make(map[string][]pkg.Diagnostic)
+	for index, diagnostic := range report.SanitizeDiagnostics(t.Diagnostics) {
+		if key := packagePathKey(t.Diagnostics[index].File); key != "" {
+			diagnostics[key] = append(diagnostics[key], diagnostic)
+		}
+	}
+
+	entries := []TaskFileEntry{}
+	err = filepath.WalkDir(sourceDir, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		artifact := artifacts[rel]
+		entries = append(entries, TaskFileEntry{
+			Path:        rel,
+			Size:        info.Size(),
+			Kind:        artifact.Kind,
+			Source:      artifact.Source,
+			Diagnostics: diagnostics[rel],
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// browsableSourceDir only exposes the source tree of a task that finished
+// with results; a running task's tree is still being written.
+func (s *TaskQueryService) browsableSourceDir(ctx context.Context, taskID string) (*task.Task, string, error) {
+	if s.cfg == nil || s.cfg.TempDir == "" || !safePathComponent(taskID) {
+		return nil, "", ErrTaskFileNotFound
+	}
+	t, err := s.repo.Get(ctx, taskID)
+	if err != nil {
+		return nil, "", ErrTaskNotFound
+	}
+	if t.ArtifactSummary == nil || (t.Status != task.TaskCompleted && t.Status != task.TaskPartial) {
+		return nil, "", ErrTaskFileNotFound
+	}
+	resolved, err := filepath.EvalSymlinks(storage.TaskDirsFor(s.cfg.TempDir, taskID).SourceDir)
+	if err != nil {
+		return nil, "", ErrTaskFileNotFound
+	}
+	return t, resolved, nil
+}
+
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// packagePathKey normalizes a diagnostic's file to the tree's path form.
+// Package paths may carry a leading slash, and output paths point into the
+// workspace's result/src directory.
+func packagePathKey(name string) string {
+	name = filepath.ToSlash(name)
+	if index := strings.LastIndex(name, "/result/src/"); index >= 0 {
+		name = name[index+len("/result/src/"):]
+	}
+	trimmed := strings.TrimLeft(name, "/")
+	if trimmed == "" {
+		return ""
+	}
+	return pathpkg.Clean(trimmed)
+}
@@ -0,0 +1,300 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	legacyservice "github.com/keepbuild/seewxapkg/internal/service"
+)
+
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrTaskFinished rejects cancelling a task that already reached a
+	// terminal state other than cancelled.
+	ErrTaskFinished = errors.New("task already finished")
+	// ErrTaskCancelled is the cause a running pipeline's context is
+	// cancelled with; markFailed records it as a cancellation.
+	ErrTaskCancelled = errors.New("task cancelled")
+	// ErrTaskNotFinished and ErrRerunUnavailable reject a rerun of a task
+	// that is still running or kept no decrypted package.
+	ErrTaskNotFinished  = errors.New("task not finished")
+	ErrRerunUnavailable = errors.New("task kept no package to rerun")
+	// ErrTaskStillStopping means a deleted task's pipeline has not stopped
+	// within cancelWaitTimeout; it deletes the task itself once it does.
+	ErrTaskStillStopping = errors.New("task is still stopping")
+)
+
+var (
+	cancelPollInterval = 200 * time.Millisecond
+	// cancelWaitTimeout bounds how long cancel and delete requests wait for
+	// the pipeline to reach a terminal state.
+	cancelWaitTimeout = 15 * time.Second
+)
+
+// runningTasks tracks the pipelines running in this process so a request
+// can cancel them directly. Pipelines in a worker process are reached
+// through the cancel marker instead; see watchCancelMarker.
+type runningTasks struct {
+	mu   sync.Mutex
+	runs map[string]context.CancelCauseFunc
+}
+
+func newRunningTasks() *runningTasks {
+	return &runningTasks{runs: make(map[string]context.CancelCauseFunc)}
+}
+
+// start derives the run's context and registers it. The returned function
+// must be called when the run returns.
+func (r *runningTasks) start(ctx context.Context, taskID string, dirs storage.TaskDirs) (context.Context, func()) {
+	runCtx, cancel := context.WithCancelCause(ctx)
+	r.mu.Lock()
+	r.runs[taskID] = cancel
+	r.mu.Unlock()
+	go watchCancelMarker(runCtx, cancel, dirs)
+	return runCtx, func() {
+		r.mu.Lock()
+		delete(r.runs, taskID)
+		r.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+func (r *runningTasks) cancel(taskID string) {
+	r.mu.Lock()
+	cancel, ok := r.runs[taskID]
+	r.mu.Unlock()
+	if ok {
+		cancel(ErrTaskCancelled)
+	}
+}
+
+// watchCancelMarker cancels the run once its workspace holds a cancel
+// marker, which is how a request reaches a worker in another process.
+func watchCancelMarker(ctx context.Context, cancel context.CancelCauseFunc, dirs storage.TaskDirs) {
+	ticker := time.NewTicker(cancelPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if storage.TaskCancelRequested(dirs) {
+				cancel(ErrTaskCancelled)
+				return
+			}
+		}
+	}
+}
+
+// cancelRequested reports whether the run was cancelled by a request.
+// Worker shutdown does not count: the task stays claimed and resumes after
+// a restart.
+func cancelRequested(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrTaskCancelled)
+}
+
+// CancelTask stops a queued or running task and waits, for a bounded time,
+// until the pipeline has recorded the cancellation. The returned task may
+// still be running when its worker did not respond in time.
+func (s *CompileService) CancelTask(ctx context.Context, taskID string) (*task.Task, error) {
+	t, err := s.repo.Get(ctx, taskID)
+	if err != nil {
+		return nil, ErrTaskNotFound
+	}
+	if t.Status == task.TaskCancelled {
+		return t, nil
+	}
+	if t.Status.IsTerminal() {
+		return nil, ErrTaskFinished
+	}
+	return s.cancelAndWait(ctx, t)
+}
+
+func (s *CompileService) cancelAndWait(ctx context.Context, t *task.Task) (*task.Task, error) {
+	dirs, err := storage.EnsureTaskDirs(s.cfg.TempDir, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.RequestTaskCancel(dirs); err != nil {
+		return nil, err
+	}
+	s.running.cancel(t.ID)
+	if t.Status == task.TaskQueued {
+		// A job no worker has claimed yet is cancelled here. If a worker
+		// claims it meanwhile, RunTask sees the marker and stops at once.
+		if s.queue != nil {
+			if err := s.queue.Remove(ctx, t.ID); err != nil {
+				log.Printf("[Task] remove cancelled job from queue failed (%T)", err)
+			}
+		}
+		if err := s.finishCancelled(ctx, t, dirs); err != nil {
+			return nil, err
+		}
+	}
+
+	deadline := time.Now().Add(cancelWaitTimeout)
+	for {
+		current, err := s.repo.Get(ctx, t.ID)
+		if err != nil {
+			return nil, ErrTaskNotFound
+		}
+		if current.Status.IsTerminal() || time.Now().After(deadline) {
+			return current, nil
+		}
+		select {
+		case <-ctx.Done():
+			return current, nil
+		case <-time.After(cancelPollInterval):
+		}
+	}
+}
+
+// DeleteTask removes every trace of a task: its workspace, download archive,
+// queued job, cached result, diagnostic sample and repository record. An
+// unfinished task is cancelled first. The tombstone written before anything
+// is removed keeps a pipeline that outlives the wait from writing the task
+// back; such a pipeline completes the deletion when it finishes.
+func (s *CompileService) DeleteTask(ctx context.Context, taskID string) error {
+	t, err := s.repo.Get(ctx, taskID)
+	if err != nil {
+		return ErrTaskNotFound
+	}
+	if err := storage.WriteTaskTombstone(s.cfg.TempDir, taskID); err != nil {
+		return err
+	}
+	if !t.Status.IsTerminal() {
+		current, err := s.cancelAndWait(ctx, t)
+		if errors.Is(err, ErrTaskNotFound) {
+			// The pipeline saw the tombstone and finished the deletion.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !current.Status.IsTerminal() {
+			return ErrTaskStillStopping
+		}
+	}
+	return s.removeTask(ctx, t)
+}
+
+func (s *CompileService) removeTask(ctx context.Context, t *task.Task) error {
+	var errs []error
+	if s.queue != nil {
+		errs = append(errs, s.queue.Remove(ctx, t.ID))
+	}
+	s.removeTaskFiles(t)
+	errs = append(errs, s.repo.Delete(ctx, t.ID))
+	s.broker.CloseAndRemove(t.ID)
+	return errors.Join(errs...)
+}
+
+// finishDeleted completes a deletion DeleteTask left to the pipeline. The
+// record may already be gone, so its removal is best-effort.
+func (s *CompileService) finishDeleted(ctx context.Context, t *task.Task) {
+	if err := s.removeTask(context.WithoutCancel(ctx), t); err != nil {
+		log.Printf("[Task] finish deleting task failed (%T)", err)
+	}
+}
+
+// removeTaskFiles is best-effort: the record is deleted regardless, and a
+// leftover workspace is swept by retention cleanup.
+func (s *CompileService) removeTaskFiles(t *task.Task) {
+	dirs := storage.TaskDirsFor(s.cfg.TempDir, t.ID)
+	if s.cache != nil {
+		if key, err := storage.ReadResultCacheKey(dirs); err != nil {
+			log.Printf("[Task] read result cache key failed (%T)", err)
+		} else if key != "" {
+			if err := s.cache.Remove(key); err != nil {
+				log.Printf("[Task] remove cached result failed (%T)", err)
+			}
+		}
+	}
+	if err := storage.RemoveTaskWorkspace(s.cfg.TempDir, t.ID); err != nil {
+		log.Printf("[Task] remove task workspace failed (%T)", err)
+	}
+	if err := removeIfExists(filepath.Join(s.cfg.OutputDir, t.ID+".zip")); err != nil {
+		log.Printf("[Task] remove task archive failed (%T)", err)
+	}
+	s.discardDiagnosticSample(t)
+}
+
+// finishCancelled discards partial results and records the cancellation.
+// The uploaded package and AppID go through finalizeTask like any other
+// terminal state. It returns nil so the queue drops the job.
+func (s *CompileService) finishCancelled(ctx context.Context, t *task.Task, dirs storage.TaskDirs) error {
+	ctx = context.WithoutCancel(ctx)
+	if err := errors.Join(storage.ResetTaskWorkspace(dirs), removeIfExists(filepath.Join(s.cfg.OutputDir, t.ID+".zip"))); err != nil {
+		log.Printf("[Task] discard cancelled results failed (%T)", err)
+	}
+	t.ArtifactSummary = nil
+	t.RecoveryScore = nil
+	return s.finalizeTask(ctx, t, task.TaskCancelled, "task_cancelled", "任务已取消", nil)
+}
+
+// RerunOptions overrides the parent task's requested options for a rerun.
+// A nil field keeps the parent's choice. The callback URL is not inherited.
+type RerunOptions struct {
+	Beautify        *bool
+	Decompile       *bool
+	RemoveGuideHTML *bool
+	CallbackURL     string
+}
+
+// RerunTask starts a child task that processes the finished parent's
+// retained decrypted package with new options. It needs
+// RETAIN_DECRYPTED_PACKAGES to have been on when the parent ran, and works
+// until retention cleanup removes the parent's workspace.
+func (s *CompileService) RerunTask(ctx context.Context, parentID string, options RerunOptions) (*task.Task, error) {
+	parent, err := s.repo.Get(ctx, parentID)
+	if err != nil {
+		return nil, ErrTaskNotFound
+	}
+	if !parent.Status.IsTerminal() {
+		return nil, ErrTaskNotFinished
+	}
+	inputPath, subPackagePaths, err := storage.RetainedPackagePaths(storage.TaskDirsFor(s.cfg.TempDir, parentID))
+	if err != nil {
+		return nil, err
+	}
+	if inputPath == "" {
+		return nil, ErrRerunUnavailable
+	}
+	cmd := StartCompileCommand{
+		Beautify:        parent.RequestedOptions.Beautify,
+		Decompile:       parent.RequestedOptions.Decompile,
+		RemoveGuideHTML: parent.RequestedOptions.RemoveGuideHTML,
+		InputPath:       inputPath,
+		SubPackagePaths: subPackagePaths,
+		ParentTaskID:    parentID,
+		CallbackURL:     options.CallbackURL,
+	}
+	for _, override := range []struct {
+		value  *bool
+		target *bool
+	}{
+		{options.Beautify, &cmd.Beautify},
+		{options.Decompile, &cmd.Decompile},
+		{options.RemoveGuideHTML, &cmd.RemoveGuideHTML},
+	} {
+		if override.value != nil {
+			*override.target = *override.value
+		}
+	}
+	return s.StartTask(ctx, cmd)
+}
+
+func retainDecryptedPackages(dirs storage.TaskDirs, plain legacyservice.PackageSource, subPackages []legacyservice.PackageSource) error {
+	readers := make([]io.Reader, 0, len(subPackages))
+	for _, src := range subPackages {
+		readers = append(readers, io.NewSectionReader(src, 0, src.Size()))
+	}
+	return storage.SaveRetainedPackages(dirs, io.NewSectionReader(plain, 0, plain.Size()), readers)
+}
@@ -3,12 +3,14 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 
 	"github.com/keepbuild/seewxapkg/internal/config"
 	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
 	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/pipeline/analyze"
 	"github.com/keepbuild/seewxapkg/internal/report"
 )
 
@@ -20,9 +22,17 @@ var reportFiles = map[string]string{
 	"wxss-recovery-report":     "wxss-recovery-report.json",
 	"format-report":            "format-report.json",
 	"zip-manifest":             "zip-manifest.json",
+	"security-report":          "security-report.json",
+	"api-inventory":            "api-inventory.json",
+	"api-inventory-openapi":    "api-inventory.openapi.json",
+	"dependency-graph":         "dependency-graph.json",
+	"sourcemaps":               "sourcemaps/index.json",
 	"package-profile":          "package-profile.json",
 }
 
+// ErrTaskListingDisabled means the operator has not enabled the task list.
+var ErrTaskListingDisabled = errors.New("task listing is disabled")
+
 type TaskQueryService struct {
 	cfg  *config.Config
 	repo task.Repository
@@ -36,6 +46,15 @@ func (s *TaskQueryService) GetTask(ctx context.Context, taskID string) (*task.Ta
 	return s.repo.Get(ctx, taskID)
 }
 
+// ListTasks pages through every task on the server, so it answers only when
+// TASK_LISTING_ENABLED is set.
+func (s *TaskQueryService) ListTasks(ctx context.Context, filter task.ListFilter) (*task.ListPage, error) {
+	if !s.cfg.TaskListingEnabled {
+		return nil, ErrTaskListingDisabled
+	}
+	return s.repo.List(ctx, filter)
+}
+
 func (s *TaskQueryService) GetReport(ctx context.Context, taskID string) (*report.RecoveryReport, error) {
 	t, err := s.repo.Get(ctx, taskID)
 	if err != nil {
@@ -85,6 +104,20 @@ func (s *TaskQueryService) GetNamedReport(ctx context.Context, taskID string, na
 	return report.SanitizeJSONBytes(data)
 }
 
+// GetDependencyGraph reads the dependency-graph report written by the
+// analyzing stage.
+func (s *TaskQueryService) GetDependencyGraph(ctx context.Context, taskID string) (*analyze.DependencyGraph, error) {
+	data, err := s.GetNamedReport(ctx, taskID, "dependency-graph")
+	if err != nil {
+		return nil, err
+	}
+	var graph analyze.DependencyGraph
+	if err := json.Unmarshal(data, &graph); err != nil {
+		return nil, err
+	}
+	return &graph, nil
+}
+
 func (s *TaskQueryService) ResolveZipPath(taskID string) string {
 	if s.cfg == nil || s.cfg.OutputDir == "" || !safePathComponent(taskID) {
 		return ""
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/keepbuild/seewxapkg/internal/config"
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
 	"github.com/keepbuild/seewxapkg/internal/domain/task"
 	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
 )
@@ -153,3 +154,81 @@ func TestGetNamedReportAllowsPublishedReportLinks(t *testing.T) {
 		}
 	}
 }
+
+func TestTaskFileBrowserServesRecoveredSourceOnly(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := persistence.NewMemoryTaskRepo()
+	current := &task.Task{
+		ID:     "task-1",
+		Status: task.TaskPartial,
+		ArtifactSummary: &task.ArtifactSummary{Files: []task.ArtifactFile{
+			{Path: "src/pages/index/index.wxml", Kind: "wxml", Source: "native"},
+		}},
+		Diagnostics: []pkg.Diagnostic{
+			pkg.Warn("recover.wxml.page.missing_runtime", "页面 WXML 缺失", "recovering_wxml", "/pages/index/index.wxml"),
+			pkg.Warn("recover.js.other", "其他文件", "recovering_js", "app.js"),
+		},
+	}
+	if err := repo.Create(context.Background(), current); err != nil {
+		t.Fatal(err)
+	}
+	sourceDir := filepath.Join(tempDir, current.ID, "result", "src")
+	for name, content := range map[string]string{
+		"pages/index/index.wxml": "<view/>",
+		"app.json":               "{}",
+		"big.js":                 strings.Repeat("x", MaxTaskFileBytes+1),
+	} {
+		path := filepath.Join(sourceDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, current.ID, "result", "secret.txt"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(tempDir, current.ID, "result", "secret.txt"), filepath.Join(sourceDir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	service := NewTaskQueryService(&config.Config{TempDir: tempDir}, repo)
+	ctx := context.Background()
+
+	file, err := service.GetTaskFile(ctx, current.ID, "/pages/index/index.wxml")
+	if err != nil || string(file.Content) != "<view/>" || file.Path != "pages/index/index.wxml" || file.ETag == "" {
+		t.Fatalf("GetTaskFile = %+v, %v", file, err)
+	}
+	if _, err := service.GetTaskFile(ctx, current.ID, "big.js"); !errors.Is(err, ErrTaskFileTooLarge) {
+		t.Fatalf("oversized file error = %v", err)
+	}
+	for _, name := range []string{"../secret.txt", "link.txt", "pages", "missing.js", `pages\index\index.wxml`} {
+		if _, err := service.GetTaskFile(ctx, current.ID, name); !errors.Is(err, ErrTaskFileNotFound) {
+			t.Fatalf("GetTaskFile(%q) error = %v, want ErrTaskFileNotFound", name, err)
+		}
+	}
+
+	tree, err := service.GetTaskFileTree(ctx, current.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, entry := range tree {
+		paths = append(paths, entry.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"app.json", "big.js", "pages/index/index.wxml"}) {
+		t.Fatalf("tree paths = %v", paths)
+	}
+	page := tree[2]
+	if page.Kind != "wxml" || page.Source != "native" || len(page.Diagnostics) != 1 || page.Size != int64(len("<view/>")) {
+		t.Fatalf("page entry = %+v", page)
+	}
+
+	current.Status = task.TaskFailed
+	if err := repo.Update(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.GetTaskFileTree(ctx, current.ID); !errors.Is(err, ErrTaskFileNotFound) {
+		t.Fatalf("failed task tree error = %v", err)
+	}
+}
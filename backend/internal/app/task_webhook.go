@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+)
+
+// ErrCallbackURLNotAllowed rejects a task callback URL when webhooks are not
+// configured or the URL's host is not in WEBHOOK_ALLOWED_HOSTS.
+var ErrCallbackURLNotAllowed = errors.New("callback url not allowed")
+
+// TaskNotifier delivers a task that reached completed, partial or failed to
+// a callback URL. The HTTP layer provides it because the payload is the
+// public task DTO.
+type TaskNotifier interface {
+	NotifyTaskFinished(ctx context.Context, callbackURL string, t *task.Task) error
+}
+
+// SetTaskNotifier enables completion callbacks for tasks this service runs.
+// Without a notifier, or without WEBHOOK_SECRET, callbacks are skipped.
+func (s *CompileService) SetTaskNotifier(notifier TaskNotifier) {
+	s.notifier = notifier
+}
+
+func (s *CompileService) validateCallbackURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	if s.cfg.WebhookSecret == "" {
+		return ErrCallbackURLNotAllowed
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.User != nil {
+		return ErrCallbackURLNotAllowed
+	}
+	for _, host := range s.cfg.WebhookAllowedHosts {
+		if strings.EqualFold(host, parsed.Host) || strings.EqualFold(host, parsed.Hostname()) {
+			return nil
+		}
+	}
+	return ErrCallbackURLNotAllowed
+}
+
+// notifyTaskFinished sends the callback in the background so a slow
+// receiver never holds up the pipeline. Deliveries still retrying when the
+// process exits are lost; the task record stays authoritative.
+func (s *CompileService) notifyTaskFinished(t *task.Task, dirs storage.TaskDirs) {
+	if s.notifier == nil || s.cfg.WebhookSecret == "" {
+		return
+	}
+	switch t.Status {
+	case task.TaskCompleted, task.TaskPartial, task.TaskFailed:
+	default:
+		return
+	}
+	callbackURL, err := storage.ReadCallbackURL(dirs)
+	if err != nil {
+		log.Printf("[Webhook] read task callback url failed (%T)", err)
+	}
+	if callbackURL == "" {
+		callbackURL = s.cfg.WebhookURL
+	}
+	if callbackURL == "" {
+		return
+	}
+	snapshot := t.Clone()
+	go func() {
+		if err := s.notifier.NotifyTaskFinished(context.Background(), callbackURL, snapshot); err != nil {
+			log.Printf("[Webhook] task callback delivery failed (%T)", err)
+		}
+	}()
+}
@@ -13,6 +13,8 @@ type Config struct {
 	ServerHost         string
 	ServerPort         int
 	MaxUploadSize      int64
+	MaxBatchUploadSize int64
+	MaxBatchPackages   int
 	TempDir            string
 	OutputDir          string
 	CORSAllowedOrigins []string
@@ -31,6 +33,12 @@ type Config struct {
 	FallbackRecoverEnabled bool
 	VerificationEnabled    bool
 	ReportEnabled          bool
+	// ResultCacheEnabled reuses the stored result of an earlier task with the
+	// same decrypted package and options instead of running the pipeline.
+	ResultCacheEnabled bool
+	// TaskListingEnabled exposes GET /api/tasks, which lists every task on
+	// the server to any caller. Disabled by default.
+	TaskListingEnabled bool
 
 	NodeBinary             string
 	NodeExecTimeoutSeconds int
@@ -45,6 +53,24 @@ type Config struct {
 	// exposed through the API. Empty by default (collection disabled).
 	DiagnosticSamplesDir string
 
+	// RetainDecryptedPackages keeps each task's decrypted package for the
+	// artifact retention window so the task can be rerun with other options
+	// without a new upload or AppID. Disabled by default.
+	RetainDecryptedPackages bool
+
+	// AppIDCandidatesFile lists AppIDs, one per line, that tasks opting into
+	// AppID search try against encrypted packages uploaded without one.
+	// Empty by default (search unavailable).
+	AppIDCandidatesFile string
+
+	// WebhookSecret keys the HMAC signature on task callbacks; callbacks
+	// are disabled while it is empty. WebhookURL receives a callback for
+	// every task, and a task may name its own callback URL on one of
+	// WebhookAllowedHosts.
+	WebhookSecret       string
+	WebhookURL          string
+	WebhookAllowedHosts []string
+
 	storageInitErr error
 }
 
@@ -53,6 +79,8 @@ func Load() *Config {
 		ServerHost:         getEnv("SERVER_HOST", "0.0.0.0"),
 		ServerPort:         getEnvInt("SERVER_PORT", 9090),
 		MaxUploadSize:      getEnvInt64("MAX_UPLOAD_SIZE", 50*1024*1024), // 50MB
+		MaxBatchUploadSize: getEnvInt64("MAX_BATCH_UPLOAD_SIZE", 200*1024*1024),
+		MaxBatchPackages:   getEnvInt("MAX_BATCH_PACKAGES", 100),
 		TempDir:            getEnv("TEMP_DIR", "/tmp/seewxapkg"),
 		OutputDir:          getEnv("OUTPUT_DIR", "/output"),
 		CORSAllowedOrigins: getEnvList("CORS_ALLOWED_ORIGINS"),
@@ -71,6 +99,8 @@ func Load() *Config {
 		FallbackRecoverEnabled: getEnvBool("FALLBACK_RECOVER_ENABLED", true),
 		VerificationEnabled:    getEnvBool("VERIFICATION_ENABLED", true),
 		ReportEnabled:          getEnvBool("REPORT_ENABLED", true),
+		ResultCacheEnabled:     getEnvBool("RESULT_CACHE_ENABLED", true),
+		TaskListingEnabled:     getEnvBool("TASK_LISTING_ENABLED", false),
 
 		NodeBinary:             getEnv("NODE_BINARY", "node"),
 		NodeExecTimeoutSeconds: getEnvInt("NODE_EXEC_TIMEOUT_SECONDS", 60),
@@ -79,6 +109,13 @@ func Load() *Config {
 		MaxConcurrentTasks:   getEnvInt("MAX_CONCURRENT_TASKS", 4),
 		RetainArtifactsHours: getEnvInt("RETAIN_ARTIFACTS_HOURS", 24),
 		DiagnosticSamplesDir: getEnv("DIAGNOSTIC_SAMPLES_DIR", ""),
+		AppIDCandidatesFile:  getEnv("APPID_CANDIDATES_FILE", ""),
+
+		RetainDecryptedPackages: getEnvBool("RETAIN_DECRYPTED_PACKAGES", false),
+
+		WebhookSecret:       getEnv("WEBHOOK_SECRET", ""),
+		WebhookURL:          getEnv("WEBHOOK_URL", ""),
+		WebhookAllowedHosts: getEnvList("WEBHOOK_ALLOWED_HOSTS"),
 	}
 
 	// These directories contain uploaded packages and recovered source. Tighten
@@ -145,6 +182,9 @@ func (c *Config) Validate() error {
 	if c.MaxUploadSize <= 0 {
 		return fmt.Errorf("max upload size must be positive")
 	}
+	if c.MaxBatchUploadSize <= 0 || c.MaxBatchPackages <= 0 {
+		return fmt.Errorf("max batch upload size and package count must be positive")
+	}
 	if c.NodeExecTimeoutSeconds <= 0 {
 		return fmt.Errorf("node exec timeout must be positive")
 	}
@@ -174,7 +214,16 @@ func (c *Config) Validate() error {
 	if wildcardOrigins > 0 && len(c.CORSAllowedOrigins) != 1 {
 		return fmt.Errorf("CORS_ALLOWED_ORIGINS wildcard must be used alone")
 	}
-	if c.TaskRepoDriver != "memory" && c.TaskRepoDriver != "file" {
+	if (c.WebhookURL != "" || len(c.WebhookAllowedHosts) > 0) && c.WebhookSecret == "" {
+		return fmt.Errorf("WEBHOOK_URL and WEBHOOK_ALLOWED_HOSTS require WEBHOOK_SECRET")
+	}
+	if c.WebhookURL != "" {
+		parsed, err := url.Parse(c.WebhookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("WEBHOOK_URL must be an absolute http(s) URL")
+		}
+	}
+	if c.TaskRepoDriver != "memory" && c.TaskRepoDriver != "file" && c.TaskRepoDriver != "sqlite" {
 		return fmt.Errorf("unsupported TASK_REPO_DRIVER %q", c.TaskRepoDriver)
 	}
 	if c.QueueDriver != "inmem" && c.QueueDriver != "file" {
@@ -183,13 +232,14 @@ func (c *Config) Validate() error {
 	for _, key := range []string{
 		"BEAUTIFY_ENABLED", "DEOBFUSCATE_ENABLED", "NATIVE_RECOVER_ENABLED",
 		"FALLBACK_RECOVER_ENABLED", "VERIFICATION_ENABLED", "REPORT_ENABLED",
+		"RESULT_CACHE_ENABLED",
 	} {
 		if err := validateOptionalBoolEnv(key); err != nil {
 			return err
 		}
 	}
 	for _, key := range []string{
-		"SERVER_PORT", "MAX_UPLOAD_SIZE", "BEAUTIFY_TIMEOUT", "BEAUTIFY_MAX_FILE_SIZE",
+		"SERVER_PORT", "MAX_UPLOAD_SIZE", "MAX_BATCH_UPLOAD_SIZE", "MAX_BATCH_PACKAGES", "BEAUTIFY_TIMEOUT", "BEAUTIFY_MAX_FILE_SIZE",
 		"BEAUTIFY_FAILURE_LIMIT", "NODE_EXEC_TIMEOUT_SECONDS", "NODE_EXEC_MEMORY_MB",
 		"MAX_CONCURRENT_TASKS", "RETAIN_ARTIFACTS_HOURS",
 	} {
@@ -182,3 +182,26 @@ func TestCORSOriginsAreOptInAndValidated(t *testing.T) {
 		}
 	}
 }
+
+func TestWebhookTargetsRequireSecret(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET", "")
+	t.Setenv("WEBHOOK_URL", "https://ci.example/hook")
+	if err := loadTestConfig(t).Validate(); err == nil {
+		t.Fatal("webhook URL without a signing secret must be rejected")
+	}
+	t.Setenv("WEBHOOK_URL", "")
+	t.Setenv("WEBHOOK_ALLOWED_HOSTS", "ci.example")
+	if err := loadTestConfig(t).Validate(); err == nil {
+		t.Fatal("webhook allow-list without a signing secret must be rejected")
+	}
+
+	t.Setenv("WEBHOOK_SECRET", "secret")
+	t.Setenv("WEBHOOK_URL", "ftp://ci.example/hook")
+	if err := loadTestConfig(t).Validate(); err == nil {
+		t.Fatal("non-http webhook URL must be rejected")
+	}
+	t.Setenv("WEBHOOK_URL", "https://ci.example/hook")
+	if err := loadTestConfig(t).Validate(); err != nil {
+		t.Fatalf("valid webhook configuration rejected: %v", err)
+	}
+}
@@ -1,5 +1,10 @@
 package pkg
 
+import (
+	"path"
+	"strings"
+)
+
 type SubPackageIR struct {
 	Root    string   `json:"root"`
 	Pages   []string `json:"pages,omitempty"`
@@ -31,6 +36,37 @@ type ManifestIR struct {
 	Confidence map[string]string      `json:"confidence,omitempty"`
 }
 
+// PluginManifestIR is the plugin.json of a plugin package. Root is the
+// package directory holding the plugin code, "" when it is the package root;
+// Pages and PublicComponents map exported names to paths under Root.
+type PluginManifestIR struct {
+	AppID            string                 `json:"appId,omitempty"`
+	Root             string                 `json:"root,omitempty"`
+	Main             string                 `json:"main,omitempty"`
+	Pages            map[string]string      `json:"pages,omitempty"`
+	PublicComponents map[string]string      `json:"publicComponents,omitempty"`
+	Original         map[string]interface{} `json:"original,omitempty"`
+	Source           string                 `json:"source,omitempty"`
+}
+
+// ResolvePath resolves a plugin.json page or component path, written
+// relative to the plugin with an optional leading slash, to a package path.
+// It returns "" for paths that leave the package.
+func (p PluginManifestIR) ResolvePath(reference string) string {
+	clean := strings.TrimLeft(strings.TrimSpace(strings.ReplaceAll(reference, `\`, "/")), "/")
+	for _, extension := range []string{".js", ".wxml", ".json"} {
+		clean = strings.TrimSuffix(clean, extension)
+	}
+	if clean == "" {
+		return ""
+	}
+	clean = path.Clean(path.Join(p.Root, clean))
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return ""
+	}
+	return clean
+}
+
 func NewManifestIR() ManifestIR {
 	return ManifestIR{
 		Window:          map[string]interface{}{},
@@ -48,13 +48,14 @@ type AssetIR struct {
 }
 
 type NormalizedPackage struct {
-	Profile     PackageProfile `json:"profile"`
-	Manifest    ManifestIR     `json:"manifest"`
-	Pages       []PageIR       `json:"pages,omitempty"`
-	Components  []ComponentIR  `json:"components,omitempty"`
-	Scripts     []ScriptIR     `json:"scripts,omitempty"`
-	Styles      []StyleIR      `json:"styles,omitempty"`
-	Templates   []TemplateIR   `json:"templates,omitempty"`
-	Assets      []AssetIR      `json:"assets,omitempty"`
-	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	Profile     PackageProfile    `json:"profile"`
+	Manifest    ManifestIR        `json:"manifest"`
+	Plugin      *PluginManifestIR `json:"plugin,omitempty"`
+	Pages       []PageIR          `json:"pages,omitempty"`
+	Components  []ComponentIR     `json:"components,omitempty"`
+	Scripts     []ScriptIR        `json:"scripts,omitempty"`
+	Styles      []StyleIR         `json:"styles,omitempty"`
+	Templates   []TemplateIR      `json:"templates,omitempty"`
+	Assets      []AssetIR         `json:"assets,omitempty"`
+	Diagnostics []Diagnostic      `json:"diagnostics,omitempty"`
 }
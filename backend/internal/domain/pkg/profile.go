@@ -6,6 +6,8 @@ type PackageProfile struct {
 	IsWeChat4xLike   bool   `json:"isWeChat4xLike"`
 	IsSubPackage     bool   `json:"isSubPackage"`
 	IsGamePackage    bool   `json:"isGamePackage"`
+	IsPluginPackage  bool   `json:"isPluginPackage"`
+	PluginAppID      string `json:"pluginAppId,omitempty"`
 	HasAppConfigJSON bool   `json:"hasAppConfigJSON"`
 	HasAppServiceJS  bool   `json:"hasAppServiceJS"`
 	HasWorkersJS     bool   `json:"hasWorkersJS"`
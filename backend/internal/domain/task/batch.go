@@ -0,0 +1,21 @@
+package task
+
+import "time"
+
+// Batch groups tasks that were submitted together from one archive or
+// directory. It only references tasks by ID; each task keeps its own state,
+// retention and reports.
+type Batch struct {
+	ID        string           `json:"id"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Options   RequestedOptions `json:"requestedOptions"`
+	Items     []BatchItem      `json:"items"`
+}
+
+type BatchItem struct {
+	Index int `json:"index"`
+	// Name is the archive-relative package path with AppIDs masked.
+	Name      string `json:"name"`
+	TaskID    string `json:"taskId,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
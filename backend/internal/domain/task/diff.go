@@ -0,0 +1,13 @@
+package task
+
+import "time"
+
+// Diff pairs two compile tasks of the same mini program, base (older build)
+// and head (newer build). Like Batch it only references the tasks; the diff
+// report is computed from their results once both are terminal.
+type Diff struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	BaseTaskID string    `json:"baseTaskId"`
+	HeadTaskID string    `json:"headTaskId"`
+}
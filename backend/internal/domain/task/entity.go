@@ -13,6 +13,11 @@ type RequestedOptions struct {
 	// files from the delivered source tree (they are loader scripts, not
 	// templates). Defaults to true via the frontend checkbox.
 	RemoveGuideHTML bool `json:"removeGuideHtml"`
+	// AppIDSearch tries the configured candidate AppIDs when an encrypted
+	// package arrives without one. AppIDFromPath records that the AppID was
+	// taken from the package's WeChat directory instead of the request.
+	AppIDSearch   bool `json:"appIdSearch,omitempty"`
+	AppIDFromPath bool `json:"appIdFromPath,omitempty"`
 }
 
 type ArtifactFile struct {
@@ -39,16 +44,17 @@ type ArtifactSummary struct {
 }
 
 type RecoveryScore struct {
-	Overall         int  `json:"overall"`
-	Manifest        int  `json:"manifest"`
-	JS              int  `json:"js"`
-	WXML            int  `json:"wxml"`
-	WXSS            int  `json:"wxss"`
-	DecompileHit    bool `json:"decompileHit"`
-	FallbackUsed    bool `json:"fallbackUsed"`
-	GeneratedRatio  int  `json:"generatedRatio"`
-	FallbackPenalty int  `json:"fallbackPenalty"`
-	VerifierPassed  bool `json:"verifierPassed"`
+	Kind            string `json:"kind,omitempty"`
+	Overall         int    `json:"overall"`
+	Manifest        int    `json:"manifest"`
+	JS              int    `json:"js"`
+	WXML            int    `json:"wxml"`
+	WXSS            int    `json:"wxss"`
+	DecompileHit    bool   `json:"decompileHit"`
+	FallbackUsed    bool   `json:"fallbackUsed"`
+	GeneratedRatio  int    `json:"generatedRatio"`
+	FallbackPenalty int    `json:"fallbackPenalty"`
+	VerifierPassed  bool   `json:"verifierPassed"`
 }
 
 type StageResult struct {
@@ -71,6 +77,7 @@ type Task struct {
 	ID               string               `json:"id"`
 	Status           TaskStatus           `json:"status"`
 	RequestedOptions RequestedOptions     `json:"requestedOptions"`
+	ParentTaskID     string               `json:"parentTaskId,omitempty"`
 	PackageProfile   *pkg.PackageProfile  `json:"profile,omitempty"`
 	StageResults     []StageResult        `json:"stages,omitempty"`
 	ArtifactSummary  *ArtifactSummary     `json:"artifacts,omitempty"`
@@ -0,0 +1,187 @@
+package task
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid task list cursor")
+
+// ListFilter selects the tasks returned by Repository.List. Zero fields do
+// not filter. A score bound excludes tasks that have not been scored yet.
+type ListFilter struct {
+	Statuses      []TaskStatus
+	Variant       string
+	MinScore      *int
+	MaxScore      *int
+	CreatedAfter  time.Time // inclusive
+	CreatedBefore time.Time // exclusive
+	Cursor        string
+	Limit         int
+}
+
+// ListPage is one page of a listing. NextCursor is empty on the last page.
+type ListPage struct {
+	Tasks      []*Task
+	NextCursor string
+}
+
+// PageLimit returns the requested page size clamped to [1, MaxListLimit].
+func (f ListFilter) PageLimit() int {
+	switch {
+	case f.Limit <= 0:
+		return DefaultListLimit
+	case f.Limit > MaxListLimit:
+		return MaxListLimit
+	default:
+		return f.Limit
+	}
+}
+
+// Matches reports whether t passes every filter except the cursor.
+func (f ListFilter) Matches(t *Task) bool {
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, status := range f.Statuses {
+			if t.Status == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Variant != "" && (t.PackageProfile == nil || t.PackageProfile.SuspectedVariant != f.Variant) {
+		return false
+	}
+	if f.MinScore != nil || f.MaxScore != nil {
+		if t.RecoveryScore == nil {
+			return false
+		}
+		if f.MinScore != nil && t.RecoveryScore.Overall < *f.MinScore {
+			return false
+		}
+		if f.MaxScore != nil && t.RecoveryScore.Overall > *f.MaxScore {
+			return false
+		}
+	}
+	if !f.CreatedAfter.IsZero() && t.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !t.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// ListCursor is the position after the last task of a page: listings are
+// ordered by creation time, then ID, both descending.
+type ListCursor struct {
+	CreatedAt int64
+	ID        string
+}
+
+// CursorAfter returns the cursor that continues a listing after t.
+func CursorAfter(t *Task) string {
+	payload := strconv.FormatInt(ListSortKey(t), 10) + ":" + t.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(payload))
+}
+
+// ParseListCursor decodes a cursor produced by CursorAfter. An empty cursor
+// starts from the newest task and yields nil.
+func ParseListCursor(value string) (*ListCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	created, id, ok := strings.Cut(string(payload), ":")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, err := strconv.ParseInt(created, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &ListCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// Before reports whether t sorts after the cursor position, i.e. belongs
+// to a later page.
+func (c *ListCursor) Before(t *Task) bool {
+	if c == nil {
+		return true
+	}
+	key := ListSortKey(t)
+	return key < c.CreatedAt || key == c.CreatedAt && t.ID < c.ID
+}
+
+// ListSortKey is the creation time in Unix nanoseconds, or zero for a task
+// without one.
+func ListSortKey(t *Task) int64 {
+	if t.CreatedAt.IsZero() {
+		return 0
+	}
+	return t.CreatedAt.UnixNano()
+}
+
+// PageTasks filters, orders and pages an unordered set of tasks. Drivers
+// without an index use it over their full task set.
+func PageTasks(tasks []*Task, filter ListFilter) (*ListPage, error) {
+	cursor, err := ParseListCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]*Task, 0, len(tasks))
+	for _, current := range tasks {
+		if filter.Matches(current) && cursor.Before(current) {
+			matched = append(matched, current)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		left, right := ListSortKey(matched[i]), ListSortKey(matched[j])
+		if left != right {
+			return left > right
+		}
+		return matched[i].ID > matched[j].ID
+	})
+	page := &ListPage{}
+	limit := filter.PageLimit()
+	if len(matched) > limit {
+		matched = matched[:limit]
+		page.NextCursor = CursorAfter(matched[limit-1])
+	}
+	page.Tasks = matched
+	return page, nil
+}
+
+// ParseStatuses parses a comma-separated status list, rejecting statuses
+// that do not exist.
+func ParseStatuses(value string) ([]TaskStatus, error) {
+	var statuses []TaskStatus
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		status := TaskStatus(part)
+		if !status.IsKnown() {
+			return nil, fmt.Errorf("unknown task status %q", part)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
@@ -6,4 +6,8 @@ type Repository interface {
 	Create(ctx context.Context, t *Task) error
 	Update(ctx context.Context, t *Task) error
 	Get(ctx context.Context, id string) (*Task, error)
+	// Delete removes the task record. Deleting a missing task is not an error.
+	Delete(ctx context.Context, id string) error
+	// List returns one page of tasks matching filter, newest first.
+	List(ctx context.Context, filter ListFilter) (*ListPage, error)
 }
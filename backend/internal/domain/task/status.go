@@ -15,8 +15,35 @@ const (
 	TaskFallbackRecovering TaskStatus = "fallback_recovering"
 	TaskFormatting         TaskStatus = "formatting"
 	TaskVerifying          TaskStatus = "verifying"
+	TaskAnalyzing          TaskStatus = "analyzing"
 	TaskPackaging          TaskStatus = "packaging"
 	TaskCompleted          TaskStatus = "completed"
 	TaskPartial            TaskStatus = "partial"
 	TaskFailed             TaskStatus = "failed"
+	TaskCancelled          TaskStatus = "cancelled"
 )
+
+// Sub-steps of the analyzing stage record stage results under their own
+// names; the task stays in TaskAnalyzing while they run.
+const (
+	StageDependencyGraph = "analyzing_dependency_graph"
+	StageAPIInventory    = "analyzing_api_inventory"
+)
+
+// IsKnown reports whether s is one of the statuses above.
+func (s TaskStatus) IsKnown() bool {
+	switch s {
+	case TaskQueued, TaskClassifying, TaskDecrypting, TaskUnpacking, TaskNormalizing,
+		TaskRecoveringManifest, TaskRecoveringJS, TaskRecoveringWXML, TaskRecoveringWXSS,
+		TaskFallbackRecovering, TaskFormatting, TaskVerifying, TaskAnalyzing, TaskPackaging,
+		TaskCompleted, TaskPartial, TaskFailed, TaskCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsTerminal reports whether a task in status s will not run again.
+func (s TaskStatus) IsTerminal() bool {
+	return s == TaskCompleted || s == TaskPartial || s == TaskFailed || s == TaskCancelled
+}
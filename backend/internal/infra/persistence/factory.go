@@ -14,6 +14,8 @@ func NewTaskRepository(cfg *config.Config) (task.Repository, error) {
 		return NewMemoryTaskRepo(), nil
 	case "file":
 		return NewFileTaskRepo(filepath.Join(cfg.TempDir, "task-state"))
+	case "sqlite":
+		return NewSQLiteTaskRepo(filepath.Join(cfg.TempDir, "task-state", "tasks.db"))
 	default:
 		return nil, fmt.Errorf("unsupported task repository driver %q", cfg.TaskRepoDriver)
 	}
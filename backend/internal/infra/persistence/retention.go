@@ -7,14 +7,15 @@ import (
 	"github.com/keepbuild/seewxapkg/internal/domain/task"
 )
 
-type inMemoryRetentionCleaner interface {
+type retentionCleaner interface {
 	deleteUpdatedBefore(before time.Time) int
 }
 
 // StartRetentionJanitor bounds task metadata retained by the development-only
-// in-memory repository. File-backed records are cleaned by the storage janitor.
+// in-memory repository and by the SQLite database. File-backed records are
+// cleaned by the storage janitor.
 func StartRetentionJanitor(ctx context.Context, repo task.Repository, retainHours int) {
-	cleaner, ok := repo.(inMemoryRetentionCleaner)
+	cleaner, ok := repo.(retentionCleaner)
 	if !ok || retainHours <= 0 {
 		return
 	}
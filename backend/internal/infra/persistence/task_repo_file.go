@@ -84,7 +84,7 @@ func isTerminalLegacyTaskState(value interface{}) bool {
 	}
 	status, _ := document["status"].(string)
 	switch status {
-	case string(task.TaskCompleted), string(task.TaskPartial), string(task.TaskFailed):
+	case string(task.TaskCompleted), string(task.TaskPartial), string(task.TaskFailed), string(task.TaskCancelled):
 		return true
 	default:
 		return false
@@ -103,7 +103,7 @@ func cleanAndSecureTerminalTaskTree(tempRoot, taskID string) error {
 	if info.Mode()&os.ModeSymlink != 0 || !info.IsDir() {
 		return fmt.Errorf("terminal task workspace is not a private directory")
 	}
-	for _, target := range []string{filepath.Join(taskRoot, "input"), filepath.Join(taskRoot, "fallback")} {
+	for _, target := range []string{filepath.Join(taskRoot, "input"), filepath.Join(taskRoot, "fallback"), filepath.Join(taskRoot, "subpackages")} {
 		if err := removePrivateTree(target); err != nil {
 			return err
 		}
@@ -223,6 +223,57 @@ func (r *fileTaskRepo) Get(ctx context.Context, id string) (*task.Task, error) {
 	return current.Clone(), nil
 }
 
+func (r *fileTaskRepo) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	path, err := r.taskPath(id)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return syncRepoDirectory(r.baseDir)
+}
+
+// List has no index to work from: it decodes every record in the state
+// directory and pages the result in memory. Records that vanish or fail to
+// decode mid-scan, such as ones removed by the retention janitor, are skipped.
+func (r *fileTaskRepo) List(ctx context.Context, filter task.ListFilter) (*task.ListPage, error) {
+	if _, err := task.ParseListCursor(filter.Cursor); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(r.baseDir)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]*task.Task, 0, len(entries))
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasSuffix(name, ".json") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(r.baseDir, name))
+		if err != nil {
+			continue
+		}
+		var current task.Task
+		if err := json.Unmarshal(data, &current); err != nil || current.ID+".json" != name {
+			continue
+		}
+		if filter.Matches(&current) {
+			tasks = append(tasks, &current)
+		}
+	}
+	return task.PageTasks(tasks, filter)
+}
+
 func (r *fileTaskRepo) write(ctx context.Context, t *task.Task) error {
 	if t == nil {
 		return fmt.Errorf("task is nil")
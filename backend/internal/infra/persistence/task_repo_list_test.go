@@ -0,0 +1,138 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+)
+
+func TestTaskRepositoriesListWithFiltersAndCursor(t *testing.T) {
+	drivers := map[string]func(t *testing.T) task.Repository{
+		"memory": func(*testing.T) task.Repository { return NewMemoryTaskRepo() },
+		"file": func(t *testing.T) task.Repository {
+			return newTestFileTaskRepo(t, filepath.Join(t.TempDir(), "task-state"))
+		},
+		"sqlite": func(t *testing.T) task.Repository {
+			return newTestSQLiteTaskRepo(t, filepath.Join(t.TempDir(), "task-state", "tasks.db"))
+		},
+	}
+	for name, open := range drivers {
+		t.Run(name, func(t *testing.T) {
+			repo := open(t)
+			ctx := context.Background()
+			base := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+			for i := 0; i < 7; i++ {
+				current := &task.Task{
+					ID:             fmt.Sprintf("task-%d", i),
+					Status:         task.TaskPartial,
+					PackageProfile: &pkg.PackageProfile{SuspectedVariant: "wechat4x"},
+					RecoveryScore:  &task.RecoveryScore{Overall: 50 + i*5},
+					CreatedAt:      base.Add(time.Duration(i) * time.Hour),
+					UpdatedAt:      base.Add(time.Duration(i) * time.Hour),
+				}
+				switch i {
+				case 1:
+					current.Status = task.TaskCompleted
+				case 2:
+					current.PackageProfile.SuspectedVariant = "standard"
+				case 3:
+					current.RecoveryScore = nil
+				}
+				if err := repo.Create(ctx, current); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// task-5 and task-6 share a creation time so the ID breaks the tie.
+			tied, err := repo.Get(ctx, "task-5")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tied.CreatedAt = base.Add(6 * time.Hour)
+			if err := repo.Update(ctx, tied); err != nil {
+				t.Fatal(err)
+			}
+
+			all, err := repo.List(ctx, task.ListFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := listedIDs(all); !reflect.DeepEqual(got, []string{"task-6", "task-5", "task-4", "task-3", "task-2", "task-1", "task-0"}) {
+				t.Fatalf("unfiltered order = %v", got)
+			}
+
+			minScore, maxScore := 55, 75
+			filter := task.ListFilter{
+				Statuses:      []task.TaskStatus{task.TaskPartial},
+				Variant:       "wechat4x",
+				MinScore:      &minScore,
+				MaxScore:      &maxScore,
+				CreatedBefore: base.Add(6 * time.Hour),
+				Limit:         1,
+			}
+			var pages [][]string
+			for {
+				page, err := repo.List(ctx, filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages = append(pages, listedIDs(page))
+				if page.NextCursor == "" {
+					break
+				}
+				filter.Cursor = page.NextCursor
+			}
+			if !reflect.DeepEqual(pages, [][]string{{"task-4"}}) {
+				t.Fatalf("filtered pages = %v", pages)
+			}
+
+			filter = task.ListFilter{CreatedAfter: base.Add(time.Hour), Limit: 2}
+			var seen []string
+			for {
+				page, err := repo.List(ctx, filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				seen = append(seen, listedIDs(page)...)
+				if page.NextCursor == "" {
+					break
+				}
+				filter.Cursor = page.NextCursor
+			}
+			if !reflect.DeepEqual(seen, []string{"task-6", "task-5", "task-4", "task-3", "task-2", "task-1"}) {
+				t.Fatalf("paged ids = %v", seen)
+			}
+
+			if err := repo.Delete(ctx, "task-6"); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Delete(ctx, "task-6"); err != nil {
+				t.Fatalf("deleting a missing task: %v", err)
+			}
+			if _, err := repo.Get(ctx, "task-6"); !errors.Is(err, ErrTaskNotFound) {
+				t.Fatalf("deleted task lookup error = %v", err)
+			}
+			if page, err := repo.List(ctx, task.ListFilter{Limit: 1}); err != nil || !reflect.DeepEqual(listedIDs(page), []string{"task-5"}) {
+				t.Fatalf("listing after delete = %v, %v", page, err)
+			}
+
+			if _, err := repo.List(ctx, task.ListFilter{Cursor: "not a cursor"}); !errors.Is(err, task.ErrInvalidCursor) {
+				t.Fatalf("malformed cursor error = %v", err)
+			}
+		})
+	}
+}
+
+func listedIDs(page *task.ListPage) []string {
+	ids := make([]string, 0, len(page.Tasks))
+	for _, current := range page.Tasks {
+		ids = append(ids, current.ID)
+	}
+	return ids
+}
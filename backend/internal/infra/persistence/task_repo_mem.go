@@ -46,6 +46,34 @@ func (r *memoryTaskRepo) Get(_ context.Context, id string) (*task.Task, error) {
 	return current.Clone(), nil
 }
 
+func (r *memoryTaskRepo) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tasks, id)
+	return nil
+}
+
+func (r *memoryTaskRepo) List(_ context.Context, filter task.ListFilter) (*task.ListPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	page, err := task.PageTasks(mapValues(r.tasks), filter)
+	if err != nil {
+		return nil, err
+	}
+	for i, current := range page.Tasks {
+		page.Tasks[i] = current.Clone()
+	}
+	return page, nil
+}
+
+func mapValues(tasks map[string]*task.Task) []*task.Task {
+	values := make([]*task.Task, 0, len(tasks))
+	for _, current := range tasks {
+		values = append(values, current)
+	}
+	return values
+}
+
 func (r *memoryTaskRepo) deleteUpdatedBefore(before time.Time) int {
 	r.mu.Lock()
 	defer r.mu.Unlock()
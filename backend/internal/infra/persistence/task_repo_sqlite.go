@@ -0,0 +1,619 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	_ "modernc.org/sqlite"
+)
+
+// taskLevelDiagnostic marks rows of the diagnostics table that belong to the
+// task itself rather than to one of its stage results.
+const taskLevelDiagnostic = -1
+
+const sqliteTaskSchema = `
+CREATE TABLE IF NOT EXISTS tasks (
+	id                TEXT PRIMARY KEY,
+	status            TEXT NOT NULL,
+	progress          INTEGER NOT NULL DEFAULT 0,
+	current_stage     TEXT NOT NULL DEFAULT '',
+	current_message   TEXT NOT NULL DEFAULT '',
+	requested_options TEXT NOT NULL,
+	profile           TEXT,
+	suspected_variant TEXT NOT NULL DEFAULT '',
+	artifacts         TEXT,
+	score             TEXT,
+	overall_score     INTEGER,
+	error_code        TEXT,
+	error_message     TEXT,
+	failure_cause     TEXT,
+	created_at        INTEGER,
+	updated_at        INTEGER,
+	completed_at      INTEGER,
+	parent_task_id    TEXT
+);
+CREATE INDEX IF NOT EXISTS tasks_status_created ON tasks (status, created_at);
+CREATE INDEX IF NOT EXISTS tasks_variant_created ON tasks (suspected_variant, created_at);
+CREATE INDEX IF NOT EXISTS tasks_created ON tasks (created_at);
+CREATE INDEX IF NOT EXISTS tasks_updated ON tasks (updated_at);
+
+CREATE TABLE IF NOT EXISTS stage_results (
+	task_id          TEXT NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+	position         INTEGER NOT NULL,
+	stage            TEXT NOT NULL,
+	success          INTEGER NOT NULL,
+	partial          INTEGER NOT NULL,
+	status           TEXT NOT NULL,
+	started_at       INTEGER,
+	finished_at      INTEGER,
+	duration_ms      INTEGER NOT NULL,
+	attempt          INTEGER NOT NULL,
+	engine           TEXT NOT NULL,
+	message          TEXT NOT NULL,
+	source_breakdown TEXT,
+	metrics          TEXT,
+	PRIMARY KEY (task_id, position)
+);
+CREATE INDEX IF NOT EXISTS stage_results_stage ON stage_results (stage, success);
+
+CREATE TABLE IF NOT EXISTS diagnostics (
+	task_id        TEXT NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+	stage_position INTEGER NOT NULL,
+	position       INTEGER NOT NULL,
+	code           TEXT NOT NULL,
+	severity       TEXT NOT NULL,
+	message        TEXT NOT NULL,
+	file           TEXT NOT NULL,
+	stage          TEXT NOT NULL,
+	metadata       TEXT,
+	PRIMARY KEY (task_id, stage_position, position)
+);
+CREATE INDEX IF NOT EXISTS diagnostics_code ON diagnostics (code, severity);
+`
+
+type sqliteTaskRepo struct {
+	db *sql.DB
+}
+
+// NewSQLiteTaskRepo opens, or creates, the task database at path. The
+// database lives in a private directory and inherits the task-state
+// protections: deleted rows are overwritten, and workspaces of tasks that
+// were already terminal are scrubbed on startup like the file driver does.
+func NewSQLiteTaskRepo(path string) (task.Repository, error) {
+	baseDir := filepath.Dir(path)
+	if err := os.MkdirAll(baseDir, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(baseDir, 0700); err != nil {
+		return nil, err
+	}
+	// Create the file up front so SQLite, which copies the database mode to
+	// its WAL and shared-memory files, never sees a umask-derived mode.
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return nil, err
+	}
+
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_pragma=secure_delete(1)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteTaskSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initialize task database: %w", err)
+	}
+	if err := migrateSQLiteTaskSchema(db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate task database: %w", err)
+	}
+	repo := &sqliteTaskRepo{db: db}
+	if err := repo.secureTerminalWorkspaces(filepath.Dir(baseDir)); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("sanitize terminal task workspace (%T)", err)
+	}
+	return repo, nil
+}
+
+// migrateSQLiteTaskSchema adds the columns a database created by an earlier
+// release lacks. CREATE TABLE IF NOT EXISTS leaves existing tables alone.
+func migrateSQLiteTaskSchema(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('tasks')`)
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return err
+	}
+	if !columns["parent_task_id"] {
+		if _, err := db.Exec(`ALTER TABLE tasks ADD COLUMN parent_task_id TEXT`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *sqliteTaskRepo) secureTerminalWorkspaces(tempRoot string) error {
+	rows, err := r.db.Query(`SELECT id FROM tasks WHERE status IN (?, ?, ?, ?)`,
+		string(task.TaskCompleted), string(task.TaskPartial), string(task.TaskFailed), string(task.TaskCancelled))
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if parsed, err := uuid.Parse(id); err == nil && parsed.String() == id {
+			if err := cleanAndSecureTerminalTaskTree(tempRoot, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *sqliteTaskRepo) Create(ctx context.Context, t *task.Task) error {
+	return r.write(ctx, t)
+}
+
+func (r *sqliteTaskRepo) Update(ctx context.Context, t *task.Task) error {
+	return r.write(ctx, t)
+}
+
+func (r *sqliteTaskRepo) write(ctx context.Context, t *task.Task) (retErr error) {
+	if t == nil {
+		return fmt.Errorf("task is nil")
+	}
+	if err := validateSQLiteTaskID(t.ID); err != nil {
+		return err
+	}
+	options, err := sanitizedJSON(t.RequestedOptions)
+	if err != nil {
+		return err
+	}
+	var profile, artifacts, score sql.NullString
+	var variant string
+	var overall sql.NullInt64
+	if t.PackageProfile != nil {
+		if profile, err = sanitizedNullJSON(t.PackageProfile); err != nil {
+			return err
+		}
+		variant = t.PackageProfile.SuspectedVariant
+	}
+	if t.ArtifactSummary != nil {
+		if artifacts, err = sanitizedNullJSON(t.ArtifactSummary); err != nil {
+			return err
+		}
+	}
+	if t.RecoveryScore != nil {
+		if score, err = sanitizedNullJSON(t.RecoveryScore); err != nil {
+			return err
+		}
+		overall = sql.NullInt64{Int64: int64(t.RecoveryScore.Overall), Valid: true}
+	}
+	var completedAt sql.NullInt64
+	if t.CompletedAt != nil {
+		completedAt = unixNanos(*t.CompletedAt)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO tasks (id, status, progress, current_stage, current_message, requested_options, profile,
+	suspected_variant, artifacts, score, overall_score, error_code, error_message, failure_cause,
+	created_at, updated_at, completed_at, parent_task_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET
+	status = excluded.status, progress = excluded.progress, current_stage = excluded.current_stage,
+	current_message = excluded.current_message, requested_options = excluded.requested_options,
+	profile = excluded.profile, suspected_variant = excluded.suspected_variant,
+	artifacts = excluded.artifacts, score = excluded.score, overall_score = excluded.overall_score,
+	error_code = excluded.error_code, error_message = excluded.error_message,
+	failure_cause = excluded.failure_cause, created_at = excluded.created_at,
+	updated_at = excluded.updated_at, completed_at = excluded.completed_at,
+	parent_task_id = excluded.parent_task_id`,
+		t.ID, string(t.Status), t.Progress, t.CurrentStage, t.CurrentMessage, options, profile,
+		variant, artifacts, score, overall, nullString(t.ErrorCode), nullString(t.ErrorMessage),
+		nullString(t.FailureCause), unixNanos(t.CreatedAt), unixNanos(t.UpdatedAt), completedAt,
+		sql.NullString{String: t.ParentTaskID, Valid: t.ParentTaskID != ""},
+	); err != nil {
+		return err
+	}
+	// Stage results and diagnostics are rewritten as a whole; a task carries
+	// at most a few dozen of each.
+	for _, statement := range []string{`DELETE FROM stage_results WHERE task_id = ?`, `DELETE FROM diagnostics WHERE task_id = ?`} {
+		if _, err := tx.ExecContext(ctx, statement, t.ID); err != nil {
+			return err
+		}
+	}
+	for i, stage := range t.StageResults {
+		breakdown, err := sanitizedNullJSON(stage.SourceBreakdown)
+		if err != nil {
+			return err
+		}
+		metrics, err := sanitizedNullJSON(stage.Metrics)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO stage_results (task_id, position, stage, success, partial, status, started_at, finished_at,
+	duration_ms, attempt, engine, message, source_breakdown, metrics)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			t.ID, i, stage.Stage, stage.Success, stage.Partial, stage.Status, unixNanos(stage.StartedAt),
+			unixNanos(stage.FinishedAt), stage.DurationMs, stage.Attempt, stage.Engine, stage.Message,
+			breakdown, metrics,
+		); err != nil {
+			return err
+		}
+		if err := insertDiagnostics(ctx, tx, t.ID, i, stage.Diagnostics); err != nil {
+			return err
+		}
+	}
+	if err := insertDiagnostics(ctx, tx, t.ID, taskLevelDiagnostic, t.Diagnostics); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertDiagnostics(ctx context.Context, tx *sql.Tx, taskID string, stagePosition int, diagnostics []pkg.Diagnostic) error {
+	for i, diagnostic := range diagnostics {
+		metadata, err := sanitizedNullJSON(diagnostic.Metadata)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO diagnostics (task_id, stage_position, position, code, severity, message, file, stage, metadata)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			taskID, stagePosition, i, diagnostic.Code, string(diagnostic.Severity), diagnostic.Message,
+			diagnostic.File, diagnostic.Stage, metadata,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *sqliteTaskRepo) Get(ctx context.Context, id string) (*task.Task, error) {
+	if err := validateSQLiteTaskID(id); err != nil {
+		return nil, err
+	}
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current := &task.Task{ID: id}
+	var status, options string
+	var profile, artifacts, score, errorCode, errorMessage, failureCause, parentTaskID sql.NullString
+	var createdAt, updatedAt, completedAt sql.NullInt64
+	err = tx.QueryRowContext(ctx, `
+SELECT status, progress, current_stage, current_message, requested_options, profile, artifacts, score,
+	error_code, error_message, failure_cause, created_at, updated_at, completed_at, parent_task_id
+FROM tasks WHERE id = ?`, id).Scan(
+		&status, &current.Progress, &current.CurrentStage, &current.CurrentMessage, &options, &profile,
+		&artifacts, &score, &errorCode, &errorMessage, &failureCause, &createdAt, &updatedAt, &completedAt,
+		&parentTaskID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	current.Status = task.TaskStatus(status)
+	current.ParentTaskID = parentTaskID.String
+	current.CreatedAt = fromUnixNanos(createdAt)
+	current.UpdatedAt = fromUnixNanos(updatedAt)
+	if completedAt.Valid {
+		completed := fromUnixNanos(completedAt)
+		current.CompletedAt = &completed
+	}
+	current.ErrorCode = stringPointer(errorCode)
+	current.ErrorMessage = stringPointer(errorMessage)
+	current.FailureCause = stringPointer(failureCause)
+	if err := json.Unmarshal([]byte(options), &current.RequestedOptions); err != nil {
+		return nil, err
+	}
+	if err := decodeNullJSON(profile, &current.PackageProfile); err != nil {
+		return nil, err
+	}
+	if err := decodeNullJSON(artifacts, &current.ArtifactSummary); err != nil {
+		return nil, err
+	}
+	if err := decodeNullJSON(score, &current.RecoveryScore); err != nil {
+		return nil, err
+	}
+
+	if err := loadStageResults(ctx, tx, current); err != nil {
+		return nil, err
+	}
+	if err := loadDiagnostics(ctx, tx, current); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// Delete removes the task; its stage results and diagnostics go with it
+// through the foreign keys, and secure_delete overwrites the freed pages.
+func (r *sqliteTaskRepo) Delete(ctx context.Context, id string) error {
+	if err := validateSQLiteTaskID(id); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `DELETE FROM tasks WHERE id = ?`, id)
+	return err
+}
+
+// List resolves the filter and ordering on the indexed task columns and
+// then loads each task on the page in full.
+func (r *sqliteTaskRepo) List(ctx context.Context, filter task.ListFilter) (*task.ListPage, error) {
+	cursor, err := task.ParseListCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	var conditions []string
+	var args []interface{}
+	if len(filter.Statuses) > 0 {
+		placeholders := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			placeholders[i] = "?"
+			args = append(args, string(status))
+		}
+		conditions = append(conditions, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if filter.Variant != "" {
+		conditions = append(conditions, "suspected_variant = ?")
+		args = append(args, filter.Variant)
+	}
+	if filter.MinScore != nil {
+		conditions = append(conditions, "overall_score >= ?")
+		args = append(args, *filter.MinScore)
+	}
+	if filter.MaxScore != nil {
+		conditions = append(conditions, "overall_score <= ?")
+		args = append(args, *filter.MaxScore)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.CreatedAfter.UnixNano())
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conditions = append(conditions, "COALESCE(created_at, 0) < ?")
+		args = append(args, filter.CreatedBefore.UnixNano())
+	}
+	if cursor != nil {
+		conditions = append(conditions, "(COALESCE(created_at, 0) < ? OR COALESCE(created_at, 0) = ? AND id < ?)")
+		args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+	query := "SELECT id FROM tasks"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := filter.PageLimit()
+	query += " ORDER BY COALESCE(created_at, 0) DESC, id DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return nil, err
+	}
+
+	page := &task.ListPage{}
+	more := len(ids) > limit
+	if more {
+		ids = ids[:limit]
+	}
+	for _, id := range ids {
+		current, err := r.Get(ctx, id)
+		if errors.Is(err, ErrTaskNotFound) {
+			// Expired between the two queries.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		page.Tasks = append(page.Tasks, current)
+	}
+	if more && len(page.Tasks) > 0 {
+		page.NextCursor = task.CursorAfter(page.Tasks[len(page.Tasks)-1])
+	}
+	return page, nil
+}
+
+func loadStageResults(ctx context.Context, tx *sql.Tx, current *task.Task) error {
+	rows, err := tx.QueryContext(ctx, `
+SELECT stage, success, partial, status, started_at, finished_at, duration_ms, attempt, engine, message,
+	source_breakdown, metrics
+FROM stage_results WHERE task_id = ? ORDER BY position`, current.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var stage task.StageResult
+		var startedAt, finishedAt sql.NullInt64
+		var breakdown, metrics sql.NullString
+		if err := rows.Scan(&stage.Stage, &stage.Success, &stage.Partial, &stage.Status, &startedAt, &finishedAt,
+			&stage.DurationMs, &stage.Attempt, &stage.Engine, &stage.Message, &breakdown, &metrics); err != nil {
+			return err
+		}
+		stage.StartedAt = fromUnixNanos(startedAt)
+		stage.FinishedAt = fromUnixNanos(finishedAt)
+		if err := decodeNullJSON(breakdown, &stage.SourceBreakdown); err != nil {
+			return err
+		}
+		if err := decodeNullJSON(metrics, &stage.Metrics); err != nil {
+			return err
+		}
+		current.StageResults = append(current.StageResults, stage)
+	}
+	return rows.Err()
+}
+
+func loadDiagnostics(ctx context.Context, tx *sql.Tx, current *task.Task) error {
+	rows, err := tx.QueryContext(ctx, `
+SELECT stage_position, code, severity, message, file, stage, metadata
+FROM diagnostics WHERE task_id = ? ORDER BY stage_position, position`, current.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var stagePosition int
+		var diagnostic pkg.Diagnostic
+		var severity string
+		var metadata sql.NullString
+		if err := rows.Scan(&stagePosition, &diagnostic.Code, &severity, &diagnostic.Message,
+			&diagnostic.File, &diagnostic.Stage, &metadata); err != nil {
+			return err
+		}
+		diagnostic.Severity = pkg.DiagnosticSeverity(severity)
+		if err := decodeNullJSON(metadata, &diagnostic.Metadata); err != nil {
+			return err
+		}
+		switch {
+		case stagePosition == taskLevelDiagnostic:
+			current.Diagnostics = append(current.Diagnostics, diagnostic)
+		case stagePosition >= 0 && stagePosition < len(current.StageResults):
+			stage := &current.StageResults[stagePosition]
+			stage.Diagnostics = append(stage.Diagnostics, diagnostic)
+		}
+	}
+	return rows.Err()
+}
+
+func (r *sqliteTaskRepo) deleteUpdatedBefore(before time.Time) int {
+	result, err := r.db.Exec(`DELETE FROM tasks WHERE COALESCE(updated_at, created_at) < ?`, before.UnixNano())
+	if err != nil {
+		return 0
+	}
+	removed, _ := result.RowsAffected()
+	return int(removed)
+}
+
+// validateSQLiteTaskID applies the file driver's task ID rules, so an ID
+// accepted by one driver is accepted by the other.
+func validateSQLiteTaskID(id string) error {
+	if id == "" || id == "." || id == ".." || filepath.Base(id) != id || filepath.VolumeName(id) != "" || strings.IndexByte(id, 0) >= 0 {
+		return fmt.Errorf("invalid task id")
+	}
+	return nil
+}
+
+// sanitizedJSON encodes value and strips the keys the file driver scrubs
+// from legacy records, so no path or AppID reaches a JSON column.
+func sanitizedJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return "", err
+	}
+	if !removeLegacySensitiveFields(document) {
+		return string(data), nil
+	}
+	data, err = json.Marshal(document)
+	return string(data), err
+}
+
+func sanitizedNullJSON(value interface{}) (sql.NullString, error) {
+	data, err := sanitizedJSON(value)
+	// Empty maps are dropped like the omitempty JSON fields they come from.
+	if err != nil || data == "null" || data == "{}" {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: data, Valid: true}, nil
+}
+
+func decodeNullJSON(value sql.NullString, target interface{}) error {
+	if !value.Valid {
+		return nil
+	}
+	return json.Unmarshal([]byte(value.String), target)
+}
+
+func nullString(value *string) sql.NullString {
+	if value == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *value, Valid: true}
+}
+
+func stringPointer(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	result := value.String
+	return &result
+}
+
+// unixNanos stores times as integers so range scans use the indexes; the
+// zero time is stored as NULL.
+func unixNanos(value time.Time) sql.NullInt64 {
+	if value.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: value.UnixNano(), Valid: true}
+}
+
+func fromUnixNanos(value sql.NullInt64) time.Time {
+	if !value.Valid {
+		return time.Time{}
+	}
+	return time.Unix(0, value.Int64)
+}
@@ -0,0 +1,241 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+)
+
+func newTestSQLiteTaskRepo(t *testing.T, path string) *sqliteTaskRepo {
+	t.Helper()
+	repo, err := NewSQLiteTaskRepo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := repo.(*sqliteTaskRepo)
+	t.Cleanup(func() { _ = current.db.Close() })
+	return current
+}
+
+func TestSQLiteTaskRepoRoundTripsStagesAndDiagnostics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "task-state", "tasks.db")
+	repo := newTestSQLiteTaskRepo(t, path)
+	ctx := context.Background()
+
+	created := time.Date(2026, 3, 2, 10, 0, 0, 123456789, time.UTC)
+	completed := created.Add(time.Minute)
+	code := "partial_recovery"
+	current := &task.Task{
+		ID:               "task-1",
+		Status:           task.TaskPartial,
+		RequestedOptions: task.RequestedOptions{Beautify: true, Decompile: true},
+		ParentTaskID:     "task-0",
+		PackageProfile:   &pkg.PackageProfile{IsEncrypted: true, SuspectedVariant: "wechat4x"},
+		ArtifactSummary:  &task.ArtifactSummary{FileCount: 2, DownloadReady: true, ZipPath: "/data/output/task-1.zip"},
+		RecoveryScore:    &task.RecoveryScore{Overall: 72, JS: 80},
+		StageResults: []task.StageResult{
+			{Stage: "classifying", Success: true, Status: "success", StartedAt: created, FinishedAt: created.Add(time.Second), DurationMs: 1000},
+			{
+				Stage:           "recovering_wxml",
+				Partial:         true,
+				Status:          "partial",
+				StartedAt:       created.Add(2 * time.Second),
+				SourceBreakdown: map[string]int{"native": 3},
+				Metrics:         map[string]interface{}{"fileCount": 2, "zipPath": "/data/output/private.zip"},
+				Diagnostics:     []pkg.Diagnostic{pkg.Warn("recover.wxml.partial", "部分模板未能恢复", "recovering_wxml", "pages/index.wxml")},
+			},
+		},
+		Diagnostics: []pkg.Diagnostic{
+			pkg.Info("classify.variant", "识别为 4.x 包", "classifying", ""),
+			{Code: "recover.js.partial", Severity: pkg.SeverityWarn, Message: "部分脚本未能恢复", Metadata: map[string]interface{}{"appId": "wx0123456789abcdef", "count": 1}},
+		},
+		ErrorCode:   &code,
+		Progress:    100,
+		CreatedAt:   created,
+		UpdatedAt:   completed,
+		CompletedAt: &completed,
+	}
+	if err := repo.Create(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+	current.StageResults = current.StageResults[1:]
+	current.Diagnostics = current.Diagnostics[1:]
+	if err := repo.Update(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := repo.Get(ctx, current.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Status != task.TaskPartial || loaded.Progress != 100 || !loaded.RequestedOptions.Decompile || loaded.ParentTaskID != "task-0" {
+		t.Fatalf("task fields not restored: %#v", loaded)
+	}
+	if !loaded.CreatedAt.Equal(created) || loaded.CompletedAt == nil || !loaded.CompletedAt.Equal(completed) {
+		t.Fatalf("times = %v / %v", loaded.CreatedAt, loaded.CompletedAt)
+	}
+	if loaded.ErrorCode == nil || *loaded.ErrorCode != code || loaded.ErrorMessage != nil {
+		t.Fatalf("error fields = %v / %v", loaded.ErrorCode, loaded.ErrorMessage)
+	}
+	if loaded.PackageProfile.SuspectedVariant != "wechat4x" || loaded.RecoveryScore.Overall != 72 || loaded.ArtifactSummary.ZipPath != "" {
+		t.Fatalf("nested documents = %#v %#v %#v", loaded.PackageProfile, loaded.RecoveryScore, loaded.ArtifactSummary)
+	}
+	if len(loaded.StageResults) != 1 || len(loaded.Diagnostics) != 1 {
+		t.Fatalf("update did not replace stages and diagnostics: %d / %d", len(loaded.StageResults), len(loaded.Diagnostics))
+	}
+	stage := loaded.StageResults[0]
+	if stage.Stage != "recovering_wxml" || !stage.Partial || stage.SourceBreakdown["native"] != 3 || !stage.FinishedAt.IsZero() {
+		t.Fatalf("stage = %#v", stage)
+	}
+	if stage.Metrics["fileCount"] != float64(2) || stage.Metrics["zipPath"] != nil {
+		t.Fatalf("stage metrics = %#v", stage.Metrics)
+	}
+	if len(stage.Diagnostics) != 1 || stage.Diagnostics[0].File != "pages/index.wxml" {
+		t.Fatalf("stage diagnostics = %#v", stage.Diagnostics)
+	}
+	diagnostic := loaded.Diagnostics[0]
+	if diagnostic.Severity != pkg.SeverityWarn || diagnostic.Metadata["count"] != float64(1) || diagnostic.Metadata["appId"] != nil {
+		t.Fatalf("task diagnostic = %#v", diagnostic)
+	}
+
+	if _, err := repo.Get(ctx, "missing"); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("Get(missing) = %v, want ErrTaskNotFound", err)
+	}
+	for _, id := range []string{"", "../escape", "nested/task", ".."} {
+		if err := repo.Create(ctx, &task.Task{ID: id}); err == nil {
+			t.Fatalf("expected task id %q to be rejected", id)
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != 0600 {
+			t.Fatalf("database mode = %o, want 600", got)
+		}
+	}
+}
+
+func TestSQLiteTaskRepoMigratesDatabaseWithoutParentColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "task-state", "tasks.db")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSchema := strings.Replace(sqliteTaskSchema, ",\n\tparent_task_id    TEXT", "", 1)
+	if oldSchema == sqliteTaskSchema {
+		t.Fatal("test could not derive the schema without parent_task_id")
+	}
+	if _, err := db.Exec(oldSchema); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO tasks (id, status, requested_options) VALUES ('task-old', 'completed', '{}')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := newTestSQLiteTaskRepo(t, path)
+	ctx := context.Background()
+	if old, err := repo.Get(ctx, "task-old"); err != nil || old.ParentTaskID != "" {
+		t.Fatalf("existing row after migration = %v, %v", old, err)
+	}
+	if err := repo.Create(ctx, &task.Task{ID: "task-new", Status: task.TaskQueued, ParentTaskID: "task-old"}); err != nil {
+		t.Fatal(err)
+	}
+	if child, err := repo.Get(ctx, "task-new"); err != nil || child.ParentTaskID != "task-old" {
+		t.Fatalf("child after migration = %v, %v", child, err)
+	}
+}
+
+func TestSQLiteTaskRepoSharesDatabaseAndExpiresOldTasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "task-state", "tasks.db")
+	repoA := newTestSQLiteTaskRepo(t, path)
+	repoB := newTestSQLiteTaskRepo(t, path)
+	ctx := context.Background()
+
+	now := time.Now()
+	old := &task.Task{ID: "old", Status: task.TaskCompleted, CreatedAt: now.Add(-48 * time.Hour), UpdatedAt: now.Add(-48 * time.Hour),
+		Diagnostics: []pkg.Diagnostic{pkg.Info("classify.variant", "识别为标准包", "classifying", "")}}
+	fresh := &task.Task{ID: "fresh", Status: task.TaskQueued, CreatedAt: now, UpdatedAt: now}
+	for _, current := range []*task.Task{old, fresh} {
+		if err := repoA.Create(ctx, current); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var writers sync.WaitGroup
+	errorsCh := make(chan error, 2)
+	for _, repo := range []task.Repository{repoA, repoB} {
+		writers.Add(1)
+		go func(repo task.Repository) {
+			defer writers.Done()
+			for i := 0; i < 50; i++ {
+				current := fresh.Clone()
+				current.Progress = i
+				current.StageResults = []task.StageResult{{Stage: "classifying", Attempt: i}}
+				if err := repo.Update(ctx, current); err != nil {
+					errorsCh <- err
+					return
+				}
+			}
+		}(repo)
+	}
+	writers.Wait()
+	close(errorsCh)
+	for err := range errorsCh {
+		t.Fatalf("concurrent Update returned error: %v", err)
+	}
+	loaded, err := repoB.Get(ctx, fresh.ID)
+	if err != nil || len(loaded.StageResults) != 1 {
+		t.Fatalf("Get after concurrent updates = %#v, %v", loaded, err)
+	}
+
+	if removed := repoB.deleteUpdatedBefore(now.Add(-24 * time.Hour)); removed != 1 {
+		t.Fatalf("deleteUpdatedBefore removed %d tasks, want 1", removed)
+	}
+	if _, err := repoA.Get(ctx, old.ID); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expired task still readable: %v", err)
+	}
+	var orphans int
+	if err := repoA.db.QueryRow(`SELECT COUNT(*) FROM diagnostics WHERE task_id = ?`, old.ID).Scan(&orphans); err != nil || orphans != 0 {
+		t.Fatalf("expired task left %d diagnostics (%v)", orphans, err)
+	}
+	if _, err := repoA.Get(ctx, fresh.ID); err != nil {
+		t.Fatalf("fresh task was removed: %v", err)
+	}
+}
+
+func TestSQLiteTaskRepoAppliesConnectionPragmas(t *testing.T) {
+	repo := newTestSQLiteTaskRepo(t, filepath.Join(t.TempDir(), "task-state", "tasks.db"))
+	for pragma, want := range map[string]string{
+		"journal_mode":  "wal",
+		"busy_timeout":  "5000",
+		"foreign_keys":  "1",
+		"secure_delete": "1",
+	} {
+		var got string
+		if err := repo.db.QueryRow("PRAGMA " + pragma).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("PRAGMA %s = %q, want %q", pragma, got, want)
+		}
+	}
+}
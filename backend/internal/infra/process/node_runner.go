@@ -44,6 +44,10 @@ func (r *NodeRunner) RunInDir(ctx context.Context, dir string, script string, ar
 	cmdArgs = append(cmdArgs, args...)
 
 	cmd := exec.CommandContext(runCtx, binaryName, cmdArgs...)
+	startInProcessGroup(cmd)
+	// A killed group member can leave the output pipes open in a grandchild;
+	// stop waiting for them shortly after the process itself is gone.
+	cmd.WaitDelay = 5 * time.Second
 	if dir != "" {
 		cmd.Dir = dir
 	}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package process
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startInProcessGroup puts the command in its own process group and makes
+// cancellation kill the whole group, so helpers a Node script spawned do
+// not outlive it.
+func startInProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
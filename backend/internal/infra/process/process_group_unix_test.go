@@ -0,0 +1,31 @@
+//go:build !windows
+
+package process
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunnerCancellationKillsChildProcesses(t *testing.T) {
+	shell, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	runner := &NodeRunner{Binary: shell}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	// The background sleep inherits the output pipes. Unless it is killed
+	// with its parent, Run waits out WaitDelay before returning.
+	started := time.Now()
+	_, _, err = runner.Run(ctx, "-c", "sleep 30 & wait")
+	if err == nil {
+		t.Fatal("cancelled run returned no error")
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Fatalf("cancelled run took %s; child process outlived its parent", elapsed)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package process
+
+import "os/exec"
+
+// startInProcessGroup keeps exec's default on Windows, where cancellation
+// kills only the direct child.
+func startInProcessGroup(*exec.Cmd) {}
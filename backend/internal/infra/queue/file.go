@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -96,6 +97,39 @@ func (q *FileQueue) Wait() {
 	q.workers.Wait()
 }
 
+// Remove deletes the task's pending and dead-lettered jobs. A claimed job
+// stays with its worker, which removes it once the handler returns.
+func (q *FileQueue) Remove(ctx context.Context, taskID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	suffix := "-" + taskIDToken(taskID) + ".job"
+	var errs []error
+	for _, dir := range []string{q.queueDir, q.dlqDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed := false
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !(strings.HasSuffix(name, suffix) || strings.HasSuffix(name, suffix+".invalid")) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+				continue
+			}
+			removed = true
+		}
+		if removed {
+			errs = append(errs, syncQueueDirectory(dir))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (q *FileQueue) handleJob(ctx context.Context, claimedPath string, job fileQueueJob, handler func(context.Context, string) error) {
 	stopHeartbeat := q.startLeaseHeartbeat(ctx, claimedPath)
 	err := invokeHandler(handler, ctx, job.TaskID)
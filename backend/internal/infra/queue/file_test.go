@@ -229,3 +229,34 @@ func TestFileQueueRepeatedReclaimsKeepFilenameBounded(t *testing.T) {
 		}
 	}
 }
+
+func TestFileQueueRemoveDropsPendingAndDeadLetteredJobs(t *testing.T) {
+	q := newTestFileQueue(t)
+	ctx := context.Background()
+	for _, taskID := range []string{"task-removed", "task-kept"} {
+		if err := q.Enqueue(ctx, taskID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.writeJob(q.dlqDir, fileQueueJob{TaskID: "task-removed", Retries: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Remove(ctx, "task-removed"); err != nil {
+		t.Fatalf("Remove returned error: %v", err)
+	}
+	if entries, _ := os.ReadDir(q.dlqDir); len(entries) != 0 {
+		t.Fatalf("dead-lettered job survived removal: %v", entries)
+	}
+	entries, err := os.ReadDir(q.queueDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the other task's job to remain, got %d", len(entries))
+	}
+	job, err := q.readJob(filepath.Join(q.queueDir, entries[0].Name()))
+	if err != nil || job.TaskID != "task-kept" {
+		t.Fatalf("remaining job = %+v, %v", job, err)
+	}
+}
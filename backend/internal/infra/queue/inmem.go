@@ -12,6 +12,9 @@ type JobQueue interface {
 	Enqueue(ctx context.Context, taskID string) error
 	StartWorkers(ctx context.Context, workers int, handler func(context.Context, string) error)
 	Wait()
+	// Remove withdraws the task's waiting jobs. A job already handed to a
+	// worker is left alone; the handler is expected to drop it.
+	Remove(ctx context.Context, taskID string) error
 }
 
 type InMemoryQueue struct {
@@ -76,6 +79,12 @@ func (q *InMemoryQueue) Wait() {
 	q.workers.Wait()
 }
 
+// Remove is a no-op: a buffered channel cannot give a job back. The handler
+// finds the task gone when the job comes up and returns without retrying.
+func (q *InMemoryQueue) Remove(context.Context, string) error {
+	return nil
+}
+
 func (q *InMemoryQueue) handleMemoryJob(ctx context.Context, job memoryJob, handler func(context.Context, string) error) {
 	for {
 		err := invokeHandler(handler, ctx, job.taskID)
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrBatchEmpty           = errors.New("batch contains no .wxapkg packages")
+	ErrBatchTooManyPackages = errors.New("batch exceeds the package limit")
+	ErrBatchPackageTooLarge = errors.New("batch package exceeds the size limit")
+	ErrBatchFormat          = errors.New("unsupported batch archive format")
+)
+
+// BatchPackage is one .wxapkg found in a batch input. Name is the slash
+// separated path relative to the archive or directory root and is only used
+// as a label; Path is where the bytes can be read from.
+type BatchPackage struct {
+	Name string
+	Path string
+}
+
+type BatchLimits struct {
+	MaxPackages    int
+	MaxPackageSize int64
+}
+
+func BatchRecordPath(tempDir, batchID string) string {
+	return filepath.Join(tempDir, "batches", batchID+".json")
+}
+
+// DiffRecordPath sits next to the batch records and shares their retention.
+func DiffRecordPath(tempDir, diffID string) string {
+	return filepath.Join(tempDir, "diffs", diffID+".json")
+}
+
+// DiffReportPath holds the report computed once both sides of a diff have
+// finished; it expires with the diff record.
+func DiffReportPath(tempDir, diffID string) string {
+	return filepath.Join(tempDir, "diffs", "reports", diffID+".json")
+}
+
+// StageBatchUpload copies an uploaded batch archive into stagingDir.
+func StageBatchUpload(stagingDir string, file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	dstPath := filepath.Join(stagingDir, "batch.archive")
+	if err := writePrivateFileAtomic(dstPath, func(dst *os.File) error {
+		_, err := io.Copy(dst, src)
+		return err
+	}); err != nil {
+		return "", err
+	}
+	return dstPath, nil
+}
+
+// CollectBatchPackages lists the .wxapkg files below root, such as a WeChat
+// `Applet/<appid>/<version>/` tree. Files are read in place; symlinks are
+// skipped so the walk cannot escape root.
+func CollectBatchPackages(root string, limits BatchLimits) ([]BatchPackage, error) {
+	var packages []BatchPackage
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || !isPackageName(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > limits.MaxPackageSize {
+			return fmt.Errorf("%w: %s", ErrBatchPackageTooLarge, entry.Name())
+		}
+		if len(packages) >= limits.MaxPackages {
+			return ErrBatchTooManyPackages
+		}
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		packages = append(packages, BatchPackage{Name: filepath.ToSlash(relative), Path: path})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return finishBatchPackages(packages)
+}
+
+// ExtractBatchArchive stages every .wxapkg entry of a zip, tar or tar.gz
+// archive into stagingDir under generated names. Entry names never become
+// filesystem paths, and every entry is size-bounded while it is copied, so a
+// crafted archive can neither traverse nor exhaust the staging directory.
+func ExtractBatchArchive(archivePath, stagingDir string, limits BatchLimits) ([]BatchPackage, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	header = header[:n]
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	extractor := &batchExtractor{stagingDir: stagingDir, limits: limits}
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		err = extractor.fromZip(file, info.Size())
+		if err != nil {
+			return nil, err
+		}
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bufio.NewReader(file))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBatchFormat, err)
+		}
+		defer gz.Close()
+		if err := extractor.fromTar(gz); err != nil {
+			return nil, err
+		}
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		if err := extractor.fromTar(file); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrBatchFormat
+	}
+	return finishBatchPackages(extractor.packages)
+}
+
+type batchExtractor struct {
+	stagingDir string
+	limits     BatchLimits
+	packages   []BatchPackage
+}
+
+func (e *batchExtractor) fromZip(reader io.ReaderAt, size int64) error {
+	archive, err := zip.NewReader(reader, size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBatchFormat, err)
+	}
+	for _, entry := range archive.File {
+		if !entry.Mode().IsRegular() || !isPackageName(entry.Name) {
+			continue
+		}
+		if entry.UncompressedSize64 > uint64(e.limits.MaxPackageSize) {
+			return fmt.Errorf("%w: %s", ErrBatchPackageTooLarge, pathBase(entry.Name))
+		}
+		src, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = e.stage(entry.Name, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *batchExtractor) fromTar(reader io.Reader) error {
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrBatchFormat, err)
+		}
+		if header.Typeflag != tar.TypeReg || !isPackageName(header.Name) {
+			continue
+		}
+		if header.Size > e.limits.MaxPackageSize {
+			return fmt.Errorf("%w: %s", ErrBatchPackageTooLarge, pathBase(header.Name))
+		}
+		if err := e.stage(header.Name, archive); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *batchExtractor) stage(name string, src io.Reader) error {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "./")
+	if err := ValidateZipEntryPath(name); err != nil {
+		return err
+	}
+	if len(e.packages) >= e.limits.MaxPackages {
+		return ErrBatchTooManyPackages
+	}
+	dstPath := filepath.Join(e.stagingDir, fmt.Sprintf("package-%04d.wxapkg", len(e.packages)))
+	if err := writePrivateFileAtomic(dstPath, func(dst *os.File) error {
+		written, err := io.Copy(dst, io.LimitReader(src, e.limits.MaxPackageSize+1))
+		if err != nil {
+			return err
+		}
+		if written > e.limits.MaxPackageSize {
+			return fmt.Errorf("%w: %s", ErrBatchPackageTooLarge, pathBase(name))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	e.packages = append(e.packages, BatchPackage{Name: name, Path: dstPath})
+	return nil
+}
+
+func finishBatchPackages(packages []BatchPackage) ([]BatchPackage, error) {
+	if len(packages) == 0 {
+		return nil, ErrBatchEmpty
+	}
+	sort.SliceStable(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return packages, nil
+}
+
+func isPackageName(name string) bool {
+	base := pathBase(name)
+	return strings.HasSuffix(strings.ToLower(base), ".wxapkg") && !strings.HasPrefix(base, ".")
+}
+
+func pathBase(name string) string {
+	return pathpkg.Base(filepath.ToSlash(name))
+}
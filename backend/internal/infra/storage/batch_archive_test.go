@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testBatchLimits = BatchLimits{MaxPackages: 10, MaxPackageSize: 1024}
+
+func writeTestZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, content := range files {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "batch.zip")
+	if err := os.WriteFile(path, buffer.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractBatchArchiveStagesOnlyPackagesFromZip(t *testing.T) {
+	archive := writeTestZip(t, map[string]string{
+		"Applet/wx0123456789abcdef/12/__APP__.wxapkg": "main",
+		"Applet/wx0123456789abcdef/12/sub.wxapkg":     "sub",
+		"Applet/readme.txt":                           "ignored",
+	})
+	staging := t.TempDir()
+
+	packages, err := ExtractBatchArchive(archive, staging, testBatchLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{packages[0].Name, packages[1].Name}
+	want := []string{"Applet/wx0123456789abcdef/12/__APP__.wxapkg", "Applet/wx0123456789abcdef/12/sub.wxapkg"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("names = %#v, want %#v", names, want)
+	}
+	for _, item := range packages {
+		if filepath.Dir(item.Path) != staging {
+			t.Fatalf("package staged outside staging dir: %s", item.Path)
+		}
+	}
+	if data, err := os.ReadFile(packages[1].Path); err != nil || string(data) != "sub" {
+		t.Fatalf("staged content = %q err=%v", data, err)
+	}
+}
+
+func TestExtractBatchArchiveReadsTarGzip(t *testing.T) {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	writer := tar.NewWriter(gz)
+	content := []byte("package")
+	if err := writer.WriteHeader(&tar.Header{Name: "./v1/__APP__.wxapkg", Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "batch.tar.gz")
+	if err := os.WriteFile(archive, buffer.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	packages, err := ExtractBatchArchive(archive, t.TempDir(), testBatchLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 1 || packages[0].Name != "v1/__APP__.wxapkg" {
+		t.Fatalf("unexpected packages: %#v", packages)
+	}
+}
+
+func TestExtractBatchArchiveEnforcesLimits(t *testing.T) {
+	for name, tc := range map[string]struct {
+		files map[string]string
+		want  error
+	}{
+		"empty":     {files: map[string]string{"notes.txt": "x"}, want: ErrBatchEmpty},
+		"too large": {files: map[string]string{"big.wxapkg": string(make([]byte, 2048))}, want: ErrBatchPackageTooLarge},
+		"too many":  {files: map[string]string{"a.wxapkg": "a", "b.wxapkg": "b", "c.wxapkg": "c"}, want: ErrBatchTooManyPackages},
+	} {
+		t.Run(name, func(t *testing.T) {
+			limits := testBatchLimits
+			limits.MaxPackages = 2
+			_, err := ExtractBatchArchive(writeTestZip(t, tc.files), t.TempDir(), limits)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestExtractBatchArchiveRejectsUnknownFormatAndTraversal(t *testing.T) {
+	plain := filepath.Join(t.TempDir(), "batch.zip")
+	if err := os.WriteFile(plain, []byte("not an archive"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExtractBatchArchive(plain, t.TempDir(), testBatchLimits); !errors.Is(err, ErrBatchFormat) {
+		t.Fatalf("err = %v, want ErrBatchFormat", err)
+	}
+	if _, err := ExtractBatchArchive(writeTestZip(t, map[string]string{"../escape.wxapkg": "x"}), t.TempDir(), testBatchLimits); err == nil {
+		t.Fatal("expected traversal entry to be rejected")
+	}
+}
+
+func TestCollectBatchPackagesWalksDirectory(t *testing.T) {
+	root := t.TempDir()
+	for _, relative := range []string{"wx0123456789abcdef/2/__APP__.wxapkg", "wx0123456789abcdef/1/__APP__.wxapkg", "notes.txt"} {
+		path := filepath.Join(root, filepath.FromSlash(relative))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(relative), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	packages, err := CollectBatchPackages(root, testBatchLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 2 || packages[0].Name != "wx0123456789abcdef/1/__APP__.wxapkg" {
+		t.Fatalf("unexpected packages: %#v", packages)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	pathpkg "path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -25,13 +26,7 @@ type TaskDirs struct {
 }
 
 func EnsureTaskDirs(base, taskID string) (TaskDirs, error) {
-	root := filepath.Join(base, taskID)
-	dirs := TaskDirs{
-		RootDir:    root,
-		InputDir:   filepath.Join(root, "input"),
-		SourceDir:  filepath.Join(root, "result", "src"),
-		ReportsDir: filepath.Join(root, "result", "reports"),
-	}
+	dirs := TaskDirsFor(base, taskID)
 
 	for _, dir := range []string{dirs.RootDir, dirs.InputDir, dirs.SourceDir, dirs.ReportsDir} {
 		if err := os.MkdirAll(dir, 0700); err != nil {
@@ -48,7 +43,7 @@ func EnsureTaskDirs(base, taskID string) (TaskDirs, error) {
 // ResetTaskWorkspace removes every derived file from an interrupted attempt
 // while preserving the uploaded package and its one-shot AppID secret.
 func ResetTaskWorkspace(dirs TaskDirs) error {
-	for _, path := range []string{filepath.Join(dirs.RootDir, "result"), filepath.Join(dirs.RootDir, "fallback")} {
+	for _, path := range []string{filepath.Join(dirs.RootDir, "result"), filepath.Join(dirs.RootDir, "fallback"), filepath.Join(dirs.RootDir, "subpackages")} {
 		if err := os.RemoveAll(path); err != nil {
 			return err
 		}
@@ -68,6 +63,22 @@ func InputFilePath(dirs TaskDirs) string {
 	return filepath.Join(dirs.InputDir, "input.wxapkg")
 }
 
+// SubPackageInputPath is where the index-th subpackage uploaded with the main
+// package is staged. It lives in InputDir, so DeleteTaskInput removes it too.
+func SubPackageInputPath(dirs TaskDirs, index int) string {
+	return filepath.Join(dirs.InputDir, fmt.Sprintf("subpackage-%03d.wxapkg", index))
+}
+
+// SubPackageInputPaths lists the staged subpackages in upload order.
+func SubPackageInputPaths(dirs TaskDirs) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dirs.InputDir, "subpackage-*.wxapkg"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
 func AppIDSecretPath(dirs TaskDirs) string {
 	return filepath.Join(dirs.InputDir, ".appid")
 }
@@ -119,8 +130,8 @@ func DeleteTaskInput(dirs TaskDirs) error {
 // one-shot AppID in a private per-task directory. Samples are never exposed
 // through the API and are removed by the retention janitor like other
 // artifacts. bestEffort=true means failures are logged, never fatal.
-func SaveDiagnosticSample(samplesDir string, taskID string, data []byte, appID string) error {
-	if samplesDir == "" || taskID == "" || len(data) == 0 {
+func SaveDiagnosticSample(samplesDir string, taskID string, data io.Reader, appID string) error {
+	if samplesDir == "" || taskID == "" || data == nil {
 		return nil
 	}
 	dir := filepath.Join(samplesDir, taskID)
@@ -128,7 +139,7 @@ func SaveDiagnosticSample(samplesDir string, taskID string, data []byte, appID s
 		return err
 	}
 	if err := writePrivateFileAtomic(filepath.Join(dir, "input.wxapkg"), func(file *os.File) error {
-		_, err := file.Write(data)
+		_, err := io.Copy(file, data)
 		return err
 	}); err != nil {
 		return err
@@ -151,7 +162,13 @@ func CleanupDiagnosticSamples(samplesDir string, cutoff time.Duration) {
 	if samplesDir == "" {
 		return
 	}
-	entries, err := os.ReadDir(samplesDir)
+	removeOldDirectories(samplesDir, cutoff)
+}
+
+// removeOldDirectories removes the direct child directories of root last
+// modified before the cutoff.
+func removeOldDirectories(root string, cutoff time.Duration) {
+	entries, err := os.ReadDir(root)
 	if err != nil {
 		return
 	}
@@ -165,19 +182,56 @@ func CleanupDiagnosticSamples(samplesDir string, cutoff time.Duration) {
 			continue
 		}
 		if info.ModTime().Before(deadline) {
-			_ = os.RemoveAll(filepath.Join(samplesDir, entry.Name()))
+			_ = os.RemoveAll(filepath.Join(root, entry.Name()))
 		}
 	}
 }
 
 func SaveUploadedFile(dirs TaskDirs, file *multipart.FileHeader) (string, error) {
+	return saveUploadedInput(InputFilePath(dirs), file)
+}
+
+// SaveLocalFile stages a package that already exists on the local filesystem
+// (CLI and batch entry points) exactly like an uploaded one, so RunTask never
+// reads from a caller-owned path.
+func SaveLocalFile(dirs TaskDirs, path string) (string, error) {
+	return saveLocalInput(InputFilePath(dirs), path)
+}
+
+func SaveUploadedSubPackage(dirs TaskDirs, index int, file *multipart.FileHeader) (string, error) {
+	return saveUploadedInput(SubPackageInputPath(dirs, index), file)
+}
+
+func SaveLocalSubPackage(dirs TaskDirs, index int, path string) (string, error) {
+	return saveLocalInput(SubPackageInputPath(dirs, index), path)
+}
+
+func saveUploadedInput(dstPath string, file *multipart.FileHeader) (string, error) {
 	src, err := file.Open()
 	if err != nil {
 		return "", err
 	}
 	defer src.Close()
+	return saveTaskInput(dstPath, src)
+}
 
-	dstPath := InputFilePath(dirs)
+func saveLocalInput(dstPath, path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("input is not a regular file")
+	}
+	return saveTaskInput(dstPath, src)
+}
+
+func saveTaskInput(dstPath string, src io.Reader) (string, error) {
 	if err := writePrivateFileAtomic(dstPath, func(dst *os.File) error {
 		_, err := io.Copy(dst, src)
 		return err
@@ -331,7 +385,9 @@ func zipDir(src, dst, archivePrefix string) (entries []string, retErr error) {
 		_ = os.Remove(tmpPath)
 	}()
 
-	writer := zip.NewWriter(file)
+	// Collect first and write in entry-name order: the walk visits "a/b"
+	// before "a-b", and the archive should not depend on directory order.
+	var files []archiveFile
 	walkErr := filepath.Walk(srcAbs, func(path string, info os.FileInfo, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
@@ -355,52 +411,29 @@ func zipDir(src, dst, archivePrefix string) (entries []string, retErr error) {
 		if err := ValidateZipEntryPath(entryPath); err != nil {
 			return err
 		}
-
-		header, err := zip.FileInfoHeader(info)
-		if err != nil {
-			return err
-		}
-		header.Name = entryPath
+		name := entryPath
 		if archivePrefix != "" {
-			header.Name = pathpkg.Join(archivePrefix, header.Name)
+			name = pathpkg.Join(archivePrefix, name)
 		}
-		if err := ValidateZipEntryPath(header.Name); err != nil {
+		if err := ValidateZipEntryPath(name); err != nil {
 			return err
 		}
-		header.Method = zip.Deflate
-
-		writerFile, err := writer.CreateHeader(header)
-		if err != nil {
-			return err
-		}
-
-		source, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		openedInfo, statErr := source.Stat()
-		if statErr != nil || !openedInfo.Mode().IsRegular() || !os.SameFile(info, openedInfo) {
-			_ = source.Close()
-			if statErr != nil {
-				return statErr
-			}
-			return fmt.Errorf("ZIP source changed while archiving: %s", path)
-		}
-		_, copyErr := io.Copy(writerFile, source)
-		closeErr := source.Close()
-		if copyErr != nil {
-			return copyErr
-		}
-		if closeErr != nil {
-			return closeErr
-		}
-		entries = append(entries, header.Name)
+		files = append(files, archiveFile{path: path, name: name, info: info})
 		return nil
 	})
 	if walkErr != nil {
-		_ = writer.Close()
 		return nil, walkErr
 	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+
+	writer := zip.NewWriter(file)
+	for _, archived := range files {
+		if err := writeArchiveFile(writer, archived); err != nil {
+			_ = writer.Close()
+			return nil, err
+		}
+		entries = append(entries, archived.name)
+	}
 	if len(entries) == 0 {
 		_ = writer.Close()
 		return nil, fmt.Errorf("refusing to publish empty ZIP archive")
@@ -423,6 +456,47 @@ func zipDir(src, dst, archivePrefix string) (entries []string, retErr error) {
 	return entries, nil
 }
 
+// archiveEpoch is the modification time of every archive entry, the
+// earliest a ZIP's DOS timestamp can hold, so archive bytes depend only on
+// the names and contents of the files.
+var archiveEpoch = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type archiveFile struct {
+	path string
+	name string
+	info os.FileInfo
+}
+
+// writeArchiveFile writes one entry with a fixed timestamp and mode. The
+// file is reopened and must still be the one the walk saw.
+func writeArchiveFile(writer *zip.Writer, archived archiveFile) error {
+	header := &zip.FileHeader{Name: archived.name, Method: zip.Deflate, Modified: archiveEpoch}
+	header.SetMode(0644)
+	writerFile, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	source, err := os.Open(archived.path)
+	if err != nil {
+		return err
+	}
+	openedInfo, statErr := source.Stat()
+	if statErr != nil || !openedInfo.Mode().IsRegular() || !os.SameFile(archived.info, openedInfo) {
+		_ = source.Close()
+		if statErr != nil {
+			return statErr
+		}
+		return fmt.Errorf("ZIP source changed while archiving: %s", archived.path)
+	}
+	_, copyErr := io.Copy(writerFile, source)
+	closeErr := source.Close()
+	if copyErr != nil {
+		return copyErr
+	}
+	return closeErr
+}
+
 func StartRetentionJanitor(ctx context.Context, tempDir, outputDir string, retainHours int) {
 	StartRetentionJanitorWithSamples(ctx, tempDir, outputDir, "", retainHours)
 }
@@ -455,8 +529,12 @@ func cleanupRetentionRoots(tempDir, outputDir string, cutoff time.Duration) {
 	tempClean := filepath.Clean(tempDir)
 	outputClean := filepath.Clean(outputDir)
 	tempPreserved := map[string]struct{}{
+		"batches":    {},
+		"cache":      {},
+		"diffs":      {},
 		"queue":      {},
 		"task-state": {},
+		"tombstones": {},
 	}
 	if child := directChildContaining(tempClean, outputClean); child != "" {
 		tempPreserved[child] = struct{}{}
@@ -466,7 +544,12 @@ func cleanupRetentionRoots(tempDir, outputDir string, cutoff time.Duration) {
 			return isTaskArtifactEntry(entry) || isOutputArtifactEntry(entry)
 		})
 		cleanupOldStateFiles(filepath.Join(tempClean, "task-state"), cutoff)
+		cleanupOldStateFiles(filepath.Join(tempClean, "batches"), cutoff)
+		cleanupOldStateFiles(filepath.Join(tempClean, "diffs"), cutoff)
+		cleanupOldStateFiles(filepath.Join(tempClean, "diffs", "reports"), cutoff)
+		cleanupOldStateFiles(filepath.Join(tempClean, "tombstones"), cutoff)
 		cleanupOldQueueRecords(filepath.Join(tempClean, "queue"), cutoff)
+		CleanupResultCache(tempClean, cutoff)
 		return
 	}
 	outputPreserved := make(map[string]struct{})
@@ -476,7 +559,12 @@ func cleanupRetentionRoots(tempDir, outputDir string, cutoff time.Duration) {
 	cleanupArtifactsExcept(tempClean, cutoff, tempPreserved, isTaskArtifactEntry)
 	cleanupArtifactsExcept(outputClean, cutoff, outputPreserved, isOutputArtifactEntry)
 	cleanupOldStateFiles(filepath.Join(tempClean, "task-state"), cutoff)
+	cleanupOldStateFiles(filepath.Join(tempClean, "batches"), cutoff)
+	cleanupOldStateFiles(filepath.Join(tempClean, "diffs"), cutoff)
+	cleanupOldStateFiles(filepath.Join(tempClean, "diffs", "reports"), cutoff)
+	cleanupOldStateFiles(filepath.Join(tempClean, "tombstones"), cutoff)
 	cleanupOldQueueRecords(filepath.Join(tempClean, "queue"), cutoff)
+	CleanupResultCache(tempClean, cutoff)
 }
 
 func cleanupOldStateFiles(root string, cutoff time.Duration) {
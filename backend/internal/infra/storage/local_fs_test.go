@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -220,6 +221,54 @@ func TestZipDirWithPrefixKeepsEntriesUnderOneTopLevelDirectory(t *testing.T) {
 	}
 }
 
+func TestZipDirIsReproducibleAcrossTimesModesAndWriteOrder(t *testing.T) {
+	files := map[string]string{"a/b.js": "b", "a-b.js": "dash", "app.json": "{}"}
+	build := func(order []string, mode os.FileMode, modified time.Time) []byte {
+		root := t.TempDir()
+		source := filepath.Join(root, "source")
+		for _, name := range order {
+			target := filepath.Join(source, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(target, []byte(files[name]), mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(target, modified, modified); err != nil {
+				t.Fatal(err)
+			}
+		}
+		destination := filepath.Join(root, "result.zip")
+		entries, err := ZipDirWithPrefixEntries(source, destination, "src")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(entries, ",") != "src/a-b.js,src/a/b.js,src/app.json" {
+			t.Fatalf("entries are not in name order: %v", entries)
+		}
+		data, err := os.ReadFile(destination)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	first := build([]string{"a/b.js", "a-b.js", "app.json"}, 0600, time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC))
+	second := build([]string{"app.json", "a-b.js", "a/b.js"}, 0644, time.Date(2024, 11, 9, 17, 30, 5, 0, time.UTC))
+	if !bytes.Equal(first, second) {
+		t.Fatal("archives of the same tree must be byte-identical")
+	}
+	reader, err := zip.NewReader(bytes.NewReader(first), int64(len(first)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range reader.File {
+		if !file.Modified.Equal(archiveEpoch) || file.Mode() != 0644 {
+			t.Fatalf("%s: modified %v mode %v", file.Name, file.Modified, file.Mode())
+		}
+	}
+}
+
 func TestValidateZipEntryPathRejectsCrossPlatformTraversal(t *testing.T) {
 	for _, entry := range []string{"", ".", "../escape.js", "src/../../escape.js", `src/..\..\escape.js`, "src/C:/escape.js", "/src/app.js", "src//app.js", "src/app.js/"} {
 		if err := ValidateZipEntryPath(entry); err == nil {
@@ -436,7 +485,7 @@ func TestRetentionCleanupPreservesStateDirectoryAndExpiresOldRecords(t *testing.
 func TestSaveDiagnosticSampleWritesPrivateFiles(t *testing.T) {
 	root := t.TempDir()
 	samplesDir := filepath.Join(root, "samples")
-	if err := SaveDiagnosticSample(samplesDir, "task-1", []byte("decrypted-bytes"), "wx0123456789abcdef"); err != nil {
+	if err := SaveDiagnosticSample(samplesDir, "task-1", strings.NewReader("decrypted-bytes"), "wx0123456789abcdef"); err != nil {
 		t.Fatal(err)
 	}
 	pkg, err := os.ReadFile(filepath.Join(samplesDir, "task-1", "input.wxapkg"))
@@ -459,7 +508,7 @@ func TestSaveDiagnosticSampleWritesPrivateFiles(t *testing.T) {
 }
 
 func TestSaveDiagnosticSampleDisabledWhenDirEmpty(t *testing.T) {
-	if err := SaveDiagnosticSample("", "task-1", []byte("x"), ""); err != nil {
+	if err := SaveDiagnosticSample("", "task-1", strings.NewReader("x"), ""); err != nil {
 		t.Fatalf("empty samples dir must be a no-op: %v", err)
 	}
 }
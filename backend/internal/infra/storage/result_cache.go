@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	resultCacheDirName    = "cache"
+	resultCacheRecordName = "record.json"
+	resultCacheArchive    = "archive.zip"
+)
+
+// ResultCache keeps finished task results under <TEMP_DIR>/cache/<key>, where
+// the key is derived from the decrypted package bytes and everything else
+// that shapes the output. An entry holds the recovered source tree, the
+// reports, the download archive and a caller-defined JSON record.
+type ResultCache struct {
+	root   string
+	maxAge time.Duration
+}
+
+// NewResultCache returns the cache below tempDir. Entries older than maxAge
+// are never served; zero keeps them until removed, like RETAIN_ARTIFACTS_HOURS.
+func NewResultCache(tempDir string, maxAge time.Duration) *ResultCache {
+	return &ResultCache{root: filepath.Join(tempDir, resultCacheDirName), maxAge: maxAge}
+}
+
+// ResultCacheKey hashes the key parts in order. Each part is length-prefixed
+// so adjacent parts cannot run into each other.
+func ResultCacheKey(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(hash, "%d:%s;", len(part), part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Load restores the entry for key into dirs and zipPath and decodes its
+// record. It reports false when there is no usable entry.
+func (c *ResultCache) Load(key string, dirs TaskDirs, zipPath string, record interface{}) (bool, error) {
+	entry, ok := c.entryPath(key)
+	if !ok {
+		return false, nil
+	}
+	info, err := os.Stat(entry)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if c.maxAge > 0 && time.Since(info.ModTime()) > c.maxAge {
+		return false, nil
+	}
+	data, err := os.ReadFile(filepath.Join(entry, resultCacheRecordName))
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, record); err != nil {
+		return false, fmt.Errorf("decode cached result: %w", err)
+	}
+	if err := copyTree(filepath.Join(entry, "src"), dirs.SourceDir); err != nil {
+		return false, err
+	}
+	if err := copyTree(filepath.Join(entry, "reports"), dirs.ReportsDir); err != nil {
+		return false, err
+	}
+	if err := copyPrivateFile(filepath.Join(entry, resultCacheArchive), zipPath); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Store copies a finished result into the cache. The entry is assembled in
+// a scratch directory and renamed into place, so readers never see half an
+// entry; when another task stored the same key first, its entry is kept.
+func (c *ResultCache) Store(key string, dirs TaskDirs, zipPath string, record interface{}) (retErr error) {
+	entry, ok := c.entryPath(key)
+	if !ok {
+		return fmt.Errorf("invalid result cache key")
+	}
+	if _, err := os.Stat(entry); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(c.root, 0700); err != nil {
+		return err
+	}
+	scratch := filepath.Join(c.root, ".tmp-"+uuid.NewString())
+	defer func() {
+		if retErr != nil {
+			_ = os.RemoveAll(scratch)
+		}
+	}()
+	if err := copyTree(dirs.SourceDir, filepath.Join(scratch, "src")); err != nil {
+		return err
+	}
+	if err := copyTree(dirs.ReportsDir, filepath.Join(scratch, "reports")); err != nil {
+		return err
+	}
+	if err := copyPrivateFile(zipPath, filepath.Join(scratch, resultCacheArchive)); err != nil {
+		return err
+	}
+	if err := WriteJSON(filepath.Join(scratch, resultCacheRecordName), record); err != nil {
+		return err
+	}
+	if err := os.Rename(scratch, entry); err != nil {
+		if _, statErr := os.Stat(entry); statErr == nil {
+			return os.RemoveAll(scratch)
+		}
+		return err
+	}
+	return syncDirectory(c.root)
+}
+
+// Remove drops the entry for key. Other tasks that shared the entry simply
+// miss the cache next time.
+func (c *ResultCache) Remove(key string) error {
+	entry, ok := c.entryPath(key)
+	if !ok {
+		return nil
+	}
+	return os.RemoveAll(entry)
+}
+
+func (c *ResultCache) entryPath(key string) (string, bool) {
+	decoded, err := hex.DecodeString(key)
+	if err != nil || len(decoded) != sha256.Size {
+		return "", false
+	}
+	return filepath.Join(c.root, key), true
+}
+
+// CleanupResultCache removes cache entries, and scratch directories left by
+// an interrupted Store, older than the cutoff.
+func CleanupResultCache(tempDir string, cutoff time.Duration) {
+	removeOldDirectories(filepath.Join(tempDir, resultCacheDirName), cutoff)
+}
+
+// copyTree copies the regular files below src into dst, creating private
+// directories. Anything that is not a directory or regular file is refused.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, 0700)
+		case entry.Type().IsRegular():
+			return copyPrivateFile(path, target)
+		default:
+			return fmt.Errorf("refusing to copy non-regular file: %s", rel)
+		}
+	})
+}
+
+func copyPrivateFile(src, dst string) error {
+	input, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	return writePrivateFileAtomic(dst, func(output *os.File) error {
+		_, err := io.Copy(output, input)
+		return err
+	})
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResultCacheRoundTripsResultAndHonoursRetention(t *testing.T) {
+	tempDir := t.TempDir()
+	outputDir := t.TempDir()
+	first, err := EnsureTaskDirs(tempDir, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(first.SourceDir, "app.json"):                   `{"pages":["pages/index"]}`,
+		filepath.Join(first.SourceDir, "pages", "index.js"):          "Page({})\n",
+		filepath.Join(first.ReportsDir, "zip-manifest.json"):         `{"taskId":"first"}`,
+		filepath.Join(outputDir, "first.zip"):                        "zip",
+		filepath.Join(first.ReportsDir, "js-recovery-report.json"):   "{}",
+		filepath.Join(first.SourceDir, "components", "card.wxml"):    "<view/>",
+		filepath.Join(first.SourceDir, "components", "card.json"):    "{}",
+		filepath.Join(first.ReportsDir, "wxml-recovery-report.json"): "{}",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache := NewResultCache(tempDir, time.Hour)
+	key := ResultCacheKey("v1", "beautify=true", "package-hash")
+	if key == ResultCacheKey("v1", "beautify=truepackage-hash") {
+		t.Fatal("key parts must not run into each other")
+	}
+	if err := cache.Store(key, first, filepath.Join(outputDir, "first.zip"), map[string]string{"status": "completed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := EnsureTaskDirs(tempDir, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]string
+	hit, err := cache.Load(key, second, filepath.Join(outputDir, "second.zip"), &record)
+	if err != nil || !hit {
+		t.Fatalf("Load = %v, %v; want hit", hit, err)
+	}
+	if record["status"] != "completed" {
+		t.Fatalf("record = %#v", record)
+	}
+	for _, path := range []string{
+		filepath.Join(second.SourceDir, "pages", "index.js"),
+		filepath.Join(second.SourceDir, "components", "card.wxml"),
+		filepath.Join(second.ReportsDir, "js-recovery-report.json"),
+		filepath.Join(outputDir, "second.zip"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("cached file not restored: %v", err)
+		}
+	}
+
+	if hit, err := cache.Load(ResultCacheKey("other"), second, filepath.Join(outputDir, "other.zip"), &record); hit || err != nil {
+		t.Fatalf("unknown key = %v, %v; want miss", hit, err)
+	}
+	if hit, err := cache.Load("../first", second, filepath.Join(outputDir, "other.zip"), &record); hit || err != nil {
+		t.Fatalf("malformed key = %v, %v; want miss", hit, err)
+	}
+
+	entry := filepath.Join(tempDir, "cache", key)
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(entry, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if hit, _ := cache.Load(key, second, filepath.Join(outputDir, "second.zip"), &record); hit {
+		t.Fatal("an entry older than the retention window was served")
+	}
+	cleanupRetentionRoots(tempDir, outputDir, time.Hour)
+	if _, err := os.Stat(entry); !os.IsNotExist(err) {
+		t.Fatalf("retention janitor kept an expired cache entry: %v", err)
+	}
+}
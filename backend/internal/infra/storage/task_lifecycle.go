@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	cancelMarkerName   = ".cancel"
+	resultCacheKeyName = ".cache-key"
+	callbackURLName    = ".callback-url"
+	retainedDirName    = "retained"
+)
+
+// RequestTaskCancel leaves a marker in the task workspace. The marker is how
+// a cancellation reaches a pipeline running in another process: the worker
+// polls TaskCancelRequested while the task runs.
+func RequestTaskCancel(dirs TaskDirs) error {
+	return writePrivateFileAtomic(filepath.Join(dirs.RootDir, cancelMarkerName), func(*os.File) error {
+		return nil
+	})
+}
+
+func TaskCancelRequested(dirs TaskDirs) bool {
+	_, err := os.Lstat(filepath.Join(dirs.RootDir, cancelMarkerName))
+	return err == nil
+}
+
+// TaskWorkspaceExists reports whether the workspace is still there. A
+// deleted task's worker notices the deletion this way.
+func TaskWorkspaceExists(dirs TaskDirs) bool {
+	_, err := os.Lstat(dirs.RootDir)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+// TaskTombstonePath marks a deleted task. It lives outside the workspace,
+// which the deletion removes, and expires with the retention window.
+func TaskTombstonePath(tempDir, taskID string) string {
+	return filepath.Join(tempDir, "tombstones", taskID+".json")
+}
+
+// WriteTaskTombstone records that a task was deleted, so a pipeline still
+// holding it finishes the deletion instead of writing the task back.
+func WriteTaskTombstone(tempDir, taskID string) error {
+	path := TaskTombstonePath(tempDir, taskID)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writePrivateFileAtomic(path, func(file *os.File) error {
+		_, err := file.WriteString("{}")
+		return err
+	})
+}
+
+func TaskDeleted(tempDir, taskID string) bool {
+	_, err := os.Lstat(TaskTombstonePath(tempDir, taskID))
+	return err == nil
+}
+
+// TaskDirsFor returns the task's directories without creating them.
+func TaskDirsFor(base, taskID string) TaskDirs {
+	root := filepath.Join(base, taskID)
+	return TaskDirs{
+		RootDir:    root,
+		InputDir:   filepath.Join(root, "input"),
+		SourceDir:  filepath.Join(root, "result", "src"),
+		ReportsDir: filepath.Join(root, "result", "reports"),
+	}
+}
+
+// RemoveTaskWorkspace deletes the task's whole working directory: input,
+// recovered source, reports and markers.
+func RemoveTaskWorkspace(base, taskID string) error {
+	if taskID == "" || filepath.Base(taskID) != taskID || strings.HasPrefix(taskID, ".") {
+		return errors.New("invalid task id")
+	}
+	if err := os.RemoveAll(filepath.Join(base, taskID)); err != nil {
+		return err
+	}
+	return syncDirectory(base)
+}
+
+// SaveResultCacheKey records the cache entry a task's result was looked up
+// under, so deleting the task can also drop the cached copy.
+func SaveResultCacheKey(dirs TaskDirs, key string) error {
+	return writePrivateFileAtomic(filepath.Join(dirs.RootDir, resultCacheKeyName), func(file *os.File) error {
+		_, err := file.WriteString(key)
+		return err
+	})
+}
+
+func ReadResultCacheKey(dirs TaskDirs) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dirs.RootDir, resultCacheKeyName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+// SaveCallbackURL records the URL a task's completion callback goes to. It
+// lives beside the workspace rather than in the task record because the URL
+// may carry a receiver token.
+func SaveCallbackURL(dirs TaskDirs, callbackURL string) error {
+	return writePrivateFileAtomic(filepath.Join(dirs.RootDir, callbackURLName), func(file *os.File) error {
+		_, err := file.WriteString(callbackURL)
+		return err
+	})
+}
+
+func ReadCallbackURL(dirs TaskDirs) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dirs.RootDir, callbackURLName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+// SaveRetainedPackages keeps the decrypted main package and subpackages in
+// the task workspace, where they live until retention cleanup removes the
+// workspace. Unlike the upload they need no AppID to process again.
+func SaveRetainedPackages(dirs TaskDirs, main io.Reader, subPackages []io.Reader) error {
+	retainedDir := filepath.Join(dirs.RootDir, retainedDirName)
+	if err := os.RemoveAll(retainedDir); err != nil {
+		return err
+	}
+	if err := os.Mkdir(retainedDir, 0700); err != nil {
+		return err
+	}
+	write := func(name string, src io.Reader) error {
+		return writePrivateFileAtomic(filepath.Join(retainedDir, name), func(file *os.File) error {
+			_, err := io.Copy(file, src)
+			return err
+		})
+	}
+	for index, src := range subPackages {
+		if err := write(fmt.Sprintf("subpackage-%03d.wxapkg", index), src); err != nil {
+			return err
+		}
+	}
+	// The main package goes last: its presence marks a complete copy.
+	return write("package.wxapkg", main)
+}
+
+// RetainedPackagePaths returns the retained main package and subpackages.
+// The main path is empty when the task kept no copy.
+func RetainedPackagePaths(dirs TaskDirs) (string, []string, error) {
+	retainedDir := filepath.Join(dirs.RootDir, retainedDirName)
+	main := filepath.Join(retainedDir, "package.wxapkg")
+	if _, err := os.Lstat(main); errors.Is(err, fs.ErrNotExist) {
+		return "", nil, nil
+	} else if err != nil {
+		return "", nil, err
+	}
+	subPackages, err := filepath.Glob(filepath.Join(retainedDir, "subpackage-*.wxapkg"))
+	if err != nil {
+		return "", nil, err
+	}
+	sort.Strings(subPackages)
+	return main, subPackages, nil
+}
@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256, keyed with
+	// the shared secret, of the TimestampHeader value, a ".", and the body.
+	SignatureHeader = "X-Seewxapkg-Signature"
+	// TimestampHeader is the Unix time of the attempt in seconds. Receivers
+	// reject stale timestamps so a captured callback cannot be replayed.
+	TimestampHeader = "X-Seewxapkg-Timestamp"
+	EventHeader     = "X-Seewxapkg-Event"
+)
+
+// Sender posts signed callbacks. A failed delivery is retried with the same
+// linear backoff the file queue uses for failed jobs. Redirects are not
+// followed: the callback host was checked, the redirect target was not.
+type Sender struct {
+	secret       []byte
+	client       *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+func NewSender(secret string) *Sender {
+	return &Sender{
+		secret: []byte(secret),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		maxRetries:   3,
+		retryBackoff: 2 * time.Second,
+	}
+}
+
+// Sign returns the SignatureHeader value for body sent at timestamp.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver posts body to url until the receiver answers 2xx, retrying
+// network errors, 429 and 5xx responses. Redirects and other 4xx responses
+// are final.
+func (s *Sender) Deliver(ctx context.Context, url, event string, body []byte) error {
+	var lastErr error
+	for retries := 0; ; retries++ {
+		retry, err := s.post(ctx, url, event, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || retries >= s.maxRetries {
+			return lastErr
+		}
+		timer := time.NewTimer(time.Duration(retries+1) * s.retryBackoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Sender) post(ctx context.Context, url, event string, body []byte) (bool, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, event)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(s.secret, timestamp, body))
+	response, err := s.client.Do(request)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+	_ = response.Body.Close()
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+	return retry, fmt.Errorf("webhook receiver answered %d", response.StatusCode)
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSenderSignsAndRetriesUntilAccepted(t *testing.T) {
+	var attempts atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get(SignatureHeader); got != Sign([]byte("secret"), r.Header.Get(TimestampHeader), body) {
+			t.Errorf("signature = %q", got)
+		}
+		if r.Header.Get(EventHeader) != "task.completed" {
+			t.Errorf("event header = %q", r.Header.Get(EventHeader))
+		}
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	sender := NewSender("secret")
+	sender.retryBackoff = time.Millisecond
+
+	if err := sender.Deliver(context.Background(), receiver.URL, "task.completed", []byte(`{"id":"task-1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if attempts.Load() != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts.Load())
+	}
+}
+
+func TestSenderStopsOnClientErrorsAndAfterMaxRetries(t *testing.T) {
+	var attempts atomic.Int32
+	status := http.StatusBadRequest
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+	sender := NewSender("secret")
+	sender.retryBackoff = time.Millisecond
+
+	if err := sender.Deliver(context.Background(), receiver.URL, "task.failed", []byte(`{}`)); err == nil || attempts.Load() != 1 {
+		t.Fatalf("400 response: err = %v, attempts = %d, want one failed attempt", err, attempts.Load())
+	}
+	attempts.Store(0)
+	status = http.StatusInternalServerError
+	if err := sender.Deliver(context.Background(), receiver.URL, "task.failed", []byte(`{}`)); err == nil || attempts.Load() != 4 {
+		t.Fatalf("500 response: err = %v, attempts = %d, want the first attempt and 3 retries", err, attempts.Load())
+	}
+}
+
+func TestSenderDoesNotFollowRedirects(t *testing.T) {
+	var redirected atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer target.Close()
+	var attempts atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+	sender := NewSender("secret")
+	sender.retryBackoff = time.Millisecond
+
+	if err := sender.Deliver(context.Background(), receiver.URL, "task.completed", []byte(`{}`)); err == nil || attempts.Load() != 1 {
+		t.Fatalf("redirect: err = %v, attempts = %d, want one failed attempt", err, attempts.Load())
+	}
+	if redirected.Load() != 0 {
+		t.Fatal("the callback followed a redirect to an unchecked host")
+	}
+}
+
+func TestSignatureCoversTheTimestamp(t *testing.T) {
+	body := []byte(`{"id":"task-1"}`)
+	if Sign([]byte("secret"), "1700000000", body) == Sign([]byte("secret"), "1700000001", body) {
+		t.Fatal("a replayed body with a new timestamp kept its signature")
+	}
+}
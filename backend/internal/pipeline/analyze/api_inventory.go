@@ -0,0 +1,660 @@
+package analyze
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/pipeline/jslex"
+)
+
+// APIInventory is api-inventory.json: every server endpoint and cloud
+// function the recovered scripts call with a literal argument object.
+// Endpoints whose origin is not a literal are grouped under an empty host.
+// PartialScripts counts scripts the lexer could not read to the end; calls
+// after the point it stopped are missing.
+type APIInventory struct {
+	ScannedScripts  int             `json:"scannedScripts"`
+	PartialScripts  int             `json:"partialScripts,omitempty"`
+	Calls           int             `json:"calls"`
+	UnresolvedCalls int             `json:"unresolvedCalls"`
+	Hosts           []APIHost       `json:"hosts"`
+	CloudFunctions  []CloudFunction `json:"cloudFunctions"`
+}
+
+// APIHost groups the endpoints of one host, sorted by URL.
+type APIHost struct {
+	Host      string        `json:"host"`
+	Endpoints []APIEndpoint `json:"endpoints"`
+}
+
+// APIEndpoint is one URL called through one wx API. Parts of the URL that
+// are not literals appear as {name} placeholders, named after the variable
+// or property that supplies them; Static reports a URL without any. Header
+// names are listed without their values, which often carry tokens.
+type APIEndpoint struct {
+	API        string   `json:"api"`
+	URL        string   `json:"url"`
+	Static     bool     `json:"static"`
+	Methods    []string `json:"methods"`
+	Headers    []string `json:"headers"`
+	Fields     []string `json:"fields"`
+	Pages      []string `json:"pages"`
+	Components []string `json:"components"`
+	Files      []string `json:"files"`
+}
+
+// CloudFunction is one function name passed to wx.cloud.callFunction.
+type CloudFunction struct {
+	Name       string   `json:"name"`
+	Pages      []string `json:"pages"`
+	Components []string `json:"components"`
+	Files      []string `json:"files"`
+}
+
+var (
+	originPattern      = regexp.MustCompile(`^(?:https?|wss?)://([^/?#\s]+)`)
+	placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+)
+
+// inventoryAPIs are the calls the inventory records.
+var inventoryAPIs = map[string]bool{
+	"wx.request":            true,
+	"wx.uploadFile":         true,
+	"wx.downloadFile":       true,
+	"wx.connectSocket":      true,
+	"wx.cloud.callFunction": true,
+}
+
+// defaultMethods is what each API sends when the call names no method.
+var defaultMethods = map[string]string{
+	"wx.request":      "GET",
+	"wx.uploadFile":   "POST",
+	"wx.downloadFile": "GET",
+}
+
+type apiCallers struct {
+	pages      map[string]bool
+	components map[string]bool
+	files      map[string]bool
+}
+
+// AnalyzeAPIInventory extracts the network and cloud function calls of the
+// package's scripts. Calls are attributed to the pages and components whose
+// scripts reach the calling file through require(), so a request wrapper in
+// utils/ lists every page that uses it.
+func AnalyzeAPIInventory(ctx context.Context, np *pkg.NormalizedPackage) (*APIInventory, error) {
+	result := &APIInventory{Hosts: []APIHost{}, CloudFunctions: []CloudFunction{}}
+	if np == nil {
+		return result, nil
+	}
+	scripts := inventoryScripts(np)
+	tokens := make(map[string][]jslex.Token, len(scripts))
+	for _, script := range scripts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		scriptTokens, complete := tokenizeScript(script.Content)
+		if !complete {
+			result.PartialScripts++
+		}
+		tokens[script.Path] = scriptTokens
+	}
+	constants := urlConstants(scripts, tokens)
+	callers := scriptCallers(np, scripts, tokens)
+
+	endpoints := make(map[string]*APIEndpoint)
+	endpointCallers := make(map[string]*apiCallers)
+	functionCallers := make(map[string]*apiCallers)
+	for _, script := range scripts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result.ScannedScripts++
+		scriptTokens := tokens[script.Path]
+		for index := range scriptTokens {
+			api, open, ok := apiCall(scriptTokens, index)
+			if !ok {
+				continue
+			}
+			result.Calls++
+			properties, ok := callOptions(scriptTokens, open)
+			if !ok {
+				result.UnresolvedCalls++
+				continue
+			}
+			if api == "wx.cloud.callFunction" {
+				name, static := staticValue(properties["name"], constants)
+				if !static || name == "" {
+					result.UnresolvedCalls++
+					continue
+				}
+				functionCallers[name] = mergeCallers(functionCallers[name], callers[script.Path], script.Path)
+				continue
+			}
+			url, static := staticValue(properties["url"], constants)
+			if !hasLiteral(url) {
+				result.UnresolvedCalls++
+				continue
+			}
+			key := api + "\x00" + url
+			endpoint := endpoints[key]
+			if endpoint == nil {
+				endpoint = &APIEndpoint{API: api, URL: url, Static: static}
+				endpoints[key] = endpoint
+			}
+			if method := callMethod(api, properties, constants); method != "" {
+				endpoint.Methods = appendUnique(endpoint.Methods, method)
+			}
+			endpoint.Headers = appendUnique(endpoint.Headers, objectKeys(properties["header"])...)
+			endpoint.Fields = appendUnique(endpoint.Fields, objectKeys(properties["data"])...)
+			if api == "wx.uploadFile" {
+				endpoint.Fields = appendUnique(endpoint.Fields, objectKeys(properties["formData"])...)
+				if name, static := staticValue(properties["name"], constants); static && name != "" {
+					endpoint.Fields = appendUnique(endpoint.Fields, name)
+				}
+			}
+			endpointCallers[key] = mergeCallers(endpointCallers[key], callers[script.Path], script.Path)
+		}
+	}
+
+	hosts := make(map[string]*APIHost)
+	for key, endpoint := range endpoints {
+		endpoint.Pages, endpoint.Components, endpoint.Files = endpointCallers[key].lists()
+		sortOrEmpty(&endpoint.Methods)
+		sortOrEmpty(&endpoint.Headers)
+		sortOrEmpty(&endpoint.Fields)
+		host := urlHost(endpoint.URL)
+		if hosts[host] == nil {
+			hosts[host] = &APIHost{Host: host}
+		}
+		hosts[host].Endpoints = append(hosts[host].Endpoints, *endpoint)
+	}
+	for _, group := range hosts {
+		sort.Slice(group.Endpoints, func(i, j int) bool {
+			if group.Endpoints[i].URL != group.Endpoints[j].URL {
+				return group.Endpoints[i].URL < group.Endpoints[j].URL
+			}
+			return group.Endpoints[i].API < group.Endpoints[j].API
+		})
+		result.Hosts = append(result.Hosts, *group)
+	}
+	sort.Slice(result.Hosts, func(i, j int) bool { return result.Hosts[i].Host < result.Hosts[j].Host })
+	for name, found := range functionCallers {
+		function := CloudFunction{Name: name}
+		function.Pages, function.Components, function.Files = found.lists()
+		result.CloudFunctions = append(result.CloudFunctions, function)
+	}
+	sort.Slice(result.CloudFunctions, func(i, j int) bool { return result.CloudFunctions[i].Name < result.CloudFunctions[j].Name })
+	return result, nil
+}
+
+// EndpointCount is the number of distinct endpoints across all hosts.
+func (r *APIInventory) EndpointCount() int {
+	count := 0
+	for _, host := range r.Hosts {
+		count += len(host.Endpoints)
+	}
+	return count
+}
+
+// inventoryScripts returns the package's JavaScript modules. Runtime files
+// are left out once js recovery has split the bundles, since the split
+// modules repeat their code under the original file names.
+func inventoryScripts(np *pkg.NormalizedPackage) []pkg.ScriptIR {
+	split := false
+	for _, script := range np.Scripts {
+		if strings.HasSuffix(script.Path, ".js") && !runtimeFiles[path.Base(script.Path)] {
+			split = true
+			break
+		}
+	}
+	var scripts []pkg.ScriptIR
+	for _, script := range np.Scripts {
+		if !strings.HasSuffix(script.Path, ".js") || (split && runtimeFiles[path.Base(script.Path)]) {
+			continue
+		}
+		scripts = append(scripts, script)
+	}
+	return scripts
+}
+
+// tokenizeScript reads a script to the end, or up to the first token the
+// lexer cannot read, and reports whether it reached the end.
+func tokenizeScript(src string) ([]jslex.Token, bool) {
+	lexer := jslex.NewLexer(src, 0)
+	for {
+		lexer.Scan()
+		if tokens := lexer.Tokens(); tokens[len(tokens)-1].Kind == jslex.EOF {
+			return tokens, lexer.Err() == nil
+		}
+	}
+}
+
+// urlConstants maps names to the URL literal assigned to them, such as
+// var BASE = "https://..." or a config property baseUrl: "...". A name
+// bound to different URLs in different places is ambiguous and left out.
+func urlConstants(scripts []pkg.ScriptIR, tokens map[string][]jslex.Token) map[string]string {
+	constants := make(map[string]string)
+	ambiguous := make(map[string]bool)
+	for _, script := range scripts {
+		scriptTokens := tokens[script.Path]
+		for index := 0; index+2 < len(scriptTokens); index++ {
+			name, ok := propertyKey(scriptTokens[index])
+			value, isString := scriptTokens[index+2].Value.(string)
+			if !ok || !isString || !originPattern.MatchString(value) {
+				continue
+			}
+			switch {
+			case jslex.IsPunct(scriptTokens[index+1], "="):
+			case jslex.IsPunct(scriptTokens[index+1], ":") && index > 0 &&
+				(jslex.IsPunct(scriptTokens[index-1], "{") || jslex.IsPunct(scriptTokens[index-1], ",")):
+			default:
+				continue
+			}
+			if name == "url" {
+				// An inline request option, not a shared base URL.
+				continue
+			}
+			if previous, ok := constants[name]; ok && previous != value {
+				ambiguous[name] = true
+			}
+			constants[name] = value
+		}
+	}
+	for name := range ambiguous {
+		delete(constants, name)
+	}
+	return constants
+}
+
+// scriptCallers maps every script to the pages and components whose own
+// script reaches it through require().
+func scriptCallers(np *pkg.NormalizedPackage, scripts []pkg.ScriptIR, tokens map[string][]jslex.Token) map[string]*apiCallers {
+	known := make(map[string]bool, len(scripts))
+	for _, script := range scripts {
+		known[script.Path] = true
+	}
+	requires := make(map[string][]string, len(scripts))
+	for _, script := range scripts {
+		for _, spec := range requireSpecs(tokens[script.Path]) {
+			if target := resolveRequire(script.Path, spec, known); target != "" {
+				requires[script.Path] = append(requires[script.Path], target)
+			}
+		}
+	}
+
+	callers := make(map[string]*apiCallers)
+	visit := func(entry, kind string) {
+		seen := map[string]bool{}
+		queue := []string{entry + ".js"}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			if seen[current] || !known[current] {
+				continue
+			}
+			seen[current] = true
+			if callers[current] == nil {
+				callers[current] = &apiCallers{pages: map[string]bool{}, components: map[string]bool{}}
+			}
+			if kind == "page" {
+				callers[current].pages[entry] = true
+			} else {
+				callers[current].components[entry] = true
+			}
+			queue = append(queue, requires[current]...)
+		}
+	}
+	for _, page := range np.Pages {
+		entry := page.Path
+		if page.ScriptPath != "" {
+			entry = strings.TrimSuffix(page.ScriptPath, ".js")
+		}
+		visit(entry, "page")
+	}
+	for _, component := range np.Components {
+		visit(component.Path, "component")
+	}
+	return callers
+}
+
+// requireSpecs lists the string arguments of the require() calls.
+func requireSpecs(tokens []jslex.Token) []string {
+	var specs []string
+	for index := 0; index+3 < len(tokens); index++ {
+		spec, ok := tokens[index+2].Value.(string)
+		if tokens[index].Kind == jslex.Ident && tokens[index].Text == "require" && ok &&
+			jslex.IsPunct(tokens[index+1], "(") && jslex.IsPunct(tokens[index+3], ")") {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// resolveRequire resolves a require() argument the way the mini program
+// runtime does: relative to the requiring file, with an optional .js
+// extension or index.js.
+func resolveRequire(from, spec string, known map[string]bool) string {
+	var base string
+	if strings.HasPrefix(spec, "/") {
+		base = path.Clean(strings.TrimPrefix(spec, "/"))
+	} else {
+		base = path.Join(path.Dir(from), spec)
+	}
+	for _, candidate := range []string{base, base + ".js", path.Join(base, "index.js")} {
+		if known[candidate] {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// apiCall reports whether tokens[index] starts a call of an inventoried
+// API, and returns the API and the index of the call's opening parenthesis.
+func apiCall(tokens []jslex.Token, index int) (string, int, bool) {
+	if tokens[index].Kind != jslex.Ident || tokens[index].Text != "wx" || index > 0 && jslex.IsPunct(tokens[index-1], ".") {
+		return "", 0, false
+	}
+	api := "wx"
+	next := index + 1
+	for next+1 < len(tokens) && jslex.IsPunct(tokens[next], ".") && tokens[next+1].Kind == jslex.Ident {
+		api += "." + tokens[next+1].Text
+		next += 2
+	}
+	if !inventoryAPIs[api] || next >= len(tokens) || !jslex.IsPunct(tokens[next], "(") {
+		return "", 0, false
+	}
+	return api, next, true
+}
+
+// callOptions parses the object literal passed as the first argument of
+// the call whose parenthesis is tokens[open]. Calls that pass a variable
+// are not resolved.
+func callOptions(tokens []jslex.Token, open int) (map[string][]jslex.Token, bool) {
+	start := open + 1
+	if start >= len(tokens) || !jslex.IsPunct(tokens[start], "{") {
+		return nil, false
+	}
+	end := matchingClose(tokens, start)
+	if end < 0 {
+		return nil, false
+	}
+	return objectProperties(tokens[start : end+1]), true
+}
+
+// objectProperties maps the keys of an object literal to their value
+// expressions. Shorthand properties map to their own name; methods,
+// accessors and spreads are skipped.
+func objectProperties(object []jslex.Token) map[string][]jslex.Token {
+	properties := make(map[string][]jslex.Token)
+	for _, entry := range splitTopLevel(object[1:len(object)-1], ",") {
+		if len(entry) == 0 {
+			continue
+		}
+		key, ok := propertyKey(entry[0])
+		switch {
+		case !ok:
+		case len(entry) == 1 && entry[0].Kind == jslex.Ident:
+			properties[key] = entry
+		case len(entry) > 2 && jslex.IsPunct(entry[1], ":"):
+			properties[key] = entry[2:]
+		}
+	}
+	return properties
+}
+
+// propertyKey is the name an identifier or string token gives a property.
+func propertyKey(token jslex.Token) (string, bool) {
+	switch token.Kind {
+	case jslex.Ident:
+		return token.Text, true
+	case jslex.String:
+		key, ok := token.Value.(string)
+		return key, ok
+	}
+	return "", false
+}
+
+// objectKeys lists the keys of an object literal expression.
+func objectKeys(expression []jslex.Token) []string {
+	if len(expression) < 2 || !jslex.IsPunct(expression[0], "{") || matchingClose(expression, 0) != len(expression)-1 {
+		return nil
+	}
+	var keys []string
+	for key := range objectProperties(expression) {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func callMethod(api string, properties map[string][]jslex.Token, constants map[string]string) string {
+	expression, ok := properties["method"]
+	if !ok {
+		return defaultMethods[api]
+	}
+	method, static := staticValue(expression, constants)
+	if !static {
+		return ""
+	}
+	return strings.ToUpper(method)
+}
+
+// staticValue folds a string expression built from literals, template
+// literals and + into text. Known URL constants are substituted; any other
+// operand becomes a {name} placeholder and the value is not static.
+func staticValue(expression []jslex.Token, constants map[string]string) (string, bool) {
+	if len(expression) == 0 {
+		return "", false
+	}
+	var builder strings.Builder
+	static := true
+	for _, part := range splitTopLevel(expression, "+") {
+		switch {
+		case len(part) == 0:
+			continue
+		case len(part) == 1 && part[0].Kind == jslex.String:
+			literal, _ := part[0].Value.(string)
+			builder.WriteString(literal)
+			continue
+		case len(part) == 1 && part[0].Kind == jslex.Template:
+			text, partStatic := templateValue(part[0].Text, constants)
+			builder.WriteString(text)
+			static = static && partStatic
+			continue
+		}
+		name := "param"
+		if isReference(part) {
+			name = part[len(part)-1].Text
+			if value, ok := constants[name]; ok {
+				builder.WriteString(value)
+				continue
+			}
+		}
+		builder.WriteString("{" + name + "}")
+		static = false
+	}
+	return builder.String(), static
+}
+
+// isReference reports a name or a chain of property accesses, a.b.c.
+func isReference(expression []jslex.Token) bool {
+	if len(expression)%2 == 0 {
+		return false
+	}
+	for index, token := range expression {
+		if index%2 == 0 && token.Kind != jslex.Ident || index%2 == 1 && !jslex.IsPunct(token, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+func templateValue(template string, constants map[string]string) (string, bool) {
+	var builder strings.Builder
+	static := true
+	body := template[1 : len(template)-1]
+	for i := 0; i < len(body); i++ {
+		switch {
+		case body[i] == '\\' && i+1 < len(body):
+			i++
+			builder.WriteByte(body[i])
+		case body[i] == '$' && i+1 < len(body) && body[i+1] == '{':
+			expression, end := substitution(body, i+2)
+			if end < 0 {
+				return builder.String(), false
+			}
+			text, partStatic := staticValue(expression, constants)
+			builder.WriteString(text)
+			static = static && partStatic
+			i = end
+		default:
+			builder.WriteByte(body[i])
+		}
+	}
+	return builder.String(), static
+}
+
+// substitution returns the tokens of the template substitution starting
+// at offset start of body and the offset of its closing brace, or -1 when
+// it does not close.
+func substitution(body string, start int) ([]jslex.Token, int) {
+	lexer := jslex.NewLexer(body, start)
+	depth := 0
+	for {
+		lexer.Scan()
+		tokens := lexer.Tokens()
+		token := tokens[len(tokens)-1]
+		switch {
+		case token.Kind == jslex.EOF:
+			return nil, -1
+		case jslex.IsPunct(token, "{"):
+			depth++
+		case jslex.IsPunct(token, "}"):
+			if depth == 0 {
+				return tokens[:len(tokens)-1], token.Start
+			}
+			depth--
+		}
+	}
+}
+
+// matchingClose returns the index of the token closing the bracket at
+// tokens[open], or -1 when the brackets do not balance.
+func matchingClose(tokens []jslex.Token, open int) int {
+	var stack []string
+	for index := open; index < len(tokens); index++ {
+		if tokens[index].Kind != jslex.Punct {
+			continue
+		}
+		switch text := tokens[index].Text; text {
+		case "(":
+			stack = append(stack, ")")
+		case "[":
+			stack = append(stack, "]")
+		case "{":
+			stack = append(stack, "}")
+		case ")", "]", "}":
+			if len(stack) == 0 || stack[len(stack)-1] != text {
+				return -1
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return index
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits tokens at the punctuator sep outside brackets.
+func splitTopLevel(tokens []jslex.Token, sep string) [][]jslex.Token {
+	var parts [][]jslex.Token
+	depth, start := 0, 0
+	for index, token := range tokens {
+		if token.Kind != jslex.Punct {
+			continue
+		}
+		switch token.Text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, tokens[start:index])
+				start = index + 1
+			}
+		}
+	}
+	return append(parts, tokens[start:])
+}
+
+// hasLiteral reports whether a folded URL has any literal text; a bare
+// {url} placeholder is a wrapper forwarding its caller's URL.
+func hasLiteral(url string) bool {
+	return strings.TrimSpace(placeholderPattern.ReplaceAllString(url, "")) != ""
+}
+
+func urlHost(url string) string {
+	match := originPattern.FindStringSubmatch(url)
+	if match == nil || strings.ContainsAny(match[1], "{}") {
+		return ""
+	}
+	return strings.ToLower(match[1])
+}
+
+func mergeCallers(into, from *apiCallers, file string) *apiCallers {
+	if into == nil {
+		into = &apiCallers{pages: map[string]bool{}, components: map[string]bool{}, files: map[string]bool{}}
+	}
+	into.files[file] = true
+	if from != nil {
+		for page := range from.pages {
+			into.pages[page] = true
+		}
+		for component := range from.components {
+			into.components[component] = true
+		}
+	}
+	return into
+}
+
+func (c *apiCallers) lists() (pages, components, files []string) {
+	return sortedKeys(c.pages), sortedKeys(c.components), sortedKeys(c.files)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func appendUnique(values []string, additions ...string) []string {
+	for _, addition := range additions {
+		found := false
+		for _, value := range values {
+			if value == addition {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, addition)
+		}
+	}
+	return values
+}
+
+func sortOrEmpty(values *[]string) {
+	if *values == nil {
+		*values = []string{}
+	}
+	sort.Strings(*values)
+}
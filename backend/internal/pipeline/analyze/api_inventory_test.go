@@ -0,0 +1,139 @@
+package analyze
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+func TestAnalyzeAPIInventoryGroupsEndpointsByHostWithCallers(t *testing.T) {
+	np := &pkg.NormalizedPackage{
+		Pages:      []pkg.PageIR{{Path: "pages/index/index"}, {Path: "pages/user/user"}},
+		Components: []pkg.ComponentIR{{Path: "components/avatar/avatar"}},
+		Scripts: []pkg.ScriptIR{
+			{Path: "app-service.js", Content: `define("utils/api.js", function(){ wx.request({url: "https://api.example.com/v1/dup"}) });`},
+			{Path: "utils/config.js", Content: `module.exports = { baseUrl: "https://api.example.com/v1" };`},
+			{Path: "utils/api.js", Content: strings.Join([]string{
+				`var config = require("./config.js");`,
+				`function get(path, data) { return wx.request({url: config.baseUrl + path, data: data}); }`,
+				`function profile(id) {`,
+				`  return wx.request({url: config.baseUrl + "/users/" + id + "?fields=name", method: "post", header: {"X-Token": token, "content-type": "application/json"}, data: {nick: "a", age: 1}});`,
+				`}`,
+				`module.exports = {get: get, profile: profile};`,
+			}, "\n")},
+			{Path: "pages/index/index.js", Content: strings.Join([]string{
+				`var api = require("../../utils/api");`,
+				"Page({onLoad() { wx.downloadFile({url: `https://cdn.example.com/${name}.png`}); wx.request(options); }});",
+			}, "\n")},
+			{Path: "pages/user/user.js", Content: `var api = require("/utils/api.js"); Page({});`},
+			{Path: "components/avatar/avatar.js", Content: strings.Join([]string{
+				`Component({methods: {upload() {`,
+				`  wx.uploadFile({url: "https://upload.example.com/avatar", name: "file", formData: {uid: 1}});`,
+				`  wx.connectSocket({url: "wss://push.example.com/ws"});`,
+				`  wx.cloud.callFunction({name: 'login', data: {}});`,
+				`}}});`,
+			}, "\n")},
+		},
+	}
+
+	inventory, err := AnalyzeAPIInventory(context.Background(), np)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inventory.ScannedScripts != 5 {
+		t.Fatalf("scanned %d scripts, want 5 without the split runtime bundle", inventory.ScannedScripts)
+	}
+	if inventory.Calls != 7 || inventory.UnresolvedCalls != 1 {
+		t.Fatalf("calls = %d unresolved = %d, want 7 and 1 for the options variable", inventory.Calls, inventory.UnresolvedCalls)
+	}
+	var hosts []string
+	endpoints := map[string]APIEndpoint{}
+	for _, host := range inventory.Hosts {
+		hosts = append(hosts, host.Host)
+		for _, endpoint := range host.Endpoints {
+			endpoints[endpoint.URL] = endpoint
+		}
+	}
+	if strings.Join(hosts, ",") != "api.example.com,cdn.example.com,push.example.com,upload.example.com" {
+		t.Fatalf("hosts = %v", hosts)
+	}
+
+	if wrapper := endpoints["https://api.example.com/v1{path}"]; wrapper.API != "wx.request" || strings.Join(wrapper.Pages, ",") != "pages/index/index,pages/user/user" {
+		t.Fatalf("wrapper endpoint = %+v", wrapper)
+	}
+	profile, ok := endpoints["https://api.example.com/v1/users/{id}?fields=name"]
+	if !ok {
+		t.Fatalf("profile endpoint missing: %+v", endpoints)
+	}
+	if profile.Static || strings.Join(profile.Methods, ",") != "POST" ||
+		strings.Join(profile.Headers, ",") != "X-Token,content-type" || strings.Join(profile.Fields, ",") != "age,nick" {
+		t.Fatalf("profile endpoint = %+v", profile)
+	}
+	if strings.Join(profile.Pages, ",") != "pages/index/index,pages/user/user" || strings.Join(profile.Files, ",") != "utils/api.js" {
+		t.Fatalf("profile callers = %v from %v", profile.Pages, profile.Files)
+	}
+	if download := endpoints["https://cdn.example.com/{name}.png"]; download.API != "wx.downloadFile" || strings.Join(download.Methods, ",") != "GET" {
+		t.Fatalf("download endpoint = %+v", download)
+	}
+	upload := endpoints["https://upload.example.com/avatar"]
+	if !upload.Static || strings.Join(upload.Fields, ",") != "file,uid" || strings.Join(upload.Components, ",") != "components/avatar/avatar" {
+		t.Fatalf("upload endpoint = %+v", upload)
+	}
+	if len(inventory.CloudFunctions) != 1 || inventory.CloudFunctions[0].Name != "login" ||
+		strings.Join(inventory.CloudFunctions[0].Components, ",") != "components/avatar/avatar" {
+		t.Fatalf("cloud functions = %+v", inventory.CloudFunctions)
+	}
+
+	spec := BuildOpenAPI(inventory, "demo")
+	paths := spec["paths"].(map[string]map[string]interface{})
+	item, ok := paths["/v1/users/{id}"]
+	if !ok {
+		t.Fatalf("openapi paths = %v", paths)
+	}
+	if servers := item["servers"].([]interface{}); len(servers) != 1 || servers[0].(map[string]interface{})["url"] != "https://api.example.com" {
+		t.Fatalf("servers = %v", item["servers"])
+	}
+	post := item["post"].(map[string]interface{})
+	var parameters []string
+	for _, parameter := range post["parameters"].([]interface{}) {
+		described := parameter.(map[string]interface{})
+		parameters = append(parameters, described["in"].(string)+":"+described["name"].(string))
+	}
+	if strings.Join(parameters, ",") != "path:id,query:fields,header:X-Token" || post["requestBody"] == nil {
+		t.Fatalf("post operation parameters = %v body = %v", parameters, post["requestBody"])
+	}
+	if sockets := spec["x-wechat-sockets"].([]string); len(sockets) != 1 || sockets[0] != "wss://push.example.com/ws" {
+		t.Fatalf("sockets = %v", sockets)
+	}
+	if _, ok := paths["/ws"]; ok {
+		t.Fatal("WebSocket URLs must not become OpenAPI paths")
+	}
+}
+
+func TestAnalyzeAPIInventoryIgnoresCallsInCommentsAndStrings(t *testing.T) {
+	np := &pkg.NormalizedPackage{
+		Scripts: []pkg.ScriptIR{{Path: "utils/api.js", Content: strings.Join([]string{
+			`// wx.request({url: "https://commented.example.com/line"})`,
+			`/* var BASE = "https://commented.example.com"; wx.request({url: BASE}) */`,
+			`var help = "call wx.request({url: 'https://quoted.example.com'}) to fetch";`,
+			"var doc = `wx.uploadFile({url: \"https://template.example.com\"})`;",
+			`var BASE = "https://api.example.com";`,
+			`wx.request({url: BASE + "/list", header: {"X-Note": "// not a comment"}, data: {q: "}"}});`,
+		}, "\n")}},
+	}
+
+	inventory, err := AnalyzeAPIInventory(context.Background(), np)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inventory.Calls != 1 || len(inventory.Hosts) != 1 || inventory.Hosts[0].Host != "api.example.com" {
+		t.Fatalf("calls = %d hosts = %+v, want only the call in code", inventory.Calls, inventory.Hosts)
+	}
+	endpoint := inventory.Hosts[0].Endpoints[0]
+	if endpoint.URL != "https://api.example.com/list" || !endpoint.Static ||
+		strings.Join(endpoint.Headers, ",") != "X-Note" || strings.Join(endpoint.Fields, ",") != "q" {
+		t.Fatalf("endpoint = %+v", endpoint)
+	}
+}
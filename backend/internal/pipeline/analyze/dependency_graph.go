@@ -0,0 +1,408 @@
+package analyze
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/pipeline/normalize"
+)
+
+// DependencyGraph is dependency-graph.json. Pages and components are keyed
+// by their package path without extension, like app.json does; templates
+// and scripts by their file path. The app node holds app.json's global
+// components and app.js's requires.
+type DependencyGraph struct {
+	Nodes            []GraphNode      `json:"nodes"`
+	Edges            []GraphEdge      `json:"edges"`
+	OrphanComponents []string         `json:"orphanComponents"`
+	Diagnostics      []pkg.Diagnostic `json:"diagnostics"`
+}
+
+// GraphNode kinds are app, page, component, plugin, template and script.
+// Orphan marks a component no page or the app reaches.
+type GraphNode struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Orphan bool   `json:"orphan,omitempty"`
+}
+
+// GraphEdge kinds are component, import, include and require. Name is the
+// tag a usingComponents entry declares.
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+	Name string `json:"name,omitempty"`
+}
+
+var (
+	wxmlReferencePattern = regexp.MustCompile(`<(import|include)\b[^>]*?\bsrc\s*=\s*["']([^"']+)["']`)
+	// runtimeFiles are compiler output kept beside the recovered source.
+	// Their require calls use the bundle's module paths, and js recovery
+	// splits app-service.js and workers.js into the original modules.
+	runtimeFiles = map[string]bool{"app-service.js": true, "workers.js": true, "page-frame.js": true, "app-wxss.js": true}
+	// nodeKindRank keeps the most specific kind when a path is reached as
+	// more than one kind.
+	nodeKindRank = map[string]int{"script": 1, "template": 1, "plugin": 1, "component": 2, "page": 3, "app": 4}
+)
+
+type graphBuilder struct {
+	sourceDir   string
+	files       map[string]bool
+	nodes       map[string]string
+	edges       map[GraphEdge]bool
+	diagnostics []pkg.Diagnostic
+	reported    map[string]bool
+}
+
+// BuildDependencyGraph links the recovered source tree: usingComponents
+// from app.json, pages and components, WXML import and include, and JS
+// require. References that resolve to no file become warnings.
+func BuildDependencyGraph(ctx context.Context, manifest pkg.ManifestIR, sourceDir string) (*DependencyGraph, error) {
+	b := &graphBuilder{
+		sourceDir: sourceDir,
+		files:     make(map[string]bool),
+		nodes:     map[string]string{"app": "app"},
+		edges:     make(map[GraphEdge]bool),
+		reported:  make(map[string]bool),
+	}
+	err := filepath.WalkDir(sourceDir, func(file string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			rel, err := filepath.Rel(sourceDir, file)
+			if err != nil {
+				return err
+			}
+			b.files[filepath.ToSlash(rel)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pages := append([]string{}, manifest.Pages...)
+	for _, sub := range manifest.SubPackages {
+		for _, page := range sub.Pages {
+			pages = append(pages, path.Join(sub.Root, page))
+		}
+	}
+	for _, page := range pages {
+		b.addNode(page, "page")
+	}
+	for _, file := range b.sortedFiles(".json") {
+		if config := b.readJSON(file); config["component"] == true {
+			b.addNode(strings.TrimSuffix(file, ".json"), "component")
+		}
+	}
+
+	// Declared components are walked breadth-first so nested components
+	// are found even when their JSON lacks "component": true.
+	queue := []string{"app"}
+	for id, kind := range b.nodes {
+		if kind == "page" || kind == "component" {
+			queue = append(queue, id)
+		}
+	}
+	sort.Strings(queue[1:])
+	visited := make(map[string]bool)
+	for len(queue) > 0 {
+		owner := queue[0]
+		queue = queue[1:]
+		if visited[owner] {
+			continue
+		}
+		visited[owner] = true
+		for _, target := range b.linkComponents(owner, manifest) {
+			queue = append(queue, target)
+		}
+	}
+	for _, file := range b.sortedFiles(".wxml") {
+		b.linkTemplates(file)
+	}
+	jsFiles := make(map[string]bool)
+	for file := range b.files {
+		if strings.HasSuffix(file, ".js") {
+			jsFiles[file] = true
+		}
+	}
+	for _, file := range b.sortedFiles(".js") {
+		if !runtimeFiles[path.Base(file)] {
+			b.linkRequires(file, jsFiles)
+		}
+	}
+	return b.result(), nil
+}
+
+// linkComponents adds the usingComponents edges of one page or component
+// and returns the components they reach.
+func (b *graphBuilder) linkComponents(owner string, manifest pkg.ManifestIR) []string {
+	references := manifest.UsingComponents
+	configFile, base := "app.json", ""
+	if owner != "app" {
+		configFile, base = owner+".json", owner
+		references = map[string]string{}
+		if using, ok := b.readJSON(configFile)["usingComponents"].(map[string]interface{}); ok {
+			for name, value := range using {
+				if reference, ok := value.(string); ok {
+					references[name] = reference
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(references))
+	for name := range references {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var reached []string
+	for _, name := range names {
+		reference := references[name]
+		if strings.HasPrefix(reference, "plugin://") || strings.HasPrefix(reference, "plugin-private://") {
+			b.addNode(reference, "plugin")
+			b.edges[GraphEdge{From: owner, To: reference, Kind: "component", Name: name}] = true
+			continue
+		}
+		target := b.resolveComponent(base, reference)
+		if target == "" {
+			b.unresolved("graph.component_unresolved", fmt.Sprintf("组件 %s 引用的 %s 不存在", name, reference), configFile, reference)
+			continue
+		}
+		b.addNode(target, "component")
+		b.edges[GraphEdge{From: owner, To: target, Kind: "component", Name: name}] = true
+		reached = append(reached, target)
+	}
+	return reached
+}
+
+// resolveComponent finds the files of a component reference. Bare
+// references that are not beside the owner fall back to miniprogram_npm.
+func (b *graphBuilder) resolveComponent(owner, reference string) string {
+	candidates := []string{normalize.ResolveComponentPath(owner, reference)}
+	if !strings.HasPrefix(reference, ".") && !strings.HasPrefix(reference, "/") {
+		candidates = append(candidates, path.Join("miniprogram_npm", reference))
+	}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		for _, stem := range []string{candidate, path.Join(candidate, "index")} {
+			if b.files[stem+".json"] || b.files[stem+".wxml"] || b.files[stem+".js"] {
+				return stem
+			}
+		}
+	}
+	return ""
+}
+
+func (b *graphBuilder) linkTemplates(file string) {
+	content, err := os.ReadFile(filepath.Join(b.sourceDir, filepath.FromSlash(file)))
+	if err != nil {
+		return
+	}
+	owner := b.ownerOf(file, "template")
+	for _, match := range wxmlReferencePattern.FindAllStringSubmatch(string(content), -1) {
+		kind, reference := match[1], strings.TrimSpace(match[2])
+		if reference == "" || strings.Contains(reference, "{{") {
+			continue
+		}
+		var target string
+		if strings.HasPrefix(reference, "/") {
+			target = path.Clean(strings.TrimPrefix(reference, "/"))
+		} else {
+			target = path.Join(path.Dir(file), reference)
+		}
+		if path.Ext(target) == "" {
+			target += ".wxml"
+		}
+		if !b.files[target] {
+			b.unresolved("graph.template_unresolved", fmt.Sprintf("WXML %s 的文件 %s 不存在", kind, reference), file, reference)
+			continue
+		}
+		b.edges[GraphEdge{From: owner, To: b.ownerOf(target, "template"), Kind: kind}] = true
+	}
+}
+
+func (b *graphBuilder) linkRequires(file string, jsFiles map[string]bool) {
+	content, err := os.ReadFile(filepath.Join(b.sourceDir, filepath.FromSlash(file)))
+	if err != nil {
+		return
+	}
+	owner := b.ownerOf(file, "script")
+	tokens, _ := tokenizeScript(string(content))
+	for _, reference := range requireSpecs(tokens) {
+		target := resolveRequire(file, reference, jsFiles)
+		if target == "" && !strings.HasPrefix(reference, ".") && !strings.HasPrefix(reference, "/") {
+			target = resolveRequire("miniprogram_npm/", reference, jsFiles)
+		}
+		if target == "" {
+			b.unresolved("graph.require_unresolved", fmt.Sprintf("require 的模块 %s 不存在", reference), file, reference)
+			continue
+		}
+		b.edges[GraphEdge{From: owner, To: b.ownerOf(target, "script"), Kind: "require"}] = true
+	}
+}
+
+// ownerOf maps a .wxml or .js file to the page or component it belongs to,
+// or to a node for the file itself.
+func (b *graphBuilder) ownerOf(file, kind string) string {
+	stem := strings.TrimSuffix(file, path.Ext(file))
+	if stem == "app" {
+		return "app"
+	}
+	if existing := b.nodes[stem]; existing == "page" || existing == "component" {
+		return stem
+	}
+	b.addNode(file, kind)
+	return file
+}
+
+func (b *graphBuilder) addNode(id, kind string) {
+	if nodeKindRank[kind] > nodeKindRank[b.nodes[id]] {
+		b.nodes[id] = kind
+	}
+}
+
+func (b *graphBuilder) unresolved(code, message, file, reference string) {
+	key := file + "\x00" + reference
+	if b.reported[key] || len(b.diagnostics) >= maxFindings {
+		return
+	}
+	b.reported[key] = true
+	diagnostic := pkg.Warn(code, message, stage, file)
+	diagnostic.Metadata = map[string]interface{}{"reference": reference}
+	b.diagnostics = append(b.diagnostics, diagnostic)
+}
+
+func (b *graphBuilder) readJSON(file string) map[string]interface{} {
+	config := map[string]interface{}{}
+	if content, err := os.ReadFile(filepath.Join(b.sourceDir, filepath.FromSlash(file))); err == nil {
+		_ = json.Unmarshal(content, &config)
+	}
+	return config
+}
+
+func (b *graphBuilder) sortedFiles(extension string) []string {
+	var files []string
+	for file := range b.files {
+		if strings.HasSuffix(file, extension) {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// result marks the components no page or the app reaches through any edge
+// and sorts everything for stable output.
+func (b *graphBuilder) result() *DependencyGraph {
+	graph := &DependencyGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}, OrphanComponents: []string{}, Diagnostics: b.diagnostics}
+	adjacent := make(map[string][]string)
+	for edge := range b.edges {
+		graph.Edges = append(graph.Edges, edge)
+		adjacent[edge.From] = append(adjacent[edge.From], edge.To)
+	}
+	reached := make(map[string]bool)
+	var queue []string
+	for id, kind := range b.nodes {
+		if kind == "app" || kind == "page" {
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if reached[current] {
+			continue
+		}
+		reached[current] = true
+		queue = append(queue, adjacent[current]...)
+	}
+	for id, kind := range b.nodes {
+		node := GraphNode{ID: id, Kind: kind, Orphan: kind == "component" && !reached[id]}
+		if node.Orphan {
+			graph.OrphanComponents = append(graph.OrphanComponents, id)
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		left, right := graph.Edges[i], graph.Edges[j]
+		if left.From != right.From {
+			return left.From < right.From
+		}
+		if left.To != right.To {
+			return left.To < right.To
+		}
+		if left.Kind != right.Kind {
+			return left.Kind < right.Kind
+		}
+		return left.Name < right.Name
+	})
+	sort.Strings(graph.OrphanComponents)
+	for _, orphan := range graph.OrphanComponents {
+		file := orphan + ".json"
+		if !b.files[file] {
+			file = orphan
+		}
+		graph.Diagnostics = append(graph.Diagnostics, pkg.Info("graph.orphan_component", "组件 "+orphan+" 未被任何页面引用", stage, file))
+	}
+	if graph.Diagnostics == nil {
+		graph.Diagnostics = []pkg.Diagnostic{}
+	}
+	return graph
+}
+
+var dotNodeShapes = map[string]string{
+	"app":       "house",
+	"page":      "box",
+	"component": "component",
+	"plugin":    "box3d",
+	"template":  "note",
+	"script":    "ellipse",
+}
+
+var dotEdgeStyles = map[string]string{
+	"component": "solid",
+	"import":    "dashed",
+	"include":   "dashed",
+	"require":   "dotted",
+}
+
+// DOT renders the graph for Graphviz. Orphaned components are drawn
+// dashed in red.
+func (g *DependencyGraph) DOT() string {
+	var builder strings.Builder
+	builder.WriteString("digraph dependencies {\n\trankdir=LR;\n\tnode [fontname=\"Helvetica\"];\n")
+	for _, node := range g.Nodes {
+		attributes := "shape=" + dotNodeShapes[node.Kind]
+		if node.Orphan {
+			attributes += ", style=dashed, color=red"
+		}
+		fmt.Fprintf(&builder, "\t%s [%s];\n", strconv.Quote(node.ID), attributes)
+	}
+	for _, edge := range g.Edges {
+		label := edge.Kind
+		if edge.Name != "" {
+			label = edge.Name
+		}
+		fmt.Fprintf(&builder, "\t%s -> %s [label=%s, style=%s];\n", strconv.Quote(edge.From), strconv.Quote(edge.To), strconv.Quote(label), dotEdgeStyles[edge.Kind])
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
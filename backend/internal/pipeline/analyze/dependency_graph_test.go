@@ -0,0 +1,91 @@
+package analyze
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+func TestBuildDependencyGraphLinksComponentsTemplatesAndRequires(t *testing.T) {
+	sourceDir := t.TempDir()
+	for name, content := range map[string]string{
+		"app.json":                              `{"pages":["pages/index/index"],"usingComponents":{"nav-bar":"/components/nav/nav"}}`,
+		"app.js":                                `var config = require("./utils/config.js"); App({});`,
+		"pages/index/index.json":                `{"usingComponents":{"card":"../../components/card/card","dialog":"weui/dialog/dialog","chart":"plugin://charts/line","ghost":"../../components/ghost/ghost"}}`,
+		"pages/index/index.wxml":                `<import src="../../templates/item.wxml"/><include src="/templates/footer"/><include src="missing.wxml"/>`,
+		"pages/index/index.js":                  `var api = require("../../utils/api"); var gone = require("./gone.js"); Page({});`,
+		"components/nav/nav.json":               `{"component":true}`,
+		"components/nav/nav.wxml":               `<view/>`,
+		"components/card/card.json":             `{"component":true,"usingComponents":{"badge":"../badge/badge"}}`,
+		"components/card/card.wxml":             `<badge/>`,
+		"components/badge/badge.wxml":           `<view/>`,
+		"components/unused/unused.json":         `{"component":true}`,
+		"components/unused/unused.js":           `Component({});`,
+		"miniprogram_npm/weui/dialog/dialog.js": `Component({});`,
+		"templates/item.wxml":                   `<template name="item"><include src="./footer.wxml"/></template>`,
+		"templates/footer.wxml":                 `<view/>`,
+		"utils/api.js":                          `module.exports = require("./config");`,
+		"utils/config.js":                       `module.exports = {};`,
+		"app-service.js":                        `define("a.js", function(require){ require("./not-here.js"); });`,
+	} {
+		target := filepath.Join(sourceDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := pkg.ManifestIR{Pages: []string{"pages/index/index"}, UsingComponents: map[string]string{"nav-bar": "/components/nav/nav"}}
+
+	graph, err := BuildDependencyGraph(context.Background(), manifest, sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges := map[string]bool{}
+	for _, edge := range graph.Edges {
+		edges[edge.From+" -"+edge.Kind+"-> "+edge.To] = true
+	}
+	for _, want := range []string{
+		"app -component-> components/nav/nav",
+		"app -require-> utils/config.js",
+		"pages/index/index -component-> components/card/card",
+		"components/card/card -component-> components/badge/badge",
+		"pages/index/index -component-> miniprogram_npm/weui/dialog/dialog",
+		"pages/index/index -component-> plugin://charts/line",
+		"pages/index/index -import-> templates/item.wxml",
+		"pages/index/index -include-> templates/footer.wxml",
+		"templates/item.wxml -include-> templates/footer.wxml",
+		"pages/index/index -require-> utils/api.js",
+		"utils/api.js -require-> utils/config.js",
+	} {
+		if !edges[want] {
+			t.Errorf("missing edge %q in %v", want, edges)
+		}
+	}
+	if strings.Join(graph.OrphanComponents, ",") != "components/unused/unused" {
+		t.Fatalf("orphan components = %v", graph.OrphanComponents)
+	}
+
+	codes := map[string][]string{}
+	for _, diagnostic := range graph.Diagnostics {
+		codes[diagnostic.Code] = append(codes[diagnostic.Code], diagnostic.File)
+	}
+	if strings.Join(codes["graph.component_unresolved"], ",") != "pages/index/index.json" ||
+		strings.Join(codes["graph.template_unresolved"], ",") != "pages/index/index.wxml" ||
+		strings.Join(codes["graph.require_unresolved"], ",") != "pages/index/index.js" ||
+		strings.Join(codes["graph.orphan_component"], ",") != "components/unused/unused.json" {
+		t.Fatalf("diagnostics = %v; runtime bundles must not be scanned", codes)
+	}
+
+	dot := graph.DOT()
+	if !strings.HasPrefix(dot, "digraph dependencies {") ||
+		!strings.Contains(dot, `"components/unused/unused" [shape=component, style=dashed, color=red];`) ||
+		!strings.Contains(dot, `"pages/index/index" -> "components/card/card" [label="card", style=solid];`) {
+		t.Fatalf("dot = %s", dot)
+	}
+}
@@ -0,0 +1,175 @@
+package analyze
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var endpointOriginPattern = regexp.MustCompile(`^(?:(?:https?|wss?)://[^/?#]+|\{[^{}]*\})`)
+
+// openAPIIgnoredHeaders are header parameters OpenAPI 3 says to ignore;
+// they are described by the media type and security schemes instead.
+var openAPIIgnoredHeaders = map[string]bool{"accept": true, "content-type": true, "authorization": true}
+
+// BuildOpenAPI renders the inventory as an OpenAPI 3.0 skeleton: one path
+// per endpoint with its callers' servers, methods and statically known
+// parameters. Request and response schemas are left for a human to fill
+// in. WebSocket URLs and cloud functions have no OpenAPI form and are kept
+// under x- extensions.
+func BuildOpenAPI(inventory *APIInventory, title string) map[string]interface{} {
+	paths := make(map[string]map[string]interface{})
+	sockets := []string{}
+	for _, host := range inventory.Hosts {
+		for _, endpoint := range host.Endpoints {
+			if endpoint.API == "wx.connectSocket" {
+				sockets = append(sockets, endpoint.URL)
+				continue
+			}
+			origin, pathPart, query := splitEndpointURL(endpoint.URL)
+			item := paths[pathPart]
+			if item == nil {
+				item = map[string]interface{}{}
+				paths[pathPart] = item
+			}
+			if origin != "" {
+				item["servers"] = appendServer(item["servers"], origin)
+			}
+			if len(endpoint.Methods) == 0 {
+				item["x-seewxapkg-dynamic-method"] = true
+				item["parameters"] = pathParameters(pathPart)
+				continue
+			}
+			for _, method := range endpoint.Methods {
+				key := strings.ToLower(method)
+				if _, exists := item[key]; exists {
+					continue
+				}
+				item[key] = openAPIOperation(endpoint, method, pathPart, query)
+			}
+		}
+	}
+	functions := make([]string, 0, len(inventory.CloudFunctions))
+	for _, function := range inventory.CloudFunctions {
+		functions = append(functions, function.Name)
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       title,
+			"version":     "0.0.0",
+			"description": "由小程序源码静态提取的接口骨架，请求与响应结构需要人工补全。",
+		},
+		"paths":                    paths,
+		"x-wechat-sockets":         sockets,
+		"x-wechat-cloud-functions": functions,
+	}
+}
+
+func openAPIOperation(endpoint APIEndpoint, method, pathPart, query string) map[string]interface{} {
+	parameters := pathParameters(pathPart)
+	seen := make(map[string]bool)
+	add := func(in, name string) {
+		if name == "" || seen[in+"\x00"+name] {
+			return
+		}
+		seen[in+"\x00"+name] = true
+		parameters = append(parameters, map[string]interface{}{"name": name, "in": in, "schema": map[string]interface{}{"type": "string"}})
+	}
+	for _, pair := range strings.Split(query, "&") {
+		name, _, _ := strings.Cut(pair, "=")
+		add("query", name)
+	}
+	for _, header := range endpoint.Headers {
+		if !openAPIIgnoredHeaders[strings.ToLower(header)] {
+			add("header", header)
+		}
+	}
+
+	operation := map[string]interface{}{
+		"summary":             endpoint.API,
+		"responses":           map[string]interface{}{"default": map[string]interface{}{"description": "响应结构无法静态确定"}},
+		"x-wechat-pages":      endpoint.Pages,
+		"x-wechat-components": endpoint.Components,
+	}
+	if len(endpoint.Fields) > 0 {
+		switch {
+		case endpoint.API == "wx.uploadFile":
+			operation["requestBody"] = fieldsBody("multipart/form-data", endpoint.Fields)
+		case method == "GET" || method == "HEAD" || method == "DELETE":
+			// wx.request sends data as the query string for these methods.
+			for _, field := range endpoint.Fields {
+				add("query", field)
+			}
+		default:
+			operation["requestBody"] = fieldsBody("application/json", endpoint.Fields)
+		}
+	}
+	operation["parameters"] = parameters
+	return operation
+}
+
+func fieldsBody(mediaType string, fields []string) map[string]interface{} {
+	properties := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		properties[field] = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"content": map[string]interface{}{
+			mediaType: map[string]interface{}{
+				"schema": map[string]interface{}{"type": "object", "properties": properties},
+			},
+		},
+	}
+}
+
+func pathParameters(pathPart string) []interface{} {
+	parameters := []interface{}{}
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllString(pathPart, -1) {
+		name := strings.Trim(match, "{}")
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		parameters = append(parameters, map[string]interface{}{"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+	}
+	return parameters
+}
+
+// appendServer adds origin to a path's servers. A placeholder origin such
+// as {baseUrl} becomes a server variable.
+func appendServer(existing interface{}, origin string) []interface{} {
+	servers, _ := existing.([]interface{})
+	for _, server := range servers {
+		if server.(map[string]interface{})["url"] == origin {
+			return servers
+		}
+	}
+	server := map[string]interface{}{"url": origin}
+	if variables := placeholderPattern.FindAllString(origin, -1); len(variables) > 0 {
+		described := make(map[string]interface{}, len(variables))
+		for _, variable := range variables {
+			described[strings.Trim(variable, "{}")] = map[string]interface{}{"default": "", "description": "静态分析无法确定的地址前缀"}
+		}
+		server["variables"] = described
+	}
+	servers = append(servers, server)
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].(map[string]interface{})["url"].(string) < servers[j].(map[string]interface{})["url"].(string)
+	})
+	return servers
+}
+
+// splitEndpointURL splits a folded URL into its origin, an OpenAPI path
+// beginning with / and the query string.
+func splitEndpointURL(url string) (origin, pathPart, query string) {
+	origin = endpointOriginPattern.FindString(url)
+	rest := strings.TrimPrefix(url, origin)
+	rest, _, _ = strings.Cut(rest, "#")
+	pathPart, query, _ = strings.Cut(rest, "?")
+	if !strings.HasPrefix(pathPart, "/") {
+		pathPart = "/" + pathPart
+	}
+	return origin, pathPart, query
+}
@@ -0,0 +1,262 @@
+package analyze
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+const (
+	stage = "analyzing"
+	// maxScanFileBytes skips files too large to be hand-written source,
+	// typically bundled vendor code or inlined assets.
+	maxScanFileBytes = 4 * 1024 * 1024
+	// maxFindings bounds each severity's findings for packages that repeat
+	// one pattern thousands of times; counts in the summary stay exact.
+	maxFindings = 1000
+)
+
+// SecurityReport is security-report.json: what an auditor of a recovered
+// mini program looks for first. Every finding names a file below
+// result/src and carries its 1-based line and column in Metadata.
+type SecurityReport struct {
+	ScannedFiles            int              `json:"scannedFiles"`
+	SkippedFiles            int              `json:"skippedFiles"`
+	SecretCount             int              `json:"secretCount"`
+	Endpoints               []Endpoint       `json:"endpoints"`
+	Domains                 []string         `json:"domains"`
+	PrivacyAPIs             map[string]int   `json:"privacyApis"`
+	Permissions             []string         `json:"permissions"`
+	RequiredBackgroundModes []string         `json:"requiredBackgroundModes"`
+	Findings                []pkg.Diagnostic `json:"findings"`
+	FindingsTruncated       bool             `json:"findingsTruncated,omitempty"`
+
+	warnFindings, infoFindings int
+}
+
+// Endpoint is the first place a network URL appears.
+type Endpoint struct {
+	URL  string `json:"url"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+type secretRule struct {
+	code    string
+	label   string
+	pattern *regexp.Regexp
+	// group is the submatch holding the secret value; 0 is the whole match.
+	group int
+}
+
+var secretRules = []secretRule{
+	{"security.secret.private_key", "私钥", regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |ENCRYPTED )?PRIVATE KEY-----`), 0},
+	{"security.secret.aws_access_key", "AWS Access Key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), 0},
+	{"security.secret.aliyun_access_key", "阿里云 AccessKey", regexp.MustCompile(`\bLTAI[0-9A-Za-z]{12,20}\b`), 0},
+	{"security.secret.google_api_key", "Google API Key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`), 0},
+	{"security.secret.jwt", "JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`), 0},
+	{"security.secret.assignment", "疑似硬编码密钥", regexp.MustCompile(`(?i)\b[\w$]*(?:secret|apikey|api_key|accesskey|access_key|token|password|passwd|privatekey|private_key)[\w$]*["']?\s*[:=]\s*["']([A-Za-z0-9_\-+/=.]{16,})["']`), 1},
+}
+
+var (
+	networkAPIPattern = regexp.MustCompile(`\bwx\.(request|uploadFile|downloadFile|connectSocket)\b`)
+	urlPattern        = regexp.MustCompile(`\b(?:https?|wss?)://[A-Za-z0-9.\-]+(?::[0-9]{1,5})?(?:/[^\s"'` + "`" + `<>()\\]*)?`)
+	privacyAPIPattern = regexp.MustCompile(`\bwx\.(getLocation|getFuzzyLocation|chooseLocation|choosePoi|startLocationUpdate|startLocationUpdateBackground|onLocationChange|getUserProfile|getUserInfo|chooseAddress|chooseInvoiceTitle|getWeRunData|startRecord|getRecorderManager|chooseImage|chooseMedia|chooseVideo|chooseMessageFile|saveImageToPhotosAlbum|saveVideoToPhotosAlbum|getClipboardData|addPhoneContact|openBluetoothAdapter|createCameraContext|startSoterAuthentication)\b`)
+	// Phone numbers are obtained through a button, not an API call.
+	privacyOpenTypePattern = regexp.MustCompile(`open-type\s*=\s*["'](getPhoneNumber|getRealtimePhoneNumber|getUserInfo|chooseAvatar)["']`)
+)
+
+var scannedExtensions = map[string]bool{".js": true, ".json": true, ".wxml": true, ".wxs": true, ".html": true}
+
+// AnalyzeSecurity scans the recovered source tree for hardcoded secrets,
+// network endpoints and privacy-sensitive API usage, and reports the
+// permissions and background modes the manifest declares.
+func AnalyzeSecurity(ctx context.Context, np *pkg.NormalizedPackage, sourceDir string) (*SecurityReport, error) {
+	result := &SecurityReport{
+		Endpoints:               []Endpoint{},
+		Domains:                 []string{},
+		PrivacyAPIs:             map[string]int{},
+		Permissions:             []string{},
+		RequiredBackgroundModes: []string{},
+		Findings:                []pkg.Diagnostic{},
+	}
+	seenURLs := make(map[string]bool)
+	domains := make(map[string]bool)
+
+	err := filepath.WalkDir(sourceDir, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || !scannedExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > maxScanFileBytes {
+			result.SkippedFiles++
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		result.ScannedFiles++
+		scanFile(result, filepath.ToSlash(rel), data, seenURLs, domains)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if np != nil {
+		addManifestFindings(result, np.Manifest, sourceDir)
+	}
+	for domain := range domains {
+		result.Domains = append(result.Domains, domain)
+	}
+	sort.Strings(result.Domains)
+	return result, nil
+}
+
+func scanFile(result *SecurityReport, file string, data []byte, seenURLs, domains map[string]bool) {
+	isMarkup := strings.HasSuffix(file, ".wxml") || strings.HasSuffix(file, ".html")
+	for index, line := range bytes.Split(data, []byte("\n")) {
+		lineNumber := index + 1
+		text := string(line)
+		for _, rule := range secretRules {
+			for _, match := range rule.pattern.FindAllStringSubmatchIndex(text, -1) {
+				start, end := match[2*rule.group], match[2*rule.group+1]
+				result.SecretCount++
+				finding := pkg.Warn(rule.code, "发现"+rule.label+"，请确认是否为可滥用的凭据", stage, file)
+				finding.Metadata = position(text, lineNumber, match[0])
+				finding.Metadata["preview"] = maskSecret(text[start:end])
+				result.addFinding(finding)
+			}
+		}
+		for _, match := range networkAPIPattern.FindAllStringSubmatchIndex(text, -1) {
+			api := text[match[2]:match[3]]
+			finding := pkg.Info("security.network.api", "调用网络接口 wx."+api, stage, file)
+			finding.Metadata = position(text, lineNumber, match[0])
+			finding.Metadata["api"] = "wx." + api
+			result.addFinding(finding)
+		}
+		for _, match := range urlPattern.FindAllStringIndex(text, -1) {
+			raw := strings.TrimRight(text[match[0]:match[1]], ".,;")
+			parsed, err := url.Parse(raw)
+			if err != nil || parsed.Hostname() == "" {
+				continue
+			}
+			host := strings.ToLower(parsed.Hostname())
+			domains[host] = true
+			if seenURLs[raw] {
+				continue
+			}
+			seenURLs[raw] = true
+			result.Endpoints = append(result.Endpoints, Endpoint{URL: raw, File: file, Line: lineNumber})
+			if parsed.Scheme == "http" || parsed.Scheme == "ws" {
+				finding := pkg.Warn("security.network.insecure_scheme", "使用未加密的 "+parsed.Scheme+" 地址 "+host, stage, file)
+				finding.Metadata = position(text, lineNumber, match[0])
+				finding.Metadata["url"] = raw
+				result.addFinding(finding)
+			}
+		}
+		for _, match := range privacyAPIPattern.FindAllStringSubmatchIndex(text, -1) {
+			api := "wx." + text[match[2]:match[3]]
+			result.PrivacyAPIs[api]++
+			finding := pkg.Info("security.privacy.api", "调用隐私相关接口 "+api, stage, file)
+			finding.Metadata = position(text, lineNumber, match[0])
+			finding.Metadata["api"] = api
+			result.addFinding(finding)
+		}
+		if isMarkup {
+			for _, match := range privacyOpenTypePattern.FindAllStringSubmatchIndex(text, -1) {
+				openType := "open-type=" + text[match[2]:match[3]]
+				result.PrivacyAPIs[openType]++
+				finding := pkg.Info("security.privacy.open_type", "使用隐私相关开放能力 "+openType, stage, file)
+				finding.Metadata = position(text, lineNumber, match[0])
+				finding.Metadata["api"] = openType
+				result.addFinding(finding)
+			}
+		}
+	}
+}
+
+func addManifestFindings(result *SecurityReport, manifest pkg.ManifestIR, sourceDir string) {
+	appJSON, _ := os.ReadFile(filepath.Join(sourceDir, "app.json"))
+	permissions := make([]string, 0, len(manifest.Permission))
+	for scope := range manifest.Permission {
+		permissions = append(permissions, scope)
+	}
+	sort.Strings(permissions)
+	for _, scope := range permissions {
+		result.Permissions = append(result.Permissions, scope)
+		finding := pkg.Info("security.manifest.permission", "app.json 声明权限 "+scope, stage, "app.json")
+		finding.Metadata = map[string]interface{}{"line": lineOf(appJSON, `"`+scope+`"`), "column": 1, "scope": scope}
+		result.addFinding(finding)
+	}
+	for _, mode := range manifest.RequiredBackgroundModes {
+		result.RequiredBackgroundModes = append(result.RequiredBackgroundModes, mode)
+		finding := pkg.Info("security.manifest.background_mode", "app.json 声明后台运行能力 "+mode, stage, "app.json")
+		finding.Metadata = map[string]interface{}{"line": lineOf(appJSON, `"requiredBackgroundModes"`), "column": 1, "mode": mode}
+		result.addFinding(finding)
+	}
+}
+
+// addFinding caps warnings and informational findings separately, so the
+// network and privacy calls a large bundle repeats cannot crowd out the
+// secrets.
+func (r *SecurityReport) addFinding(finding pkg.Diagnostic) {
+	count := &r.infoFindings
+	if finding.Severity == pkg.SeverityWarn {
+		count = &r.warnFindings
+	}
+	if *count >= maxFindings {
+		r.FindingsTruncated = true
+		return
+	}
+	*count++
+	r.Findings = append(r.Findings, finding)
+}
+
+// position reports a match's line and 1-based rune column. Minified
+// bundles put a whole module on one line, so the column matters.
+func position(text string, line, offset int) map[string]interface{} {
+	return map[string]interface{}{"line": line, "column": len([]rune(text[:offset])) + 1}
+}
+
+// lineOf returns the line of the first occurrence of needle, or 1 when the
+// file does not contain it, so the finding still points into the file.
+func lineOf(data []byte, needle string) int {
+	index := bytes.Index(data, []byte(needle))
+	if index < 0 {
+		return 1
+	}
+	return bytes.Count(data[:index], []byte("\n")) + 1
+}
+
+// maskSecret keeps enough of a value to recognize it without the report
+// itself becoming a copy of the credential.
+func maskSecret(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return fmt.Sprintf("%s…%s (%d 字符)", value[:4], value[len(value)-2:], len(value))
+}
@@ -0,0 +1,122 @@
+package analyze
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+func TestAnalyzeSecurityReportsFindingsWithFileAndLine(t *testing.T) {
+	sourceDir := t.TempDir()
+	for name, content := range map[string]string{
+		"app.json": "{\n  \"pages\": [\"pages/index/index\"],\n  \"permission\": {\n    \"scope.userLocation\": {\"desc\": \"定位\"}\n  },\n  \"requiredBackgroundModes\": [\"location\"]\n}",
+		"utils/config.js": strings.Join([]string{
+			`var BASE = "https://api.example.com/v1/";`,
+			`var appSecret = "0123456789abcdef0123456789abcdef";`,
+			`var legacy = "http://old.example.com/upload";`,
+		}, "\n"),
+		"pages/index/index.js": strings.Join([]string{
+			`Page({onLoad: function() {`,
+			`  wx.getLocation({type: "gcj02"}); wx.request({url: BASE + "user"});`,
+			`}});`,
+		}, "\n"),
+		"pages/index/index.wxml": `<button open-type="getPhoneNumber" bindgetphonenumber="onPhone">登录</button>`,
+		"images/logo.png":        `https://ignored.example.com/`,
+	} {
+		path := filepath.Join(sourceDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := pkg.ManifestIR{
+		Pages:                   []string{"pages/index/index"},
+		Permission:              map[string]interface{}{"scope.userLocation": map[string]interface{}{"desc": "定位"}},
+		RequiredBackgroundModes: []string{"location"},
+	}
+
+	result, err := AnalyzeSecurity(context.Background(), &pkg.NormalizedPackage{Manifest: manifest}, sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ScannedFiles != 4 {
+		t.Fatalf("scanned %d files, want 4", result.ScannedFiles)
+	}
+	if result.SecretCount != 1 || strings.Contains(findingPreview(t, result, "security.secret.assignment"), "0123456789abcdef0123") {
+		t.Fatalf("secret findings = %d with preview %q; the report must mask the value", result.SecretCount, findingPreview(t, result, "security.secret.assignment"))
+	}
+	if strings.Join(result.Domains, ",") != "api.example.com,old.example.com" {
+		t.Fatalf("domains = %v", result.Domains)
+	}
+	if result.PrivacyAPIs["wx.getLocation"] != 1 || result.PrivacyAPIs["open-type=getPhoneNumber"] != 1 {
+		t.Fatalf("privacy APIs = %v", result.PrivacyAPIs)
+	}
+	if strings.Join(result.Permissions, ",") != "scope.userLocation" || strings.Join(result.RequiredBackgroundModes, ",") != "location" {
+		t.Fatalf("manifest declarations = %v %v", result.Permissions, result.RequiredBackgroundModes)
+	}
+
+	want := map[string]struct {
+		file string
+		line int
+	}{
+		"security.secret.assignment":        {"utils/config.js", 2},
+		"security.network.insecure_scheme":  {"utils/config.js", 3},
+		"security.network.api":              {"pages/index/index.js", 2},
+		"security.privacy.api":              {"pages/index/index.js", 2},
+		"security.privacy.open_type":        {"pages/index/index.wxml", 1},
+		"security.manifest.permission":      {"app.json", 4},
+		"security.manifest.background_mode": {"app.json", 6},
+	}
+	for _, finding := range result.Findings {
+		if finding.File == "" || finding.Metadata["line"] == nil || finding.Metadata["column"] == nil {
+			t.Fatalf("finding without position: %+v", finding)
+		}
+		if expected, ok := want[finding.Code]; ok {
+			if finding.File != expected.file || finding.Metadata["line"] != expected.line {
+				t.Fatalf("%s at %s:%v, want %s:%d", finding.Code, finding.File, finding.Metadata["line"], expected.file, expected.line)
+			}
+			delete(want, finding.Code)
+		}
+	}
+	if len(want) != 0 {
+		t.Fatalf("missing findings: %v", want)
+	}
+}
+
+func findingPreview(t *testing.T, result *SecurityReport, code string) string {
+	t.Helper()
+	for _, finding := range result.Findings {
+		if finding.Code == code {
+			preview, _ := finding.Metadata["preview"].(string)
+			return preview
+		}
+	}
+	t.Fatalf("no %s finding", code)
+	return ""
+}
+
+func TestAnalyzeSecurityKeepsSecretsPastTheInfoFindingCap(t *testing.T) {
+	sourceDir := t.TempDir()
+	calls := strings.Repeat("wx.request({url: u});\n", maxFindings+200)
+	code := calls + `var appSecret = "0123456789abcdef0123456789abcdef";`
+	if err := os.WriteFile(filepath.Join(sourceDir, "app.js"), []byte(code), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := AnalyzeSecurity(context.Background(), nil, sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.FindingsTruncated || len(result.Findings) != maxFindings+1 {
+		t.Fatalf("findings = %d (truncated %v), want the capped calls and the secret", len(result.Findings), result.FindingsTruncated)
+	}
+	if findingPreview(t, result, "security.secret.assignment") == "" {
+		t.Fatal("the secret was truncated behind informational findings")
+	}
+}
@@ -10,23 +10,44 @@ import (
 )
 
 func DetectPackageProfile(data []byte, extractedDir string) (*pkg.PackageProfile, error) {
-	profile := &pkg.PackageProfile{
-		IsEncrypted:      decrypt.IsEncrypted(data),
-		IsStandardWxapkg: decrypt.IsDecrypted(data),
-		IndexFileCount:   countIndexedFiles(data),
-	}
-
+	profile := newProfile(data)
 	if extractedDir != "" {
 		if err := detectExtractedVariant(extractedDir, profile); err != nil {
 			return nil, err
 		}
 	}
+	finishProfile(profile)
+	return profile, nil
+}
+
+// DetectPackageProfileFromIndex classifies a package from its index entry
+// names, so callers that only parse the index never have to extract it.
+// Names may carry the leading slash WeChat writes.
+func DetectPackageProfileFromIndex(data []byte, names []string) *pkg.PackageProfile {
+	profile := newProfile(data)
+	for _, name := range names {
+		observeEntry(strings.TrimLeft(name, "/"), profile)
+	}
+	finishProfile(profile)
+	return profile
+}
+
+func newProfile(data []byte) *pkg.PackageProfile {
+	return &pkg.PackageProfile{
+		IsEncrypted:      decrypt.IsEncrypted(data),
+		IsStandardWxapkg: decrypt.IsDecrypted(data),
+		IndexFileCount:   countIndexedFiles(data),
+	}
+}
 
+func finishProfile(profile *pkg.PackageProfile) {
 	profile.IsWeChat4xLike = profile.HasAppConfigJSON || profile.HasPageFrameHTML || profile.HasPageFrameJS || profile.HasAppWxssJS
 
 	switch {
 	case profile.IsGamePackage:
 		profile.SuspectedVariant = "game"
+	case profile.IsPluginPackage:
+		profile.SuspectedVariant = "plugin"
 	case profile.IsSubPackage:
 		profile.SuspectedVariant = "subpackage"
 	case profile.IsWeChat4xLike:
@@ -38,8 +59,6 @@ func DetectPackageProfile(data []byte, extractedDir string) (*pkg.PackageProfile
 	default:
 		profile.SuspectedVariant = "unknown"
 	}
-
-	return profile, nil
 }
 
 func detectExtractedVariant(extractedDir string, profile *pkg.PackageProfile) error {
@@ -52,32 +71,56 @@ func detectExtractedVariant(extractedDir string, profile *pkg.PackageProfile) er
 		if err != nil {
 			return err
 		}
-		name := filepath.ToSlash(rel)
+		observeEntry(filepath.ToSlash(rel), profile)
+		return nil
+	})
+}
 
-		switch name {
-		case "app-config.json":
-			profile.HasAppConfigJSON = true
-		case "app-service.js":
-			profile.HasAppServiceJS = true
-		case "workers.js":
-			profile.HasWorkersJS = true
-		case "page-frame.html":
-			profile.HasPageFrameHTML = true
-		case "page-frame.js":
-			profile.HasPageFrameJS = true
-		case "app-wxss.js":
-			profile.HasAppWxssJS = true
-		case "game.json", "game.js":
-			// Mini-game packages ship game.js (often alongside app-config.json),
-			// with no page-frame/app-wxss renderer sources.
-			profile.IsGamePackage = true
-		}
+func observeEntry(name string, profile *pkg.PackageProfile) {
+	switch name {
+	case "app-config.json":
+		profile.HasAppConfigJSON = true
+	case "app-service.js":
+		profile.HasAppServiceJS = true
+	case "workers.js":
+		profile.HasWorkersJS = true
+	case "page-frame.html":
+		profile.HasPageFrameHTML = true
+	case "page-frame.js":
+		profile.HasPageFrameJS = true
+	case "app-wxss.js":
+		profile.HasAppWxssJS = true
+	case "game.json", "game.js":
+		// Mini-game packages ship game.js (often alongside app-config.json),
+		// with no page-frame/app-wxss renderer sources.
+		profile.IsGamePackage = true
+	}
 
-		if strings.Contains(name, "/__APP__") || strings.HasPrefix(name, "__APP__/") {
-			profile.IsSubPackage = true
+	if appID, ok := PluginManifestAppID(name); ok {
+		profile.IsPluginPackage = true
+		if profile.PluginAppID == "" {
+			profile.PluginAppID = appID
 		}
-		return nil
-	})
+	}
+
+	if strings.Contains(name, "/__APP__") || strings.HasPrefix(name, "__APP__/") {
+		profile.IsSubPackage = true
+	}
+}
+
+// PluginManifestAppID reports whether name is the plugin.json of a plugin
+// package: at the package root, as __plugin__/<appid>/plugin.json, or as the
+// __extended__/<appid>/plugin.json metadata copy. The AppID is empty for a
+// root plugin.json.
+func PluginManifestAppID(name string) (string, bool) {
+	if name == "plugin.json" {
+		return "", true
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) == 3 && (parts[0] == "__plugin__" || parts[0] == "__extended__") && parts[1] != "" && parts[2] == "plugin.json" {
+		return parts[1], true
+	}
+	return "", false
 }
 
 func countIndexedFiles(data []byte) int {
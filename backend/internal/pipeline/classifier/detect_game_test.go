@@ -46,3 +46,19 @@ func TestDetectMiniProgramNotGame(t *testing.T) {
 		t.Fatalf("mini-program must not be flagged as game")
 	}
 }
+
+func TestDetectPluginPackageFromIndexNames(t *testing.T) {
+	data := []byte{0xBE, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xED}
+	p := DetectPackageProfileFromIndex(data, []string{"/__extended__/wx1234567890abcdef/plugin.json", "/components/list/list.js", "/app-service.js"})
+	if !p.IsPluginPackage || p.PluginAppID != "wx1234567890abcdef" {
+		t.Fatalf("plugin metadata must mark the package as plugin: %#v", p)
+	}
+	if p.SuspectedVariant != "plugin" {
+		t.Fatalf("variant = %s, want plugin", p.SuspectedVariant)
+	}
+
+	host := DetectPackageProfileFromIndex(data, []string{"/app.json", "/pages/index/plugin.json"})
+	if host.IsPluginPackage {
+		t.Fatalf("nested plugin.json must not mark a host app as plugin: %#v", host)
+	}
+}
@@ -0,0 +1,93 @@
+package decrypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrAppIDNotFound means no candidate AppID decrypts the package.
+var ErrAppIDNotFound = errors.New("no candidate appID decrypts the package")
+
+// AppIDFromPath extracts the AppID from the directory WeChat stores a
+// package in, e.g. .../Applet/wx0123456789abcdef/12/__APP__.wxapkg. Both
+// slash styles are accepted and the directory closest to the file wins.
+func AppIDFromPath(packagePath string) string {
+	segments := strings.FieldsFunc(packagePath, func(r rune) bool { return r == '/' || r == '\\' })
+	for index := len(segments) - 2; index >= 0; index-- {
+		if isAppID(segments[index]) {
+			return segments[index]
+		}
+	}
+	return ""
+}
+
+// MatchesAppID reports whether appID decrypts a package of size bytes whose
+// leading bytes are head to a wxapkg header. Only the encrypted head is
+// decrypted and its 14-byte header must carry both markers, a zero info
+// field, and index and body lengths that fit within the package; the two
+// markers alone would match one wrong key in 65536.
+func MatchesAppID(head []byte, size int64, appID string) bool {
+	if ValidateAppID(appID) != nil || !IsEncrypted(head) || len(head) < len(FileHeader)+encryptedHeadSize || size < int64(len(head)) {
+		return false
+	}
+	key, err := pbkdf2.Key(sha1.New, appID, []byte(Salt), Iterations, KeyLength)
+	if err != nil {
+		return false
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return false
+	}
+	plain := make([]byte, encryptedHeadSize)
+	cipher.NewCBCDecrypter(block, []byte(IV)).CryptBlocks(plain, head[len(FileHeader):len(FileHeader)+encryptedHeadSize])
+	if !IsDecrypted(plain) || binary.BigEndian.Uint32(plain[1:5]) != 0 {
+		return false
+	}
+	indexLength := uint64(binary.BigEndian.Uint32(plain[5:9]))
+	bodyLength := uint64(binary.BigEndian.Uint32(plain[9:13]))
+	return 14+indexLength+bodyLength <= uint64(size)-uint64(len(FileHeader))-1
+}
+
+// SearchAppID returns the first candidate that decrypts the package of
+// size bytes whose leading bytes are head. It is meant for offline use with
+// a trusted candidate list; an attacker-controlled list only costs key
+// derivations.
+func SearchAppID(head []byte, size int64, candidates []string) (string, error) {
+	if !IsEncrypted(head) {
+		return "", ErrInvalidHeader
+	}
+	for _, candidate := range candidates {
+		if MatchesAppID(head, size, candidate) {
+			return candidate, nil
+		}
+	}
+	return "", ErrAppIDNotFound
+}
+
+// ReadAppIDCandidates reads one AppID per line. Blank lines, # comments and
+// malformed entries are skipped, and duplicates are dropped.
+func ReadAppIDCandidates(r io.Reader) ([]string, error) {
+	var candidates []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		line = strings.ToLower(strings.TrimSpace(line))
+		if ValidateAppID(line) != nil || seen[line] {
+			continue
+		}
+		seen[line] = true
+		candidates = append(candidates, line)
+	}
+	return candidates, scanner.Err()
+}
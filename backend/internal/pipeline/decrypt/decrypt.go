@@ -1,12 +1,14 @@
 package decrypt
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/pbkdf2"
 	"crypto/sha1"
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
 )
 
@@ -23,7 +25,9 @@ var (
 	ErrNeedAppID     = errors.New("encrypted package requires appID")
 	ErrBadAppID      = errors.New("invalid appID format")
 	ErrInvalidHeader = errors.New("invalid wxapkg header")
-	appIDPattern     = regexp.MustCompile(`^wx[a-f0-9]{16}$`)
+	// AppIDPattern finds AppIDs anywhere in a string, such as the directory
+	// in Applet/wx0123456789abcdef/12/__APP__.wxapkg.
+	AppIDPattern = regexp.MustCompile(`wx[a-f0-9]{16}`)
 )
 
 type EncryptionMode string
@@ -70,60 +74,68 @@ func ValidateHeader(data []byte) error {
 }
 
 func ValidateAppID(appID string) error {
-	if !appIDPattern.MatchString(appID) {
+	if !isAppID(appID) {
 		return ErrBadAppID
 	}
 	return nil
 }
 
+func isAppID(value string) bool {
+	return len(value) == len("wx")+16 && AppIDPattern.MatchString(value)
+}
+
+// DecryptWxapkg returns the plaintext package in memory. Large inputs should
+// go through NewPackageReader instead.
 func DecryptWxapkg(data []byte, appID string) ([]byte, error) {
 	if IsDecrypted(data) {
 		return data, nil
 	}
-	if IsEncrypted(data) && appID == "" {
-		return nil, ErrNeedAppID
-	}
-	if appID != "" {
-		if err := ValidateAppID(appID); err != nil {
-			return nil, err
-		}
+	reader, err := NewPackageReader(bytes.NewReader(data), int64(len(data)), appID)
+	if err != nil {
+		return nil, err
 	}
-	if err := ValidateHeader(data); err != nil {
+	result := make([]byte, reader.Size())
+	if _, err := reader.ReadAt(result, 0); err != nil && err != io.EOF {
 		return nil, err
 	}
+	return result, nil
+}
 
-	salt := []byte(Salt)
-	iv := []byte(IV)
-	key, err := pbkdf2.Key(sha1.New, appID, salt, Iterations, KeyLength)
-	if err != nil {
-		return nil, fmt.Errorf("derive decryption key: %w", err)
+// EncryptWxapkg produces the V1MMWX format that DecryptWxapkg reverses: the
+// first 1024 plaintext bytes are AES-256-CBC encrypted (decryption keeps only
+// 1023 of them) and every byte from offset 1023 on is XORed with the
+// second-to-last AppID byte. Packages shorter than 1023 bytes cannot be
+// represented in this format.
+func EncryptWxapkg(data []byte, appID string) ([]byte, error) {
+	if err := ValidateAppID(appID); err != nil {
+		return nil, err
 	}
-
-	headerLen := len(FileHeader)
-	if len(data) < headerLen+1024 {
-		return nil, fmt.Errorf("file too small to decrypt")
+	if !IsDecrypted(data) {
+		return nil, ErrInvalidHeader
+	}
+	if len(data) < 1023 {
+		return nil, fmt.Errorf("package too small to encrypt: %d bytes", len(data))
 	}
 
+	key, err := pbkdf2.Key(sha1.New, appID, []byte(Salt), Iterations, KeyLength)
+	if err != nil {
+		return nil, fmt.Errorf("derive encryption key: %w", err)
+	}
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("AES cipher creation failed: %w", err)
 	}
 
-	mode := cipher.NewCBCDecrypter(block, iv)
-	decrypted1024 := make([]byte, 1024)
-	mode.CryptBlocks(decrypted1024, data[headerLen:headerLen+1024])
-
-	xorKey := DefaultXORKey
-	if len(appID) >= 2 {
-		xorKey = int(appID[len(appID)-2])
-	}
+	head := make([]byte, 1024)
+	copy(head, data)
+	result := make([]byte, len(FileHeader)+1024+len(data)-1023)
+	copy(result, FileHeader)
+	cipher.NewCBCEncrypter(block, []byte(IV)).CryptBlocks(result[len(FileHeader):len(FileHeader)+1024], head)
 
-	remainingLen := len(data) - 1024 - headerLen
-	result := make([]byte, 1023+remainingLen)
-	copy(result, decrypted1024[:1023])
-	for i := 0; i < remainingLen; i++ {
-		result[1023+i] = data[1024+headerLen+i] ^ byte(xorKey)
+	xorKey := byte(appID[len(appID)-2])
+	tail := result[len(FileHeader)+1024:]
+	for i, value := range data[1023:] {
+		tail[i] = value ^ xorKey
 	}
-
 	return result, nil
 }
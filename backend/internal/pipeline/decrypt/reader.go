@@ -0,0 +1,116 @@
+package decrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"fmt"
+	"io"
+)
+
+// encryptedHeadSize is the AES-CBC encrypted prefix that follows the V1MMWX
+// marker. Only its first 1023 bytes are plaintext package bytes.
+const encryptedHeadSize = 1024
+
+// PackageReader exposes the plaintext of a wxapkg without materializing it:
+// the AES-encrypted head is decrypted once and the XORed body is decoded on
+// every read, so memory stays constant however large the package is. A
+// package that is already plaintext is passed through unchanged.
+type PackageReader struct {
+	src    io.ReaderAt
+	size   int64
+	head   []byte
+	body   int64
+	xorKey byte
+}
+
+// NewPackageReader validates the package like DecryptWxapkg and returns a
+// reader over its plaintext. src must stay open while the reader is used.
+func NewPackageReader(src io.ReaderAt, size int64, appID string) (*PackageReader, error) {
+	prefix := make([]byte, min(size, int64(len(FileHeader)+encryptedHeadSize)))
+	if _, err := src.ReadAt(prefix, 0); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read package header: %w", err)
+	}
+	if IsDecrypted(prefix) {
+		return &PackageReader{src: src, size: size}, nil
+	}
+	if IsEncrypted(prefix) && appID == "" {
+		return nil, ErrNeedAppID
+	}
+	if appID != "" {
+		if err := ValidateAppID(appID); err != nil {
+			return nil, err
+		}
+	}
+	if err := ValidateHeader(prefix); err != nil {
+		return nil, err
+	}
+	if len(prefix) < len(FileHeader)+encryptedHeadSize {
+		return nil, fmt.Errorf("file too small to decrypt")
+	}
+
+	key, err := pbkdf2.Key(sha1.New, appID, []byte(Salt), Iterations, KeyLength)
+	if err != nil {
+		return nil, fmt.Errorf("derive decryption key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("AES cipher creation failed: %w", err)
+	}
+	head := make([]byte, encryptedHeadSize)
+	cipher.NewCBCDecrypter(block, []byte(IV)).CryptBlocks(head, prefix[len(FileHeader):])
+
+	body := int64(len(FileHeader) + encryptedHeadSize)
+	return &PackageReader{
+		src:    src,
+		size:   encryptedHeadSize - 1 + size - body,
+		head:   head[:encryptedHeadSize-1],
+		body:   body,
+		xorKey: appID[len(appID)-2],
+	}, nil
+}
+
+// Size is the plaintext length.
+func (r *PackageReader) Size() int64 {
+	return r.size
+}
+
+// ReadAt implements io.ReaderAt over the plaintext.
+func (r *PackageReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if r.head == nil {
+		return r.src.ReadAt(p, off)
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	want := len(p)
+	if remaining := r.size - off; int64(want) > remaining {
+		p = p[:remaining]
+	}
+
+	n := 0
+	if headLen := int64(len(r.head)); off < headLen {
+		n = copy(p, r.head[off:])
+	}
+	if n < len(p) {
+		read, err := r.src.ReadAt(p[n:], r.body+off+int64(n)-int64(len(r.head)))
+		for i := n; i < n+read; i++ {
+			p[i] ^= r.xorKey
+		}
+		n += read
+		if n < len(p) {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+	}
+	if n < want {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,369 @@
+// Package jslex tokenizes the ES5 code mini program compilers emit. It is
+// shared by source recovery, which parses the tokens into syntax trees, and
+// by the analyzers, which only need to tell code from strings and comments.
+package jslex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kind classifies a token.
+type Kind int
+
+const (
+	EOF Kind = iota
+	Ident
+	Number
+	String
+	Template
+	Regexp
+	Punct
+)
+
+// Token is one token of the source. Value holds the decoded string of a
+// String token and the float64 of a Number token; Start and End are byte
+// offsets into the source.
+type Token struct {
+	Kind  Kind
+	Text  string
+	Value interface{}
+	Start int
+	End   int
+	// Newline reports a line break before the token, for ASI.
+	Newline bool
+}
+
+var punctuators = []string{
+	">>>=", "...", "===", "!==", "**=", "<<=", ">>=", ">>>", "&&=", "||=", "??=",
+	"=>", "==", "!=", "<=", ">=", "&&", "||", "??", "?.", "++", "--", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<", ">>", "**",
+}
+
+var regexpKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true,
+	"delete": true, "void": true, "throw": true, "case": true, "do": true, "else": true,
+}
+
+// Tokenize reads the whole source. The last token is always EOF.
+func Tokenize(src string) ([]Token, error) {
+	lexer := &Lexer{src: src}
+	for len(lexer.tokens) == 0 || lexer.tokens[len(lexer.tokens)-1].Kind != EOF {
+		lexer.Scan()
+	}
+	if lexer.err != nil {
+		return nil, lexer.err
+	}
+	return lexer.tokens, nil
+}
+
+// Lexer reads tokens on demand, so parsing an expression in the middle of
+// a bundle tokenizes only that expression.
+type Lexer struct {
+	src    string
+	pos    int
+	tokens []Token
+	err    error
+}
+
+// NewLexer reads src from offset pos.
+func NewLexer(src string, pos int) *Lexer {
+	return &Lexer{src: src, pos: pos}
+}
+
+// Tokens returns the tokens scanned so far.
+func (l *Lexer) Tokens() []Token {
+	return l.tokens
+}
+
+// Err returns the error that ended the stream, if any.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// Scan appends the next token. The end of the source and a malformed
+// literal both end the stream with an EOF token; the latter also sets err.
+func (l *Lexer) Scan() {
+	src := l.src
+	start := SkipSpace(src, l.pos)
+	newline := strings.ContainsAny(src[l.pos:start], "\n\r")
+	pos := start
+	if pos >= len(src) {
+		l.pos = pos
+		l.tokens = append(l.tokens, Token{Kind: EOF, Start: pos, End: pos, Newline: true})
+		return
+	}
+	token := Token{Start: pos, Newline: newline}
+	var err error
+	switch ch := src[pos]; {
+	case ch == '"' || ch == '\'':
+		token.Kind = String
+		token.Value, pos, err = ScanString(src, pos)
+	case ch == '`':
+		token.Kind = Template
+		pos, err = SkipTemplate(src, pos)
+	case IsDigit(ch) || ch == '.' && pos+1 < len(src) && IsDigit(src[pos+1]):
+		token.Kind = Number
+		token.Value, pos, err = ScanNumber(src, pos)
+	case IsIdentStart(ch) || ch >= 0x80:
+		for pos < len(src) && (IsIdentStart(src[pos]) || IsDigit(src[pos]) || src[pos] >= 0x80) {
+			pos++
+		}
+		token.Kind = Ident
+	case ch == '/' && regexpAllowed(l.tokens):
+		token.Kind, pos = Regexp, SkipRegexp(src, pos)
+	default:
+		token.Kind = Punct
+		length := 1
+		for _, punct := range punctuators {
+			if strings.HasPrefix(src[pos:], punct) {
+				length = len(punct)
+				break
+			}
+		}
+		pos += length
+	}
+	if err != nil {
+		l.err = err
+		l.tokens = append(l.tokens, Token{Kind: EOF, Start: start, End: start, Newline: true})
+		return
+	}
+	l.pos = pos
+	token.End = pos
+	token.Text = src[token.Start:pos]
+	l.tokens = append(l.tokens, token)
+}
+
+// ScanString decodes the quoted string at pos and returns the offset past
+// its closing quote.
+func ScanString(src string, pos int) (string, int, error) {
+	quote := src[pos]
+	pos++
+	var out strings.Builder
+	for pos < len(src) {
+		ch := src[pos]
+		switch {
+		case ch == quote:
+			return out.String(), pos + 1, nil
+		case ch == '\n':
+			return "", pos, fmt.Errorf("offset %d: unterminated string", pos)
+		case ch != '\\':
+			out.WriteByte(ch)
+			pos++
+			continue
+		}
+		pos++
+		if pos >= len(src) {
+			break
+		}
+		escape := src[pos]
+		pos++
+		switch escape {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case 'b':
+			out.WriteByte('\b')
+		case 'f':
+			out.WriteByte('\f')
+		case 'v':
+			out.WriteByte('\v')
+		case '0':
+			out.WriteByte(0)
+		case '\n':
+			// Line continuation.
+		case 'x', 'u':
+			digits := 2
+			if escape == 'u' {
+				digits = 4
+				if pos < len(src) && src[pos] == '{' {
+					end := strings.IndexByte(src[pos:], '}')
+					if end < 0 {
+						return "", pos, fmt.Errorf("offset %d: invalid unicode escape", pos)
+					}
+					code, err := strconv.ParseUint(src[pos+1:pos+end], 16, 32)
+					if err != nil {
+						return "", pos, fmt.Errorf("offset %d: invalid unicode escape", pos)
+					}
+					out.WriteRune(rune(code))
+					pos += end + 1
+					continue
+				}
+			}
+			if pos+digits > len(src) {
+				return "", pos, fmt.Errorf("offset %d: invalid escape", pos)
+			}
+			code, err := strconv.ParseUint(src[pos:pos+digits], 16, 32)
+			if err != nil {
+				return "", pos, fmt.Errorf("offset %d: invalid escape", pos)
+			}
+			pos += digits
+			r := rune(code)
+			// Join UTF-16 surrogate pairs written as two \u escapes.
+			if r >= 0xD800 && r < 0xDC00 && strings.HasPrefix(src[pos:], "\\u") && pos+6 <= len(src) {
+				if low, err := strconv.ParseUint(src[pos+2:pos+6], 16, 32); err == nil && low >= 0xDC00 && low < 0xE000 {
+					r = (r-0xD800)<<10 + (rune(low) - 0xDC00) + 0x10000
+					pos += 6
+				}
+			}
+			if !utf8.ValidRune(r) {
+				r = utf8.RuneError
+			}
+			out.WriteRune(r)
+		default:
+			out.WriteByte(escape)
+		}
+	}
+	return "", pos, fmt.Errorf("offset %d: unterminated string", pos)
+}
+
+// ScanNumber reads the unsigned numeric literal at pos.
+func ScanNumber(src string, pos int) (float64, int, error) {
+	start := pos
+	if strings.HasPrefix(src[pos:], "0x") || strings.HasPrefix(src[pos:], "0X") {
+		pos += 2
+		digits := pos
+		for pos < len(src) && IsHexDigit(src[pos]) {
+			pos++
+		}
+		value, err := strconv.ParseInt(src[digits:pos], 16, 64)
+		if err != nil {
+			return 0, pos, fmt.Errorf("offset %d: invalid number", start)
+		}
+		return float64(value), pos, nil
+	}
+	for pos < len(src) {
+		ch := src[pos]
+		if IsDigit(ch) || ch == '.' || ch == 'e' || ch == 'E' ||
+			(ch == '-' || ch == '+') && (src[pos-1] == 'e' || src[pos-1] == 'E') {
+			pos++
+			continue
+		}
+		break
+	}
+	value, err := strconv.ParseFloat(src[start:pos], 64)
+	if err != nil {
+		return 0, pos, fmt.Errorf("offset %d: invalid number %q", start, src[start:pos])
+	}
+	return value, pos, nil
+}
+
+func regexpAllowed(tokens []Token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	previous := tokens[len(tokens)-1]
+	switch previous.Kind {
+	case Punct:
+		return previous.Text != ")" && previous.Text != "]" && previous.Text != "}"
+	case Ident:
+		return regexpKeywords[previous.Text]
+	}
+	return false
+}
+
+// SkipTemplate returns the offset past the template literal at pos.
+func SkipTemplate(src string, pos int) (int, error) {
+	depth := 0
+	for pos++; pos < len(src); pos++ {
+		switch src[pos] {
+		case '\\':
+			pos++
+		case '$':
+			if depth == 0 && pos+1 < len(src) && src[pos+1] == '{' {
+				depth = 1
+				pos++
+			}
+		case '{':
+			if depth > 0 {
+				depth++
+			}
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case '`':
+			if depth == 0 {
+				return pos + 1, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated template literal")
+}
+
+// SkipSpace returns the offset of the first byte at or after pos that is
+// not whitespace or part of a comment.
+func SkipSpace(src string, pos int) int {
+	for pos < len(src) {
+		switch {
+		case src[pos] == ' ' || src[pos] == '\t' || src[pos] == '\n' || src[pos] == '\r':
+			pos++
+		case strings.HasPrefix(src[pos:], "//"):
+			end := strings.IndexByte(src[pos:], '\n')
+			if end < 0 {
+				return len(src)
+			}
+			pos += end + 1
+		case strings.HasPrefix(src[pos:], "/*"):
+			end := strings.Index(src[pos+2:], "*/")
+			if end < 0 {
+				return len(src)
+			}
+			pos += end + 4
+		default:
+			return pos
+		}
+	}
+	return pos
+}
+
+// IsDigit reports an ASCII decimal digit.
+func IsDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// IsHexDigit reports an ASCII hexadecimal digit.
+func IsHexDigit(ch byte) bool {
+	return IsDigit(ch) || ch >= 'a' && ch <= 'f' || ch >= 'A' && ch <= 'F'
+}
+
+// IsIdentStart reports an ASCII byte that can start an identifier.
+func IsIdentStart(ch byte) bool {
+	return ch == '_' || ch == '$' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+// SkipRegexp returns the offset past the regular expression literal at
+// pos, including its flags.
+func SkipRegexp(src string, pos int) int {
+	inClass := false
+	for pos++; pos < len(src); pos++ {
+		switch src[pos] {
+		case '\\':
+			pos++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '\n':
+			return pos
+		case '/':
+			if !inClass {
+				pos++
+				for pos < len(src) && IsIdentStart(src[pos]) {
+					pos++
+				}
+				return pos
+			}
+		}
+	}
+	return pos
+}
+
+// IsPunct reports whether token is the punctuator text.
+func IsPunct(token Token, text string) bool {
+	return token.Kind == Punct && token.Text == text
+}
@@ -0,0 +1,41 @@
+package jslex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenizeSkipsCommentsAndTellsRegexpsFromDivision(t *testing.T) {
+	src := "a = b / 2; // c / d\n/* e */ return /[/]x/g.test(\"\\u4e2d\\n\")"
+	tokens, err := Tokenize(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, token := range tokens {
+		texts = append(texts, token.Text)
+	}
+	if got := strings.Join(texts, " "); got != `a = b / 2 ; return /[/]x/g . test ( "\u4e2d\n" ) ` {
+		t.Fatalf("tokens = %q", got)
+	}
+	if tokens[3].Kind != Punct || tokens[7].Kind != Regexp || !tokens[6].Newline {
+		t.Fatalf("kinds = %+v", tokens)
+	}
+	if value := tokens[11].Value; value != "中\n" {
+		t.Fatalf("string value = %q", value)
+	}
+}
+
+func TestLexerStopsAtAMalformedLiteral(t *testing.T) {
+	lexer := NewLexer(`x; f("open`, 3)
+	for {
+		lexer.Scan()
+		if tokens := lexer.Tokens(); tokens[len(tokens)-1].Kind == EOF {
+			break
+		}
+	}
+	tokens := lexer.Tokens()
+	if lexer.Err() == nil || len(tokens) != 3 || tokens[0].Text != "f" || tokens[0].Start != 3 {
+		t.Fatalf("tokens = %+v err = %v", tokens, lexer.Err())
+	}
+}
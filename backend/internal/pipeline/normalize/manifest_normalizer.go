@@ -579,10 +579,10 @@ func shouldIgnoreManifestJSON(pagePath string, content map[string]interface{}) b
 
 func isIgnoredManifestPath(pagePath string) bool {
 	switch pagePath {
-	case "app", "project.config", "project.private.config", "sitemap", "ext", "ext-app":
+	case "app", "project.config", "project.private.config", "sitemap", "ext", "ext-app", "plugin":
 		return true
 	default:
-		return false
+		return strings.HasPrefix(pagePath, "__extended__/")
 	}
 }
 
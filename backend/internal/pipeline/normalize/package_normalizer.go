@@ -17,14 +17,27 @@ func NormalizePackage(extractedDir string, profile *pkg.PackageProfile) (*pkg.No
 		return nil, err
 	}
 
-	manifest, diagnostics, err := NormalizeManifest(raw)
-	if err != nil {
-		return nil, err
+	var plugin *pkg.PluginManifestIR
+	var diagnostics []pkg.Diagnostic
+	if profile.IsPluginPackage {
+		plugin, diagnostics = NormalizePluginManifest(raw)
+	}
+	var manifest *pkg.ManifestIR
+	if plugin != nil {
+		manifest = pluginManifest(plugin)
+	} else {
+		var manifestDiagnostics []pkg.Diagnostic
+		manifest, manifestDiagnostics, err = NormalizeManifest(raw)
+		if err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, manifestDiagnostics...)
 	}
 
 	normalized := &pkg.NormalizedPackage{
 		Profile:     *profile,
 		Manifest:    *manifest,
+		Plugin:      plugin,
 		Diagnostics: diagnostics,
 	}
 
@@ -65,6 +78,9 @@ func NormalizePackage(extractedDir string, profile *pkg.PackageProfile) (*pkg.No
 	}
 
 	normalized.Components = inferComponents(raw, *manifest)
+	if plugin != nil {
+		normalized.Components = pluginComponents(raw, plugin, normalized.Components)
+	}
 
 	return normalized, nil
 }
@@ -193,7 +209,7 @@ func buildPageIR(raw *RawArtifactSet, page string) pkg.PageIR {
 func inferComponents(raw *RawArtifactSet, manifest pkg.ManifestIR) []pkg.ComponentIR {
 	componentPaths := make([]string, 0, len(manifest.UsingComponents))
 	for _, componentPath := range manifest.UsingComponents {
-		if resolved := resolveComponentPath("", componentPath); resolved != "" {
+		if resolved := ResolveComponentPath("", componentPath); resolved != "" {
 			componentPaths = append(componentPaths, resolved)
 		}
 	}
@@ -205,7 +221,7 @@ func inferComponents(raw *RawArtifactSet, manifest pkg.ManifestIR) []pkg.Compone
 		if usingComponents, ok := config["usingComponents"].(map[string]interface{}); ok {
 			for _, value := range usingComponents {
 				if componentPath, ok := value.(string); ok {
-					if resolved := resolveComponentPath(pagePath, componentPath); resolved != "" {
+					if resolved := ResolveComponentPath(pagePath, componentPath); resolved != "" {
 						componentPaths = append(componentPaths, resolved)
 					}
 				}
@@ -230,7 +246,10 @@ func inferComponents(raw *RawArtifactSet, manifest pkg.ManifestIR) []pkg.Compone
 	return components
 }
 
-func resolveComponentPath(pagePath, reference string) string {
+// ResolveComponentPath resolves a usingComponents reference declared by the
+// page or component at pagePath ("" for app.json) to a package path without
+// extension. Plugin references and paths escaping the package resolve to "".
+func ResolveComponentPath(pagePath, reference string) string {
 	if reference == "" || strings.Contains(reference, "://") {
 		return ""
 	}
@@ -0,0 +1,104 @@
+package normalize
+
+import (
+	pathpkg "path"
+	"slices"
+	"strings"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/pipeline/classifier"
+)
+
+// NormalizePluginManifest reads plugin.json. A copy under __plugin__/<appid>/
+// also locates the plugin code; the root copy comes next and the
+// __extended__/<appid>/ metadata copy is used only when it is the only one.
+func NormalizePluginManifest(raw *RawArtifactSet) (*pkg.PluginManifestIR, []pkg.Diagnostic) {
+	var chosen string
+	var appID string
+	rank := 0
+	for _, file := range raw.Files {
+		id, ok := classifier.PluginManifestAppID(file)
+		if !ok {
+			continue
+		}
+		if appID == "" {
+			appID = id
+		}
+		candidateRank := 1
+		switch {
+		case strings.HasPrefix(file, "__plugin__/"):
+			candidateRank = 3
+		case file == "plugin.json":
+			candidateRank = 2
+		}
+		if _, parsed := raw.PageJSON[file]; parsed && candidateRank > rank {
+			chosen, rank = file, candidateRank
+		}
+	}
+	if chosen == "" {
+		return nil, []pkg.Diagnostic{pkg.Warn("manifest.plugin.missing", "插件包缺少可解析的 plugin.json", "normalizing", "plugin.json")}
+	}
+
+	config := raw.PageJSON[chosen]
+	plugin := &pkg.PluginManifestIR{
+		AppID:            appID,
+		Pages:            map[string]string{},
+		PublicComponents: map[string]string{},
+		Original:         cloneMapDeep(config),
+		Source:           chosen,
+	}
+	if rank == 3 {
+		plugin.Root = pathpkg.Dir(chosen)
+	}
+	if main, ok := config["main"].(string); ok {
+		plugin.Main = main
+	}
+	copyPluginPaths(config["pages"], plugin.Pages)
+	copyPluginPaths(config["publicComponents"], plugin.PublicComponents)
+
+	diagnostic := pkg.Info("manifest.plugin.parsed", "已从 plugin.json 提取插件页面与公开组件", "normalizing", chosen)
+	diagnostic.Metadata = map[string]interface{}{"pages": len(plugin.Pages), "publicComponents": len(plugin.PublicComponents)}
+	return plugin, []pkg.Diagnostic{diagnostic}
+}
+
+// pluginManifest stands in for app.json: the exported plugin pages are the
+// pages of the package.
+func pluginManifest(plugin *pkg.PluginManifestIR) *pkg.ManifestIR {
+	manifest := pkg.NewManifestIR()
+	for _, reference := range plugin.Pages {
+		if route := plugin.ResolvePath(reference); route != "" {
+			manifest.Pages = append(manifest.Pages, route)
+		}
+	}
+	slices.Sort(manifest.Pages)
+	manifest.Pages = slices.Compact(manifest.Pages)
+	manifest.Sources["pages"] = plugin.Source
+	manifest.Confidence["pages"] = "authoritative"
+	return &manifest
+}
+
+func pluginComponents(raw *RawArtifactSet, plugin *pkg.PluginManifestIR, components []pkg.ComponentIR) []pkg.ComponentIR {
+	for _, reference := range plugin.PublicComponents {
+		clean := plugin.ResolvePath(reference)
+		if clean == "" || slices.ContainsFunc(components, func(component pkg.ComponentIR) bool { return component.Path == clean }) {
+			continue
+		}
+		if slices.Contains(raw.Files, clean+".wxml") || slices.Contains(raw.Files, clean+".js") {
+			components = append(components, pkg.ComponentIR{Path: clean})
+		}
+	}
+	slices.SortFunc(components, func(a, b pkg.ComponentIR) int { return strings.Compare(a.Path, b.Path) })
+	return components
+}
+
+func copyPluginPaths(input interface{}, dst map[string]string) {
+	values, ok := input.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for name, value := range values {
+		if text, ok := value.(string); ok && text != "" {
+			dst[name] = text
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package normalize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+func TestNormalizePackageReadsPluginManifest(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"__extended__/wxplugin/plugin.json":               `{"main":"index.js"}`,
+		"__plugin__/wxplugin/plugin.json":                 `{"main":"index.js","pages":{"hello":"pages/hello/index"},"publicComponents":{"list":"/components/list/list"},"themeLocation":"theme.json"}`,
+		"__plugin__/wxplugin/index.js":                    `module.exports = {}`,
+		"__plugin__/wxplugin/pages/hello/index.js":        `Page({})`,
+		"__plugin__/wxplugin/components/list/list.js":     `Component({})`,
+		"__plugin__/wxplugin/components/list/list.wxml":   `<view/>`,
+		"__plugin__/wxplugin/components/ghost/ghost.json": `{"component":true}`,
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	normalized, err := NormalizePackage(root, &pkg.PackageProfile{IsPluginPackage: true, PluginAppID: "wxplugin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin := normalized.Plugin
+	if plugin == nil || plugin.Root != "__plugin__/wxplugin" || plugin.Source != "__plugin__/wxplugin/plugin.json" || plugin.AppID != "wxplugin" {
+		t.Fatalf("unexpected plugin manifest: %#v", plugin)
+	}
+	if plugin.Original["themeLocation"] != "theme.json" {
+		t.Fatalf("unknown plugin.json fields must be kept: %#v", plugin.Original)
+	}
+	if len(normalized.Manifest.Pages) != 1 || normalized.Manifest.Pages[0] != "__plugin__/wxplugin/pages/hello/index" {
+		t.Fatalf("plugin pages were not resolved against the plugin root: %#v", normalized.Manifest.Pages)
+	}
+	if len(normalized.Components) != 1 || normalized.Components[0].Path != "__plugin__/wxplugin/components/list/list" {
+		t.Fatalf("public components were not mapped to component files: %#v", normalized.Components)
+	}
+	for _, diagnostic := range normalized.Diagnostics {
+		if diagnostic.Code == "manifest.pages.empty" || diagnostic.Code == "manifest.pages.inferred" {
+			t.Fatalf("plugin must not be normalized as an app: %#v", normalized.Diagnostics)
+		}
+	}
+}
@@ -201,6 +201,7 @@ func MergeFallbackArtifactsWithPolicy(targetDir, fallbackDir string) (*FallbackM
 			return err
 		}
 		result.Added++
+		result.AddedFiles = append(result.AddedFiles, filepath.ToSlash(rel))
 		return nil
 	})
 	return result, err
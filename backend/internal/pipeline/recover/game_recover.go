@@ -0,0 +1,248 @@
+package recover
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	"github.com/keepbuild/seewxapkg/internal/report"
+)
+
+// gameConfigFields are the game.json fields a mini-game build copies into
+// app-config.json.
+var gameConfigFields = []string{
+	"deviceOrientation",
+	"showStatusBar",
+	"networkTimeout",
+	"subpackages",
+	"subPackages",
+	"parallelPreloadSubpackages",
+	"workers",
+	"openDataContext",
+	"plugins",
+	"permission",
+	"resizable",
+	"requiredBackgroundModes",
+	"navigateToMiniProgramAppIdList",
+	"embeddedAppIdList",
+}
+
+// gameEngineMarkers identify the engine a mini-game was exported from by
+// the runtime files the engine ships. A marker matches any path containing
+// it from a path segment boundary, case-insensitively.
+var gameEngineMarkers = []struct {
+	engine  string
+	markers []string
+}{
+	{"cocos", []string{"cocos2d-js.js", "cocos2d-js-min.js", "cocos/", "cocos-js/", "src/settings.js", "src/settings.json", "ccrequire.js", "main.js.map.cc"}},
+	{"laya", []string{"laya.core.js", "laya.wxmini.js", "libs/laya.", "libs/min/laya."}},
+	{"egret", []string{"egret.min.js", "egret.wxgame.js", "egret.game.min.js", "egret-library/"}},
+	{"unity", []string{"unity-namespace.js", "webgl.wasm.code.unityweb.wasm.br", "webgl.data.unityweb.bin.txt", "unity-sdk/"}},
+	{"phaser", []string{"phaser.min.js", "phaser.js"}},
+}
+
+// gameAssetKinds groups engine resources by extension.
+var gameAssetKinds = map[string]string{
+	".png": "image", ".jpg": "image", ".jpeg": "image", ".webp": "image", ".gif": "image", ".bmp": "image",
+	".astc": "texture", ".pkm": "texture", ".ktx": "texture", ".dds": "texture", ".pvr": "texture",
+	".mp3": "audio", ".ogg": "audio", ".wav": "audio", ".m4a": "audio", ".aac": "audio",
+	".ttf": "font", ".otf": "font", ".fnt": "font", ".woff": "font",
+	".plist": "atlas", ".atlas": "atlas", ".skel": "animation", ".lani": "animation", ".ani": "animation",
+	".ls": "scene", ".lh": "prefab", ".scene": "scene", ".fire": "scene", ".prefab": "prefab", ".exml": "scene",
+	".lm": "mesh", ".lmat": "material", ".ltc": "texture", ".mtl": "material", ".obj": "mesh", ".gltf": "mesh", ".glb": "mesh", ".fbx": "mesh",
+	".cconb": "binary", ".bin": "binary", ".data": "binary", ".wasm": "binary", ".br": "binary", ".unityweb": "binary",
+	".json": "data", ".txt": "data", ".xml": "data", ".csv": "data",
+}
+
+// RecoverGame is the recovery path for mini-game packages. Games have no
+// pages: game.js, subpackage game.js files and workers.js are module
+// bundles, and the remaining files are resources of the game engine.
+func RecoverGame(np *pkg.NormalizedPackage, outDir, reportsDir string) (*GameRecoveryResult, error) {
+	result := &GameRecoveryResult{Success: true, Entry: "game.js", AssetKinds: map[string]int{}}
+
+	modules, ranges, diagnostics := splitRuntimeModules(np, "game.js", "workers.js")
+	result.Diagnostics = append(result.Diagnostics, diagnostics...)
+	if len(diagnostics) > 0 {
+		result.Partial = true
+	}
+	result.SourceRanges = unpackagedRanges(np, ranges)
+	// A game.js bundle defines game.js itself; the module, which is the
+	// original entry, replaces the bundle so the tree runs as a project.
+	var split []pkg.ScriptIR
+	for _, module := range modules {
+		replaced := false
+		for index := range np.Scripts {
+			script := &np.Scripts[index]
+			if script.Path != module.Path || path.Base(script.Path) != "game.js" {
+				continue
+			}
+			if err := writeRecoveredFile(filepath.Join(outDir, filepath.FromSlash(script.Path)), module.Content); err != nil {
+				return nil, err
+			}
+			script.Content, script.Source, script.EntryKind = module.Content, module.Source, module.EntryKind
+			replaced = true
+			for _, sourceRange := range ranges {
+				if sourceRange.Output == module.Path {
+					result.SourceRanges = append(result.SourceRanges, sourceRange)
+				}
+			}
+			result.Diagnostics = append(result.Diagnostics, pkg.Info("recover.game.bundle_replaced", "小游戏 bundle 已拆分，入口文件替换为其中的同名模块", "recovering_js", script.Path))
+		}
+		if !replaced {
+			split = append(split, module)
+		}
+	}
+	addSplitModules(np, split)
+
+	files, native, err := recoverScriptFiles(np, outDir)
+	if err != nil {
+		return nil, err
+	}
+	result.Files = files
+	result.Recovered = len(files)
+	result.Native = native
+
+	if !fileExists(filepath.Join(outDir, "game.js")) {
+		result.Partial = true
+		result.Diagnostics = append(result.Diagnostics, pkg.Warn("recover.game.entry_missing", "小游戏入口 game.js 缺失", "recovering_js", "game.js"))
+	}
+	if !fileExists(filepath.Join(outDir, "game.json")) {
+		result.Partial = true
+		result.Diagnostics = append(result.Diagnostics, pkg.Warn("recover.game.config_missing", "game.json 缺失且无法从 app-config.json 还原", "recovering_js", "game.json"))
+	}
+
+	assets, engine, err := listGameAssets(outDir)
+	if err != nil {
+		return nil, err
+	}
+	result.Engine = engine
+	result.Assets = assets
+	for _, asset := range assets {
+		result.AssetKinds[asset.Kind]++
+	}
+
+	result.ReportPath = filepath.Join(reportsDir, "game-recovery-report.json")
+	persisted := *result
+	persisted.ReportPath = filepath.ToSlash(filepath.Join("reports", filepath.Base(result.ReportPath)))
+	persisted.Diagnostics = report.SanitizeDiagnostics(result.Diagnostics)
+	if err := storage.WriteJSON(result.ReportPath, &persisted); err != nil {
+		return nil, err
+	}
+
+	if result.Recovered == 0 {
+		result.Success = false
+	}
+	return result, nil
+}
+
+// listGameAssets lists the resources of a game tree and names the engine
+// that exported it, or "unknown".
+func listGameAssets(root string) ([]GameAsset, string, error) {
+	var assets []GameAsset
+	var paths []string
+	err := filepath.WalkDir(root, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, current)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		paths = append(paths, rel)
+		ext := strings.ToLower(path.Ext(rel))
+		if ext == ".js" || rel == "game.json" || rel == "app-config.json" || rel == "project.config.json" {
+			return nil
+		}
+		kind, ok := gameAssetKinds[ext]
+		if !ok {
+			kind = "other"
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		assets = append(assets, GameAsset{Path: rel, Kind: kind, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Path < assets[j].Path })
+	return assets, detectGameEngine(paths), nil
+}
+
+func detectGameEngine(paths []string) string {
+	for _, candidate := range gameEngineMarkers {
+		for _, rel := range paths {
+			lower := "/" + strings.ToLower(rel)
+			for _, marker := range candidate.markers {
+				if strings.Contains(lower, "/"+marker) {
+					return candidate.engine
+				}
+			}
+		}
+	}
+	return "unknown"
+}
+
+// recoverGameManifest writes game.json. A packaged game.json is kept as is;
+// otherwise the game fields of app-config.json are restored.
+func recoverGameManifest(np *pkg.NormalizedPackage, sourceDir, reportsDir string) (*ManifestRecoveryResult, error) {
+	outputPath := filepath.Join(sourceDir, "game.json")
+	result := &ManifestRecoveryResult{
+		Success:     true,
+		OutputPath:  outputPath,
+		ReportPath:  filepath.Join(reportsDir, "manifest-recovery-report.json"),
+		Sources:     map[string]string{},
+		Diagnostics: append([]pkg.Diagnostic(nil), np.Diagnostics...),
+	}
+
+	switch config, err := readJSONObject(filepath.Join(sourceDir, "app-config.json")); {
+	case fileExists(outputPath):
+		result.Sources["game.json"] = "game.json"
+	case err == nil:
+		gameJSON := map[string]interface{}{}
+		fields := config
+		if global, ok := config["global"].(map[string]interface{}); ok {
+			fields = global
+		}
+		for _, name := range gameConfigFields {
+			if value, ok := config[name]; ok {
+				gameJSON[name] = cloneJSONValue(value)
+			} else if value, ok := fields[name]; ok {
+				gameJSON[name] = cloneJSONValue(value)
+			}
+		}
+		if err := storage.WriteJSON(outputPath, gameJSON); err != nil {
+			return nil, err
+		}
+		result.Sources["game.json"] = "app-config.json"
+	default:
+		result.Success = false
+		result.Diagnostics = append(result.Diagnostics, pkg.Warn("recover.manifest.game_config_missing", "小游戏包缺少 game.json 与 app-config.json，无法还原配置", "recovering_manifest", "game.json"))
+	}
+
+	if err := writeManifestRecoveryReport(result, np, reportsDir); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func readJSONObject(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
@@ -0,0 +1,94 @@
+package recover
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+func TestRecoverGameSplitsBundleAndRestoresGameJSON(t *testing.T) {
+	outDir := t.TempDir()
+	bundle := strings.Join([]string{
+		`define("game.js", function(require, module, exports){ require("js/main.js"); });`,
+		`define("js/main.js", function(require, module, exports){ module.exports = cc.game; });`,
+		`require("game.js");`,
+	}, "\n")
+	writeRecoveryReportFixture(t, outDir, "game.js", bundle)
+	writeRecoveryReportFixture(t, outDir, "app-config.json", `{"deviceOrientation":"landscape","subpackages":[{"name":"stage","root":"stage/"}],"workers":"workers","global":{"networkTimeout":{"request":5000}},"pages":[]}`)
+	writeRecoveryReportFixture(t, outDir, "cocos/cocos2d-js-min.js", "var cc = {};\n")
+	writeRecoveryReportFixture(t, outDir, "res/raw-assets/bg.png", "png")
+	writeRecoveryReportFixture(t, outDir, "res/import/scene.json", "{}")
+	writeRecoveryReportFixture(t, outDir, "res/audio/click.mp3", "mp3")
+	normalized := &pkg.NormalizedPackage{
+		Profile: pkg.PackageProfile{IsGamePackage: true},
+		Scripts: []pkg.ScriptIR{
+			{Path: "game.js", Content: bundle, Source: "runtime"},
+			{Path: "cocos/cocos2d-js-min.js", Content: "var cc = {};\n", Source: "native"},
+		},
+	}
+
+	manifest, err := RecoverManifest(normalized, outDir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !manifest.Success || manifest.Sources["game.json"] != "app-config.json" {
+		t.Fatalf("unexpected manifest result: %#v", manifest)
+	}
+	var gameJSON map[string]interface{}
+	if err := json.Unmarshal([]byte(readRecoveredStylesheet(t, outDir, "game.json")), &gameJSON); err != nil {
+		t.Fatal(err)
+	}
+	if gameJSON["deviceOrientation"] != "landscape" || gameJSON["workers"] != "workers" || gameJSON["subpackages"] == nil || gameJSON["networkTimeout"] == nil {
+		t.Fatalf("game fields were not restored: %#v", gameJSON)
+	}
+	if _, ok := gameJSON["pages"]; ok {
+		t.Fatalf("app fields leaked into game.json: %#v", gameJSON)
+	}
+
+	result, err := RecoverGame(normalized, outDir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.Partial {
+		t.Fatalf("unexpected game result: %#v", result)
+	}
+	if entry := readRecoveredStylesheet(t, outDir, "game.js"); entry != "require(\"js/main.js\");\n" {
+		t.Fatalf("game.js bundle was not replaced by its module: %q", entry)
+	}
+	if main := readRecoveredStylesheet(t, outDir, "js/main.js"); main != "module.exports = cc.game;\n" {
+		t.Fatalf("unexpected js/main.js: %q", main)
+	}
+	if result.Engine != "cocos" {
+		t.Fatalf("engine = %q, want cocos", result.Engine)
+	}
+	if result.AssetKinds["image"] != 1 || result.AssetKinds["audio"] != 1 || result.AssetKinds["data"] != 1 || len(result.Assets) != 3 {
+		t.Fatalf("unexpected assets: %#v %#v", result.Assets, result.AssetKinds)
+	}
+	codes := map[string]bool{}
+	for _, diagnostic := range result.Diagnostics {
+		codes[diagnostic.Code] = true
+	}
+	if !codes["recover.game.bundle_replaced"] || codes["recover.game.entry_missing"] || codes["recover.game.config_missing"] {
+		t.Fatalf("unexpected diagnostics: %#v", result.Diagnostics)
+	}
+}
+
+func TestRecoverGameManifestKeepsPackagedGameJSON(t *testing.T) {
+	outDir := t.TempDir()
+	writeRecoveryReportFixture(t, outDir, "game.json", `{"deviceOrientation":"portrait"}`)
+	writeRecoveryReportFixture(t, outDir, "app-config.json", `{"deviceOrientation":"landscape"}`)
+	normalized := &pkg.NormalizedPackage{Profile: pkg.PackageProfile{IsGamePackage: true}}
+
+	result, err := RecoverManifest(normalized, outDir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sources["game.json"] != "game.json" {
+		t.Fatalf("unexpected sources: %#v", result.Sources)
+	}
+	if kept := readRecoveredStylesheet(t, outDir, "game.json"); kept != `{"deviceOrientation":"portrait"}` {
+		t.Fatalf("packaged game.json was overwritten: %q", kept)
+	}
+}
@@ -1,6 +1,10 @@
 package recover
 
-import "os"
+import (
+	"os"
+	"path"
+	"strings"
+)
 
 func fileExists(path string) bool {
 	info, err := os.Stat(path)
@@ -11,3 +15,32 @@ func dirExists(path string) bool {
 	info, err := os.Stat(path)
 	return err == nil && info.IsDir()
 }
+
+// cleanPackagePath normalizes a path taken from compiled runtime code, such
+// as "./pages/index/index.wxss", to a slash-separated package path. Paths
+// that climb out of the package are rejected.
+func cleanPackagePath(entry string) (string, bool) {
+	entry = strings.TrimLeft(strings.TrimPrefix(strings.ReplaceAll(entry, "\\", "/"), "./"), "/")
+	for _, part := range strings.Split(entry, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+	cleaned := path.Clean(entry)
+	return cleaned, cleaned != "."
+}
+
+// relativePackagePath is the reference from the file at from to the file at
+// to, both package paths, as written in @import or src attributes.
+func relativePackagePath(from, to string) string {
+	fromParts := strings.Split(path.Dir(from), "/")
+	toParts := strings.Split(to, "/")
+	if path.Dir(from) == "." {
+		fromParts = nil
+	}
+	common := 0
+	for common < len(fromParts) && common < len(toParts)-1 && fromParts[common] == toParts[common] {
+		common++
+	}
+	return strings.Repeat("../", len(fromParts)-common) + strings.Join(toParts[common:], "/")
+}
@@ -0,0 +1,156 @@
+package recover
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/keepbuild/seewxapkg/internal/pipeline/jslex"
+)
+
+// errUnknownIdentifier marks a reference to a runtime value. Package code is
+// never executed, so only literals and already-recovered tables resolve.
+var errUnknownIdentifier = errors.New("unknown identifier")
+
+// parseJSLiteral folds the expression at offset pos of src: the JSON-like
+// subset the WXSS compiler emits, built from literals, `a || b` and member
+// access on values bound in env. Arrays decode to []interface{}, objects to
+// map[string]interface{}, numbers to float64, and null and undefined to nil.
+func parseJSLiteral(src string, pos int, env map[string]interface{}) (interface{}, error) {
+	node, err := newJSPrefixParser(src, pos).parseAssignment()
+	if err != nil {
+		return nil, err
+	}
+	return evalJSLiteral(node, env)
+}
+
+// evalJSLiteral is evalStatic over env, with WXSS recovery's value types.
+func evalJSLiteral(node *jsNode, env map[string]interface{}) (interface{}, error) {
+	if node != nil && node.Type == "LogicalExpression" && node.Name == "||" {
+		// `table = table || {}` is how 4.x bundles initialize shared tables; an
+		// absent left-hand side is the one unknown value treated as undefined.
+		left, err := evalJSLiteral(node.Left, env)
+		if err != nil && !errors.Is(err, errUnknownIdentifier) {
+			return nil, err
+		}
+		if err == nil && jsTruthy(left) {
+			return left, nil
+		}
+		return evalJSLiteral(node.Right, env)
+	}
+	value, err := evalStatic(node, &jsScope{vars: env})
+	if err != nil {
+		return nil, err
+	}
+	return literalValue(value), nil
+}
+
+// literalValue converts objects to maps and null to nil. Arrays convert in
+// place, so a table bound in env keeps its identity.
+func literalValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case jsNullType:
+		return nil
+	case *jsObject:
+		values := make(map[string]interface{}, len(typed.keys))
+		for _, key := range typed.keys {
+			values[key] = literalValue(typed.values[key])
+		}
+		return values
+	case []interface{}:
+		for index, item := range typed {
+			typed[index] = literalValue(item)
+		}
+	}
+	return value
+}
+
+// jsKey is String(value) for the property keys the compiler uses.
+func jsKey(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case float64:
+		return jsNumber(typed)
+	case bool:
+		return strconv.FormatBool(typed)
+	case nil:
+		return "undefined"
+	case jsNullType:
+		return "null"
+	}
+	return fmt.Sprint(value)
+}
+
+func jsNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func jsTruthy(value interface{}) bool {
+	switch typed := value.(type) {
+	case nil, jsNullType:
+		return false
+	case bool:
+		return typed
+	case string:
+		return typed != ""
+	case float64:
+		return typed != 0 && !math.IsNaN(typed)
+	}
+	return true
+}
+
+// scanJSIdentifiers calls visit for every identifier outside strings,
+// comments and regular expression literals, with the offset just past it.
+func scanJSIdentifiers(src string, visit func(name string, end int)) {
+	previous := byte(0)
+	for pos := 0; pos < len(src); {
+		ch := src[pos]
+		switch {
+		case ch == '"' || ch == '\'' || ch == '`':
+			pos = skipJSQuoted(src, pos)
+			previous = ch
+		case ch == '/' && pos+1 < len(src) && (src[pos+1] == '/' || src[pos+1] == '*'):
+			pos = jslex.SkipSpace(src, pos)
+		case ch == '/' && (previous == 0 || strings.IndexByte("(,=:[!&|?{};+-*%<>~^", previous) >= 0):
+			pos = jslex.SkipRegexp(src, pos)
+			previous = '/'
+		case jslex.IsIdentStart(ch):
+			start := pos
+			for pos < len(src) && (jslex.IsIdentStart(src[pos]) || jslex.IsDigit(src[pos])) {
+				pos++
+			}
+			visit(src[start:pos], pos)
+			previous = 'a'
+		case jslex.IsDigit(ch):
+			for pos < len(src) && (jslex.IsIdentStart(src[pos]) || jslex.IsDigit(src[pos]) || src[pos] == '.') {
+				pos++
+			}
+			previous = '0'
+		default:
+			if ch != ' ' && ch != '\t' && ch != '\n' && ch != '\r' {
+				previous = ch
+			}
+			pos++
+		}
+	}
+}
+
+func skipJSQuoted(src string, pos int) int {
+	quote := src[pos]
+	for pos++; pos < len(src); pos++ {
+		switch src[pos] {
+		case '\\':
+			pos++
+		case quote:
+			return pos + 1
+		case '\n':
+			if quote != '`' {
+				return pos
+			}
+		}
+	}
+	return pos
+}
@@ -0,0 +1,141 @@
+package recover
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/pipeline/jslex"
+)
+
+// definedModule is one define("path.js", function(require, module, exports){...})
+// wrapper found in a runtime bundle. Body is the factory body, which is the
+// original file after compilation, and Start and End its byte range in the
+// bundle.
+type definedModule struct {
+	Name  string
+	Body  string
+	Start int
+	End   int
+}
+
+// splitRuntimeModules extracts the modules wrapped in the named bundles,
+// such as app-service.js and workers.js, including the bundles of
+// subpackages. Module names are package-relative in every bundle; the first
+// bundle to define a path wins. Every module comes with the range of the
+// bundle it was copied from.
+func splitRuntimeModules(np *pkg.NormalizedPackage, bundles ...string) ([]pkg.ScriptIR, []SourceRange, []pkg.Diagnostic) {
+	var scripts []pkg.ScriptIR
+	var ranges []SourceRange
+	var diagnostics []pkg.Diagnostic
+	seen := map[string]bool{}
+	for _, script := range np.Scripts {
+		if !slices.Contains(bundles, path.Base(script.Path)) {
+			continue
+		}
+		modules, err := extractDefineModules(script.Content)
+		if err != nil {
+			diagnostics = append(diagnostics, pkg.Warn("recover.js.bundle_unreadable", "运行时 bundle 无法静态拆分，已保留原始文件："+err.Error(), "recovering_js", script.Path))
+			continue
+		}
+		for _, module := range modules {
+			target, ok := cleanPackagePath(module.Name)
+			if !ok {
+				diagnostics = append(diagnostics, pkg.Warn("recover.js.output_unsafe", "JS 模块输出路径越界，已跳过", "recovering_js", module.Name))
+				continue
+			}
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+			kind := "page"
+			switch target {
+			case "app.js":
+				kind = "app"
+			case "game.js":
+				kind = "game"
+			}
+			scripts = append(scripts, pkg.ScriptIR{Path: target, Content: module.Body + "\n", Source: "native", EntryKind: kind})
+			ranges = append(ranges, newSourceRange(target, script.Path, script.Content, module.Start, module.End, true))
+		}
+	}
+	return scripts, ranges, diagnostics
+}
+
+// extractDefineModules is a static port of extractDefineModules in
+// wxappUnpacker/wuStatic.js. The bundle is only tokenized: every call to a
+// bare define with a string name and a function factory yields a module,
+// wherever it is nested.
+func extractDefineModules(code string) ([]definedModule, error) {
+	tokens, err := jslex.Tokenize(code)
+	if err != nil {
+		return nil, err
+	}
+	var modules []definedModule
+	for index := 0; index+3 < len(tokens); index++ {
+		token := tokens[index]
+		if token.Kind != jslex.Ident || token.Text != "define" || !jslex.IsPunct(tokens[index+1], "(") || tokens[index+2].Kind != jslex.String {
+			continue
+		}
+		if index > 0 && (jslex.IsPunct(tokens[index-1], ".") || tokens[index-1].Kind == jslex.Ident && tokens[index-1].Text == "function") {
+			continue
+		}
+		name, _ := tokens[index+2].Value.(string)
+		open, close := defineFactoryBody(tokens, index+1)
+		if open < 0 {
+			continue
+		}
+		raw := code[tokens[open].End:tokens[close].Start]
+		body := strings.TrimSpace(raw)
+		start := tokens[open].End + len(raw) - len(strings.TrimLeftFunc(raw, unicode.IsSpace))
+		modules = append(modules, definedModule{Name: name, Body: body, Start: start, End: start + len(body)})
+	}
+	return modules, nil
+}
+
+// defineFactoryBody finds the braces of the last function argument of the
+// call whose opening parenthesis is at tokens[open]. Arrow factories count
+// only with a block body.
+func defineFactoryBody(tokens []jslex.Token, open int) (int, int) {
+	bodyOpen, bodyClose := -1, -1
+	depth := 0
+	for index := open; index < len(tokens); index++ {
+		token := tokens[index]
+		if token.Kind == jslex.EOF {
+			break
+		}
+		if token.Kind != jslex.Punct && !(token.Kind == jslex.Ident && token.Text == "function") {
+			continue
+		}
+		switch token.Text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			if depth--; depth == 0 {
+				return bodyOpen, bodyClose
+			}
+		case "function", "=>":
+			if depth != 1 {
+				continue
+			}
+			brace := index + 1
+			if token.Text == "function" {
+				for brace < len(tokens) && !jslex.IsPunct(tokens[brace], "{") && tokens[brace].Kind != jslex.EOF {
+					brace++
+				}
+			}
+			if brace >= len(tokens) || !jslex.IsPunct(tokens[brace], "{") {
+				continue
+			}
+			end := matchingBrace(tokens, brace)
+			if end < 0 {
+				return -1, -1
+			}
+			bodyOpen, bodyClose = brace, end
+			index = end
+		}
+	}
+	return -1, -1
+}
@@ -0,0 +1,84 @@
+package recover
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+func TestRecoverJSSplitsRuntimeBundleModules(t *testing.T) {
+	outDir := t.TempDir()
+	writeRecoveryReportFixture(t, outDir, "utils/kept.js", "module.exports = 'packaged';\n")
+	bundle := strings.Join([]string{
+		`var __wxAppData = {};`,
+		`define("app.js", function(require, module, exports, window, document){`,
+		`  "use strict";`,
+		`  App({ onLaunch: function () { var x = "}"; } });`,
+		`});`,
+		`define("pages/home/index.js", function(require, module, exports){ Page({ data: { re: /[})]/ } }); });`,
+		`define("utils/kept.js", function(require, module, exports){ module.exports = 'bundled'; });`,
+		`(function(){ define("utils/arrow.js", (require, module) => { module.exports = 1; }); })();`,
+		`loader.define("ignored.js", function(){ ignored(); });`,
+		`define("../escape.js", function(){ escape(); });`,
+		`__wxRoute = "pages/home/index";require("pages/home/index.js");`,
+	}, "\n")
+	normalized := &pkg.NormalizedPackage{
+		Pages: []pkg.PageIR{{Path: "pages/home/index"}, {Path: "pages/other/index"}},
+		Scripts: []pkg.ScriptIR{
+			{Path: "app-service.js", Content: bundle, Source: "runtime"},
+			{Path: "utils/kept.js", Content: "module.exports = 'packaged';\n", Source: "native"},
+		},
+	}
+
+	result, err := RecoverJS(normalized, outDir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"app.js":              "\"use strict\";\n  App({ onLaunch: function () { var x = \"}\"; } });\n",
+		"pages/home/index.js": "Page({ data: { re: /[})]/ } });\n",
+		"utils/arrow.js":      "module.exports = 1;\n",
+		"utils/kept.js":       "module.exports = 'packaged';\n",
+	}
+	for path, content := range want {
+		data, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("%s = %q, want %q", path, data, content)
+		}
+	}
+	for _, path := range []string{"ignored.js", "escape.js"} {
+		if _, err := os.Stat(filepath.Join(outDir, path)); err == nil {
+			t.Fatalf("%s should not be written", path)
+		}
+	}
+
+	if normalized.Pages[0].ScriptPath != "pages/home/index.js" || normalized.Pages[1].ScriptPath != "" {
+		t.Fatalf("page script paths were not filled from the bundle: %#v", normalized.Pages)
+	}
+	sources := map[string]string{}
+	for _, file := range result.Files {
+		sources[file.Path] = file.Source
+	}
+	for _, path := range []string{"app.js", "pages/home/index.js", "utils/arrow.js"} {
+		if sources[path] != "native" {
+			t.Fatalf("%s missing from native recovery files: %#v", path, result.Files)
+		}
+	}
+	codes := map[string]string{}
+	for _, diagnostic := range result.Diagnostics {
+		codes[diagnostic.Code] = diagnostic.File
+	}
+	if codes["recover.js.output_unsafe"] != "../escape.js" || codes["recover.js.page.missing_runtime"] != "pages/other/index.js" {
+		t.Fatalf("unexpected diagnostics: %#v", result.Diagnostics)
+	}
+	if _, ok := codes["recover.js.app.missing"]; ok {
+		t.Fatalf("app.js split from the bundle was reported missing: %#v", result.Diagnostics)
+	}
+}
@@ -0,0 +1,734 @@
+package recover
+
+import (
+	"fmt"
+
+	"github.com/keepbuild/seewxapkg/internal/pipeline/jslex"
+)
+
+// jsParser is a small recursive-descent parser for the ES5 code the WXML
+// compiler emits. It builds syntax trees only: nothing is evaluated beyond
+// the literal folding in evalStatic. Function bodies are parsed lazily, so a
+// bundle whose WXS modules use syntax outside this subset still yields its
+// registries and renderers.
+
+// jsNode is one syntax tree node. Which fields are set depends on Type, which
+// follows the ESTree names used by the Node implementation.
+type jsNode struct {
+	Type  string
+	Name  string      // Identifier name, operator, or declared name
+	Value interface{} // Literal value
+	Raw   string      // source text of the node
+
+	Object, Property *jsNode
+	Computed         bool
+	Callee           *jsNode
+	Arguments        []*jsNode
+	Elements         []*jsNode
+	Properties       []*jsNode // Property nodes: Key (Name) and Init
+	Left, Right      *jsNode
+	Argument         *jsNode
+	Test             *jsNode
+	Consequent       *jsNode
+	Alternate        *jsNode
+	Declarations     []*jsNode
+	Init             *jsNode
+	Params           []string
+	Body             []*jsNode
+	Handler          *jsNode
+	Finalizer        *jsNode
+
+	// Functions keep their body tokens until functionBody parses them.
+	bodyTokens []jslex.Token
+	src        string
+}
+
+type jsParser struct {
+	src    string
+	tokens []jslex.Token
+	pos    int
+	// lexer, when set, supplies tokens as the parser reaches them.
+	lexer *jslex.Lexer
+}
+
+// newJSPrefixParser parses from offset pos of src and stops wherever the
+// caller's production ends, leaving the rest of src untokenized.
+func newJSPrefixParser(src string, pos int) *jsParser {
+	return &jsParser{src: src, lexer: jslex.NewLexer(src, pos)}
+}
+
+func parseJSProgram(src string) ([]*jsNode, error) {
+	tokens, err := jslex.Tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	parser := &jsParser{src: src, tokens: tokens}
+	return parser.parseStatements(false)
+}
+
+func parseJSExpression(src string) (*jsNode, error) {
+	tokens, err := jslex.Tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	parser := &jsParser{src: src, tokens: tokens}
+	node, err := parser.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if parser.peek().Kind != jslex.EOF && !parser.is(";") {
+		return nil, parser.errorf("unexpected %q", parser.peek().Text)
+	}
+	return node, nil
+}
+
+// functionBody parses the statements of a function node on first use.
+func (n *jsNode) functionBody() ([]*jsNode, error) {
+	if n.Body != nil || n.bodyTokens == nil {
+		return n.Body, nil
+	}
+	parser := &jsParser{src: n.src, tokens: n.bodyTokens}
+	body, err := parser.parseStatements(false)
+	if err != nil {
+		return nil, err
+	}
+	n.Body = body
+	return body, nil
+}
+
+func (p *jsParser) peek() jslex.Token {
+	for p.pos >= len(p.tokens) {
+		p.lexer.Scan()
+		p.tokens = p.lexer.Tokens()
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *jsParser) next() jslex.Token {
+	token := p.peek()
+	if token.Kind != jslex.EOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *jsParser) is(text string) bool {
+	token := p.peek()
+	return (token.Kind == jslex.Punct || token.Kind == jslex.Ident) && token.Text == text
+}
+
+func (p *jsParser) accept(text string) bool {
+	if p.is(text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *jsParser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %q, found %q", text, p.peek().Text)
+	}
+	return nil
+}
+
+func (p *jsParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", p.peek().Start, fmt.Sprintf(format, args...))
+}
+
+func (p *jsParser) raw(start int) string {
+	if p.pos == 0 {
+		return ""
+	}
+	return p.src[start:p.tokens[p.pos-1].End]
+}
+
+func (p *jsParser) consumeSemicolon() error {
+	if p.accept(";") {
+		return nil
+	}
+	token := p.peek()
+	if token.Kind == jslex.EOF || token.Newline || p.is("}") {
+		return nil
+	}
+	return p.errorf("expected ';', found %q", token.Text)
+}
+
+func (p *jsParser) parseStatements(inBlock bool) ([]*jsNode, error) {
+	var statements []*jsNode
+	for {
+		if p.peek().Kind == jslex.EOF {
+			if inBlock {
+				return nil, p.errorf("unterminated block")
+			}
+			return statements, nil
+		}
+		if inBlock && p.is("}") {
+			return statements, nil
+		}
+		statement, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+	}
+}
+
+func (p *jsParser) parseStatement() (*jsNode, error) {
+	start := p.peek().Start
+	token := p.peek()
+	if token.Kind == jslex.Ident {
+		switch token.Text {
+		case "var", "let", "const":
+			p.next()
+			node, err := p.parseDeclarations(start)
+			if err != nil {
+				return nil, err
+			}
+			return node, p.consumeSemicolon()
+		case "function":
+			return p.parseFunction(true)
+		case "if":
+			p.next()
+			test, err := p.parseParenthesized()
+			if err != nil {
+				return nil, err
+			}
+			consequent, err := p.parseStatement()
+			if err != nil {
+				return nil, err
+			}
+			node := &jsNode{Type: "IfStatement", Test: test, Consequent: consequent}
+			if p.accept("else") {
+				if node.Alternate, err = p.parseStatement(); err != nil {
+					return nil, err
+				}
+			}
+			node.Raw = p.raw(start)
+			return node, nil
+		case "return", "throw":
+			p.next()
+			node := &jsNode{Type: "ReturnStatement"}
+			if token.Text == "throw" {
+				node.Type = "ThrowStatement"
+			}
+			if next := p.peek(); !p.is(";") && !p.is("}") && next.Kind != jslex.EOF && !next.Newline {
+				argument, err := p.parseExpression()
+				if err != nil {
+					return nil, err
+				}
+				node.Argument = argument
+			}
+			node.Raw = p.raw(start)
+			return node, p.consumeSemicolon()
+		case "try":
+			p.next()
+			block, err := p.parseBlock()
+			if err != nil {
+				return nil, err
+			}
+			node := &jsNode{Type: "TryStatement", Body: block.Body}
+			if p.accept("catch") {
+				handler := &jsNode{Type: "CatchClause"}
+				if p.accept("(") {
+					handler.Name = p.next().Text
+					if err := p.expect(")"); err != nil {
+						return nil, err
+					}
+				}
+				body, err := p.parseBlock()
+				if err != nil {
+					return nil, err
+				}
+				handler.Body = body.Body
+				node.Handler = handler
+			}
+			if p.accept("finally") {
+				if node.Finalizer, err = p.parseBlock(); err != nil {
+					return nil, err
+				}
+			}
+			node.Raw = p.raw(start)
+			return node, nil
+		case "for", "while", "with", "switch":
+			// Loops never appear in renderer code; they are kept opaque so the
+			// surrounding statements still parse.
+			p.next()
+			if err := p.skipBalanced("(", ")"); err != nil {
+				return nil, err
+			}
+			if token.Text == "switch" {
+				if err := p.skipBalanced("{", "}"); err != nil {
+					return nil, err
+				}
+			} else if _, err := p.parseStatement(); err != nil {
+				return nil, err
+			}
+			return &jsNode{Type: "OpaqueStatement", Name: token.Text, Raw: p.raw(start)}, nil
+		case "do":
+			p.next()
+			if _, err := p.parseStatement(); err != nil {
+				return nil, err
+			}
+			if err := p.expect("while"); err != nil {
+				return nil, err
+			}
+			if err := p.skipBalanced("(", ")"); err != nil {
+				return nil, err
+			}
+			p.accept(";")
+			return &jsNode{Type: "OpaqueStatement", Name: "do", Raw: p.raw(start)}, nil
+		case "break", "continue":
+			p.next()
+			if next := p.peek(); next.Kind == jslex.Ident && !next.Newline {
+				p.next()
+			}
+			return &jsNode{Type: "OpaqueStatement", Name: token.Text, Raw: p.raw(start)}, p.consumeSemicolon()
+		}
+	}
+	if p.is("{") {
+		return p.parseBlock()
+	}
+	if p.accept(";") {
+		return &jsNode{Type: "EmptyStatement"}, nil
+	}
+	expression, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	node := &jsNode{Type: "ExpressionStatement", Argument: expression, Raw: p.raw(start)}
+	return node, p.consumeSemicolon()
+}
+
+func (p *jsParser) parseBlock() (*jsNode, error) {
+	start := p.peek().Start
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	body, err := p.parseStatements(true)
+	if err != nil {
+		return nil, err
+	}
+	p.next()
+	return &jsNode{Type: "BlockStatement", Body: body, Raw: p.raw(start)}, nil
+}
+
+func (p *jsParser) parseParenthesized() (*jsNode, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	node, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return node, p.expect(")")
+}
+
+func (p *jsParser) skipBalanced(open, close string) error {
+	if err := p.expect(open); err != nil {
+		return err
+	}
+	for depth := 1; depth > 0; {
+		token := p.next()
+		switch {
+		case token.Kind == jslex.EOF:
+			return p.errorf("unbalanced %q", open)
+		case token.Kind != jslex.Punct:
+		case token.Text == open:
+			depth++
+		case token.Text == close:
+			depth--
+		}
+	}
+	return nil
+}
+
+func (p *jsParser) parseDeclarations(start int) (*jsNode, error) {
+	node := &jsNode{Type: "VariableDeclaration"}
+	for {
+		name := p.next()
+		if name.Kind != jslex.Ident {
+			return nil, p.errorf("unsupported declaration target %q", name.Text)
+		}
+		declarator := &jsNode{Type: "VariableDeclarator", Name: name.Text}
+		if p.accept("=") {
+			init, err := p.parseAssignment()
+			if err != nil {
+				return nil, err
+			}
+			declarator.Init = init
+		}
+		node.Declarations = append(node.Declarations, declarator)
+		if !p.accept(",") {
+			break
+		}
+	}
+	node.Raw = p.raw(start)
+	return node, nil
+}
+
+func (p *jsParser) parseFunction(declaration bool) (*jsNode, error) {
+	start := p.next().Start
+	node := &jsNode{Type: "FunctionExpression", src: p.src}
+	if declaration {
+		node.Type = "FunctionDeclaration"
+	}
+	if p.peek().Kind == jslex.Ident {
+		node.Name = p.next().Text
+	}
+	return p.parseFunctionRest(node, start)
+}
+
+// parseFunctionRest reads the parameter list and body that follow a function
+// keyword, or a method name in an object literal.
+func (p *jsParser) parseFunctionRest(node *jsNode, start int) (*jsNode, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.accept(")") {
+		token := p.next()
+		if token.Kind == jslex.EOF {
+			return nil, p.errorf("unterminated parameter list")
+		}
+		if token.Kind == jslex.Ident {
+			node.Params = append(node.Params, token.Text)
+		}
+	}
+	if !p.is("{") {
+		return nil, p.errorf("expected function body")
+	}
+	bodyStart := p.pos + 1
+	if err := p.skipBalanced("{", "}"); err != nil {
+		return nil, err
+	}
+	// The body tokens get a synthetic EOF so the lazy parser stops there.
+	body := append([]jslex.Token{}, p.tokens[bodyStart:p.pos-1]...)
+	closing := p.tokens[p.pos-1]
+	node.bodyTokens = append(body, jslex.Token{Kind: jslex.EOF, Start: closing.Start, End: closing.Start, Newline: true})
+	node.Raw = p.raw(start)
+	return node, nil
+}
+
+func (p *jsParser) parseExpression() (*jsNode, error) {
+	start := p.peek().Start
+	first, err := p.parseAssignment()
+	if err != nil || !p.is(",") {
+		return first, err
+	}
+	node := &jsNode{Type: "SequenceExpression", Elements: []*jsNode{first}}
+	for p.accept(",") {
+		item, err := p.parseAssignment()
+		if err != nil {
+			return nil, err
+		}
+		node.Elements = append(node.Elements, item)
+	}
+	node.Raw = p.raw(start)
+	return node, nil
+}
+
+var jsAssignmentOperators = map[string]bool{
+	"=": true, "+=": true, "-=": true, "*=": true, "/=": true, "%=": true, "**=": true, "<<=": true, ">>=": true,
+	">>>=": true, "&=": true, "|=": true, "^=": true, "&&=": true, "||=": true, "??=": true,
+}
+
+func (p *jsParser) parseAssignment() (*jsNode, error) {
+	start := p.peek().Start
+	left, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.Kind == jslex.Punct && jsAssignmentOperators[token.Text] {
+		p.next()
+		right, err := p.parseAssignment()
+		if err != nil {
+			return nil, err
+		}
+		return &jsNode{Type: "AssignmentExpression", Name: token.Text, Left: left, Right: right, Raw: p.raw(start)}, nil
+	}
+	return left, nil
+}
+
+func (p *jsParser) parseConditional() (*jsNode, error) {
+	start := p.peek().Start
+	test, err := p.parseBinary(0)
+	if err != nil || !p.accept("?") {
+		return test, err
+	}
+	consequent, err := p.parseAssignment()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	alternate, err := p.parseAssignment()
+	if err != nil {
+		return nil, err
+	}
+	return &jsNode{Type: "ConditionalExpression", Test: test, Consequent: consequent, Alternate: alternate, Raw: p.raw(start)}, nil
+}
+
+var jsBinaryPrecedence = map[string]int{
+	"??": 1, "||": 2, "&&": 3, "|": 4, "^": 5, "&": 6,
+	"==": 7, "!=": 7, "===": 7, "!==": 7,
+	"<": 8, ">": 8, "<=": 8, ">=": 8, "instanceof": 8, "in": 8,
+	"<<": 9, ">>": 9, ">>>": 9,
+	"+": 10, "-": 10, "*": 11, "/": 11, "%": 11, "**": 12,
+}
+
+func (p *jsParser) parseBinary(minPrecedence int) (*jsNode, error) {
+	start := p.peek().Start
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token := p.peek()
+		precedence, ok := jsBinaryPrecedence[token.Text]
+		if !ok || token.Kind != jslex.Punct && token.Kind != jslex.Ident || precedence <= minPrecedence {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseBinary(precedence)
+		if err != nil {
+			return nil, err
+		}
+		nodeType := "BinaryExpression"
+		if token.Text == "||" || token.Text == "&&" || token.Text == "??" {
+			nodeType = "LogicalExpression"
+		}
+		left = &jsNode{Type: nodeType, Name: token.Text, Left: left, Right: right, Raw: p.src[start:p.tokens[p.pos-1].End]}
+	}
+}
+
+func (p *jsParser) parseUnary() (*jsNode, error) {
+	start := p.peek().Start
+	token := p.peek()
+	switch token.Text {
+	case "!", "~", "+", "-", "typeof", "void", "delete", "++", "--":
+		p.next()
+		argument, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &jsNode{Type: "UnaryExpression", Name: token.Text, Argument: argument, Raw: p.raw(start)}, nil
+	}
+	node, err := p.parseLeftHandSide()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); (next.Text == "++" || next.Text == "--") && next.Kind == jslex.Punct && !next.Newline {
+		p.next()
+		node = &jsNode{Type: "UpdateExpression", Name: next.Text, Argument: node, Raw: p.raw(start)}
+	}
+	return node, nil
+}
+
+func (p *jsParser) parseLeftHandSide() (*jsNode, error) {
+	start := p.peek().Start
+	var node *jsNode
+	var err error
+	if p.accept("new") {
+		callee, err := p.parseLeftHandSide()
+		if err != nil {
+			return nil, err
+		}
+		node = &jsNode{Type: "NewExpression", Callee: callee, Raw: p.raw(start)}
+	} else if node, err = p.parsePrimary(); err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept(".") || p.accept("?."):
+			if p.is("(") || p.is("[") {
+				continue
+			}
+			name := p.next()
+			if name.Kind != jslex.Ident {
+				return nil, p.errorf("expected property name")
+			}
+			node = &jsNode{Type: "MemberExpression", Object: node, Property: &jsNode{Type: "Identifier", Name: name.Text, Raw: name.Text}, Raw: p.raw(start)}
+		case p.accept("["):
+			property, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = &jsNode{Type: "MemberExpression", Object: node, Property: property, Computed: true, Raw: p.raw(start)}
+		case p.is("("):
+			p.next()
+			call := &jsNode{Type: "CallExpression", Callee: node}
+			for !p.accept(")") {
+				argument, err := p.parseAssignment()
+				if err != nil {
+					return nil, err
+				}
+				call.Arguments = append(call.Arguments, argument)
+				if !p.is(")") {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+			}
+			call.Raw = p.raw(start)
+			node = call
+		case p.peek().Kind == jslex.Template:
+			p.next()
+			node = &jsNode{Type: "TaggedTemplateExpression", Callee: node, Raw: p.raw(start)}
+		default:
+			return node, nil
+		}
+	}
+}
+
+func (p *jsParser) parsePrimary() (*jsNode, error) {
+	start := p.peek().Start
+	token := p.peek()
+	switch token.Kind {
+	case jslex.Number, jslex.String:
+		p.next()
+		return &jsNode{Type: "Literal", Value: token.Value, Raw: token.Text}, nil
+	case jslex.Template, jslex.Regexp:
+		p.next()
+		return &jsNode{Type: "OpaqueLiteral", Raw: token.Text}, nil
+	case jslex.Ident:
+		switch token.Text {
+		case "function":
+			return p.parseFunction(false)
+		case "true", "false":
+			p.next()
+			return &jsNode{Type: "Literal", Value: token.Text == "true", Raw: token.Text}, nil
+		case "null":
+			p.next()
+			return &jsNode{Type: "Literal", Value: nil, Raw: token.Text}, nil
+		}
+		p.next()
+		return &jsNode{Type: "Identifier", Name: token.Text, Raw: token.Text}, nil
+	case jslex.EOF:
+		return nil, p.errorf("unexpected end of input")
+	}
+	switch token.Text {
+	case "(":
+		p.next()
+		node, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+	case "[":
+		p.next()
+		node := &jsNode{Type: "ArrayExpression"}
+		for !p.accept("]") {
+			if p.accept(",") {
+				node.Elements = append(node.Elements, nil)
+				continue
+			}
+			if p.accept("...") {
+				argument, err := p.parseAssignment()
+				if err != nil {
+					return nil, err
+				}
+				node.Elements = append(node.Elements, &jsNode{Type: "SpreadElement", Argument: argument})
+			} else {
+				element, err := p.parseAssignment()
+				if err != nil {
+					return nil, err
+				}
+				node.Elements = append(node.Elements, element)
+			}
+			if !p.is("]") {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+		node.Raw = p.raw(start)
+		return node, nil
+	case "{":
+		return p.parseObject(start)
+	}
+	return nil, p.errorf("unexpected %q", token.Text)
+}
+
+func (p *jsParser) parseObject(start int) (*jsNode, error) {
+	p.next()
+	node := &jsNode{Type: "ObjectExpression"}
+	for !p.accept("}") {
+		property := &jsNode{Type: "Property"}
+		if p.accept("...") {
+			argument, err := p.parseAssignment()
+			if err != nil {
+				return nil, err
+			}
+			property.Type, property.Argument = "SpreadElement", argument
+		} else {
+			key := p.next()
+			switch {
+			case key.Kind == jslex.String:
+				property.Name = key.Value.(string)
+			case key.Kind == jslex.Number:
+				property.Name = jsNumber(key.Value.(float64))
+			case key.Kind == jslex.Ident:
+				property.Name = key.Text
+			case key.Text == "[":
+				computed, err := p.parseAssignment()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect("]"); err != nil {
+					return nil, err
+				}
+				property.Computed, property.Property = true, computed
+			default:
+				return nil, p.errorf("unexpected object key %q", key.Text)
+			}
+			switch {
+			case p.accept(":"):
+				value, err := p.parseAssignment()
+				if err != nil {
+					return nil, err
+				}
+				property.Init = value
+			case p.is("("):
+				value, err := p.parseFunctionRest(&jsNode{Type: "FunctionExpression", src: p.src}, p.peek().Start)
+				if err != nil {
+					return nil, err
+				}
+				property.Init = value
+			default:
+				property.Init = &jsNode{Type: "Identifier", Name: property.Name, Raw: property.Name}
+			}
+		}
+		node.Properties = append(node.Properties, property)
+		if !p.is("}") {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	node.Raw = p.raw(start)
+	return node, nil
+}
+
+// matchingBrace returns the index of the token closing the first block
+// that opens at or after from, or -1.
+func matchingBrace(tokens []jslex.Token, from int) int {
+	depth := 0
+	for index := from; index < len(tokens); index++ {
+		if tokens[index].Kind != jslex.Punct {
+			continue
+		}
+		switch tokens[index].Text {
+		case "{":
+			depth++
+		case "}":
+			if depth--; depth == 0 {
+				return index
+			}
+		}
+	}
+	return -1
+}
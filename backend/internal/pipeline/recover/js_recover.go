@@ -14,24 +14,23 @@ func RecoverJS(np *pkg.NormalizedPackage, outDir, reportsDir string) (*JSRecover
 	result := &JSRecoveryResult{Success: true}
 	hasRuntime := hasRuntimeScript(np)
 
-	for _, script := range np.Scripts {
-		if !strings.EqualFold(filepath.Ext(script.Path), ".js") {
-			continue
-		}
-		if !fileExists(filepath.Join(outDir, filepath.FromSlash(script.Path))) {
-			if script.Content == "" {
-				continue
-			}
-			if err := writeRecoveredFile(filepath.Join(outDir, filepath.FromSlash(script.Path)), script.Content); err != nil {
-				return nil, err
-			}
-		}
-		result.Files = append(result.Files, RecoveredFile{Path: script.Path, Kind: "js", Source: pickSource(script.Source, "native")})
-		result.Recovered++
-		if script.Source == "native" {
-			result.Native++
-		}
+	// Modules split out of the runtime bundles join the package scripts, so
+	// later stages see them like packaged files. A packaged file wins.
+	modules, ranges, diagnostics := splitRuntimeModules(np, "app-service.js", "workers.js")
+	result.Diagnostics = append(result.Diagnostics, diagnostics...)
+	if len(diagnostics) > 0 {
+		result.Partial = true
+	}
+	result.SourceRanges = unpackagedRanges(np, ranges)
+	addSplitModules(np, modules)
+
+	files, native, err := recoverScriptFiles(np, outDir)
+	if err != nil {
+		return nil, err
 	}
+	result.Files = files
+	result.Recovered = len(files)
+	result.Native = native
 
 	appExists := fileExists(filepath.Join(outDir, "app.js"))
 	if !appExists && hasRuntime {
@@ -73,6 +72,67 @@ func RecoverJS(np *pkg.NormalizedPackage, outDir, reportsDir string) (*JSRecover
 	return result, nil
 }
 
+// unpackagedRanges drops the ranges of modules the package also ships as
+// files; the packaged file is what recovery keeps.
+func unpackagedRanges(np *pkg.NormalizedPackage, ranges []SourceRange) []SourceRange {
+	packaged := make(map[string]bool, len(np.Scripts))
+	for _, script := range np.Scripts {
+		packaged[script.Path] = true
+	}
+	var kept []SourceRange
+	for _, sourceRange := range ranges {
+		if !packaged[sourceRange.Output] {
+			kept = append(kept, sourceRange)
+		}
+	}
+	return kept
+}
+
+// addSplitModules appends split modules that the package does not ship and
+// points pages at the scripts they gained.
+func addSplitModules(np *pkg.NormalizedPackage, modules []pkg.ScriptIR) {
+	packaged := make(map[string]bool, len(np.Scripts))
+	for _, script := range np.Scripts {
+		packaged[script.Path] = true
+	}
+	for _, module := range modules {
+		if !packaged[module.Path] {
+			packaged[module.Path] = true
+			np.Scripts = append(np.Scripts, module)
+		}
+	}
+	for index := range np.Pages {
+		if page := &np.Pages[index]; page.ScriptPath == "" && packaged[page.Path+".js"] {
+			page.ScriptPath = page.Path + ".js"
+		}
+	}
+}
+
+// recoverScriptFiles writes the .js scripts missing from outDir and lists
+// every .js script, returning how many are native.
+func recoverScriptFiles(np *pkg.NormalizedPackage, outDir string) ([]RecoveredFile, int, error) {
+	var files []RecoveredFile
+	native := 0
+	for _, script := range np.Scripts {
+		if !strings.EqualFold(filepath.Ext(script.Path), ".js") {
+			continue
+		}
+		if !fileExists(filepath.Join(outDir, filepath.FromSlash(script.Path))) {
+			if script.Content == "" {
+				continue
+			}
+			if err := writeRecoveredFile(filepath.Join(outDir, filepath.FromSlash(script.Path)), script.Content); err != nil {
+				return nil, 0, err
+			}
+		}
+		files = append(files, RecoveredFile{Path: script.Path, Kind: "js", Source: pickSource(script.Source, "native")})
+		if script.Source == "native" {
+			native++
+		}
+	}
+	return files, native, nil
+}
+
 func hasRuntimeScript(np *pkg.NormalizedPackage) bool {
 	for _, script := range np.Scripts {
 		if script.Path == "app-service.js" || script.Path == "workers.js" {
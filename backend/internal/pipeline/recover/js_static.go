@@ -0,0 +1,382 @@
+package recover
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// evalStatic folds the constant expressions compiled templates are built
+// from. It is a port of staticEvaluate in wxappUnpacker/wuStatic.js: only
+// literals, operators and lookups of names already bound in scope resolve;
+// calls and any other runtime construct are errors.
+
+// jsNullType is JavaScript null; nil stands for undefined.
+type jsNullType struct{}
+
+var jsNull = jsNullType{}
+
+// jsObject is an object literal. Keys keep their insertion order, which the
+// restored WXML expressions and registry walks depend on.
+type jsObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newJSObject() *jsObject {
+	return &jsObject{values: map[string]interface{}{}}
+}
+
+func (o *jsObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *jsObject) get(key string) (interface{}, bool) {
+	value, ok := o.values[key]
+	return value, ok
+}
+
+// jsFunction stands in for a function value. The source is kept but never
+// run; returnValue is set when the body provably returns a constant. A
+// function with a nil node marks an nv_require(...) module reference.
+type jsFunction struct {
+	node        *jsNode
+	returnValue interface{}
+}
+
+func (f *jsFunction) source() string {
+	if f.node == nil {
+		return ""
+	}
+	return f.node.Raw
+}
+
+// jsScope is a chain of static bindings.
+type jsScope struct {
+	vars   map[string]interface{}
+	parent *jsScope
+}
+
+func newJSScope(parent *jsScope) *jsScope {
+	return &jsScope{vars: map[string]interface{}{}, parent: parent}
+}
+
+func (s *jsScope) lookup(name string) (interface{}, bool) {
+	for scope := s; scope != nil; scope = scope.parent {
+		if value, ok := scope.vars[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+var jsForbiddenKeys = map[string]bool{"__proto__": true, "constructor": true, "prototype": true}
+
+func evalStatic(node *jsNode, scope *jsScope) (interface{}, error) {
+	if node == nil {
+		return nil, nil
+	}
+	switch node.Type {
+	case "Literal":
+		if node.Raw == "null" {
+			return jsNull, nil
+		}
+		return node.Value, nil
+	case "Identifier":
+		if node.Name == "undefined" {
+			return nil, nil
+		}
+		if value, ok := scope.lookup(node.Name); ok {
+			return value, nil
+		}
+		return nil, fmt.Errorf("%w %q", errUnknownIdentifier, node.Name)
+	case "ArrayExpression":
+		values := make([]interface{}, 0, len(node.Elements))
+		for _, element := range node.Elements {
+			if element != nil && element.Type == "SpreadElement" {
+				return nil, fmt.Errorf("unsupported spread element")
+			}
+			value, err := evalStatic(element, scope)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case "ObjectExpression":
+		object := newJSObject()
+		for _, property := range node.Properties {
+			key, err := staticObjectKey(property, scope)
+			if err != nil {
+				return nil, err
+			}
+			value, err := evalStatic(property.Init, scope)
+			if err != nil {
+				return nil, err
+			}
+			object.set(key, value)
+		}
+		return object, nil
+	case "UnaryExpression":
+		value, err := evalStatic(node.Argument, scope)
+		if err != nil {
+			return nil, err
+		}
+		switch node.Name {
+		case "+":
+			return jsToNumber(value), nil
+		case "-":
+			return -jsToNumber(value), nil
+		case "!":
+			return !jsTruthy(value), nil
+		case "~":
+			return float64(^jsToInt32(value)), nil
+		case "void":
+			return nil, nil
+		case "typeof":
+			return jsTypeOf(value), nil
+		}
+		return nil, fmt.Errorf("unsupported unary operator %s", node.Name)
+	case "BinaryExpression":
+		left, err := evalStatic(node.Left, scope)
+		if err != nil {
+			return nil, err
+		}
+		right, err := evalStatic(node.Right, scope)
+		if err != nil {
+			return nil, err
+		}
+		return jsBinary(node.Name, left, right)
+	case "LogicalExpression":
+		left, err := evalStatic(node.Left, scope)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case node.Name == "&&" && !jsTruthy(left),
+			node.Name == "||" && jsTruthy(left),
+			node.Name == "??" && left != nil && left != jsNull:
+			return left, nil
+		}
+		return evalStatic(node.Right, scope)
+	case "ConditionalExpression":
+		test, err := evalStatic(node.Test, scope)
+		if err != nil {
+			return nil, err
+		}
+		if jsTruthy(test) {
+			return evalStatic(node.Consequent, scope)
+		}
+		return evalStatic(node.Alternate, scope)
+	case "MemberExpression":
+		object, err := evalStatic(node.Object, scope)
+		if err != nil {
+			return nil, err
+		}
+		key, err := staticPropertyName(node, scope)
+		if err != nil {
+			return nil, err
+		}
+		switch target := object.(type) {
+		case []interface{}:
+			if index, err := strconv.Atoi(key); err == nil && index >= 0 && index < len(target) {
+				return target[index], nil
+			}
+			if key == "length" {
+				return float64(len(target)), nil
+			}
+		case *jsObject:
+			if value, ok := target.get(key); ok {
+				return value, nil
+			}
+		case map[string]interface{}:
+			// A table WXSS recovery has already decoded.
+			if value, ok := target[key]; ok {
+				return value, nil
+			}
+		default:
+			return nil, fmt.Errorf("invalid static member access")
+		}
+		return nil, fmt.Errorf("unknown static member %q", key)
+	case "SequenceExpression":
+		var value interface{}
+		for _, expression := range node.Elements {
+			var err error
+			if value, err = evalStatic(expression, scope); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unsupported static expression %s", node.Type)
+}
+
+// staticPropertyName is the key read by a member expression.
+func staticPropertyName(node *jsNode, scope *jsScope) (string, error) {
+	if !node.Computed && node.Property != nil && node.Property.Type == "Identifier" {
+		return node.Property.Name, nil
+	}
+	value, err := evalStatic(node.Property, scope)
+	if err != nil {
+		return "", err
+	}
+	switch value.(type) {
+	case string, float64:
+	default:
+		return "", fmt.Errorf("non-static property name")
+	}
+	name := jsKey(value)
+	if jsForbiddenKeys[name] {
+		return "", fmt.Errorf("forbidden property name %q", name)
+	}
+	return name, nil
+}
+
+func staticObjectKey(property *jsNode, scope *jsScope) (string, error) {
+	if property.Type != "Property" {
+		return "", fmt.Errorf("unsupported object property")
+	}
+	key := property.Name
+	if property.Computed {
+		value, err := evalStatic(property.Property, scope)
+		if err != nil {
+			return "", err
+		}
+		key = jsKey(value)
+	}
+	if jsForbiddenKeys[key] {
+		return "", fmt.Errorf("forbidden object key %q", key)
+	}
+	return key, nil
+}
+
+func jsBinary(operator string, left, right interface{}) (interface{}, error) {
+	switch operator {
+	case "+":
+		_, leftString := left.(string)
+		_, rightString := right.(string)
+		if leftString || rightString {
+			return jsKey(left) + jsKey(right), nil
+		}
+		return jsToNumber(left) + jsToNumber(right), nil
+	case "-":
+		return jsToNumber(left) - jsToNumber(right), nil
+	case "*":
+		return jsToNumber(left) * jsToNumber(right), nil
+	case "/":
+		return jsToNumber(left) / jsToNumber(right), nil
+	case "%":
+		return math.Mod(jsToNumber(left), jsToNumber(right)), nil
+	case "|":
+		return float64(jsToInt32(left) | jsToInt32(right)), nil
+	case "&":
+		return float64(jsToInt32(left) & jsToInt32(right)), nil
+	case "^":
+		return float64(jsToInt32(left) ^ jsToInt32(right)), nil
+	case "<<":
+		return float64(jsToInt32(left) << (uint32(jsToInt32(right)) & 31)), nil
+	case ">>":
+		return float64(jsToInt32(left) >> (uint32(jsToInt32(right)) & 31)), nil
+	case "===":
+		return jsStrictEqual(left, right), nil
+	case "!==":
+		return !jsStrictEqual(left, right), nil
+	case "==":
+		return jsLooseEqual(left, right), nil
+	case "!=":
+		return !jsLooseEqual(left, right), nil
+	case "<", "<=", ">", ">=":
+		leftString, leftOK := left.(string)
+		rightString, rightOK := right.(string)
+		if leftOK && rightOK {
+			compare := strings.Compare(leftString, rightString)
+			return operator == "<" && compare < 0 || operator == "<=" && compare <= 0 ||
+				operator == ">" && compare > 0 || operator == ">=" && compare >= 0, nil
+		}
+		a, b := jsToNumber(left), jsToNumber(right)
+		return operator == "<" && a < b || operator == "<=" && a <= b ||
+			operator == ">" && a > b || operator == ">=" && a >= b, nil
+	}
+	return nil, fmt.Errorf("unsupported binary operator %s", operator)
+}
+
+func jsStrictEqual(left, right interface{}) bool {
+	switch left.(type) {
+	case nil, jsNullType, bool, float64, string:
+		return left == right
+	}
+	// Arrays, objects and functions compare by identity.
+	return false
+}
+
+func jsLooseEqual(left, right interface{}) bool {
+	leftNullish := left == nil || left == jsNull
+	rightNullish := right == nil || right == jsNull
+	if leftNullish || rightNullish {
+		return leftNullish && rightNullish
+	}
+	if _, ok := left.(string); ok {
+		if _, ok := right.(string); ok {
+			return left == right
+		}
+	}
+	switch left.(type) {
+	case bool, float64, string:
+		switch right.(type) {
+		case bool, float64, string:
+			return jsToNumber(left) == jsToNumber(right)
+		}
+	}
+	return false
+}
+
+func jsToNumber(value interface{}) float64 {
+	switch typed := value.(type) {
+	case float64:
+		return typed
+	case bool:
+		if typed {
+			return 1
+		}
+		return 0
+	case jsNullType:
+		return 0
+	case string:
+		text := strings.TrimSpace(typed)
+		if text == "" {
+			return 0
+		}
+		if number, err := strconv.ParseFloat(text, 64); err == nil {
+			return number
+		}
+	}
+	return math.NaN()
+}
+
+func jsToInt32(value interface{}) int32 {
+	number := jsToNumber(value)
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0
+	}
+	return int32(uint32(int64(math.Trunc(number))))
+}
+
+func jsTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "undefined"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *jsFunction:
+		return "function"
+	}
+	return "object"
+}
@@ -10,23 +10,33 @@ import (
 )
 
 type ManifestRecoveryResult struct {
-	Success     bool              `json:"success"`
-	OutputPath  string            `json:"outputPath"`
-	ReportPath  string            `json:"reportPath"`
-	Sources     map[string]string `json:"sources,omitempty"`
-	PageCount   int               `json:"pageCount"`
-	Diagnostics []pkg.Diagnostic  `json:"diagnostics,omitempty"`
+	Success    bool              `json:"success"`
+	OutputPath string            `json:"outputPath"`
+	ReportPath string            `json:"reportPath"`
+	Sources    map[string]string `json:"sources,omitempty"`
+	PageCount  int               `json:"pageCount"`
+	// PublicComponents maps the public components of a plugin to their
+	// package paths.
+	PublicComponents map[string]string `json:"publicComponents,omitempty"`
+	Diagnostics      []pkg.Diagnostic  `json:"diagnostics,omitempty"`
 }
 
 type manifestRecoveryReport struct {
-	Success     bool              `json:"success"`
-	PageCount   int               `json:"pageCount"`
-	Sources     map[string]string `json:"sources"`
-	Diagnostics []pkg.Diagnostic  `json:"diagnostics,omitempty"`
-	Manifest    pkg.ManifestIR    `json:"manifest"`
+	Success     bool                  `json:"success"`
+	PageCount   int                   `json:"pageCount"`
+	Sources     map[string]string     `json:"sources"`
+	Diagnostics []pkg.Diagnostic      `json:"diagnostics,omitempty"`
+	Manifest    pkg.ManifestIR        `json:"manifest"`
+	Plugin      *pkg.PluginManifestIR `json:"plugin,omitempty"`
 }
 
 func RecoverManifest(np *pkg.NormalizedPackage, sourceDir, reportsDir string) (*ManifestRecoveryResult, error) {
+	if np.Profile.IsGamePackage {
+		return recoverGameManifest(np, sourceDir, reportsDir)
+	}
+	if np.Plugin != nil {
+		return recoverPluginManifest(np, sourceDir, reportsDir)
+	}
 	outputPath := filepath.Join(sourceDir, "app.json")
 	if err := writeAppJSON(np.Manifest, outputPath); err != nil {
 		return nil, err
@@ -184,6 +194,7 @@ func writeManifestRecoveryReport(result *ManifestRecoveryResult, np *pkg.Normali
 		Sources:     result.Sources,
 		Diagnostics: report.SanitizeDiagnostics(np.Diagnostics),
 		Manifest:    np.Manifest,
+		Plugin:      np.Plugin,
 	}
 	return storage.WriteJSON(result.ReportPath, snapshot)
 }
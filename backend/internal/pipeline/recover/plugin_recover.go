@@ -0,0 +1,68 @@
+package recover
+
+import (
+	"path/filepath"
+	"sort"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+)
+
+// recoverPluginManifest writes plugin.json in place of app.json. The parsed
+// document is kept and the fields this service reads are written back, so a
+// metadata-only copy from __extended__ still yields a complete plugin root.
+func recoverPluginManifest(np *pkg.NormalizedPackage, sourceDir, reportsDir string) (*ManifestRecoveryResult, error) {
+	plugin := np.Plugin
+	outputPath, ok := safeOutputPath(sourceDir, filepath.Join(plugin.Root, "plugin.json"))
+	if !ok {
+		outputPath = filepath.Join(sourceDir, "plugin.json")
+	}
+
+	pluginJSON := cloneJSONObject(plugin.Original)
+	if plugin.Main != "" {
+		pluginJSON["main"] = plugin.Main
+	}
+	if len(plugin.Pages) > 0 {
+		pluginJSON["pages"] = plugin.Pages
+	}
+	if len(plugin.PublicComponents) > 0 {
+		pluginJSON["publicComponents"] = plugin.PublicComponents
+	}
+	if err := storage.WriteJSON(outputPath, pluginJSON); err != nil {
+		return nil, err
+	}
+	if err := writeRecoveredPageConfigs(np.Pages, sourceDir); err != nil {
+		return nil, err
+	}
+
+	result := &ManifestRecoveryResult{
+		Success:          true,
+		OutputPath:       outputPath,
+		ReportPath:       filepath.Join(reportsDir, "manifest-recovery-report.json"),
+		Sources:          map[string]string{"plugin.json": plugin.Source},
+		PageCount:        len(np.Manifest.Pages),
+		PublicComponents: map[string]string{},
+		Diagnostics:      append([]pkg.Diagnostic(nil), np.Diagnostics...),
+	}
+
+	names := make([]string, 0, len(plugin.PublicComponents))
+	for name := range plugin.PublicComponents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		resolved := plugin.ResolvePath(plugin.PublicComponents[name])
+		if resolved == "" {
+			diagnostic := pkg.Warn("recover.manifest.plugin_component_unsafe", "插件公开组件路径越界，已跳过", "recovering_manifest", "plugin.json")
+			diagnostic.Metadata = map[string]interface{}{"component": name}
+			result.Diagnostics = append(result.Diagnostics, diagnostic)
+			continue
+		}
+		result.PublicComponents[name] = resolved
+	}
+
+	if err := writeManifestRecoveryReport(result, np, reportsDir); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
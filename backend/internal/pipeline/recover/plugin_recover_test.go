@@ -0,0 +1,47 @@
+package recover
+
+import (
+	"encoding/json"
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+func TestRecoverManifestWritesPluginJSON(t *testing.T) {
+	outDir := t.TempDir()
+	normalized := &pkg.NormalizedPackage{
+		Manifest: pkg.ManifestIR{Pages: []string{"pages/hello/index"}},
+		Plugin: &pkg.PluginManifestIR{
+			Main:             "index.js",
+			Pages:            map[string]string{"hello": "pages/hello/index"},
+			PublicComponents: map[string]string{"list": "/components/list/list", "escape": "../outside"},
+			Original:         map[string]interface{}{"themeLocation": "theme.json"},
+			Source:           "__extended__/wxplugin/plugin.json",
+		},
+	}
+
+	result, err := RecoverManifest(normalized, outDir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sources["plugin.json"] != "__extended__/wxplugin/plugin.json" || result.PageCount != 1 {
+		t.Fatalf("unexpected manifest result: %#v", result)
+	}
+	if len(result.PublicComponents) != 1 || result.PublicComponents["list"] != "components/list/list" {
+		t.Fatalf("public components were not mapped: %#v", result.PublicComponents)
+	}
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Code != "recover.manifest.plugin_component_unsafe" {
+		t.Fatalf("unexpected diagnostics: %#v", result.Diagnostics)
+	}
+
+	var pluginJSON map[string]interface{}
+	if err := json.Unmarshal([]byte(readRecoveredStylesheet(t, outDir, "plugin.json")), &pluginJSON); err != nil {
+		t.Fatal(err)
+	}
+	if pluginJSON["main"] != "index.js" || pluginJSON["themeLocation"] != "theme.json" || pluginJSON["pages"] == nil || pluginJSON["publicComponents"] == nil {
+		t.Fatalf("unexpected plugin.json: %#v", pluginJSON)
+	}
+	if fileExists(outDir + "/app.json") {
+		t.Fatal("plugin packages must not get an app.json")
+	}
+}
@@ -0,0 +1,269 @@
+package recover
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+	"github.com/keepbuild/seewxapkg/internal/model"
+)
+
+// SourceRange records where a recovered file came from: Source[Start:End]
+// of a packed entry. An exact range was copied verbatim and the output
+// begins with it; otherwise the output is a reformatted rendering of it.
+// SourceLine and SourceColumn locate Start, 0-based with UTF-16 columns as
+// source maps count them.
+type SourceRange struct {
+	Output       string `json:"output"`
+	Source       string `json:"source"`
+	Start        int    `json:"start"`
+	End          int    `json:"end"`
+	SourceLine   int    `json:"sourceLine"`
+	SourceColumn int    `json:"sourceColumn"`
+	Exact        bool   `json:"exact"`
+}
+
+func newSourceRange(output, source, content string, start, end int, exact bool) SourceRange {
+	before := content[:start]
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	return SourceRange{
+		Output:       output,
+		Source:       source,
+		Start:        start,
+		End:          end,
+		SourceLine:   strings.Count(before, "\n"),
+		SourceColumn: utf16Length(before[lineStart:]),
+		Exact:        exact,
+	}
+}
+
+// FallbackSourceRanges traces the .js files the fallback merge added to the
+// define() module of the same name. wxappUnpacker reformats module bodies,
+// so the ranges are not exact; WXML and WXSS it rebuilds have no range.
+func FallbackSourceRanges(np *pkg.NormalizedPackage, added []string) []SourceRange {
+	wanted := make(map[string]bool, len(added))
+	for _, file := range added {
+		if strings.HasSuffix(file, ".js") {
+			wanted[file] = true
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+	var ranges []SourceRange
+	for _, script := range np.Scripts {
+		if base := path.Base(script.Path); base != "app-service.js" && base != "workers.js" {
+			continue
+		}
+		modules, err := extractDefineModules(script.Content)
+		if err != nil {
+			continue
+		}
+		for _, module := range modules {
+			target, ok := cleanPackagePath(module.Name)
+			if !ok || !wanted[target] {
+				continue
+			}
+			delete(wanted, target)
+			ranges = append(ranges, newSourceRange(target, script.Path, script.Content, module.Start, module.End, false))
+		}
+	}
+	return ranges
+}
+
+// SourceMapEntry is one line of sourcemaps/index.json. EntryOffset is the
+// packed entry's offset in the main package and PackageOffset that of the
+// range; both are absent for entries of a merged subpackage. OutputSHA256
+// is the output as mapped: once formatting rewrites a file, its line and
+// column mappings no longer apply, while the byte range still does.
+type SourceMapEntry struct {
+	SourceRange
+	Map           string `json:"map"`
+	EntryOffset   *int64 `json:"entryOffset,omitempty"`
+	PackageOffset *int64 `json:"packageOffset,omitempty"`
+	OutputSHA256  string `json:"outputSha256"`
+}
+
+// SourceMapIndex is sourcemaps/index.json.
+type SourceMapIndex struct {
+	Maps []SourceMapEntry `json:"maps"`
+}
+
+// WriteSourceMaps writes one Source Map v3 file per range to mapsDir, named
+// after the output with a .map suffix, and an index of all of them. sources
+// holds the content of each packed entry by path. An exact range whose
+// output no longer starts with the source bytes is mapped as inexact;
+// outputs that no longer exist are skipped. The index is written even when
+// nothing was mapped.
+func WriteSourceMaps(sourceDir, mapsDir string, ranges []SourceRange, entries []model.FileEntry, sources map[string]string) (*SourceMapIndex, error) {
+	index := &SourceMapIndex{Maps: []SourceMapEntry{}}
+	offsets := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		offsets[strings.TrimLeft(entry.Name, "/")] = int64(entry.Offset)
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Output < ranges[j].Output })
+	seen := make(map[string]bool, len(ranges))
+	for _, sourceRange := range ranges {
+		if seen[sourceRange.Output] {
+			continue
+		}
+		seen[sourceRange.Output] = true
+		content, err := os.ReadFile(filepath.Join(sourceDir, filepath.FromSlash(sourceRange.Output)))
+		if err != nil {
+			continue
+		}
+		if sourceRange.Exact && !copiedVerbatim(content, sources[sourceRange.Source], sourceRange) {
+			sourceRange.Exact = false
+		}
+		mapPath, err := storage.SafePackageOutputPath(mapsDir, sourceRange.Output+".map")
+		if err != nil {
+			continue
+		}
+		entry := SourceMapEntry{SourceRange: sourceRange, Map: sourceRange.Output + ".map"}
+		if offset, ok := offsets[sourceRange.Source]; ok {
+			packageOffset := offset + int64(sourceRange.Start)
+			entry.EntryOffset, entry.PackageOffset = &offset, &packageOffset
+		}
+		sum := sha256.Sum256(content)
+		entry.OutputSHA256 = hex.EncodeToString(sum[:])
+
+		mapping := map[string]interface{}{
+			"version":  3,
+			"file":     path.Base(sourceRange.Output),
+			"sources":  []string{sourceRange.Source},
+			"names":    []string{},
+			"mappings": sourceMappings(string(content), sourceRange),
+			"x_seewxapkg_range": map[string]interface{}{
+				"start": sourceRange.Start,
+				"end":   sourceRange.End,
+				"exact": sourceRange.Exact,
+			},
+		}
+		if entry.PackageOffset != nil {
+			mapping["x_seewxapkg_range"].(map[string]interface{})["packageOffset"] = *entry.PackageOffset
+		}
+		if err := os.MkdirAll(filepath.Dir(mapPath), 0700); err != nil {
+			return nil, err
+		}
+		if err := storage.WriteJSON(mapPath, mapping); err != nil {
+			return nil, err
+		}
+		index.Maps = append(index.Maps, entry)
+	}
+	if err := os.MkdirAll(mapsDir, 0700); err != nil {
+		return nil, err
+	}
+	if err := storage.WriteJSON(filepath.Join(mapsDir, "index.json"), index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// copiedVerbatim reports whether output starts with the bytes of the range.
+func copiedVerbatim(output []byte, source string, sourceRange SourceRange) bool {
+	length := sourceRange.End - sourceRange.Start
+	return sourceRange.Start >= 0 && length >= 0 && sourceRange.End <= len(source) && len(output) >= length &&
+		string(output[:length]) == source[sourceRange.Start:sourceRange.End]
+}
+
+// sourceMappings encodes the mappings field. A verbatim copy maps every
+// line start and every statement or block boundary to the same text in the
+// source; a reformatted output only maps its start to the range start.
+func sourceMappings(output string, sourceRange SourceRange) string {
+	var encoder mappingEncoder
+	if !sourceRange.Exact {
+		encoder.segment(0, sourceRange.SourceLine, sourceRange.SourceColumn)
+		return encoder.String()
+	}
+	region := output[:sourceRange.End-sourceRange.Start]
+	sourceLine, sourceColumn := sourceRange.SourceLine, sourceRange.SourceColumn
+	generatedColumn := 0
+	mark := true
+	for _, r := range region {
+		if r == '\n' {
+			encoder.newLine()
+			sourceLine, sourceColumn, generatedColumn = sourceLine+1, 0, 0
+			mark = true
+			continue
+		}
+		if mark {
+			encoder.segment(generatedColumn, sourceLine, sourceColumn)
+			mark = false
+		}
+		width := len(utf16.Encode([]rune{r}))
+		generatedColumn += width
+		sourceColumn += width
+		mark = r == ';' || r == '{' || r == '}' || r == ','
+	}
+	return encoder.String()
+}
+
+// mappingEncoder writes Source Map v3 segments for a single source. Fields
+// are deltas: the generated column against the previous segment on the
+// line, the source position against the previous segment overall.
+type mappingEncoder struct {
+	builder         strings.Builder
+	lineHasSegment  bool
+	generatedColumn int
+	sourceLine      int
+	sourceColumn    int
+}
+
+func (e *mappingEncoder) newLine() {
+	e.builder.WriteByte(';')
+	e.lineHasSegment = false
+	e.generatedColumn = 0
+}
+
+func (e *mappingEncoder) segment(generatedColumn, sourceLine, sourceColumn int) {
+	if e.lineHasSegment {
+		e.builder.WriteByte(',')
+	}
+	e.lineHasSegment = true
+	writeVLQ(&e.builder, generatedColumn-e.generatedColumn)
+	writeVLQ(&e.builder, 0)
+	writeVLQ(&e.builder, sourceLine-e.sourceLine)
+	writeVLQ(&e.builder, sourceColumn-e.sourceColumn)
+	e.generatedColumn, e.sourceLine, e.sourceColumn = generatedColumn, sourceLine, sourceColumn
+}
+
+func (e *mappingEncoder) String() string {
+	return e.builder.String()
+}
+
+const vlqAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// writeVLQ appends value as a base64 VLQ: the sign in the lowest bit, then
+// five bits per digit with a continuation bit.
+func writeVLQ(builder *strings.Builder, value int) {
+	vlq := value << 1
+	if value < 0 {
+		vlq = (-value << 1) | 1
+	}
+	for {
+		digit := vlq & 31
+		vlq >>= 5
+		if vlq > 0 {
+			digit |= 32
+		}
+		builder.WriteByte(vlqAlphabet[digit])
+		if vlq == 0 {
+			return
+		}
+	}
+}
+
+func utf16Length(text string) int {
+	length := 0
+	for _, r := range text {
+		length += len(utf16.Encode([]rune{r}))
+	}
+	return length
+}
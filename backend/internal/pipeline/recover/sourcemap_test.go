@@ -0,0 +1,106 @@
+package recover
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/model"
+)
+
+func TestWriteSourceMapsTracesSplitModulesToPackedEntries(t *testing.T) {
+	outDir := t.TempDir()
+	bundle := strings.Join([]string{
+		`var __wxAppData = {};`,
+		`define("pages/home/index.js", function(require, module, exports){`,
+		`  var 名 = 1;`,
+		`  Page({ data: {} });`,
+		`});`,
+		`define("utils/util.js", function(require, module, exports){ module.exports = 2; });`,
+	}, "\n")
+	normalized := &pkg.NormalizedPackage{
+		Pages:   []pkg.PageIR{{Path: "pages/home/index"}},
+		Scripts: []pkg.ScriptIR{{Path: "app-service.js", Content: bundle, Source: "runtime"}},
+	}
+	result, err := RecoverJS(normalized, outDir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges := map[string]SourceRange{}
+	for _, sourceRange := range result.SourceRanges {
+		ranges[sourceRange.Output] = sourceRange
+	}
+	page, ok := ranges["pages/home/index.js"]
+	if !ok || !page.Exact || page.Source != "app-service.js" {
+		t.Fatalf("source ranges = %+v", result.SourceRanges)
+	}
+	if body := bundle[page.Start:page.End]; body != "var 名 = 1;\n  Page({ data: {} });" || page.SourceLine != 2 || page.SourceColumn != 2 {
+		t.Fatalf("page range %+v covers %q", page, body)
+	}
+
+	mapsDir := filepath.Join(t.TempDir(), "sourcemaps")
+	fallback := FallbackSourceRanges(normalized, []string{"utils/util.js", "pages/home/index.wxml"})
+	if len(fallback) != 1 || fallback[0].Exact {
+		t.Fatalf("fallback ranges = %+v", fallback)
+	}
+	entries := []model.FileEntry{{Name: "/app-service.js", Offset: 100, Size: uint32(len(bundle))}}
+	sources := map[string]string{"app-service.js": bundle}
+	index, err := WriteSourceMaps(outDir, mapsDir, append([]SourceRange{page}, fallback...), entries, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Maps) != 2 || index.Maps[0].Output != "pages/home/index.js" || index.Maps[0].Map != "pages/home/index.js.map" {
+		t.Fatalf("index = %+v", index.Maps)
+	}
+	if entry := index.Maps[0]; entry.EntryOffset == nil || *entry.EntryOffset != 100 || *entry.PackageOffset != int64(100+page.Start) {
+		t.Fatalf("offsets = %v %v", entry.EntryOffset, entry.PackageOffset)
+	}
+
+	var sourceMap struct {
+		Version  int      `json:"version"`
+		File     string   `json:"file"`
+		Sources  []string `json:"sources"`
+		Mappings string   `json:"mappings"`
+	}
+	data, err := os.ReadFile(filepath.Join(mapsDir, "pages", "home", "index.js.map"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &sourceMap); err != nil {
+		t.Fatal(err)
+	}
+	// Line 0 starts at line 2 column 2 of the bundle; line 1 maps its start
+	// and each token after a brace to the same columns of bundle line 3.
+	if sourceMap.Version != 3 || sourceMap.File != "index.js" || strings.Join(sourceMap.Sources, ",") != "app-service.js" ||
+		sourceMap.Mappings != "AAEE;AACF,QAAQ,QAAQ,CAAC,EAAE" {
+		t.Fatalf("source map = %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(mapsDir, "index.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	// An output rewritten after recovery no longer matches the bundle, so
+	// it only maps its start.
+	if err := os.WriteFile(filepath.Join(outDir, "pages", "home", "index.js"), []byte("var 名 = 2;\n  Page({ data: {} });"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	index, err = WriteSourceMaps(outDir, mapsDir, []SourceRange{page}, entries, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Maps) != 1 || index.Maps[0].Exact {
+		t.Fatalf("index = %+v, want the rewritten output mapped as inexact", index.Maps)
+	}
+	if data, err = os.ReadFile(filepath.Join(mapsDir, "pages", "home", "index.js.map")); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &sourceMap); err != nil {
+		t.Fatal(err)
+	}
+	if sourceMap.Mappings != "AAEE" {
+		t.Fatalf("source map = %s", data)
+	}
+}
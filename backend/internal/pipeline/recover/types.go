@@ -18,6 +18,9 @@ type JSRecoveryResult struct {
 	Recovered   int              `json:"recovered"`
 	Generated   int              `json:"generated"`
 	Native      int              `json:"native"`
+	// SourceRanges trace split modules back to their bundles; they are
+	// written as source maps, not into the report.
+	SourceRanges []SourceRange `json:"-"`
 }
 
 type WXMLRecoveryResult struct {
@@ -42,6 +45,31 @@ type WXSSRecoveryResult struct {
 	Native      int              `json:"native"`
 }
 
+// GameRecoveryResult describes a mini-game package: its split scripts and
+// the engine resources that ship next to them.
+type GameRecoveryResult struct {
+	Success     bool             `json:"success"`
+	Partial     bool             `json:"partial"`
+	Entry       string           `json:"entry"`
+	Engine      string           `json:"engine"`
+	Files       []RecoveredFile  `json:"files,omitempty"`
+	Assets      []GameAsset      `json:"assets,omitempty"`
+	AssetKinds  map[string]int   `json:"assetKinds,omitempty"`
+	Diagnostics []pkg.Diagnostic `json:"diagnostics,omitempty"`
+	ReportPath  string           `json:"reportPath,omitempty"`
+	Recovered   int              `json:"recovered"`
+	Generated   int              `json:"generated"`
+	Native      int              `json:"native"`
+	// SourceRanges trace split modules back to their bundles.
+	SourceRanges []SourceRange `json:"-"`
+}
+
+type GameAsset struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	Size int64  `json:"size"`
+}
+
 type FallbackResult struct {
 	Success     bool             `json:"success"`
 	Partial     bool             `json:"partial"`
@@ -59,8 +87,9 @@ type FallbackMergeConflict struct {
 }
 
 type FallbackMergeResult struct {
-	Added     int                     `json:"added"`
-	Identical int                     `json:"identical"`
-	Preserved int                     `json:"preserved"`
-	Conflicts []FallbackMergeConflict `json:"conflicts,omitempty"`
+	Added      int                     `json:"added"`
+	AddedFiles []string                `json:"addedFiles,omitempty"`
+	Identical  int                     `json:"identical"`
+	Preserved  int                     `json:"preserved"`
+	Conflicts  []FallbackMergeConflict `json:"conflicts,omitempty"`
 }
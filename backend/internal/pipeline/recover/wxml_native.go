@@ -0,0 +1,1338 @@
+package recover
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/pipeline/jslex"
+)
+
+// The native WXML engine is a static port of wxappUnpacker/wuWxml.js. The
+// compiler turns each .wxml file into a renderer function registered in the
+// e_ table of the page frame; templates go to d_ and WXS modules to f_.
+// Renderers build the tree through helper calls (_n creates an element, _r
+// sets an attribute, _ appends a child, _2 expands wx:for, ...) whose values
+// are indexes into the $gwx table. Those calls are read back from the syntax
+// tree; no package code is evaluated.
+
+const (
+	wxmlUnresolvedText       = "<!-- seewx-recovery: unresolved text omitted -->"
+	wxmlUnresolvedAttributes = "<!-- seewx-recovery: unresolved attributes omitted -->"
+	wxmlRequireBootstrap     = "\nvar nv_require=function(){var nnm="
+	wxmlRequireMap           = "var nnm="
+	wxmlPathDispatcher       = "if(path&&e_[path]){"
+	wxsModuleStart           = "nv_module={nv_exports:{}};"
+	wxsModuleEnd             = "return nv_module.nv_exports;}"
+)
+
+var (
+	wxmlEventAttribute = regexp.MustCompile(`(?i)^(?:(?:capture-)?(?:bind|catch)|mut-bind)(?::?[A-Za-z][\w-]*)$`)
+	wxsRequireCall     = regexp.MustCompile(`(require\(.*?\))\(\)`)
+	wxmlRegistryNames  = map[string]bool{"d_": true, "e_": true, "f_": true}
+)
+
+// rebuiltTemplate is one .wxml or .wxs file reconstructed from a frame.
+type rebuiltTemplate struct {
+	Path    string
+	Kind    string
+	Content string
+}
+
+type wxmlFrame struct {
+	name string
+	code string
+}
+
+type wxmlRegistries struct {
+	d, e, f     *jsObject
+	x           []interface{}
+	requireInfo *jsObject
+}
+
+// rebuildRuntimeTemplates reconstructs the templates of a package from the
+// renderer registry of its page frame and of every subpackage frame. Paths
+// are slash-separated and source-relative; the first frame to produce a
+// path wins.
+func rebuildRuntimeTemplates(np *pkg.NormalizedPackage) ([]rebuiltTemplate, []pkg.Diagnostic) {
+	roots, subpackages := templateFrames(np)
+	var files []rebuiltTemplate
+	var diagnostics []pkg.Diagnostic
+	seen := map[string]bool{}
+	collect := func(frame wxmlFrame) bool {
+		frameFiles, frameDiagnostics, found := rebuildFrameTemplates(frame)
+		diagnostics = append(diagnostics, frameDiagnostics...)
+		for _, file := range frameFiles {
+			if !seen[file.Path] {
+				seen[file.Path] = true
+				files = append(files, file)
+			}
+		}
+		return found
+	}
+	// 4.x main packages may ship a placeholder page-frame.js and keep the
+	// real registry in app-wxss.js, so the first frame with renderers wins.
+	for _, frame := range roots {
+		if collect(frame) {
+			break
+		}
+	}
+	for _, frame := range subpackages {
+		collect(frame)
+	}
+	return files, diagnostics
+}
+
+func templateFrames(np *pkg.NormalizedPackage) ([]wxmlFrame, []wxmlFrame) {
+	candidates := map[string]string{}
+	var subpackages []wxmlFrame
+	for _, template := range np.Templates {
+		if template.Path == "page-frame.html" {
+			candidates[template.Path] = frameScript(template.Content)
+		}
+	}
+	for _, script := range np.Scripts {
+		switch {
+		case script.Path == "page-frame.js" || script.Path == "app-wxss.js":
+			candidates[script.Path] = script.Content
+		case path.Base(script.Path) == "page-frame.js" && strings.Contains(script.Content, "e_["):
+			subpackages = append(subpackages, wxmlFrame{name: script.Path, code: script.Content})
+		}
+	}
+	var roots []wxmlFrame
+	for _, name := range []string{"page-frame.html", "page-frame.js", "app-wxss.js"} {
+		if code, ok := candidates[name]; ok && strings.Contains(code, "e_[") {
+			roots = append(roots, wxmlFrame{name: name, code: code})
+		}
+	}
+	return roots, subpackages
+}
+
+// rebuildFrameTemplates rebuilds one frame. It reports whether the frame
+// had renderer entries at all.
+func rebuildFrameTemplates(frame wxmlFrame) ([]rebuiltTemplate, []pkg.Diagnostic, bool) {
+	registries, err := extractWXMLRegistries(frame.code)
+	if err != nil {
+		return nil, []pkg.Diagnostic{pkg.Warn("recover.wxml.registry_unreadable", "WXML 注册表无法静态解析："+err.Error(), "recovering_wxml", frame.name)}, false
+	}
+	if len(registries.e.keys) == 0 {
+		return nil, nil, false
+	}
+
+	var diagnostics []pkg.Diagnostic
+	opcodes, problems := extractWXMLOpcodes(frame.code)
+	for _, problem := range problems {
+		diagnostics = append(diagnostics, pkg.Warn("recover.wxml.opcode_unreadable", "WXML 常量表无法静态还原，相关属性与文本将被省略："+problem, "recovering_wxml", frame.name))
+	}
+	files, wxsTags, wxsDiagnostics := rebuildWXSModules(registries, frame.name)
+	diagnostics = append(diagnostics, wxsDiagnostics...)
+
+	for _, key := range registries.e.keys {
+		entry, _ := registries.e.values[key].(*jsObject)
+		if entry == nil {
+			continue
+		}
+		value, _ := entry.get("f")
+		renderer, _ := value.(*jsFunction)
+		if renderer == nil || renderer.node == nil {
+			continue
+		}
+		output, ok := cleanPackagePath(key)
+		if !ok {
+			diagnostics = append(diagnostics, pkg.Warn("recover.wxml.output_unsafe", "WXML 输出路径越界，已跳过", "recovering_wxml", key))
+			continue
+		}
+		if path.Ext(output) == "" {
+			output += ".wxml"
+		}
+		templates, _ := registries.d.values[key].(*jsObject)
+		writer := &wxmlWriter{attributes: map[string]int{}}
+		content, err := writer.render(renderer, templates, &wxmlAnalyzer{opcodes: opcodes, x: registries.x})
+		if err != nil {
+			diagnostics = append(diagnostics, pkg.Warn("recover.wxml.render_failed", "WXML 渲染函数无法静态还原，已保留运行时代码："+err.Error(), "recovering_wxml", output))
+			continue
+		}
+		if tags := wxsTags[output]; tags != "" {
+			content += tags + "\n"
+		}
+		if diagnostic, ok := writer.diagnostic(output); ok {
+			diagnostics = append(diagnostics, diagnostic)
+		}
+		files = append(files, rebuiltTemplate{Path: output, Kind: "wxml", Content: content})
+	}
+	return files, diagnostics, true
+}
+
+// extractWXMLRegistries reads the d_, e_ and f_ registries of a frame. The
+// compiler emits them as a straight-line run of top-level assignments after
+// the nv_require bootstrap; the bootstrap and the trailing path dispatcher
+// are cut off first.
+func extractWXMLRegistries(code string) (*wxmlRegistries, error) {
+	requireMap := ""
+	mapStart := strings.LastIndex(code, wxmlRequireMap)
+	if mapStart >= 0 {
+		if end := strings.Index(code[mapStart+len(wxmlRequireMap):], "};"); end >= 0 {
+			requireMap = code[mapStart+len(wxmlRequireMap) : mapStart+len(wxmlRequireMap)+end+1]
+		}
+	}
+	region := code
+	bootstrap := strings.LastIndex(code, wxmlRequireBootstrap)
+	if bootstrap >= 0 {
+		region = region[bootstrap+len(wxmlRequireBootstrap):]
+	}
+	if dispatcher := strings.LastIndex(region, wxmlPathDispatcher); dispatcher >= 0 {
+		region = region[:dispatcher]
+	}
+	// The bootstrap IIFE ends with "}()" and a newline; the search starts
+	// after the require map so a "()" inside it is never mistaken for it.
+	searchFrom := -1
+	switch {
+	case bootstrap >= 0:
+		searchFrom = len(requireMap) + 1
+	case mapStart >= 0:
+		searchFrom = mapStart + len(requireMap) + 1
+	}
+	cut := -1
+	if searchFrom >= 0 && searchFrom <= len(region) {
+		for _, marker := range []string{"()\n", "()\r\n"} {
+			if index := strings.Index(region[searchFrom:], marker); index >= 0 && (cut < 0 || searchFrom+index < cut) {
+				cut = searchFrom + index
+			}
+		}
+	}
+	if cut >= 0 {
+		region = region[cut+strings.IndexByte(region[cut:], '\n')+1:]
+	} else {
+		region = stripLeadingBlock(region)
+	}
+
+	program, err := parseJSProgram(region)
+	if err != nil {
+		return nil, err
+	}
+	// WXS modules compiled as `function np_0(){...}` may sit before the
+	// bootstrap, outside the region, and are referenced by name.
+	scope := newJSScope(topLevelFunctions(code))
+	registries := &wxmlRegistries{d: newJSObject(), e: newJSObject(), f: newJSObject()}
+	registry := func(name string) *jsObject {
+		switch name {
+		case "d_":
+			return registries.d
+		case "e_":
+			return registries.e
+		case "f_":
+			return registries.f
+		}
+		return nil
+	}
+
+	for _, statement := range program {
+		switch statement.Type {
+		case "FunctionDeclaration":
+			if wxmlRegistryNames[statement.Name] {
+				return nil, fmt.Errorf("registry %s is shadowed", statement.Name)
+			}
+			scope.vars[statement.Name] = staticFunction(statement, scope)
+		case "EmptyStatement", "BlockStatement", "IfStatement":
+			// No registry data: the require map, the path dispatcher.
+		case "VariableDeclaration":
+			for _, declaration := range statement.Declarations {
+				if declaration.Init == nil {
+					continue
+				}
+				if target := registry(declaration.Name); target != nil {
+					mergeRegistryInit(target, declaration.Init, scope)
+					continue
+				}
+				if value, err := evalRegistryNode(declaration.Init, scope); err == nil {
+					scope.vars[declaration.Name] = value
+				}
+			}
+		case "ExpressionStatement":
+			assignment := statement.Argument
+			if assignment.Type != "AssignmentExpression" {
+				// Bootstrap calls interleaved with the assignments.
+				continue
+			}
+			if assignment.Name != "=" {
+				return nil, fmt.Errorf("unsupported registry assignment %s", assignment.Name)
+			}
+			assignRegistryEntry(assignment, scope, registry)
+		default:
+			return nil, fmt.Errorf("unsupported registry statement %s", statement.Type)
+		}
+	}
+
+	registries.x, _ = scope.vars["x"].([]interface{})
+	registries.requireInfo = newJSObject()
+	if requireMap != "" {
+		if node, err := parseJSExpression("(" + requireMap + ")"); err == nil {
+			if info, err := evalRegistryNode(node, scope); err == nil {
+				if object, ok := info.(*jsObject); ok {
+					registries.requireInfo = object
+				}
+			}
+		}
+	}
+	return registries, nil
+}
+
+// assignRegistryEntry applies one `left = right` statement. Besides the
+// `e_[key] = ...` form, two-level `d_[file][name] = ...` assignments are
+// followed: that is how templates and per-file WXS modules are registered.
+// A dynamic entry is skipped without failing its siblings.
+func assignRegistryEntry(assignment *jsNode, scope *jsScope, registry func(string) *jsObject) {
+	left := assignment.Left
+	switch left.Type {
+	case "Identifier":
+		if target := registry(left.Name); target != nil {
+			mergeRegistryInit(target, assignment.Right, scope)
+			return
+		}
+		if value, err := evalRegistryNode(assignment.Right, scope); err == nil {
+			scope.vars[left.Name] = value
+		}
+		return
+	case "MemberExpression":
+	default:
+		return
+	}
+
+	key, err := staticPropertyName(left, scope)
+	if err != nil {
+		return
+	}
+	var target *jsObject
+	switch object := left.Object; {
+	case object.Type == "Identifier":
+		target = registry(object.Name)
+	case object.Type == "MemberExpression" && object.Object.Type == "Identifier" && registry(object.Object.Name) != nil:
+		outer, err := staticPropertyName(object, scope)
+		if err != nil {
+			return
+		}
+		parent := registry(object.Object.Name)
+		existing, _ := parent.get(outer)
+		if target, _ = existing.(*jsObject); target == nil {
+			target = newJSObject()
+			parent.set(outer, target)
+		}
+	}
+	if target == nil {
+		return
+	}
+	if value, err := evalRegistryNode(assignment.Right, scope); err == nil {
+		target.set(key, value)
+	}
+}
+
+// mergeRegistryInit merges an object-literal initializer into a registry
+// entry by entry.
+func mergeRegistryInit(registry *jsObject, node *jsNode, scope *jsScope) {
+	if node.Type == "ObjectExpression" {
+		for _, property := range node.Properties {
+			key, err := staticObjectKey(property, scope)
+			if err != nil {
+				continue
+			}
+			if value, err := evalRegistryNode(property.Init, scope); err == nil {
+				registry.set(key, value)
+			}
+		}
+		return
+	}
+	value, err := evalRegistryNode(node, scope)
+	if object, ok := value.(*jsObject); err == nil && ok {
+		for _, key := range object.keys {
+			registry.set(key, object.values[key])
+		}
+	}
+}
+
+// evalRegistryNode is evalStatic extended with the values registries hold:
+// functions are kept as source, and nv_require("p_...") becomes a module
+// reference carrying its path.
+func evalRegistryNode(node *jsNode, scope *jsScope) (interface{}, error) {
+	switch {
+	case node == nil:
+		return nil, nil
+	case node.Type == "FunctionExpression":
+		return staticFunction(node, scope), nil
+	case node.Type == "ObjectExpression":
+		object := newJSObject()
+		for _, property := range node.Properties {
+			key, err := staticObjectKey(property, scope)
+			if err != nil {
+				continue
+			}
+			if value, err := evalRegistryNode(property.Init, scope); err == nil {
+				object.set(key, value)
+			}
+		}
+		return object, nil
+	case node.Type == "ArrayExpression":
+		values := make([]interface{}, 0, len(node.Elements))
+		for _, element := range node.Elements {
+			value, err := evalRegistryNode(element, scope)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case isRequireCall(node):
+		ref, err := evalStatic(node.Arguments[0], scope)
+		if err != nil {
+			return nil, err
+		}
+		return &jsFunction{returnValue: ref}, nil
+	case node.Type == "LogicalExpression" && node.Name == "||" && isRequireCall(node.Right):
+		// `f_[path] || nv_require(path)` reuses an already loaded module;
+		// either side is the same reference.
+		return evalRegistryNode(node.Right, scope)
+	}
+	return evalStatic(node, scope)
+}
+
+func isRequireCall(node *jsNode) bool {
+	return node.Type == "CallExpression" && node.Callee.Type == "Identifier" && node.Callee.Name == "nv_require" && len(node.Arguments) > 0
+}
+
+// staticFunction wraps a function node. Its return value is recorded only
+// when the body is a straight run of constant declarations ending in a
+// return, so a renderer never looks like a constant.
+func staticFunction(node *jsNode, scope *jsScope) *jsFunction {
+	function := &jsFunction{node: node}
+	body, err := node.functionBody()
+	if err != nil {
+		return function
+	}
+	local := newJSScope(scope)
+	for _, statement := range body {
+		switch statement.Type {
+		case "FunctionDeclaration", "EmptyStatement":
+			continue
+		case "VariableDeclaration":
+			for _, declaration := range statement.Declarations {
+				if declaration.Init == nil {
+					return function
+				}
+				value, err := evalStatic(declaration.Init, local)
+				if err != nil {
+					return function
+				}
+				local.vars[declaration.Name] = value
+			}
+			continue
+		case "IfStatement":
+			if test, err := evalStatic(statement.Test, local); err == nil && test == false && statement.Alternate == nil {
+				continue
+			}
+		case "ReturnStatement":
+			if value, err := evalStatic(statement.Argument, local); err == nil {
+				function.returnValue = value
+			}
+		}
+		return function
+	}
+	return function
+}
+
+// topLevelFunctions binds the function declarations at the top level of a
+// whole frame. Each declaration is parsed on its own, so runtime code the
+// parser does not understand elsewhere in the frame does not hide them.
+func topLevelFunctions(code string) *jsScope {
+	scope := newJSScope(nil)
+	tokens, err := jslex.Tokenize(code)
+	if err != nil {
+		return scope
+	}
+	depth := 0
+	for index := 0; index < len(tokens); index++ {
+		token := tokens[index]
+		if token.Kind == jslex.Punct {
+			switch token.Text {
+			case "{":
+				depth++
+			case "}":
+				depth--
+			}
+			continue
+		}
+		if depth != 0 || token.Kind != jslex.Ident || token.Text != "function" || index+1 >= len(tokens) || tokens[index+1].Kind != jslex.Ident {
+			continue
+		}
+		end := matchingBrace(tokens, index)
+		if end < 0 {
+			return scope
+		}
+		if program, err := parseJSProgram(code[token.Start:tokens[end].End]); err == nil && len(program) == 1 && program[0].Type == "FunctionDeclaration" {
+			scope.vars[program[0].Name] = &jsFunction{node: program[0]}
+		}
+		index = end
+	}
+	return scope
+}
+
+// stripLeadingBlock drops a leading `{...}` require map that the bootstrap
+// cut left behind; it would not parse at statement position.
+func stripLeadingBlock(code string) string {
+	tokens, err := jslex.Tokenize(code)
+	if err != nil || tokens[0].Kind != jslex.Punct || tokens[0].Text != "{" {
+		return code
+	}
+	rest := matchingBrace(tokens, 0)
+	if rest < 0 {
+		return code
+	}
+	for rest++; tokens[rest].Kind == jslex.Punct && tokens[rest].Text == ";"; rest++ {
+	}
+	return code[tokens[rest].Start:]
+}
+
+// rebuildWXSModules writes the .wxs files registered in f_ and returns the
+// <wxs> tags each .wxml file declared, keyed by its package path.
+func rebuildWXSModules(registries *wxmlRegistries, frameName string) ([]rebuiltTemplate, map[string]string, []pkg.Diagnostic) {
+	var files []rebuiltTemplate
+	var diagnostics []pkg.Diagnostic
+	unsafe := func(key string) {
+		diagnostics = append(diagnostics, pkg.Warn("recover.wxml.output_unsafe", "WXS 输出路径越界，已跳过", "recovering_wxml", key))
+	}
+	module := func(ref string) *jsFunction {
+		value, _ := registries.requireInfo.get(ref)
+		if function, ok := value.(*jsFunction); ok && function.node != nil {
+			return function
+		}
+		return nil
+	}
+
+	modules := map[string]string{}
+	for _, key := range registries.f.keys {
+		reference, ok := registries.f.values[key].(*jsFunction)
+		if !ok {
+			continue
+		}
+		target, ok := cleanPackagePath(key)
+		if !ok {
+			unsafe(key)
+			continue
+		}
+		if reference.node != nil && strings.Contains(reference.source(), wxsModuleStart) {
+			// Newer frames register the module function itself.
+			files = append(files, rebuiltTemplate{Path: target, Kind: "wxs", Content: restoreWXSModule(reference.source(), target) + "\n"})
+			continue
+		}
+		ref, ok := reference.returnValue.(string)
+		if !ok {
+			continue
+		}
+		modules[ref] = target
+		if source := module(ref); source != nil {
+			files = append(files, rebuiltTemplate{Path: target, Kind: "wxs", Content: restoreWXSModule(source.source(), target) + "\n"})
+		}
+	}
+
+	tags := map[string]string{}
+	for _, key := range registries.f.keys {
+		group, ok := registries.f.values[key].(*jsObject)
+		if !ok {
+			continue
+		}
+		target, ok := cleanPackagePath(key)
+		if !ok {
+			unsafe(key)
+			continue
+		}
+		var lines []string
+		for _, name := range group.keys {
+			reference, _ := group.values[name].(*jsFunction)
+			if reference == nil {
+				continue
+			}
+			ref, ok := reference.returnValue.(string)
+			if !ok {
+				continue
+			}
+			moduleName := escapeWXMLAttribute(name)
+			if source := module(ref); source != nil && strings.Contains(ref, ":") {
+				// Inline modules are keyed by "<file>:<module>".
+				lines = append(lines, `<wxs module="`+moduleName+"\">\n"+restoreWXSModule(source.source(), "")+"\n</wxs>")
+				continue
+			}
+			modulePath, ok := modules[ref]
+			if !ok {
+				if modulePath, ok = cleanPackagePath(strings.TrimPrefix(ref, "p_")); !ok {
+					unsafe(ref)
+					continue
+				}
+			}
+			lines = append(lines, `<wxs module="`+moduleName+`" src="`+relativePackagePath(target, modulePath)+`" />`)
+		}
+		if len(lines) > 0 {
+			tags[target] = strings.Join(lines, "\n")
+		}
+	}
+	return files, tags, diagnostics
+}
+
+// restoreWXSModule recovers the source of a compiled WXS module: the body of
+// its nv_module wrapper without the compiler's nv_ and p_ prefixes.
+func restoreWXSModule(source, name string) string {
+	start := strings.Index(source, wxsModuleStart)
+	if start < 0 {
+		start = 0
+	} else {
+		start += len(wxsModuleStart)
+	}
+	end := strings.LastIndex(source, wxsModuleEnd)
+	if end < start {
+		end = len(source)
+	}
+	code := source[start:end]
+	code = strings.ReplaceAll(code, "p_"+name[:strings.LastIndex(name, "/")+1], "")
+	code = strings.ReplaceAll(code, "nv_", "")
+	code = wxsRequireCall.ReplaceAllString(code, "$1")
+	return strings.TrimSpace(code)
+}
+
+func escapeWXMLAttribute(value string) string {
+	return strings.NewReplacer("&", "&amp;", `"`, "&quot;", "<", "&lt;", ">", "&gt;").Replace(value)
+}
+
+// wxmlElement is a node of the rebuilt tree. Renderer-local function
+// values (the bodies of wx:for items) are kept with tag "gen".
+type wxmlElement struct {
+	tag      string
+	attrs    []wxmlAttr
+	children []*wxmlElement
+	isText   bool
+	text     wxmlValue
+	gen      *jsNode
+}
+
+// wxmlAttr is an attribute; boolean attributes, such as wx:else, have no
+// value.
+type wxmlAttr struct {
+	name    string
+	value   wxmlValue
+	boolean bool
+}
+
+func (e *wxmlElement) setAttr(attr wxmlAttr) {
+	for index := range e.attrs {
+		if e.attrs[index].name == attr.name {
+			e.attrs[index] = attr
+			return
+		}
+	}
+	e.attrs = append(e.attrs, attr)
+}
+
+func (e *wxmlElement) hasAttr(name string) bool {
+	for _, attr := range e.attrs {
+		if attr.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// wxmlTarget is the element a renderer fragment returns into: the root of
+// the file, a wx:if branch or a wx:for item.
+type wxmlTarget struct {
+	name string
+	elem *wxmlElement
+}
+
+type wxmlAnalyzer struct {
+	opcodes *wxmlOpcodes
+	x       []interface{}
+	names   map[string]*wxmlElement
+}
+
+func (a *wxmlAnalyzer) analyze(body []*jsNode, target wxmlTarget, group string) error {
+	for index := 0; index < len(body); index++ {
+		statement := body[index]
+		switch statement.Type {
+		case "EmptyStatement":
+		case "ExpressionStatement":
+			if err := a.analyzeCall(statement.Argument, target, group); err != nil {
+				return err
+			}
+		case "VariableDeclaration":
+			for _, declaration := range statement.Declarations {
+				consumed, err := a.analyzeDeclaration(declaration, body[index+1:], &group)
+				if err != nil {
+					return err
+				}
+				index += consumed
+			}
+		case "IfStatement":
+			if err := a.analyzeCondition(statement, target, group); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected %s in renderer", statement.Type)
+		}
+	}
+	return nil
+}
+
+func (a *wxmlAnalyzer) analyzeCall(expression *jsNode, target wxmlTarget, group string) error {
+	if expression.Type == "AssignmentExpression" && expression.Name == "=" {
+		return nil
+	}
+	if expression.Type != "CallExpression" {
+		return fmt.Errorf("unexpected expression %q", expression.Raw)
+	}
+	callee, args := expression.Callee, expression.Arguments
+	if callee.Type == "MemberExpression" {
+		// cs.push(...) and cs.pop() only track source positions for errors.
+		if callee.Object.Type == "Identifier" && callee.Object.Name == "cs" || !callee.Computed && callee.Property.Name == "pop" {
+			return nil
+		}
+		return fmt.Errorf("unexpected call %q", expression.Raw)
+	}
+	if callee.Type != "Identifier" {
+		return fmt.Errorf("unexpected call %q", expression.Raw)
+	}
+	switch callee.Name {
+	case "_r", "_rz":
+		offset := 0
+		if callee.Name == "_rz" {
+			offset = 1
+		}
+		elem, err := a.element(args, offset)
+		if err != nil {
+			return err
+		}
+		name, err := literalString(args, offset+1)
+		if err != nil {
+			return err
+		}
+		value, err := a.opcode(args, offset+2, group, offset == 1)
+		if err != nil {
+			return err
+		}
+		elem.setAttr(wxmlAttr{name: name, value: value})
+	case "_":
+		parent, err := identifierAt(args, 0)
+		if err != nil {
+			return err
+		}
+		child, err := a.element(args, 1)
+		if err != nil {
+			return err
+		}
+		return a.appendChild(target, parent, child)
+	case "_2", "_2z":
+		return a.analyzeLoop(callee.Name == "_2z", args, group)
+	case "_ic":
+		src, err := a.xPath(args, 0)
+		if err != nil {
+			return err
+		}
+		parent, err := identifierAt(args, 5)
+		if err != nil {
+			return err
+		}
+		return a.appendChild(target, parent, &wxmlElement{tag: "include", attrs: []wxmlAttr{{name: "src", value: wxmlValue{text: src, ok: true}}}})
+	case "_ai":
+		src, err := a.xPath(args, 1)
+		if err != nil {
+			return err
+		}
+		if target.elem == nil {
+			return fmt.Errorf("import outside a template scope")
+		}
+		target.elem.children = append(target.elem.children, &wxmlElement{tag: "import", attrs: []wxmlAttr{{name: "src", value: wxmlValue{text: src, ok: true}}}})
+	case "_af":
+	default:
+		return fmt.Errorf("unknown renderer call %s", callee.Name)
+	}
+	return nil
+}
+
+// analyzeLoop handles _2(z, gen, e, s, gg, elem, item, index, key), the
+// compiled wx:for. gen renders one item and returns into elem.
+func (a *wxmlAnalyzer) analyzeLoop(grouped bool, args []*jsNode, group string) error {
+	offset := 0
+	if grouped {
+		offset = 1
+	}
+	data, err := a.opcode(args, offset, group, grouped)
+	if err != nil {
+		return err
+	}
+	gen, err := a.element(args, offset+1)
+	if err != nil {
+		return err
+	}
+	elem, err := a.element(args, offset+5)
+	if err != nil {
+		return err
+	}
+	item, err := literalString(args, offset+6)
+	if err != nil {
+		return err
+	}
+	index, err := literalString(args, offset+7)
+	if err != nil {
+		return err
+	}
+	if offset+8 >= len(args) {
+		return fmt.Errorf("wx:for is missing its key")
+	}
+	key := strings.Trim(args[offset+8].Raw, `"'`)
+
+	if gen.tag == "gen" {
+		body, err := gen.gen.functionBody()
+		if err != nil {
+			return err
+		}
+		result, statements, err := splitReturn(body)
+		if err != nil {
+			return err
+		}
+		if err := a.analyze(statements, wxmlTarget{name: result, elem: elem}, group); err != nil {
+			return err
+		}
+	}
+	elem.setAttr(wxmlAttr{name: "wx:for", value: data})
+	if index != "index" {
+		elem.setAttr(wxmlAttr{name: "wx:for-index", value: wxmlValue{text: index, ok: true}})
+	}
+	if item != "item" {
+		elem.setAttr(wxmlAttr{name: "wx:for-item", value: wxmlValue{text: item, ok: true}})
+	}
+	if key != "" {
+		elem.setAttr(wxmlAttr{name: "wx:key", value: wxmlValue{text: key, ok: true}})
+	}
+	return nil
+}
+
+// analyzeDeclaration handles `var name = ...`. It returns how many of the
+// following statements it consumed.
+func (a *wxmlAnalyzer) analyzeDeclaration(declaration *jsNode, rest []*jsNode, group *string) (int, error) {
+	init := declaration.Init
+	if init == nil {
+		return 0, fmt.Errorf("declaration of %s has no value", declaration.Name)
+	}
+	switch init.Type {
+	case "FunctionExpression":
+		a.names[declaration.Name] = &wxmlElement{tag: "gen", gen: init}
+		return 0, nil
+	case "MemberExpression":
+		// e_[x[n]].i and .j list the imports and includes of a file.
+		if object := init.Object; object.Type == "MemberExpression" && object.Object.Type == "Identifier" && object.Object.Name == "e_" &&
+			!init.Computed && (init.Property.Name == "i" || init.Property.Name == "j") {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("unexpected member declaration %q", init.Raw)
+	case "CallExpression":
+	default:
+		return 0, fmt.Errorf("unexpected declaration %q", init.Raw)
+	}
+	if init.Callee.Type != "Identifier" {
+		return 0, fmt.Errorf("unexpected declaration %q", init.Raw)
+	}
+	args := init.Arguments
+	switch name := init.Callee.Name; name {
+	case "_n":
+		tag, err := literalString(args, 0)
+		if err != nil {
+			return 0, err
+		}
+		a.names[declaration.Name] = &wxmlElement{tag: tag}
+	case "_v":
+		a.names[declaration.Name] = &wxmlElement{tag: "block"}
+	case "_o", "_oz":
+		grouped := name == "_oz"
+		offset := 0
+		if grouped {
+			offset = 1
+		}
+		text, err := a.opcode(args, offset, *group, grouped)
+		if err != nil {
+			return 0, err
+		}
+		a.names[declaration.Name] = &wxmlElement{tag: "__textNode__", isText: true, text: text}
+	case "_m", "_mz":
+		elem, err := a.staticElement(args, name == "_mz", *group)
+		if err != nil {
+			return 0, err
+		}
+		a.names[declaration.Name] = elem
+	case "_gd":
+		return 1, a.analyzeTemplateUse(args, rest, *group)
+	default:
+		if !strings.HasPrefix(name, "gz$gwx") {
+			return 0, fmt.Errorf("unknown renderer call %s", name)
+		}
+		// `var z=gz$gwx_1()` selects the renderer's opcode table.
+		*group = strings.TrimPrefix(name, "gz$gwx")
+	}
+	return 0, nil
+}
+
+// staticElement handles _m(tag, [name, offset, ...], [], ...), an element
+// whose attributes are all static. The first non-zero offset is absolute
+// and the following ones are relative to it; a negative slot marks a
+// boolean attribute.
+func (a *wxmlAnalyzer) staticElement(args []*jsNode, grouped bool, group string) (*wxmlElement, error) {
+	offset := 0
+	if grouped {
+		offset = 1
+	}
+	tag, err := literalString(args, offset)
+	if err != nil {
+		return nil, err
+	}
+	if offset+2 >= len(args) || args[offset+1].Type != "ArrayExpression" || args[offset+2].Type != "ArrayExpression" {
+		return nil, fmt.Errorf("malformed static element %s", tag)
+	}
+	if len(args[offset+2].Elements) > 0 {
+		return nil, fmt.Errorf("generic content on static element %s", tag)
+	}
+	elem := &wxmlElement{tag: tag}
+	name := ""
+	base := 0
+	for position, item := range args[offset+1].Elements {
+		if position%2 == 0 {
+			if name, err = literalString(args[offset+1].Elements, position); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		slot, err := literalInt(item)
+		if err != nil {
+			return nil, err
+		}
+		if base+slot < 0 {
+			elem.setAttr(wxmlAttr{name: name, boolean: true})
+			continue
+		}
+		elem.setAttr(wxmlAttr{name: name, value: a.opcodes.lookup(group, base+slot, grouped)})
+		if base == 0 {
+			base = slot
+		}
+	}
+	return elem, nil
+}
+
+// analyzeTemplateUse handles `var t=_gd(x[n], is, e_, d_)`, a
+// <template is="..."> use. The following if statement passes the data
+// (`_1(z, ...)`) and marks the element it renders into with wxXCkey.
+func (a *wxmlAnalyzer) analyzeTemplateUse(args []*jsNode, rest []*jsNode, group string) error {
+	is, err := a.element(args, 1)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 || rest[0].Type != "IfStatement" {
+		return fmt.Errorf("template use without a render branch")
+	}
+	var data *wxmlValue
+	owner := ""
+	for _, statement := range blockBody(rest[0].Consequent) {
+		switch statement.Type {
+		case "VariableDeclaration":
+			for _, declaration := range statement.Declarations {
+				init := declaration.Init
+				if init == nil || init.Type != "LogicalExpression" || init.Left.Type != "CallExpression" || init.Left.Callee.Type != "Identifier" {
+					continue
+				}
+				switch init.Left.Callee.Name {
+				case "_1":
+					value, err := a.opcode(init.Left.Arguments, 0, group, false)
+					if err != nil {
+						return err
+					}
+					data = &value
+				case "_1z":
+					value, err := a.opcode(init.Left.Arguments, 1, group, true)
+					if err != nil {
+						return err
+					}
+					data = &value
+				}
+			}
+		case "ExpressionStatement":
+			assignment := statement.Argument
+			if assignment.Type == "AssignmentExpression" && assignment.Name == "=" && assignment.Left.Type == "MemberExpression" &&
+				!assignment.Left.Computed && assignment.Left.Property.Name == "wxXCkey" && assignment.Left.Object.Type == "Identifier" {
+				owner = assignment.Left.Object.Name
+			}
+		}
+	}
+	elem := a.names[owner]
+	if elem == nil {
+		return fmt.Errorf("template use has no target element")
+	}
+	elem.tag = "template"
+	elem.setAttr(wxmlAttr{name: "is", value: is.text})
+	if data != nil {
+		elem.setAttr(wxmlAttr{name: "data", value: *data})
+	}
+	return nil
+}
+
+// analyzeCondition handles `if(_o(z, n, ...)){...} else if ... else ...`,
+// the compiled wx:if chain. Each branch becomes a block carrying its
+// condition, appended to the element named in the branch's first statement.
+func (a *wxmlAnalyzer) analyzeCondition(statement *jsNode, target wxmlTarget, group string) error {
+	parent := ""
+	for branch := statement; branch != nil; {
+		var attr wxmlAttr
+		var body []*jsNode
+		if branch.Type == "IfStatement" {
+			test := branch.Test
+			if test.Type != "CallExpression" || test.Callee.Type != "Identifier" || test.Callee.Name != "_o" && test.Callee.Name != "_oz" {
+				return fmt.Errorf("unexpected condition %q", test.Raw)
+			}
+			grouped := test.Callee.Name == "_oz"
+			offset := 0
+			if grouped {
+				offset = 1
+			}
+			value, err := a.opcode(test.Arguments, offset, group, grouped)
+			if err != nil {
+				return err
+			}
+			attr = wxmlAttr{name: "wx:elif", value: value}
+			if branch == statement {
+				attr.name = "wx:if"
+			}
+			body = blockBody(branch.Consequent)
+		} else {
+			attr = wxmlAttr{name: "wx:else", boolean: true}
+			body = blockBody(branch)
+		}
+		if parent == "" {
+			if len(body) == 0 || body[0].Type != "ExpressionStatement" || body[0].Argument.Type != "AssignmentExpression" ||
+				body[0].Argument.Left.Type != "MemberExpression" || body[0].Argument.Left.Object.Type != "Identifier" {
+				return fmt.Errorf("condition without a target element")
+			}
+			parent = body[0].Argument.Left.Object.Name
+		}
+		block := &wxmlElement{tag: "block", attrs: []wxmlAttr{attr}}
+		if err := a.analyze(body, wxmlTarget{name: parent, elem: block}, group); err != nil {
+			return err
+		}
+		if err := a.appendChild(target, parent, block); err != nil {
+			return err
+		}
+		if branch.Type != "IfStatement" {
+			break
+		}
+		branch = branch.Alternate
+	}
+	return nil
+}
+
+func (a *wxmlAnalyzer) appendChild(target wxmlTarget, parent string, child *wxmlElement) error {
+	if target.elem != nil && target.name == parent {
+		target.elem.children = append(target.elem.children, child)
+		return nil
+	}
+	elem := a.names[parent]
+	if elem == nil {
+		return fmt.Errorf("unknown element %s", parent)
+	}
+	elem.children = append(elem.children, child)
+	return nil
+}
+
+func (a *wxmlAnalyzer) element(args []*jsNode, index int) (*wxmlElement, error) {
+	name, err := identifierAt(args, index)
+	if err != nil {
+		return nil, err
+	}
+	elem := a.names[name]
+	if elem == nil {
+		return nil, fmt.Errorf("unknown element %s", name)
+	}
+	return elem, nil
+}
+
+func (a *wxmlAnalyzer) opcode(args []*jsNode, index int, group string, grouped bool) (wxmlValue, error) {
+	if index >= len(args) {
+		return wxmlValue{}, fmt.Errorf("missing opcode index")
+	}
+	slot, err := literalInt(args[index])
+	if err != nil {
+		return wxmlValue{}, err
+	}
+	return a.opcodes.lookup(group, slot, grouped), nil
+}
+
+// xPath reads x[n], the compiler's table of file paths.
+func (a *wxmlAnalyzer) xPath(args []*jsNode, index int) (string, error) {
+	if index >= len(args) || args[index].Type != "MemberExpression" || !args[index].Computed {
+		return "", fmt.Errorf("expected a path table reference")
+	}
+	slot, err := literalInt(args[index].Property)
+	if err != nil {
+		return "", err
+	}
+	if slot < 0 || slot >= len(a.x) {
+		return "", fmt.Errorf("path table index %d out of range", slot)
+	}
+	value, ok := a.x[slot].(string)
+	if !ok {
+		return "", fmt.Errorf("path table entry %d is not a string", slot)
+	}
+	return value, nil
+}
+
+func blockBody(node *jsNode) []*jsNode {
+	if node == nil {
+		return nil
+	}
+	if node.Type == "BlockStatement" {
+		return node.Body
+	}
+	return []*jsNode{node}
+}
+
+// splitReturn separates the trailing `return name` of a renderer body from
+// the statements before it.
+func splitReturn(body []*jsNode) (string, []*jsNode, error) {
+	if len(body) == 0 {
+		return "", nil, fmt.Errorf("empty renderer")
+	}
+	last := body[len(body)-1]
+	if last.Type != "ReturnStatement" || last.Argument == nil || last.Argument.Type != "Identifier" {
+		return "", nil, fmt.Errorf("renderer does not return an element")
+	}
+	return last.Argument.Name, body[:len(body)-1], nil
+}
+
+func identifierAt(args []*jsNode, index int) (string, error) {
+	if index >= len(args) || args[index].Type != "Identifier" {
+		return "", fmt.Errorf("expected an element name")
+	}
+	return args[index].Name, nil
+}
+
+func literalString(args []*jsNode, index int) (string, error) {
+	if index < len(args) && args[index] != nil && args[index].Type == "Literal" {
+		if text, ok := args[index].Value.(string); ok {
+			return text, nil
+		}
+	}
+	return "", fmt.Errorf("expected a string literal")
+}
+
+func literalInt(node *jsNode) (int, error) {
+	negative := false
+	if node != nil && node.Type == "UnaryExpression" && node.Name == "-" {
+		negative, node = true, node.Argument
+	}
+	if node == nil || node.Type != "Literal" {
+		return 0, fmt.Errorf("expected a number literal")
+	}
+	number, ok := node.Value.(float64)
+	if !ok || number != float64(int(number)) {
+		return 0, fmt.Errorf("expected an integer literal")
+	}
+	if negative {
+		return -int(number), nil
+	}
+	return int(number), nil
+}
+
+// wxmlWriter renders rebuilt trees and counts what could not be restored.
+type wxmlWriter struct {
+	textCount      int
+	attributeCount int
+	eventCount     int
+	attributes     map[string]int
+}
+
+// wxmlPiece is rendered output; text pieces pull the surrounding tags onto
+// their line so no whitespace is added around text content.
+type wxmlPiece struct {
+	text   string
+	isText bool
+}
+
+// render rebuilds a file: its templates first, then the renderer's tree.
+func (w *wxmlWriter) render(renderer *jsFunction, templates *jsObject, analyzer *wxmlAnalyzer) (string, error) {
+	var out strings.Builder
+	if templates != nil {
+		for _, name := range templates.keys {
+			function, ok := templates.values[name].(*jsFunction)
+			if !ok || function.node == nil {
+				continue
+			}
+			body, err := function.node.functionBody()
+			if err != nil {
+				return "", fmt.Errorf("template %s: %w", name, err)
+			}
+			result, statements, err := splitReturn(body)
+			if err != nil {
+				return "", fmt.Errorf("template %s: %w", name, err)
+			}
+			template := &wxmlElement{tag: "template", attrs: []wxmlAttr{{name: "name", value: wxmlValue{text: name, ok: true}}}}
+			if err := analyzer.analyzeRoot(templateStatements(statements), result, template); err != nil {
+				return "", fmt.Errorf("template %s: %w", name, err)
+			}
+			out.WriteString(w.element(template, 0).text)
+		}
+	}
+
+	body, err := renderer.node.functionBody()
+	if err != nil {
+		return "", err
+	}
+	result, statements, err := splitReturn(body)
+	if err != nil {
+		return "", err
+	}
+	root := &wxmlElement{}
+	if err := analyzer.analyzeRoot(statements, result, root); err != nil {
+		return "", err
+	}
+	for _, child := range root.children {
+		out.WriteString(w.element(child, 0).text)
+	}
+	content := out.String()
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content, nil
+}
+
+func (a *wxmlAnalyzer) analyzeRoot(statements []*jsNode, result string, root *wxmlElement) error {
+	a.names = map[string]*wxmlElement{result: root}
+	return a.analyze(statements, wxmlTarget{name: result, elem: root}, "0")
+}
+
+// templateStatements keeps what a template renderer builds: its opcode
+// table selection and the body of its try block. The rest guards against
+// recursive template use.
+func templateStatements(body []*jsNode) []*jsNode {
+	var statements []*jsNode
+	for _, statement := range body {
+		switch {
+		case statement.Type == "VariableDeclaration" && len(statement.Declarations) == 1 && statement.Declarations[0].Init != nil &&
+			statement.Declarations[0].Init.Type == "CallExpression" && statement.Declarations[0].Init.Callee.Type == "Identifier" &&
+			strings.HasPrefix(statement.Declarations[0].Init.Callee.Name, "gz$gwx"):
+			statements = append(statements, statement)
+		case statement.Type == "TryStatement":
+			return append(statements, statement.Body...)
+		}
+	}
+	return body
+}
+
+func (w *wxmlWriter) element(elem *wxmlElement, depth int) wxmlPiece {
+	indent := strings.Repeat("    ", depth)
+	if elem.isText {
+		if !elem.text.ok {
+			w.textCount++
+			return wxmlPiece{text: wxmlUnresolvedText, isText: true}
+		}
+		return wxmlPiece{text: elem.text.text, isText: true}
+	}
+	if elem.tag == "block" {
+		if len(elem.children) == 1 && !elem.children[0].isText {
+			if child := elem.children[0]; canMergeBlock(elem, child) {
+				child.attrs = append(append([]wxmlAttr{}, elem.attrs...), child.attrs...)
+				return w.element(child, depth)
+			}
+		} else if len(elem.attrs) == 0 {
+			pieces := make([]wxmlPiece, 0, len(elem.children))
+			for _, child := range elem.children {
+				pieces = append(pieces, w.element(child, depth))
+			}
+			return wxmlPiece{text: trimMerge(pieces)}
+		}
+	}
+
+	var open strings.Builder
+	var unresolved bool
+	open.WriteString(indent + "<" + elem.tag)
+	for _, attr := range elem.attrs {
+		switch {
+		case attr.boolean:
+			open.WriteString(" " + attr.name)
+		case !attr.value.ok:
+			unresolved = true
+			w.attributeCount++
+			w.attributes[attr.name]++
+			if wxmlEventAttribute.MatchString(attr.name) {
+				w.eventCount++
+			}
+		default:
+			open.WriteString(" " + attr.name + `="` + strings.ReplaceAll(attr.value.text, `"`, `\"`) + `"`)
+		}
+	}
+	prefix := ""
+	if unresolved {
+		prefix = indent + wxmlUnresolvedAttributes + "\n"
+	}
+	if len(elem.children) == 0 {
+		return wxmlPiece{text: prefix + open.String() + "></" + elem.tag + ">\n"}
+	}
+	pieces := []wxmlPiece{{text: prefix + open.String() + ">\n"}}
+	for _, child := range elem.children {
+		pieces = append(pieces, w.element(child, depth+1))
+	}
+	pieces = append(pieces, wxmlPiece{text: indent + "</" + elem.tag + ">\n"})
+	return wxmlPiece{text: trimMerge(pieces)}
+}
+
+// canMergeBlock reports whether a block's attributes can move onto its only
+// child. A wx:for or wx:if child under a conditional block keeps the
+// block, since one tag cannot carry both conditions.
+func canMergeBlock(block, child *wxmlElement) bool {
+	for _, attr := range block.attrs {
+		if child.hasAttr(attr.name) {
+			return false
+		}
+	}
+	childControl := child.hasAttr("wx:for") || child.hasAttr("wx:if")
+	blockCondition := block.hasAttr("wx:if") || block.hasAttr("wx:elif") || block.hasAttr("wx:else")
+	return !(childControl && blockCondition)
+}
+
+func trimMerge(pieces []wxmlPiece) string {
+	var out strings.Builder
+	inText := false
+	for _, piece := range pieces {
+		text := piece.text
+		switch {
+		case piece.isText && !inText:
+			inText = true
+			trimmed := strings.TrimRight(out.String(), " \t\r\n")
+			out.Reset()
+			out.WriteString(trimmed)
+		case !piece.isText && inText:
+			inText = false
+			text = strings.TrimLeft(text, " \t\r\n")
+		}
+		out.WriteString(text)
+	}
+	return out.String()
+}
+
+// diagnostic summarizes the fragments of one file that were omitted.
+func (w *wxmlWriter) diagnostic(file string) (pkg.Diagnostic, bool) {
+	count := w.textCount + w.attributeCount
+	if count == 0 {
+		return pkg.Diagnostic{}, false
+	}
+	diagnostic := pkg.Warn("recover.wxml.unresolved_fragments", fmt.Sprintf("该文件有 %d 处运行时值无法安全静态还原（文本 %d，属性 %d）；已省略并用不渲染的注释标记，原始运行时代码仍保留", count, w.textCount, w.attributeCount), "recovering_wxml", file)
+	diagnostic.Metadata = map[string]interface{}{
+		"count":               count,
+		"textCount":           w.textCount,
+		"attributeCount":      w.attributeCount,
+		"eventAttributeCount": w.eventCount,
+		"attributes":          w.attributes,
+		"marker":              "seewx-recovery",
+	}
+	return diagnostic, true
+}
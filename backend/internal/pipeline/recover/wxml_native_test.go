@@ -0,0 +1,242 @@
+package recover
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+// groupedPageFrame is shaped like the page-frame.js of a 4.x build: one
+// opcode table per file, templates, imports, includes and WXS modules.
+var groupedPageFrame = strings.Join([]string{
+	"var __WXML_GLOBAL__={ops_cached:{},ops_set:{},ops_init:{},total_ops:0,defines:{},modules:{},entrys:{}};",
+	"function gz$gwx_1(){",
+	"if( __WXML_GLOBAL__.ops_cached.$gwx_1)return __WXML_GLOBAL__.ops_cached.$gwx_1",
+	"__WXML_GLOBAL__.ops_cached.$gwx_1=[];",
+	"(function(z){var a=11;function Z(ops){z.push(ops)}",
+	"Z([[7],[3,'items']])",
+	"Z([3,'card'])",
+	"Z([[2,'=='],[[7],[3,'mode']],[1,'grid']])",
+	"Z([[7],[3,'loading']])",
+	"Z([a,[3,'Item '],[[6],[[7],[3,'entry']],[3,'name']]])",
+	"Z([3,'empty'])",
+	"Z([3,'tip'])",
+	"Z([[8],'text',[[7],[3,'message']]])",
+	"Z([3,'Search'])",
+	"Z(runtimeValue())",
+	"})(__WXML_GLOBAL__.ops_cached.$gwx_1);return __WXML_GLOBAL__.ops_cached.$gwx_1",
+	"}",
+	"function gz$gwx_2(){",
+	"if( __WXML_GLOBAL__.ops_cached.$gwx_2)return __WXML_GLOBAL__.ops_cached.$gwx_2",
+	"__WXML_GLOBAL__.ops_cached.$gwx_2=[];",
+	"(function(z){var a=11;function Z(ops){z.push(ops)}",
+	"Z([[7],[3,'text']])",
+	"})(__WXML_GLOBAL__.ops_cached.$gwx_2);return __WXML_GLOBAL__.ops_cached.$gwx_2",
+	"}",
+	"function np_0(){var nv_module={nv_exports:{}};nv_module.nv_exports.nv_upper=function(nv_s){return nv_s.nv_toUpperCase()};return nv_module.nv_exports;}",
+	"function np_1(){var nv_module={nv_exports:{}};nv_module.nv_exports.nv_size=function(nv_list){return nv_list.nv_length};return nv_module.nv_exports;}",
+	`var nv_require=function(){var nnm={"p_./utils/fmt.wxs":np_0,"m_./pages/list/index.wxml:tools":np_1,};var nom={};return function(n){return function(){if(!nnm[n]) return undefined;try{if(!nom[n])nom[n]=nnm[n]();return nom[n];}catch(e){throw e}}}}()`,
+	"var x=['./pages/list/index.wxml','./common/card.wxml','./common/footer.wxml'];",
+	"f_['./utils/fmt.wxs']=nv_require('p_./utils/fmt.wxs');",
+	"f_['./pages/list/index.wxml']={};",
+	"f_['./pages/list/index.wxml']['fmt']=f_['./utils/fmt.wxs'] || nv_require('p_./utils/fmt.wxs');",
+	"f_['./pages/list/index.wxml']['tools']=nv_require('m_./pages/list/index.wxml:tools');",
+	"var m0=function(e,s,r,gg){",
+	"var z=gz$gwx_1()",
+	"var oQ=e_[x[0]].i",
+	"_ai(oQ,x[1],e_,x[0],1,1)",
+	"var oB=_n('view')",
+	"_rz(z,oB,'class',1,e,s,gg)",
+	"_rz(z,oB,'bindtap',9,e,s,gg)",
+	"var xC=_v()",
+	"_(oB,xC)",
+	"var oD=function(fE,eD,gF,gg){",
+	"var hG=_n('text')",
+	"var oH=_oz(z,4,fE,eD,gg)",
+	"_(hG,oH)",
+	"_(gF,hG)",
+	"return gF",
+	"}",
+	"xC.wxXCkey=2",
+	"_2z(z,0,oD,e,s,gg,xC,'entry','index','id')",
+	"var cI=_v()",
+	"_(oB,cI)",
+	"if(_oz(z,2,e,s,gg)){cI.wxVkey=1",
+	"var oJ=_n('view')",
+	"_rz(z,oJ,'class',1,e,s,gg)",
+	"_(cI,oJ)",
+	"}",
+	"else if(_oz(z,3,e,s,gg)){cI.wxVkey=2",
+	"var lK=_n('text')",
+	"var aL=_oz(z,9,e,s,gg)",
+	"_(lK,aL)",
+	"_(cI,lK)",
+	"}",
+	"else{cI.wxVkey=3",
+	"var tM=_n('view')",
+	"_rz(z,tM,'class',5,e,s,gg)",
+	"_(cI,tM)",
+	"}",
+	"cI.wxXCkey=1",
+	"var oR=_mz(z,'input',['disabled',-1,'placeholder',8],[],e,s,gg)",
+	"_(oB,oR)",
+	"var oP=_v()",
+	"_(oB,oP)",
+	"var cS=_oz(z,6,e,s,gg)",
+	"var eN=_gd(x[0],cS,e_,d_)",
+	"if(eN){",
+	"var bO=_1z(z,7,e,s,gg) || {}",
+	"var cur_globalf=gg.f",
+	"oP.wxXCkey=3",
+	"eN(bO,bO,oP,gg)",
+	"gg.f=cur_globalf",
+	"}",
+	"else _w(cS,x[0],1,0)",
+	"_ic(x[2],e_,x[0],e,s,oB,gg)",
+	"_(r,oB)",
+	"oQ.pop()",
+	"return r",
+	"}",
+	"e_[x[0]]={f:m0,j:[],i:[],ti:[],ic:[]}",
+	"d_[x[1]]={}",
+	"d_[x[1]]['tip']=function(e,s,r,gg){",
+	"var z=gz$gwx_2()",
+	"var b=x[1]",
+	"if(p_[b]){_wl(b,x[1]);return}",
+	"p_[b]=true",
+	"try{",
+	"var oB=_n('view')",
+	"var xC=_oz(z,0,e,s,gg)",
+	"_(oB,xC)",
+	"_(r,oB)",
+	"}catch(err){",
+	"p_[b]=false",
+	"throw err",
+	"}",
+	"p_[b]=false",
+	"return r",
+	"}",
+	"var m1=function(e,s,r,gg){",
+	"var z=gz$gwx_2()",
+	"return r",
+	"}",
+	"e_[x[1]]={f:m1,j:[],i:[],ti:[],ic:[]}",
+	"if(path&&e_[path]){window.__wxml_comp_version__=0.02",
+	"return function(env,dd,global){$gwxc=0;var root={\"tag\":\"wx-page\"};root.children=[]",
+	"}",
+}, "\n")
+
+func TestRecoverWXMLRebuildsGroupedRendererRegistry(t *testing.T) {
+	outDir := t.TempDir()
+	writeRecoveryReportFixture(t, outDir, "utils/fmt.wxs", "module.exports = {};\n")
+	normalized := &pkg.NormalizedPackage{
+		Pages:   []pkg.PageIR{{Path: "pages/list/index"}},
+		Scripts: []pkg.ScriptIR{{Path: "page-frame.js", Content: groupedPageFrame, Source: "runtime"}},
+	}
+
+	result, err := RecoverWXML(normalized, outDir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || !result.Partial {
+		t.Fatalf("unresolved slots should leave a partial success: %#v", result)
+	}
+
+	page := readRecoveredStylesheet(t, outDir, "pages/list/index.wxml")
+	for _, want := range []string{
+		`<import src="./common/card.wxml"></import>`,
+		`<view class="card">`,
+		`<text wx:for="{{items}}" wx:for-item="entry" wx:key="id">Item {{entry.name}}</text>`,
+		`<view wx:if="{{mode=='grid'}}" class="card"></view>`,
+		`<text wx:elif="{{loading}}">` + wxmlUnresolvedText + `</text>`,
+		`<view wx:else class="empty"></view>`,
+		`<input disabled placeholder="Search"></input>`,
+		`<template is="tip" data="{{text:message}}"></template>`,
+		`<include src="./common/footer.wxml"></include>`,
+		`<wxs module="fmt" src="../../utils/fmt.wxs" />`,
+		"<wxs module=\"tools\">\nmodule.exports.size=function(list){return list.length};\n</wxs>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Fatalf("page is missing %q:\n%s", want, page)
+		}
+	}
+	if !strings.Contains(page, wxmlUnresolvedAttributes+"\n<view class=\"card\">") || strings.Contains(page, "bindtap") {
+		t.Fatalf("unresolved attribute was not omitted:\n%s", page)
+	}
+
+	card := readRecoveredStylesheet(t, outDir, "common/card.wxml")
+	if card != "<template name=\"tip\">\n    <view>{{text}}</view>\n</template>\n" {
+		t.Fatalf("unexpected card.wxml:\n%s", card)
+	}
+	if kept := readRecoveredStylesheet(t, outDir, "utils/fmt.wxs"); kept != "module.exports = {};\n" {
+		t.Fatalf("packaged module was overwritten: %q", kept)
+	}
+
+	sources := map[string]string{}
+	for _, file := range result.Files {
+		sources[file.Path] = file.Kind
+	}
+	if sources["pages/list/index.wxml"] != "wxml" || sources["common/card.wxml"] != "wxml" || len(result.Files) != 2 {
+		t.Fatalf("unexpected recovered files: %#v", result.Files)
+	}
+	var unresolved *pkg.Diagnostic
+	for index := range result.Diagnostics {
+		switch result.Diagnostics[index].Code {
+		case "recover.wxml.unresolved_fragments":
+			unresolved = &result.Diagnostics[index]
+		case "recover.wxml.page.missing_runtime":
+			t.Fatalf("rebuilt page was still reported missing: %#v", result.Diagnostics)
+		}
+	}
+	if unresolved == nil || unresolved.File != "pages/list/index.wxml" {
+		t.Fatalf("missing unresolved fragment diagnostic: %#v", result.Diagnostics)
+	}
+	if unresolved.Metadata["textCount"] != 1 || unresolved.Metadata["attributeCount"] != 1 || unresolved.Metadata["eventAttributeCount"] != 1 {
+		t.Fatalf("unexpected unresolved counts: %#v", unresolved.Metadata)
+	}
+}
+
+func TestRecoverWXMLRebuildsClassicPageFrame(t *testing.T) {
+	outDir := t.TempDir()
+	frame := strings.Join([]string{
+		"<!doctype html><html><body><script>",
+		"var z = __WXML_GLOBAL__.ops_set.$gwx || [];",
+		"(function(z){var a=11;function Z(ops){z.push(ops)}",
+		"Z([3,'hello'])",
+		"})(z);__WXML_GLOBAL__.ops_set.$gwx=z;",
+		"var nv_require=function(){var nnm={};return function(n){return nnm[n]}}()",
+		"var x=['./pages/index/index.wxml'];d_[x[0]]={}",
+		"var m0=function(e,s,r,gg){",
+		"var oB=_n('view')",
+		"var xC=_o(0,e,s,gg)",
+		"_(oB,xC)",
+		"_(r,oB)",
+		"return r",
+		"}",
+		"e_[x[0]]={f:m0,j:[],i:[],ti:[],ic:[]}",
+		"e_['../escape.wxml']={f:m0,j:[],i:[],ti:[],ic:[]}",
+		"if(path&&e_[path]){return function(){}}",
+		"</script></body></html>",
+	}, "\n")
+	normalized := &pkg.NormalizedPackage{
+		Pages:     []pkg.PageIR{{Path: "pages/index/index"}},
+		Templates: []pkg.TemplateIR{{Path: "page-frame.html", Content: frame}},
+	}
+
+	result, err := RecoverWXML(normalized, outDir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page := readRecoveredStylesheet(t, outDir, "pages/index/index.wxml"); page != "<view>hello</view>\n" {
+		t.Fatalf("unexpected page:\n%s", page)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(outDir), "escape.wxml")); err == nil {
+		t.Fatal("escaping renderer path was written")
+	}
+	if !result.Partial || result.Recovered != 1 || result.Native != 1 {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+}
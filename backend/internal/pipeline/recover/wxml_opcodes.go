@@ -0,0 +1,585 @@
+package recover
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The WXML compiler moves every attribute value and text node of a package
+// into the $gwx constant table, filled by an IIFE of Z([...]) calls. This is
+// a port of wxappUnpacker/wuRestoreZ.js: the builder's straight-line calls
+// are folded statically and each opcode is turned back into the WXML
+// expression it was compiled from.
+
+const (
+	wxmlOpcodeBuilder     = "(function(z){var a=11;function Z(ops){z.push(ops)}"
+	wxmlGroupBuilder      = "(function(z){var a=11;"
+	wxmlGroupBuilderEnd   = "})(__WXML_GLOBAL__.ops_cached.$gwx"
+	wxmlOpcodeTableSet    = "(z);__WXML_GLOBAL__.ops_set.$gwx=z;"
+	wxmlOpcodeTableSetAlt = "(z);__WXML_GLOBAL__.ops_set.$gwx"
+)
+
+var (
+	// 4.x builds keep one table per page or component, built on first use
+	// by gz$gwx_1() or gz$gwx12_34(); renderers pick theirs by that suffix.
+	wxmlGroupPattern   = regexp.MustCompile(`function gz\$gwx([a-zA-Z0-9_]+)\(\)\{\s*if\( __WXML_GLOBAL__\.ops_cached\.\$gwx`)
+	wxmlIdentifierExpr = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// wxmlOpcodes is the restored table of one frame: a single flat table in
+// classic builds, or one table per gz$gwx suffix in grouped builds.
+type wxmlOpcodes struct {
+	flat   []wxmlValue
+	groups map[string][]wxmlValue
+}
+
+// wxmlValue is one restored slot. ok is false for a slot that is missing or
+// whose opcode could not be folded statically.
+type wxmlValue struct {
+	text string
+	ok   bool
+}
+
+// unresolvedOpcode keeps the position of a slot that could not be folded,
+// so later z[n] references do not shift onto unrelated opcodes.
+type unresolvedOpcode struct {
+	reason string
+}
+
+func (z *wxmlOpcodes) lookup(group string, index int, grouped bool) wxmlValue {
+	table := z.flat
+	if grouped {
+		table = z.groups[group]
+	}
+	if index < 0 || index >= len(table) {
+		return wxmlValue{}
+	}
+	return table[index]
+}
+
+// extractWXMLOpcodes restores the constant tables of a frame. Problems are
+// returned as messages; a table that cannot be read stays empty, so the
+// slots it would have filled render as unresolved.
+func extractWXMLOpcodes(code string) (*wxmlOpcodes, []string) {
+	var problems []string
+	if matches := wxmlGroupPattern.FindAllStringSubmatchIndex(code, -1); len(matches) > 0 {
+		opcodes := &wxmlOpcodes{groups: map[string][]wxmlValue{}}
+		for _, match := range matches {
+			suffix := code[match[2]:match[3]]
+			content := code[match[0]:]
+			start := strings.Index(content, wxmlGroupBuilder)
+			if start < 0 {
+				problems = append(problems, fmt.Sprintf("$gwx%s: opcode builder not found", suffix))
+				continue
+			}
+			content = content[start:]
+			end := strings.Index(content, wxmlGroupBuilderEnd)
+			if end < 0 {
+				problems = append(problems, fmt.Sprintf("$gwx%s: opcode builder not closed", suffix))
+				continue
+			}
+			values, err := collectStaticOpcodes(content[:end] + "})(z);")
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("$gwx%s: %v", suffix, err))
+				continue
+			}
+			opcodes.groups[suffix] = restoreOpcodeTable(values)
+		}
+		return opcodes, problems
+	}
+
+	end := strings.LastIndex(code, wxmlOpcodeTableSet)
+	if end < 0 {
+		end = strings.LastIndex(code, wxmlOpcodeTableSetAlt)
+	}
+	start := strings.LastIndex(code, wxmlOpcodeBuilder)
+	if start < 0 || end < start {
+		return &wxmlOpcodes{}, []string{"opcode table not found"}
+	}
+	values, err := collectStaticOpcodes(code[start : end+4])
+	if err != nil {
+		return &wxmlOpcodes{}, []string{err.Error()}
+	}
+	return &wxmlOpcodes{flat: restoreOpcodeTable(values)}, nil
+}
+
+// collectStaticOpcodes folds the Z(...) calls of one builder IIFE. Only its
+// direct statements count: a call nested in a function or branch is not
+// known to run and must never take a slot.
+func collectStaticOpcodes(code string) ([]interface{}, error) {
+	program, err := parseJSProgram(code)
+	if err != nil {
+		return nil, err
+	}
+	var body []*jsNode
+	candidates := 0
+	for _, statement := range program {
+		if statement.Type != "ExpressionStatement" || statement.Argument.Type != "CallExpression" {
+			continue
+		}
+		builder := statement.Argument.Callee
+		if builder.Type != "FunctionExpression" || len(builder.Params) != 1 || builder.Params[0] != "z" {
+			continue
+		}
+		statements, err := builder.functionBody()
+		if err != nil {
+			return nil, err
+		}
+		declarations := 0
+		for _, item := range statements {
+			if item.Type == "FunctionDeclaration" && item.Name == "Z" {
+				declarations++
+			}
+		}
+		if declarations == 1 {
+			body = statements
+			candidates++
+		}
+	}
+	if candidates != 1 {
+		return nil, fmt.Errorf("expected one opcode builder, found %d", candidates)
+	}
+
+	values := []interface{}{}
+	scope := newJSScope(nil)
+	scope.vars["z"] = values
+statements:
+	for _, statement := range body {
+		switch statement.Type {
+		case "FunctionDeclaration", "EmptyStatement":
+			continue
+		case "ReturnStatement":
+			break statements
+		case "VariableDeclaration":
+			for _, declaration := range statement.Declarations {
+				if declaration.Init == nil {
+					return nil, fmt.Errorf("unsupported opcode builder declaration")
+				}
+				if declaration.Name == "z" || declaration.Name == "Z" {
+					return nil, fmt.Errorf("unsafe opcode builder binding %s", declaration.Name)
+				}
+				value, err := evalStatic(declaration.Init, scope)
+				if err != nil {
+					return nil, err
+				}
+				scope.vars[declaration.Name] = value
+			}
+			continue
+		case "IfStatement":
+			if test, err := evalStatic(statement.Test, scope); err == nil && test == false && statement.Alternate == nil {
+				continue
+			}
+			return nil, fmt.Errorf("unsupported opcode builder branch")
+		case "ExpressionStatement":
+		default:
+			return nil, fmt.Errorf("unsupported opcode builder statement %s", statement.Type)
+		}
+		call := statement.Argument
+		if call.Type != "CallExpression" || call.Callee.Type != "Identifier" || call.Callee.Name != "Z" || len(call.Arguments) == 0 {
+			return nil, fmt.Errorf("unsupported executable statement in opcode builder")
+		}
+		value, err := evalStatic(call.Arguments[0], scope)
+		switch {
+		case err != nil:
+			values = append(values, &unresolvedOpcode{reason: err.Error()})
+		case containsUnresolvedOpcode(value):
+			values = append(values, &unresolvedOpcode{reason: "depends on an unresolved opcode"})
+		default:
+			values = append(values, value)
+		}
+		scope.vars["z"] = values
+	}
+	if a, _ := scope.lookup("a"); a != 11.0 {
+		return nil, fmt.Errorf("unexpected value-list opcode")
+	}
+	return values, nil
+}
+
+func containsUnresolvedOpcode(value interface{}) bool {
+	switch typed := value.(type) {
+	case *unresolvedOpcode:
+		return true
+	case []interface{}:
+		for _, item := range typed {
+			if containsUnresolvedOpcode(item) {
+				return true
+			}
+		}
+	case *jsObject:
+		for _, key := range typed.keys {
+			if containsUnresolvedOpcode(typed.values[key]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func restoreOpcodeTable(values []interface{}) []wxmlValue {
+	table := make([]wxmlValue, 0, len(values))
+	for _, value := range values {
+		if _, ok := value.(*unresolvedOpcode); ok {
+			table = append(table, wxmlValue{})
+			continue
+		}
+		text, _, err := restoreOpcode(value, false)
+		table = append(table, wxmlValue{text: text, ok: err == nil})
+	}
+	return table
+}
+
+// restoreOpcode turns one opcode back into WXML. Outside an expression
+// (withScope false) the result is wrapped in {{ }}. isVar marks a [7,[3,name]]
+// variable read, which a following [6,...] member access indexes with [].
+func restoreOpcode(ops interface{}, withScope bool) (string, bool, error) {
+	if ops == nil {
+		return "", false, nil
+	}
+	list, ok := ops.([]interface{})
+	if !ok || len(list) == 0 {
+		return "", false, fmt.Errorf("malformed opcode %v", ops)
+	}
+	next := func(index int) (string, error) {
+		if index >= len(list) {
+			return "", nil
+		}
+		text, _, err := restoreOpcode(list[index], true)
+		return text, err
+	}
+
+	vop, isVop := list[0].([]interface{})
+	if !isVop {
+		switch list[0] {
+		case 3.0:
+			// A plain string.
+			if len(list) < 2 {
+				return "", false, nil
+			}
+			return jsKey(list[1]), false, nil
+		case 1.0:
+			// A direct value.
+			if len(list) < 2 {
+				return scopeWXML(wxmlLiteral(nil), withScope), false, nil
+			}
+			return scopeWXML(wxmlLiteral(list[1]), withScope), false, nil
+		case 11.0:
+			// A value list: text with interpolations, like "a{{b}}c".
+			var out strings.Builder
+			for index := 1; index < len(list); index++ {
+				text, _, err := restoreOpcode(list[index], withScope)
+				if err != nil {
+					return "", false, err
+				}
+				out.WriteString(text)
+			}
+			return out.String(), false, nil
+		}
+		return "", false, fmt.Errorf("unknown opcode %v", list[0])
+	}
+	if len(vop) == 0 {
+		return "", false, fmt.Errorf("malformed opcode %v", ops)
+	}
+
+	var ans string
+	isVar := false
+	switch vop[0] {
+	case 2.0:
+		// Operators.
+		operator := ""
+		if len(vop) > 1 {
+			operator = jsKey(vop[1])
+		}
+		operand := func(index int) (string, error) {
+			text, err := next(index)
+			if err != nil {
+				return "", err
+			}
+			if inner, ok := list[index].([]interface{}); ok && len(inner) > 0 {
+				if innerOp, ok := inner[0].([]interface{}); ok && len(innerOp) > 1 && innerOp[0] == 2.0 {
+					if operatorPriority(operator, len(list)) > operatorPriority(jsKey(innerOp[1]), len(inner)) {
+						text = wxmlBrace(text, '(')
+					}
+				}
+			}
+			return text, nil
+		}
+		var parts []string
+		indexes := []int{1, 2}
+		switch {
+		case operator == "?:":
+			indexes = []int{1, 2, 3}
+		case operator == "!" || operator == "~" || operator == "-" && len(list) != 3:
+			indexes = []int{1}
+		}
+		for _, index := range indexes {
+			if index >= len(list) {
+				return "", false, fmt.Errorf("operator %s is missing operands", operator)
+			}
+			text, err := operand(index)
+			if err != nil {
+				return "", false, err
+			}
+			parts = append(parts, text)
+		}
+		switch len(parts) {
+		case 3:
+			ans = parts[0] + "?" + parts[1] + ":" + parts[2]
+		case 1:
+			ans = operator + parts[0]
+		default:
+			ans = parts[0] + operator + parts[1]
+		}
+	case 4.0:
+		// An array start.
+		text, err := next(1)
+		if err != nil {
+			return "", false, err
+		}
+		ans = text
+	case 5.0:
+		// Array construction and concatenation.
+		switch len(list) {
+		case 1:
+			ans = "[]"
+		case 2:
+			text, err := next(1)
+			if err != nil {
+				return "", false, err
+			}
+			ans = wxmlBrace(text, '[')
+		default:
+			head, err := next(1)
+			if err != nil {
+				return "", false, err
+			}
+			tail, err := next(2)
+			if err != nil {
+				return "", false, err
+			}
+			switch {
+			case head == "[]":
+				ans = wxmlBrace(tail, '[')
+			case strings.HasPrefix(head, "[") && strings.HasSuffix(head, "]"):
+				ans = wxmlBrace(strings.TrimSpace(head[1:len(head)-1])+","+tail, '[')
+			default:
+				ans = wxmlBrace("..."+head+","+tail, '[')
+			}
+		}
+	case 6.0:
+		// A member access.
+		if len(list) < 3 {
+			return "", false, fmt.Errorf("member access is missing operands")
+		}
+		object, err := next(1)
+		if err != nil {
+			return "", false, err
+		}
+		property, propertyIsVar, err := restoreOpcode(list[2], true)
+		if err != nil {
+			return "", false, err
+		}
+		switch {
+		case propertyIsVar:
+			ans = object + wxmlBrace(property, '[')
+		case wxmlIdentifierExpr.MatchString(property):
+			ans = object + "." + property
+		default:
+			ans = object + wxmlBrace(property, '[')
+		}
+	case 7.0:
+		// A variable read.
+		target, _ := listAt(list, 1).([]interface{})
+		switch listAt(target, 0) {
+		case 11.0:
+			ans = wxmlBrace("__unTestedGetValue:"+wxmlBrace(wxmlLiteral(list), '['), '{')
+		case 3.0:
+			ans = jsKey(listAt(target, 1))
+			isVar = true
+		default:
+			return "", false, fmt.Errorf("unknown variable read %v", ops)
+		}
+	case 8.0:
+		// The first key of an object literal.
+		value, err := next(2)
+		if err != nil {
+			return "", false, err
+		}
+		ans = wxmlBrace(jsKey(listAt(list, 1))+":"+value, '{')
+	case 9.0:
+		// An object merge.
+		left, err := next(1)
+		if err != nil {
+			return "", false, err
+		}
+		right, err := next(2)
+		if err != nil {
+			return "", false, err
+		}
+		leftKind, rightKind := objectOperandKind(left), objectOperandKind(right)
+		if leftKind == 2 || rightKind == 2 {
+			ans = wxmlBrace("__unkownMerge:"+wxmlBrace(left+","+right, '['), '{')
+			break
+		}
+		if leftKind == 0 {
+			left = strings.TrimSpace(left[1 : len(left)-1])
+		}
+		if rightKind == 0 {
+			right = strings.TrimSpace(right[1 : len(right)-1])
+		}
+		ans = wxmlBrace(left+","+right, '{')
+	case 10.0:
+		// An object spread.
+		text, err := next(1)
+		if err != nil {
+			return "", false, err
+		}
+		ans = "..." + text
+	case 12.0:
+		// A function call.
+		callee, err := next(1)
+		if err != nil {
+			return "", false, err
+		}
+		arguments, err := next(2)
+		if err != nil {
+			return "", false, err
+		}
+		if strings.HasPrefix(arguments, "[") && strings.HasSuffix(arguments, "]") {
+			ans = callee + wxmlBrace(strings.TrimSpace(arguments[1:len(arguments)-1]), '(')
+		} else {
+			ans = callee + ".apply" + wxmlBrace("null,"+arguments, '(')
+		}
+	default:
+		ans = wxmlBrace("__unkownSpecific:"+wxmlLiteral(list), '{')
+	}
+	return scopeWXML(ans, withScope), isVar && withScope, nil
+}
+
+func listAt(list []interface{}, index int) interface{} {
+	if index < len(list) {
+		return list[index]
+	}
+	return nil
+}
+
+func scopeWXML(value string, withScope bool) string {
+	if withScope {
+		return value
+	}
+	if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+		return "{" + value + "}"
+	}
+	return "{{" + value + "}}"
+}
+
+// wxmlBrace wraps value, padding it with spaces when it starts or ends with
+// a bracket so the result never contains "{{" or "}}" by accident.
+func wxmlBrace(value string, open byte) string {
+	if strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[") || strings.HasPrefix(value, "(") ||
+		strings.HasSuffix(value, "}") || strings.HasSuffix(value, "]") || strings.HasSuffix(value, ")") {
+		value = " " + value + " "
+	}
+	switch open {
+	case '[':
+		return "[" + value + "]"
+	case '(':
+		return "(" + value + ")"
+	}
+	return "{" + value + "}"
+}
+
+func objectOperandKind(value string) int {
+	switch {
+	case strings.HasPrefix(value, "..."):
+		return 1
+	case strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}"):
+		return 0
+	}
+	return 2
+}
+
+var wxmlOperatorPriority = map[string]int{
+	"?:": 4, "||": 5, "&&": 6, "|": 7, "^": 8, "&": 9,
+	"===": 10, "==": 10, "!=": 10, "!==": 10,
+	">=": 11, "<=": 11, ">": 11, "<": 11, "<<": 12, ">>": 12,
+	"+": 13, "*": 14, "/": 14, "%": 14, "!": 16, "~": 16,
+}
+
+func operatorPriority(operator string, operands int) int {
+	if operator == "-" {
+		if operands == 3 {
+			return 13
+		}
+		return 16
+	}
+	return wxmlOperatorPriority[operator]
+}
+
+// wxmlLiteral writes a constant in WXML's object notation: single-quoted
+// strings and unquoted object keys.
+func wxmlLiteral(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "undefined"
+	case jsNullType:
+		return "null"
+	case bool:
+		return strconv.FormatBool(typed)
+	case float64:
+		encoded, err := json.Marshal(typed)
+		if err != nil {
+			// NaN and Infinity stringify as null.
+			return "null"
+		}
+		return string(encoded)
+	case string:
+		return wxmlQuote(typed)
+	case []interface{}:
+		items := make([]string, 0, len(typed))
+		for _, item := range typed {
+			items = append(items, wxmlLiteral(item))
+		}
+		return wxmlBrace(strings.Join(items, ","), '[')
+	case *jsObject:
+		items := make([]string, 0, len(typed.keys))
+		for _, key := range typed.keys {
+			items = append(items, key+":"+wxmlLiteral(typed.values[key]))
+		}
+		return wxmlBrace(strings.Join(items, ","), '{')
+	}
+	return fmt.Sprint(value)
+}
+
+func wxmlQuote(text string) string {
+	var out strings.Builder
+	out.WriteByte('\'')
+	for _, r := range text {
+		switch r {
+		case '\\':
+			out.WriteString(`\\`)
+		case '\'':
+			out.WriteString(`\'`)
+		case '\b':
+			out.WriteString(`\b`)
+		case '\f':
+			out.WriteString(`\f`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&out, `\u%04x`, r)
+			} else {
+				out.WriteRune(r)
+			}
+		}
+	}
+	out.WriteByte('\'')
+	return out.String()
+}
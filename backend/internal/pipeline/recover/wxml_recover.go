@@ -12,8 +12,38 @@ import (
 func RecoverWXML(np *pkg.NormalizedPackage, outDir, reportsDir string) (*WXMLRecoveryResult, error) {
 	result := &WXMLRecoveryResult{Success: true}
 	hasFrameSource := hasTemplateSource(np)
+	listed := map[string]bool{}
+
+	// Templates rebuilt from the renderer registry never replace a .wxml or
+	// .wxs file shipped in the package.
+	templates, diagnostics := rebuildRuntimeTemplates(np)
+	result.Diagnostics = append(result.Diagnostics, diagnostics...)
+	if len(diagnostics) > 0 {
+		result.Partial = true
+	}
+	for _, template := range templates {
+		target, ok := safeOutputPath(outDir, template.Path)
+		if !ok {
+			result.Partial = true
+			result.Diagnostics = append(result.Diagnostics, pkg.Warn("recover.wxml.output_unsafe", "WXML 输出路径越界，已跳过", "recovering_wxml", template.Path))
+			continue
+		}
+		if fileExists(target) {
+			continue
+		}
+		if err := writeRecoveredFile(target, template.Content); err != nil {
+			return nil, err
+		}
+		listed[template.Path] = true
+		result.Files = append(result.Files, RecoveredFile{Path: template.Path, Kind: template.Kind, Source: "native"})
+		result.Recovered++
+		result.Native++
+	}
 
 	for _, page := range np.Pages {
+		if listed[page.TemplatePath] || listed[page.Path+".wxml"] {
+			continue
+		}
 		if page.TemplatePath != "" && fileExists(filepath.Join(outDir, filepath.FromSlash(page.TemplatePath))) {
 			result.Files = append(result.Files, RecoveredFile{Path: page.TemplatePath, Kind: "wxml", Source: "native"})
 			result.Recovered++
@@ -43,6 +73,9 @@ func RecoverWXML(np *pkg.NormalizedPackage, outDir, reportsDir string) (*WXMLRec
 
 	for _, component := range np.Components {
 		targetPath := component.Path + ".wxml"
+		if listed[targetPath] {
+			continue
+		}
 		if fileExists(filepath.Join(outDir, filepath.FromSlash(targetPath))) {
 			result.Files = append(result.Files, RecoveredFile{Path: targetPath, Kind: "wxml", Source: "native"})
 			result.Recovered++
@@ -0,0 +1,133 @@
+package recover
+
+import (
+	"strings"
+
+	"github.com/keepbuild/seewxapkg/internal/pipeline/jslex"
+)
+
+// formatWXSS lays out the minified text of a rebuilt stylesheet one
+// declaration per line with four-space indentation, adds missing trailing
+// semicolons, and maps the runtime's type selectors back to WXSS: `wx-view`
+// becomes `view` and `body` becomes `page`.
+func formatWXSS(css string) string {
+	var out, segment strings.Builder
+	depth, parens := 0, 0
+	indent := func() string { return strings.Repeat("    ", depth) }
+	flushDeclaration := func() {
+		text := strings.TrimSpace(segment.String())
+		segment.Reset()
+		if text != "" {
+			out.WriteString(indent() + formatDeclaration(text) + ";\n")
+		}
+	}
+
+	for pos := 0; pos < len(css); pos++ {
+		ch := css[pos]
+		switch {
+		case ch == '"' || ch == '\'':
+			end := pos + 1
+			for end < len(css) && css[end] != ch {
+				if css[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(css))
+			segment.WriteString(css[pos:end])
+			pos = end - 1
+		case ch == '/' && strings.HasPrefix(css[pos:], "/*"):
+			end := strings.Index(css[pos+2:], "*/")
+			if end < 0 {
+				end = len(css)
+			} else {
+				end += pos + 4
+			}
+			if strings.TrimSpace(segment.String()) == "" {
+				segment.Reset()
+				out.WriteString(indent() + css[pos:end] + "\n")
+			} else {
+				segment.WriteString(css[pos:end])
+			}
+			pos = end - 1
+		case ch == '(':
+			parens++
+			segment.WriteByte(ch)
+		case ch == ')':
+			parens = max(parens-1, 0)
+			segment.WriteByte(ch)
+		case parens > 0:
+			segment.WriteByte(ch)
+		case ch == '{':
+			selector := strings.TrimSpace(segment.String())
+			segment.Reset()
+			if !strings.HasPrefix(selector, "@") {
+				selector = runtimeSelectorToWXSS(selector)
+			}
+			out.WriteString(indent() + selector + " {\n")
+			depth++
+		case ch == ';':
+			flushDeclaration()
+		case ch == '}':
+			flushDeclaration()
+			depth = max(depth-1, 0)
+			out.WriteString(indent() + "}\n")
+			if depth == 0 {
+				out.WriteString("\n")
+			}
+		default:
+			segment.WriteByte(ch)
+		}
+	}
+	if rest := strings.TrimSpace(segment.String()); rest != "" {
+		out.WriteString(rest + "\n")
+	}
+	formatted := strings.TrimSpace(out.String())
+	if formatted == "" {
+		return ""
+	}
+	return formatted + "\n"
+}
+
+func formatDeclaration(text string) string {
+	if strings.HasPrefix(text, "@") {
+		return text
+	}
+	colon := strings.IndexByte(text, ':')
+	if colon <= 0 {
+		return text
+	}
+	return strings.TrimSpace(text[:colon]) + ": " + strings.TrimSpace(text[colon+1:])
+}
+
+func runtimeSelectorToWXSS(selector string) string {
+	var out strings.Builder
+	brackets := 0
+	for pos := 0; pos < len(selector); pos++ {
+		ch := selector[pos]
+		switch ch {
+		case '[':
+			brackets++
+		case ']':
+			brackets = max(brackets-1, 0)
+		}
+		if brackets == 0 && (pos == 0 || strings.IndexByte(" \t\n,>+~(", selector[pos-1]) >= 0) {
+			rest := selector[pos:]
+			if strings.HasPrefix(rest, "wx-") && len(rest) > 3 && jslex.IsIdentStart(rest[3]) {
+				pos += 2
+				continue
+			}
+			if strings.HasPrefix(rest, "body") && (len(rest) == 4 || !isCSSNameByte(rest[4])) {
+				out.WriteString("page")
+				pos += 3
+				continue
+			}
+		}
+		out.WriteByte(ch)
+	}
+	return out.String()
+}
+
+func isCSSNameByte(ch byte) bool {
+	return jslex.IsIdentStart(ch) || jslex.IsDigit(ch) || ch == '-'
+}
@@ -0,0 +1,518 @@
+package recover
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/pipeline/jslex"
+)
+
+// The native WXSS engine is a static port of wxappUnpacker/wuWxss.js. The
+// compiler turns every stylesheet into a setCssToHead([...]) call whose array
+// mixes CSS text with markers: [0,n] is n rpx, [1] is the per-page selector
+// suffix and [2,id] imports the shared table entry _C[id]. Nothing is
+// executed; calls whose argument is not a literal are skipped.
+
+const (
+	wxssBaseDir        = "__wuBaseWxss__"
+	wxssCyclicImport   = "/*! Cyclic static WXSS import omitted. */"
+	wxssUnresolvedNote = "/*! Unresolved static WXSS import omitted. */"
+	wxssVendorBoxHack  = "display:-webkit-box;display:-webkit-flex;"
+)
+
+var (
+	htmlScriptPattern = regexp.MustCompile(`(?is)<script\b[^>]*>(.*?)</script\s*>`)
+	wxssNumericKey    = regexp.MustCompile(`^(?:0|[1-9][0-9]*)$`)
+)
+
+// rebuiltStylesheet is one .wxss file reconstructed from runtime calls.
+type rebuiltStylesheet struct {
+	Path    string
+	Content string
+}
+
+type wxssCall struct {
+	file string
+	data interface{}
+}
+
+type wxssRebuilder struct {
+	table map[string]interface{}
+	calls []wxssCall
+	// actualPure maps a table key to the file that is exactly that entry, so
+	// other files can @import it instead of inlining it.
+	actualPure  map[string]string
+	importCount map[string]int
+	statistic   bool
+	results     map[string]*strings.Builder
+	order       []string
+	diagnostics []pkg.Diagnostic
+	reported    map[string]bool
+}
+
+// rebuildRuntimeStyles reconstructs the stylesheets of a package from its
+// page frame (page-frame.html, app-wxss.js or page-frame.js) and the
+// per-page .html files. Paths are slash-separated and source-relative.
+func rebuildRuntimeStyles(np *pkg.NormalizedPackage) ([]rebuiltStylesheet, []pkg.Diagnostic) {
+	frameName, frameCode := findStyleFrame(np)
+	if frameName == "" {
+		return nil, nil
+	}
+	rebuilder := &wxssRebuilder{
+		table:       extractStyleTable(frameCode),
+		actualPure:  map[string]string{},
+		importCount: map[string]int{},
+		results:     map[string]*strings.Builder{},
+		reported:    map[string]bool{},
+	}
+	rebuilder.collectCalls("app.wxss", frameCode)
+	for _, template := range np.Templates {
+		name := template.Path
+		if !strings.HasSuffix(strings.ToLower(name), ".html") || path.Base(name) == "page-frame.html" {
+			continue
+		}
+		// Page frames of the per-page layout carry their stylesheet call on
+		// the first line.
+		code := strings.ReplaceAll(template.Content, wxssVendorBoxHack, "")
+		if end := strings.IndexByte(code, '\n'); end >= 0 {
+			code = code[:end]
+		}
+		start := strings.Index(code, "setCssToHead(")
+		if start < 0 {
+			continue
+		}
+		rebuilder.collectCalls(name[:len(name)-len(path.Ext(name))]+".wxss", code[start:])
+	}
+	return rebuilder.run(), rebuilder.diagnostics
+}
+
+func findStyleFrame(np *pkg.NormalizedPackage) (string, string) {
+	candidates := make(map[string]string, 3)
+	for _, template := range np.Templates {
+		if template.Path == "page-frame.html" {
+			candidates[template.Path] = frameScript(template.Content)
+		}
+	}
+	for _, script := range np.Scripts {
+		if script.Path == "app-wxss.js" || script.Path == "page-frame.js" {
+			candidates[script.Path] = script.Content
+		}
+	}
+	// The first frame that registers stylesheets wins; newer builds keep an
+	// almost empty page-frame.html next to app-wxss.js.
+	for _, name := range []string{"page-frame.html", "app-wxss.js", "page-frame.js"} {
+		code, ok := candidates[name]
+		if !ok || !strings.Contains(code, "setCssToHead(") {
+			continue
+		}
+		code = strings.ReplaceAll(code, wxssVendorBoxHack, "")
+		if start := strings.LastIndex(code, "window.__wcc_version__"); start >= 0 {
+			code = code[start:]
+		}
+		return name, code
+	}
+	return "", ""
+}
+
+// frameScript returns the inline scripts of an HTML frame, or the content
+// unchanged when it is already JavaScript.
+func frameScript(content string) string {
+	scripts := htmlScriptPattern.FindAllStringSubmatch(content, -1)
+	if len(scripts) == 0 {
+		return content
+	}
+	bodies := make([]string, 0, len(scripts))
+	for _, script := range scripts {
+		bodies = append(bodies, script[1])
+	}
+	return strings.Join(bodies, "\n")
+}
+
+// extractStyleTable recovers the compiler's shared _C table: a plain array
+// literal in older builds, or an object filled by `X[key]=[...]` assignments
+// and bound with `var _C=X` inside setCssToHead in 4.x builds.
+func extractStyleTable(code string) map[string]interface{} {
+	tracked := map[string]bool{"_C": true}
+	var aliases [][2]string
+	scanJSIdentifiers(code, func(name string, end int) {
+		rest := jslex.SkipSpace(code, end)
+		if !isPlainAssignment(code, rest) {
+			return
+		}
+		for _, alias := range aliasIdentifiers(code, rest+1) {
+			aliases = append(aliases, [2]string{name, alias})
+		}
+	})
+	for changed := true; changed; {
+		changed = false
+		for _, alias := range aliases {
+			if tracked[alias[0]] && !tracked[alias[1]] {
+				tracked[alias[1]] = true
+				changed = true
+			}
+		}
+	}
+
+	env := map[string]interface{}{}
+	scanJSIdentifiers(code, func(name string, end int) {
+		if !tracked[name] {
+			return
+		}
+		rest := jslex.SkipSpace(code, end)
+		if isPlainAssignment(code, rest) {
+			value, err := parseJSLiteral(code, rest+1, env)
+			if err != nil {
+				return
+			}
+			switch value.(type) {
+			case []interface{}, map[string]interface{}:
+				env[name] = value
+			}
+			return
+		}
+		target, ok := env[name].(map[string]interface{})
+		if !ok || rest >= len(code) || (code[rest] != '[' && code[rest] != '.') {
+			return
+		}
+		// `X[key]=[...]` or `X.key=[...]`: a one-step member expression on a
+		// bound object, then the assigned array.
+		parser := newJSPrefixParser(code, end-len(name))
+		member, err := parser.parseLeftHandSide()
+		if err != nil || member.Type != "MemberExpression" || member.Object.Type != "Identifier" || !parser.accept("=") {
+			return
+		}
+		key, err := staticPropertyName(member, &jsScope{vars: env})
+		if err != nil {
+			return
+		}
+		node, err := parser.parseAssignment()
+		if err != nil {
+			return
+		}
+		value, err := evalJSLiteral(node, env)
+		if list, isList := value.([]interface{}); err == nil && isList {
+			if _, exists := target[key]; !exists {
+				target[key] = list
+			}
+		}
+	})
+
+	value, ok := env["_C"]
+	if !ok {
+		names := make([]string, 0, len(tracked))
+		for name := range tracked {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if candidate, found := env[name]; found {
+				value = candidate
+				break
+			}
+		}
+	}
+	table := map[string]interface{}{}
+	switch typed := value.(type) {
+	case []interface{}:
+		for index, entry := range typed {
+			if entry != nil {
+				table[jsNumber(float64(index))] = entry
+			}
+		}
+	case map[string]interface{}:
+		table = typed
+	}
+	return table
+}
+
+// isPlainAssignment reports whether code[pos] is `=` and not `==` or `=>`.
+func isPlainAssignment(code string, pos int) bool {
+	return pos < len(code) && code[pos] == '=' && (pos+1 >= len(code) || code[pos+1] != '=' && code[pos+1] != '>')
+}
+
+// aliasIdentifiers lists the identifiers of a `a || b` right-hand side.
+func aliasIdentifiers(code string, pos int) []string {
+	var names []string
+	for {
+		pos = jslex.SkipSpace(code, pos)
+		start := pos
+		for pos < len(code) && (jslex.IsIdentStart(code[pos]) || jslex.IsDigit(code[pos])) {
+			pos++
+		}
+		if start == pos || jslex.IsDigit(code[start]) {
+			return names
+		}
+		names = append(names, code[start:pos])
+		pos = jslex.SkipSpace(code, pos)
+		if !strings.HasPrefix(code[pos:], "||") && !strings.HasPrefix(code[pos:], "??") {
+			return names
+		}
+		pos += 2
+	}
+}
+
+func (r *wxssRebuilder) collectCalls(name, code string) {
+	env := map[string]interface{}{"_C": r.table}
+	handled := map[int]bool{}
+	var previous string
+	scanJSIdentifiers(code, func(identifier string, end int) {
+		defer func() { previous = identifier }()
+		switch identifier {
+		case "__wxAppCode__":
+			parser := newJSPrefixParser(code, end-len(identifier))
+			member, err := parser.parseLeftHandSide()
+			if err != nil || member.Type != "MemberExpression" || !member.Computed || !parser.is("=") {
+				return
+			}
+			key, err := evalJSLiteral(member.Property, env)
+			entry, isString := key.(string)
+			if err != nil || !isString || !strings.HasSuffix(entry, ".wxss") {
+				return
+			}
+			callStart := jslex.SkipSpace(code, parser.next().End)
+			if !strings.HasPrefix(code[callStart:], "setCssToHead") {
+				return
+			}
+			file, ok := cleanPackagePath(entry)
+			if !ok {
+				r.report(pkg.Warn("recover.wxss.output_unsafe", "WXSS 注册路径越界，已跳过", "recovering_wxss", entry))
+				return
+			}
+			if data, ok := readStylesheetCall(code, callStart+len("setCssToHead"), env); ok {
+				handled[callStart] = true
+				r.calls = append(r.calls, wxssCall{file: file, data: data})
+			}
+		case "setCssToHead":
+			start := end - len(identifier)
+			if handled[start] || previous == "function" {
+				return
+			}
+			if data, ok := readStylesheetCall(code, end, env); ok {
+				handled[start] = true
+				r.calls = append(r.calls, wxssCall{file: name, data: data})
+			}
+		}
+	})
+}
+
+// readStylesheetCall parses the first argument of the call whose name ends
+// at pos. A dynamic argument yields false.
+func readStylesheetCall(code string, pos int, env map[string]interface{}) (interface{}, bool) {
+	pos = jslex.SkipSpace(code, pos)
+	if pos >= len(code) || code[pos] != '(' {
+		return nil, false
+	}
+	data, err := parseJSLiteral(code, pos+1, env)
+	return data, err == nil
+}
+
+func (r *wxssRebuilder) run() []rebuiltStylesheet {
+	// The first pass counts imports and finds files that are exactly one
+	// table entry; the second one generates the text.
+	r.statistic = true
+	for _, call := range r.calls {
+		r.rebuild(call.file, call.data)
+	}
+	r.statistic = false
+
+	keys := make([]string, 0, len(r.table))
+	for key := range r.table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, pure := r.actualPure[key]; pure {
+			continue
+		}
+		// An entry imported once is inlined where it is used; a shared one
+		// gets its own file under __wuBaseWxss__.
+		if r.importCount[key] <= 1 {
+			continue
+		}
+		file := wxssBaseDir + "/" + baseStylesheetName(key)
+		r.actualPure[key] = file
+		r.rebuild(file, key)
+	}
+	for _, call := range r.calls {
+		r.rebuild(call.file, call.data)
+	}
+
+	stylesheets := make([]rebuiltStylesheet, 0, len(r.order))
+	for _, file := range r.order {
+		stylesheets = append(stylesheets, rebuiltStylesheet{Path: file, Content: formatWXSS(r.results[file].String())})
+	}
+	return stylesheets
+}
+
+func (r *wxssRebuilder) rebuild(file string, data interface{}) {
+	if _, ok := r.results[file]; !ok {
+		r.results[file] = &strings.Builder{}
+		r.order = append(r.order, file)
+	}
+	r.results[file].WriteString(r.makeup(file, data, nil))
+}
+
+func (r *wxssRebuilder) isPureReference(value interface{}) (string, bool) {
+	switch value.(type) {
+	case string, float64:
+		key := jsKey(value)
+		_, ok := r.table[key]
+		return key, ok
+	}
+	return "", false
+}
+
+func (r *wxssRebuilder) makeup(file string, data interface{}, ancestors map[string]bool) string {
+	key, isPure := r.isPureReference(data)
+	if r.statistic {
+		r.countImports(file, data, nil)
+		if !isPure {
+			// A file whose whole content is [[2,id]] is the real path of _C[id].
+			list, _ := data.([]interface{})
+			if len(list) != 1 {
+				return ""
+			}
+			item, _ := list[0].([]interface{})
+			if len(item) < 2 || item[0] != float64(2) {
+				return ""
+			}
+			if key, isPure = r.isPureReference(item[1]); !isPure {
+				return ""
+			}
+		}
+		if _, known := r.actualPure[key]; !known {
+			r.actualPure[key] = file
+		}
+		return ""
+	}
+
+	if isPure {
+		if ancestors[key] {
+			r.reportCycle(file)
+			return wxssCyclicImport
+		}
+		nested := make(map[string]bool, len(ancestors)+1)
+		for ancestor := range ancestors {
+			nested[ancestor] = true
+		}
+		nested[key] = true
+		ancestors = nested
+	}
+	var out strings.Builder
+	attach := ""
+	if isPure && r.actualPure[key] != file {
+		if target, ok := r.actualPure[key]; ok {
+			return `@import "` + relativePackagePath(file, target) + "\";\n"
+		}
+		out.WriteString("/*! Import by _C[" + key + "], whose real path we cannot found. */")
+		attach = "/*! Import end */"
+	}
+	content := data
+	if isPure {
+		content = r.table[key]
+	}
+	list, ok := content.([]interface{})
+	if !ok {
+		r.reportUnresolved(file)
+		return wxssUnresolvedNote
+	}
+	for _, item := range list {
+		switch typed := item.(type) {
+		case string:
+			out.WriteString(typed)
+		case float64:
+			out.WriteString(jsNumber(typed))
+		case []interface{}:
+			if len(typed) == 0 {
+				continue
+			}
+			switch typed[0] {
+			case float64(0):
+				if len(typed) > 1 {
+					out.WriteString(jsKey(typed[1]) + "rpx")
+				}
+			case float64(1):
+				// The per-page suffix is added at runtime; the source
+				// selector has none.
+			case float64(2):
+				var target interface{}
+				if len(typed) > 1 {
+					target = typed[1]
+				}
+				if _, resolvable := r.isPureReference(target); resolvable {
+					out.WriteString(r.makeup(file, target, ancestors))
+				} else {
+					r.reportUnresolved(file)
+					out.WriteString(wxssUnresolvedNote)
+				}
+			}
+		}
+	}
+	out.WriteString(attach)
+	return out.String()
+}
+
+func (r *wxssRebuilder) countImports(file string, data interface{}, ancestors map[string]bool) {
+	add := func(id interface{}) {
+		key, ok := r.isPureReference(id)
+		if !ok {
+			r.reportUnresolved(file)
+			return
+		}
+		if ancestors[key] {
+			r.reportCycle(file)
+			return
+		}
+		r.importCount[key]++
+		if r.importCount[key] > 1 {
+			return
+		}
+		nested := map[string]bool{key: true}
+		for ancestor := range ancestors {
+			nested[ancestor] = true
+		}
+		r.countImports(file, r.table[key], nested)
+	}
+	if _, ok := r.isPureReference(data); ok {
+		add(data)
+		return
+	}
+	list, _ := data.([]interface{})
+	for _, item := range list {
+		if typed, ok := item.([]interface{}); ok && len(typed) > 1 && typed[0] == float64(2) {
+			add(typed[1])
+		}
+	}
+}
+
+func (r *wxssRebuilder) reportUnresolved(file string) {
+	r.report(pkg.Warn("recover.wxss.unresolved_import", "WXSS 引用的共享样式缺失，已省略该 @import", "recovering_wxss", file))
+}
+
+func (r *wxssRebuilder) reportCycle(file string) {
+	r.report(pkg.Warn("recover.wxss.import_cycle", "WXSS 共享样式存在循环引用，已省略循环部分", "recovering_wxss", file))
+}
+
+func (r *wxssRebuilder) report(diagnostic pkg.Diagnostic) {
+	key := diagnostic.Code + "\x00" + diagnostic.File
+	if r.reported[key] {
+		return
+	}
+	r.reported[key] = true
+	r.diagnostics = append(r.diagnostics, diagnostic)
+}
+
+// baseStylesheetName names the file of a shared table entry. String keys are
+// hashed so that a key such as "./styles/a.wxss" cannot choose the path.
+func baseStylesheetName(key string) string {
+	if wxssNumericKey.MatchString(key) {
+		return key + ".wxss"
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "shared-" + hex.EncodeToString(sum[:])[:16] + ".wxss"
+}
@@ -0,0 +1,160 @@
+package recover
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+func TestRecoverWXSSRebuildsLegacyStyleTable(t *testing.T) {
+	outDir := t.TempDir()
+	reportsDir := t.TempDir()
+	writeRecoveryReportFixture(t, outDir, "pages/kept/index.wxss", ".kept {}\n")
+	normalized := &pkg.NormalizedPackage{
+		Pages: []pkg.PageIR{{Path: "pages/home/index"}, {Path: "pages/kept/index", StylePath: "pages/kept/index.wxss"}},
+		Scripts: []pkg.ScriptIR{{
+			Path:    "app-wxss.js",
+			Content: `var _C=[[".shared{width:",[0,20],"}"]];setCssToHead(["body{margin:0}wx-view{display:block;color:red}"],undefined,{path:"app.wxss"});`,
+			Source:  "runtime",
+		}},
+		Templates: []pkg.TemplateIR{
+			{Path: "pages/home/index.html", Content: `setCssToHead([[2,0],".",[1],"title{font-size:` + "\\x32" + `4px}"],undefined,{path:"./pages/home/index.wxss"})` + "\nignored();"},
+			{Path: "pages/kept/index.html", Content: `setCssToHead([".replaced{}"])`},
+		},
+	}
+
+	result, err := RecoverWXSS(normalized, outDir, reportsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.Partial {
+		t.Fatalf("legacy style table should recover completely: %#v", result)
+	}
+
+	app := readRecoveredStylesheet(t, outDir, "app.wxss")
+	if app != "page {\n    margin: 0;\n}\n\nview {\n    display: block;\n    color: red;\n}\n" {
+		t.Fatalf("unexpected app.wxss:\n%s", app)
+	}
+	home := readRecoveredStylesheet(t, outDir, "pages/home/index.wxss")
+	if !strings.Contains(home, "width: 20rpx;") || !strings.Contains(home, ".title {\n    font-size: 24px;\n}") {
+		t.Fatalf("imported entry and suffix marker were not rebuilt:\n%s", home)
+	}
+	if !strings.Contains(home, "Import by _C[0]") {
+		t.Fatalf("an entry imported once should be inlined:\n%s", home)
+	}
+	if kept := readRecoveredStylesheet(t, outDir, "pages/kept/index.wxss"); kept != ".kept {}\n" {
+		t.Fatalf("packaged stylesheet was overwritten: %q", kept)
+	}
+
+	sources := map[string]string{}
+	for _, file := range result.Files {
+		sources[file.Path] = file.Source
+	}
+	for _, path := range []string{"app.wxss", "pages/home/index.wxss", "pages/kept/index.wxss"} {
+		if sources[path] != "native" {
+			t.Fatalf("%s missing from native recovery files: %#v", path, result.Files)
+		}
+	}
+	if result.Recovered != 3 || result.Native != 3 {
+		t.Fatalf("unexpected counts: %#v", result)
+	}
+}
+
+func TestRecoverWXSSRebuildsSharedStylesheetsFromPageFrame(t *testing.T) {
+	outDir := t.TempDir()
+	frame := strings.Join([]string{
+		"<!doctype html><html><body><script>",
+		"window.__wcc_version__=4;",
+		"var __COMMON_STYLESHEETS__=__COMMON_STYLESHEETS__||{};",
+		`if(!__COMMON_STYLESHEETS__.hasOwnProperty("./styles/order-common.wxss"))__COMMON_STYLESHEETS__["./styles/order-common.wxss"]=[".",[1],"confirm-page{color:#c00}"];`,
+		`if(!__COMMON_STYLESHEETS__.hasOwnProperty("./styles/self-cycle.wxss"))__COMMON_STYLESHEETS__["./styles/self-cycle.wxss"]=[[2,"./styles/self-cycle.wxss"]];`,
+		`if(!__COMMON_STYLESHEETS__.hasOwnProperty("./styles/cycle-a.wxss"))__COMMON_STYLESHEETS__["./styles/cycle-a.wxss"]=[[2,"./styles/cycle-b.wxss"]];`,
+		`if(!__COMMON_STYLESHEETS__.hasOwnProperty("./styles/cycle-b.wxss"))__COMMON_STYLESHEETS__["./styles/cycle-b.wxss"]=[[2,"./styles/cycle-a.wxss"]];`,
+		"var setCssToHead = function(file, _xcInvalid, info){var _C=__COMMON_STYLESHEETS__;return file;};",
+		"var __wxAppCode__={};",
+		`__wxAppCode__['pages/a/index.wxss']=setCssToHead([[2,"./styles/order-common.wxss"],".",[1],"local-a{margin:",[0,2],"}"]);`,
+		`__wxAppCode__['pages/b/index.wxss']=setCssToHead([[2,"./styles/order-common.wxss"],".",[1],"local-b{padding:",[0,4],"}"]);`,
+		`__wxAppCode__['pages/missing/index.wxss']=setCssToHead([[2,"./styles/missing.wxss"],".",[1],"missing-page{display:block}"]);`,
+		`__wxAppCode__['pages/self/index.wxss']=setCssToHead([[2,"./styles/self-cycle.wxss"]]);`,
+		`__wxAppCode__['pages/two/index.wxss']=setCssToHead([[2,"./styles/cycle-a.wxss"]]);`,
+		`__wxAppCode__['../escape.wxss']=setCssToHead([".escape{}"]);`,
+		`__wxAppCode__['pages/dynamic/index.wxss']=setCssToHead(buildStyles());`,
+		"</script></body></html>",
+	}, "\n")
+	normalized := &pkg.NormalizedPackage{
+		Templates: []pkg.TemplateIR{{Path: "page-frame.html", Content: frame}},
+	}
+
+	result, err := RecoverWXSS(normalized, outDir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Partial {
+		t.Fatalf("omitted imports should make the result partial: %#v", result)
+	}
+
+	base := filepath.Join(wxssBaseDir, baseStylesheetName("./styles/order-common.wxss"))
+	baseCSS := readRecoveredStylesheet(t, outDir, filepath.ToSlash(base))
+	if !strings.Contains(baseCSS, ".confirm-page {") {
+		t.Fatalf("shared entry was not saved as a base file:\n%s", baseCSS)
+	}
+	importLine := `@import "../../` + filepath.ToSlash(base) + `";`
+	for page, local := range map[string]string{"pages/a/index.wxss": ".local-a {\n    margin: 2rpx;\n}", "pages/b/index.wxss": ".local-b {\n    padding: 4rpx;\n}"} {
+		css := readRecoveredStylesheet(t, outDir, page)
+		if !strings.Contains(css, importLine) || !strings.Contains(css, local) {
+			t.Fatalf("%s should import the shared entry:\n%s", page, css)
+		}
+	}
+	if css := readRecoveredStylesheet(t, outDir, "pages/missing/index.wxss"); !strings.Contains(css, wxssUnresolvedNote) || !strings.Contains(css, ".missing-page {") {
+		t.Fatalf("unresolved import was not omitted:\n%s", css)
+	}
+	for _, page := range []string{"pages/self/index.wxss", "pages/two/index.wxss"} {
+		if css := readRecoveredStylesheet(t, outDir, page); !strings.Contains(css, wxssCyclicImport) {
+			t.Fatalf("%s should omit its import cycle:\n%s", page, css)
+		}
+	}
+	for _, path := range []string{"pages/dynamic/index.wxss", "../escape.wxss", "escape.wxss"} {
+		if _, err := os.Stat(filepath.Join(outDir, filepath.FromSlash(path))); err == nil {
+			t.Fatalf("%s should not be written", path)
+		}
+	}
+
+	selectorPath := regexp.MustCompile(`\./styles/[^\s{]+\s*\{`)
+	for _, file := range result.Files {
+		if css := readRecoveredStylesheet(t, outDir, file.Path); selectorPath.MatchString(css) {
+			t.Fatalf("%s turned a table key into a selector:\n%s", file.Path, css)
+		}
+	}
+	codes := map[string]bool{}
+	for _, diagnostic := range result.Diagnostics {
+		codes[diagnostic.Code] = true
+	}
+	for _, code := range []string{"recover.wxss.unresolved_import", "recover.wxss.import_cycle", "recover.wxss.output_unsafe"} {
+		if !codes[code] {
+			t.Fatalf("missing %s diagnostic: %#v", code, result.Diagnostics)
+		}
+	}
+}
+
+func TestBaseStylesheetNameIsTraversalSafe(t *testing.T) {
+	if name := baseStylesheetName("12"); name != "12.wxss" {
+		t.Fatalf("numeric key name = %q", name)
+	}
+	name := baseStylesheetName("./styles/order-common.wxss")
+	if !regexp.MustCompile(`^shared-[a-f0-9]{16}\.wxss$`).MatchString(name) || name != baseStylesheetName("./styles/order-common.wxss") {
+		t.Fatalf("string key name = %q", name)
+	}
+}
+
+func readRecoveredStylesheet(t *testing.T, root, relative string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(relative)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -11,18 +11,52 @@ import (
 func RecoverWXSS(np *pkg.NormalizedPackage, outDir, reportsDir string) (*WXSSRecoveryResult, error) {
 	result := &WXSSRecoveryResult{Success: true}
 	hasStyleSource := hasRuntimeStyle(np)
+	listed := map[string]bool{}
 
-	if fileExists(filepath.Join(outDir, "app.wxss")) {
+	// Stylesheets rebuilt from setCssToHead calls never replace a .wxss file
+	// shipped in the package.
+	stylesheets, diagnostics := rebuildRuntimeStyles(np)
+	result.Diagnostics = append(result.Diagnostics, diagnostics...)
+	if len(diagnostics) > 0 {
+		result.Partial = true
+	}
+	for _, stylesheet := range stylesheets {
+		target, ok := safeOutputPath(outDir, stylesheet.Path)
+		if !ok {
+			result.Partial = true
+			result.Diagnostics = append(result.Diagnostics, pkg.Warn("recover.wxss.output_unsafe", "WXSS 输出路径越界，已跳过", "recovering_wxss", stylesheet.Path))
+			continue
+		}
+		if fileExists(target) {
+			continue
+		}
+		if err := writeRecoveredFile(target, stylesheet.Content); err != nil {
+			return nil, err
+		}
+		listed[stylesheet.Path] = true
+		result.Files = append(result.Files, RecoveredFile{Path: stylesheet.Path, Kind: "wxss", Source: "native"})
+		result.Recovered++
+		result.Native++
+	}
+
+	switch {
+	case listed["app.wxss"]:
+	case fileExists(filepath.Join(outDir, "app.wxss")):
+		listed["app.wxss"] = true
 		result.Files = append(result.Files, RecoveredFile{Path: "app.wxss", Kind: "wxss", Source: "native"})
 		result.Recovered++
 		result.Native++
-	} else if hasStyleSource {
+	case hasStyleSource:
 		result.Partial = true
 		result.Diagnostics = append(result.Diagnostics, pkg.Warn("recover.wxss.app.missing", "app.wxss 缺失，已识别 runtime 样式入口但未生成占位样式", "recovering_wxss", "app.wxss"))
 	}
 
 	for _, page := range np.Pages {
+		if listed[page.StylePath] {
+			continue
+		}
 		if page.StylePath != "" && fileExists(filepath.Join(outDir, filepath.FromSlash(page.StylePath))) {
+			listed[page.StylePath] = true
 			result.Files = append(result.Files, RecoveredFile{Path: page.StylePath, Kind: "wxss", Source: "native"})
 			result.Recovered++
 			result.Native++
@@ -71,12 +71,26 @@ type artifactParserResult struct {
 }
 
 func VerifyArtifacts(runner *process.NodeRunner, np *pkg.NormalizedPackage, sourceDir string) (*ArtifactVerifyResult, error) {
+	return VerifyArtifactsContext(context.Background(), runner, np, sourceDir)
+}
+
+// VerifyArtifactsContext is VerifyArtifacts with the parser process bound
+// to ctx, so cancelling a task stops the verifier too.
+func VerifyArtifactsContext(ctx context.Context, runner *process.NodeRunner, np *pkg.NormalizedPackage, sourceDir string) (*ArtifactVerifyResult, error) {
+	routes := np.Manifest.Pages
+	if np.Plugin != nil {
+		// Public components of a plugin need the same js/wxml pair as pages.
+		routes = append([]string(nil), routes...)
+		for _, component := range np.Plugin.PublicComponents {
+			routes = append(routes, np.Plugin.ResolvePath(component))
+		}
+	}
 	result := &ArtifactVerifyResult{
-		TotalPages:        len(np.Manifest.Pages),
+		TotalPages:        len(routes),
 		WXMLQualityPassed: true,
 	}
 
-	for _, page := range np.Manifest.Pages {
+	for _, page := range routes {
 		base, safe := safePageBase(sourceDir, page)
 		if !safe {
 			result.MissingPageTriplet = append(result.MissingPageTriplet, page)
@@ -91,7 +105,7 @@ func VerifyArtifacts(runner *process.NodeRunner, np *pkg.NormalizedPackage, sour
 		result.MissingPageTriplet = append(result.MissingPageTriplet, page)
 	}
 
-	parserResult, err := runArtifactParser(runner, sourceDir)
+	parserResult, err := runArtifactParser(ctx, runner, sourceDir)
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +158,11 @@ func VerifyArtifacts(runner *process.NodeRunner, np *pkg.NormalizedPackage, sour
 	result.WXMLQualityIssueFiles = len(qualityIssueFiles)
 	result.WXMLQualityPassed = result.WXMLQualityIssueFiles == 0
 
-	if result.TotalPages > 0 {
+	game := np.Profile.IsGamePackage
+	if game {
+		result.TotalPages = 0
+	}
+	if result.TotalPages > 0 || game {
 		// Treat parser-invalid generated artifacts as critical only when a file class exists
 		// but none of those files can be parsed. Purely missing classes are handled as partial.
 		if result.JSFiles > 0 && result.JSParseable == 0 {
@@ -159,6 +177,10 @@ func VerifyArtifacts(runner *process.NodeRunner, np *pkg.NormalizedPackage, sour
 	}
 
 	pageStructurePassed := result.TotalPages > 0 && len(result.MissingPageTriplet) == 0
+	if game {
+		// Mini-games have no pages; the structure is game.js plus its modules.
+		pageStructurePassed = result.JSFiles > 0
+	}
 	result.VerifierPassed = pageStructurePassed && result.ParserPassed && result.WXMLQualityPassed
 	result.Success = result.VerifierPassed
 	return result, nil
@@ -357,7 +379,7 @@ func isMoustacheEventHandler(value string) bool {
 	return strings.HasPrefix(value, "{{") && strings.HasSuffix(value, "}}")
 }
 
-func runArtifactParser(runner *process.NodeRunner, sourceDir string) (*artifactParserResult, error) {
+func runArtifactParser(ctx context.Context, runner *process.NodeRunner, sourceDir string) (*artifactParserResult, error) {
 	script, err := process.ResolveExistingPath(
 		filepath.Join("backend", "internal", "beautify", "runtime", "verify_artifacts.js"),
 		filepath.Join("internal", "beautify", "runtime", "verify_artifacts.js"),
@@ -365,7 +387,7 @@ func runArtifactParser(runner *process.NodeRunner, sourceDir string) (*artifactP
 	if err != nil {
 		return nil, err
 	}
-	stdout, stderr, err := runner.Run(context.Background(), script, sourceDir)
+	stdout, stderr, err := runner.Run(ctx, script, sourceDir)
 	if err != nil {
 		return nil, errWithStderr(err, stderr)
 	}
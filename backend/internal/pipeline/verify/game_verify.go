@@ -0,0 +1,118 @@
+package verify
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+// GameVerifyResult replaces the page checks for mini-games, which declare
+// an entry, subpackages and workers in game.json instead of pages.
+type GameVerifyResult struct {
+	ConfigPresent      bool     `json:"configPresent"`
+	EntryPresent       bool     `json:"entryPresent"`
+	SubPackageCount    int      `json:"subPackageCount"`
+	MissingSubPackages []string `json:"missingSubPackages,omitempty"`
+	Workers            string   `json:"workers,omitempty"`
+	WorkersPresent     bool     `json:"workersPresent"`
+	OpenDataContext    string   `json:"openDataContext,omitempty"`
+	Checks             int      `json:"checks"`
+	PassedChecks       int      `json:"passedChecks"`
+}
+
+var gameOrientations = map[string]bool{"portrait": true, "landscape": true, "landscapeLeft": true, "landscapeRight": true}
+
+// verifyGameManifest checks game.json and the files it points at. Each
+// declared item is one check, so the manifest score is the share passed.
+func verifyGameManifest(sourceDir string, result *ManifestVerifyResult) {
+	game := &GameVerifyResult{}
+	result.Game = game
+	check := func(passed bool, code, message, file string, metadata map[string]interface{}) {
+		game.Checks++
+		if passed {
+			game.PassedChecks++
+			return
+		}
+		result.Success = false
+		result.ManifestIssueCount++
+		diagnostic := pkg.Warn(code, message, "verifying", file)
+		diagnostic.Metadata = metadata
+		result.Diagnostics = append(result.Diagnostics, diagnostic)
+	}
+
+	game.EntryPresent = fileExists(filepath.Join(sourceDir, "game.js"))
+	check(game.EntryPresent, "verify.game.entry_missing", "小游戏入口 game.js 未找到", "game.js", nil)
+
+	var config map[string]interface{}
+	data, err := os.ReadFile(filepath.Join(sourceDir, "game.json"))
+	if err == nil {
+		err = json.Unmarshal(data, &config)
+	}
+	game.ConfigPresent = err == nil
+	check(game.ConfigPresent, "verify.game.config_invalid", "game.json 缺失或不是有效的 JSON 对象", "game.json", nil)
+	if config == nil {
+		return
+	}
+
+	if orientation, ok := config["deviceOrientation"]; ok {
+		value, _ := orientation.(string)
+		check(gameOrientations[value], "verify.game.orientation_invalid", "deviceOrientation 取值无效", "game.json", map[string]interface{}{"deviceOrientation": orientation})
+	}
+
+	subPackages, _ := config["subpackages"].([]interface{})
+	if subPackages == nil {
+		subPackages, _ = config["subPackages"].([]interface{})
+	}
+	for index, raw := range subPackages {
+		game.SubPackageCount++
+		entry, _ := raw.(map[string]interface{})
+		root, _ := entry["root"].(string)
+		found := gameSubPackageExists(sourceDir, root)
+		if !found {
+			game.MissingSubPackages = append(game.MissingSubPackages, root)
+		}
+		check(found, "verify.game.subpackage_missing", "分包入口未找到，请将该分包与主包一并上传", "game.json", map[string]interface{}{"index": index, "root": root})
+	}
+
+	switch workers := config["workers"].(type) {
+	case string:
+		game.Workers = workers
+	case map[string]interface{}:
+		game.Workers, _ = workers["path"].(string)
+	}
+	if game.Workers != "" {
+		game.WorkersPresent = gameDirExists(sourceDir, game.Workers)
+		check(game.WorkersPresent, "verify.game.workers_missing", "workers 目录未找到", "game.json", map[string]interface{}{"workers": game.Workers})
+	}
+
+	if openDataContext, ok := config["openDataContext"].(string); ok && openDataContext != "" {
+		game.OpenDataContext = openDataContext
+		check(gameDirExists(sourceDir, openDataContext), "verify.game.open_data_context_missing", "开放数据域目录未找到", "game.json", map[string]interface{}{"openDataContext": openDataContext})
+	}
+}
+
+// gameSubPackageExists resolves a game subpackage root, which is either a
+// directory holding game.js or a single .js file.
+func gameSubPackageExists(sourceDir, root string) bool {
+	base, safe := safePageBase(sourceDir, strings.Trim(root, "/"))
+	if !safe {
+		return false
+	}
+	if path.Ext(root) == ".js" {
+		return fileExists(base)
+	}
+	return fileExists(filepath.Join(base, "game.js"))
+}
+
+func gameDirExists(sourceDir, dir string) bool {
+	base, safe := safePageBase(sourceDir, strings.Trim(dir, "/"))
+	if !safe {
+		return false
+	}
+	info, err := os.Stat(base)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,44 @@
+package verify
+
+import (
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+func TestVerifyManifestChecksGameConfigInsteadOfPages(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeVerifyFixture(t, sourceDir, "game.js", "require('js/main.js')")
+	writeVerifyFixture(t, sourceDir, "stage/game.js", "")
+	writeVerifyFixture(t, sourceDir, "single.js", "")
+	writeVerifyFixture(t, sourceDir, "workers/index.js", "")
+	writeVerifyFixture(t, sourceDir, "game.json", `{
+		"deviceOrientation": "sideways",
+		"subpackages": [{"root": "stage/"}, {"root": "single.js"}, {"root": "missing/"}, {"root": "../escape"}],
+		"workers": {"path": "workers"}
+	}`)
+	normalized := &pkg.NormalizedPackage{Profile: pkg.PackageProfile{IsGamePackage: true}}
+
+	result, err := VerifyManifest(normalized, sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := result.Game
+	if game == nil || result.PageCount != 0 {
+		t.Fatalf("game packages must not be verified as pages: %#v", result)
+	}
+	if !game.EntryPresent || !game.ConfigPresent || !game.WorkersPresent || game.SubPackageCount != 4 {
+		t.Fatalf("unexpected game checks: %#v", game)
+	}
+	if len(game.MissingSubPackages) != 2 || game.MissingSubPackages[0] != "missing/" {
+		t.Fatalf("unexpected missing subpackages: %#v", game.MissingSubPackages)
+	}
+	if result.Success || result.ManifestIssueCount != 3 || game.Checks != 8 || game.PassedChecks != 5 {
+		t.Fatalf("orientation and subpackage issues were not counted: %#v %#v", result, game)
+	}
+	for _, diagnostic := range result.Diagnostics {
+		if diagnostic.Code == "verify.manifest.pages_empty" {
+			t.Fatalf("game reported missing pages: %#v", result.Diagnostics)
+		}
+	}
+}
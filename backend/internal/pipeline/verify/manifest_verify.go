@@ -10,13 +10,16 @@ import (
 )
 
 type ManifestVerifyResult struct {
-	Success            bool             `json:"success"`
-	PageCount          int              `json:"pageCount"`
-	MissingPages       []string         `json:"missingPages,omitempty"`
-	InvalidPagePaths   []string         `json:"invalidPagePaths,omitempty"`
-	InvalidTabBarPages []string         `json:"invalidTabBarPages,omitempty"`
-	ManifestIssueCount int              `json:"manifestIssueCount"`
-	Diagnostics        []pkg.Diagnostic `json:"diagnostics,omitempty"`
+	Success            bool                `json:"success"`
+	PageCount          int                 `json:"pageCount"`
+	MissingPages       []string            `json:"missingPages,omitempty"`
+	InvalidPagePaths   []string            `json:"invalidPagePaths,omitempty"`
+	InvalidTabBarPages []string            `json:"invalidTabBarPages,omitempty"`
+	MissingSubPackages []string            `json:"missingSubPackages,omitempty"`
+	ManifestIssueCount int                 `json:"manifestIssueCount"`
+	Game               *GameVerifyResult   `json:"game,omitempty"`
+	Plugin             *PluginVerifyResult `json:"plugin,omitempty"`
+	Diagnostics        []pkg.Diagnostic    `json:"diagnostics,omitempty"`
 }
 
 func VerifyManifest(np *pkg.NormalizedPackage, sourceDir string) (*ManifestVerifyResult, error) {
@@ -24,6 +27,15 @@ func VerifyManifest(np *pkg.NormalizedPackage, sourceDir string) (*ManifestVerif
 		Success:   true,
 		PageCount: len(np.Manifest.Pages),
 	}
+	if np.Profile.IsGamePackage {
+		result.PageCount = 0
+		verifyGameManifest(sourceDir, result)
+		return result, nil
+	}
+	if np.Plugin != nil {
+		verifyPluginManifest(np.Plugin, np.Manifest.Pages, sourceDir, result)
+		return result, nil
+	}
 	if len(np.Manifest.Pages) == 0 {
 		result.Success = false
 		result.ManifestIssueCount = 1
@@ -56,28 +68,11 @@ func VerifyManifest(np *pkg.NormalizedPackage, sourceDir string) (*ManifestVerif
 			continue
 		}
 
-		exists := false
-		base, safe := safePageBase(sourceDir, pageRoute)
-		if !safe {
-			result.Success = false
-			result.ManifestIssueCount++
-			result.InvalidPagePaths = append(result.InvalidPagePaths, pageRoute)
-			result.MissingPages = append(result.MissingPages, pageRoute)
-			continue
-		}
-		for _, ext := range []string{".js", ".wxml", ".json"} {
-			if _, err := os.Stat(base + ext); err == nil {
-				exists = true
-				break
-			}
-		}
-		if !exists {
-			result.Success = false
-			result.ManifestIssueCount++
-			result.MissingPages = append(result.MissingPages, pageRoute)
-		}
+		checkPageExists(sourceDir, pageRoute, result)
 	}
 
+	verifySubPackagePages(np.Manifest.SubPackages, sourceDir, seenPages, result)
+
 	if len(result.MissingPages) > 0 {
 		result.Diagnostics = append(result.Diagnostics, pkg.Warn("verify.manifest.missing_pages", "部分 manifest 页面未找到对应源码文件", "verifying", "app.json"))
 	}
@@ -87,6 +82,75 @@ func VerifyManifest(np *pkg.NormalizedPackage, sourceDir string) (*ManifestVerif
 	return result, nil
 }
 
+func checkPageExists(sourceDir, pageRoute string, result *ManifestVerifyResult) bool {
+	base, safe := safePageBase(sourceDir, pageRoute)
+	if !safe {
+		result.Success = false
+		result.ManifestIssueCount++
+		result.InvalidPagePaths = append(result.InvalidPagePaths, pageRoute)
+		result.MissingPages = append(result.MissingPages, pageRoute)
+		return false
+	}
+	if pageFileExists(base) {
+		return true
+	}
+	result.Success = false
+	result.ManifestIssueCount++
+	result.MissingPages = append(result.MissingPages, pageRoute)
+	return false
+}
+
+// verifySubPackagePages resolves every `subPackages[].pages` entry against its
+// root. app-config.json usually repeats these routes in pages, in which case
+// they were already checked above; the subpackage view only adds routes the
+// main list omits and reports subpackages with no recovered page at all, which
+// almost always means that subpackage was not uploaded with the main package.
+func verifySubPackagePages(subPackages []pkg.SubPackageIR, sourceDir string, checked map[string]struct{}, result *ManifestVerifyResult) {
+	for _, subPackage := range subPackages {
+		root := strings.Trim(subPackage.Root, "/")
+		if root == "" || len(subPackage.Pages) == 0 {
+			continue
+		}
+		found := 0
+		for _, page := range subPackage.Pages {
+			pageRoute := path.Join(root, strings.TrimPrefix(page, "/"))
+			if _, done := checked[pageRoute]; done {
+				if base, safe := safePageBase(sourceDir, pageRoute); safe && pageFileExists(base) {
+					found++
+				}
+				continue
+			}
+			checked[pageRoute] = struct{}{}
+			result.PageCount++
+			if !validManifestRoute(pageRoute) {
+				result.Success = false
+				result.ManifestIssueCount++
+				result.InvalidPagePaths = append(result.InvalidPagePaths, pageRoute)
+				result.MissingPages = append(result.MissingPages, pageRoute)
+				continue
+			}
+			if checkPageExists(sourceDir, pageRoute, result) {
+				found++
+			}
+		}
+		if found == 0 {
+			result.MissingSubPackages = append(result.MissingSubPackages, root)
+			diagnostic := pkg.Warn("verify.manifest.subpackage_missing", "分包页面均未找到对应源码，请将该分包与主包一并上传", "verifying", "app.json")
+			diagnostic.Metadata = map[string]interface{}{"root": root}
+			result.Diagnostics = append(result.Diagnostics, diagnostic)
+		}
+	}
+}
+
+func pageFileExists(base string) bool {
+	for _, ext := range []string{".js", ".wxml", ".json"} {
+		if _, err := os.Stat(base + ext); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 func verifyTabBarRoutes(manifest *pkg.ManifestIR, pageSet map[string]struct{}, result *ManifestVerifyResult) {
 	if manifest == nil || len(manifest.TabBar) == 0 {
 		return
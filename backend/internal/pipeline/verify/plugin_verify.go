@@ -0,0 +1,82 @@
+package verify
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+// PluginVerifyResult covers the plugin.json entries a plugin exports next
+// to its pages: the public components and the main module.
+type PluginVerifyResult struct {
+	ConfigPresent     bool     `json:"configPresent"`
+	PublicComponents  int      `json:"publicComponents"`
+	MissingComponents []string `json:"missingComponents,omitempty"`
+	Main              string   `json:"main,omitempty"`
+	MainPresent       bool     `json:"mainPresent"`
+}
+
+// verifyPluginManifest checks the pages and public components of a plugin.
+// Both count towards PageCount, so a component-only plugin is scored by its
+// components.
+func verifyPluginManifest(plugin *pkg.PluginManifestIR, pages []string, sourceDir string, result *ManifestVerifyResult) {
+	verified := &PluginVerifyResult{}
+	result.Plugin = verified
+	addIssue := func(code, message string, metadata map[string]interface{}) {
+		result.Success = false
+		result.ManifestIssueCount++
+		diagnostic := pkg.Warn(code, message, "verifying", "plugin.json")
+		diagnostic.Metadata = metadata
+		result.Diagnostics = append(result.Diagnostics, diagnostic)
+	}
+
+	var config map[string]interface{}
+	data, err := os.ReadFile(filepath.Join(sourceDir, filepath.FromSlash(plugin.Root), "plugin.json"))
+	if err == nil {
+		err = json.Unmarshal(data, &config)
+	}
+	verified.ConfigPresent = err == nil && config != nil
+	if !verified.ConfigPresent {
+		addIssue("verify.plugin.config_invalid", "plugin.json 缺失或不是有效的 JSON 对象", nil)
+	}
+
+	for _, pageRoute := range pages {
+		checkPageExists(sourceDir, pageRoute, result)
+	}
+	if len(result.MissingPages) > 0 {
+		result.Diagnostics = append(result.Diagnostics, pkg.Warn("verify.manifest.missing_pages", "部分插件页面未找到对应源码文件", "verifying", "plugin.json"))
+	}
+
+	names := make([]string, 0, len(plugin.PublicComponents))
+	for name := range plugin.PublicComponents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		verified.PublicComponents++
+		base, safe := safePageBase(sourceDir, plugin.ResolvePath(plugin.PublicComponents[name]))
+		if safe && fileExists(base+".js") && fileExists(base+".wxml") {
+			continue
+		}
+		verified.MissingComponents = append(verified.MissingComponents, name)
+		addIssue("verify.plugin.component_missing", "插件公开组件缺少 js/wxml 源码", map[string]interface{}{"component": name, "path": plugin.PublicComponents[name]})
+	}
+	result.PageCount = len(pages) + verified.PublicComponents
+
+	if plugin.Main != "" {
+		verified.Main = plugin.Main
+		main := plugin.ResolvePath(plugin.Main)
+		base, safe := safePageBase(sourceDir, main)
+		verified.MainPresent = safe && fileExists(base+".js")
+		if !verified.MainPresent {
+			addIssue("verify.plugin.main_missing", "plugin.json 的 main 入口文件未找到", map[string]interface{}{"main": plugin.Main})
+		}
+	}
+
+	if result.PageCount == 0 {
+		addIssue("verify.plugin.exports_empty", "插件未导出任何页面或公开组件", nil)
+	}
+}
@@ -0,0 +1,39 @@
+package verify
+
+import (
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+func TestVerifyManifestChecksPluginExports(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeVerifyFixture(t, sourceDir, "plugin.json", `{"main":"index.js"}`)
+	writeVerifyFixture(t, sourceDir, "pages/hello/index.js", "Page({})")
+	writeVerifyFixture(t, sourceDir, "components/list/list.js", "Component({})")
+	writeVerifyFixture(t, sourceDir, "components/list/list.wxml", "<view/>")
+	writeVerifyFixture(t, sourceDir, "components/card/card.js", "Component({})")
+	normalized := &pkg.NormalizedPackage{
+		Manifest: pkg.ManifestIR{Pages: []string{"pages/hello/index"}},
+		Plugin: &pkg.PluginManifestIR{
+			Main:             "index.js",
+			Pages:            map[string]string{"hello": "pages/hello/index"},
+			PublicComponents: map[string]string{"list": "components/list/list", "card": "components/card/card"},
+		},
+	}
+
+	result, err := VerifyManifest(normalized, sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin := result.Plugin
+	if plugin == nil || !plugin.ConfigPresent || plugin.PublicComponents != 2 {
+		t.Fatalf("unexpected plugin checks: %#v", result)
+	}
+	if len(plugin.MissingComponents) != 1 || plugin.MissingComponents[0] != "card" || plugin.MainPresent {
+		t.Fatalf("missing component and main were not reported: %#v", plugin)
+	}
+	if result.Success || result.PageCount != 3 || result.ManifestIssueCount != 2 || len(result.MissingPages) != 0 {
+		t.Fatalf("unexpected manifest result: %#v", result)
+	}
+}
@@ -40,6 +40,43 @@ func TestVerifyManifestRejectsInvalidTabBarRoutes(t *testing.T) {
 	}
 }
 
+func TestVerifyManifestResolvesSubPackagePagesAgainstMergedTree(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeVerifyFixture(t, sourceDir, "pages/home/index.js", "Page({})")
+	writeVerifyFixture(t, sourceDir, "packageA/pages/detail/index.js", "Page({})")
+	normalized := &pkg.NormalizedPackage{Manifest: pkg.ManifestIR{
+		Pages: []string{"pages/home/index", "packageA/pages/detail/index"},
+		SubPackages: []pkg.SubPackageIR{
+			{Root: "packageA/", Pages: []string{"pages/detail/index", "pages/list/index"}},
+			{Root: "packageB", Pages: []string{"pages/about/index"}},
+		},
+	}}
+
+	result, err := VerifyManifest(normalized, sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PageCount != 4 {
+		t.Fatalf("subpackage routes missing from pages must be counted once: %#v", result)
+	}
+	if len(result.MissingPages) != 2 || result.MissingPages[0] != "packageA/pages/list/index" || result.MissingPages[1] != "packageB/pages/about/index" {
+		t.Fatalf("unexpected missing pages: %#v", result.MissingPages)
+	}
+	if len(result.MissingSubPackages) != 1 || result.MissingSubPackages[0] != "packageB" {
+		t.Fatalf("only the absent subpackage should be reported: %#v", result.MissingSubPackages)
+	}
+
+	writeVerifyFixture(t, sourceDir, "packageA/pages/list/index.wxml", "<view/>")
+	writeVerifyFixture(t, sourceDir, "packageB/pages/about/index.json", "{}")
+	merged, err := VerifyManifest(normalized, sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !merged.Success || len(merged.MissingPages) != 0 || len(merged.MissingSubPackages) != 0 {
+		t.Fatalf("merged subpackages should satisfy the manifest: %#v", merged)
+	}
+}
+
 func TestVerifyArtifactsSeparatesParseabilityFromWXMLQuality(t *testing.T) {
 	sourceDir := t.TempDir()
 	writeVerifyFixture(t, sourceDir, "pages/home/index.js", "Page({})")
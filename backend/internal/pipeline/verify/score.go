@@ -7,6 +7,9 @@ func ComputeRecoveryScore(manifest *ManifestVerifyResult, artifacts *ArtifactVer
 		DecompileHit: decompileRequested,
 		FallbackUsed: fallbackUsed,
 	}
+	if manifest != nil && manifest.Game != nil {
+		return computeGameScore(score, manifest, artifacts)
+	}
 
 	if manifest != nil {
 		if manifest.PageCount == 0 {
@@ -69,6 +72,32 @@ func ComputeRecoveryScore(manifest *ManifestVerifyResult, artifacts *ArtifactVer
 	return score
 }
 
+// computeGameScore scores a mini-game, which has no page triplets: the
+// manifest score is the share of passed game.json checks and JS carries the
+// weight WXML and WXSS have for apps.
+func computeGameScore(score *task.RecoveryScore, manifest *ManifestVerifyResult, artifacts *ArtifactVerifyResult) *task.RecoveryScore {
+	score.Kind = "game"
+	score.Manifest = ratio(manifest.Game.PassedChecks, manifest.Game.Checks)
+	if artifacts != nil {
+		score.JS = ratio(artifacts.JSParseable, artifacts.JSFiles)
+	}
+	score.VerifierPassed = manifest.Success && artifacts != nil && artifacts.Success && artifacts.VerifierPassed
+
+	if score.FallbackUsed {
+		score.FallbackPenalty = 10
+	}
+	verifierWeight := 0
+	if score.VerifierPassed {
+		verifierWeight = 100
+	}
+	overall := float64(score.Manifest)*0.4 + float64(score.JS)*0.5 + float64(verifierWeight)*0.1
+	score.Overall = max(int(overall)-score.FallbackPenalty, 0)
+	if !score.VerifierPassed {
+		score.Overall = min(score.Overall, 79)
+	}
+	return score
+}
+
 func ratio(ok, total int) int {
 	if total <= 0 {
 		return 0
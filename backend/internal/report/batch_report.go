@@ -0,0 +1,125 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+)
+
+const maxBatchTopDiagnostics = 5
+
+type BatchReport struct {
+	BatchID   string            `json:"batchId"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Done      bool              `json:"done"`
+	Total     int               `json:"total"`
+	Summary   map[string]int    `json:"summary"`
+	Items     []BatchItemReport `json:"items"`
+}
+
+type BatchItemReport struct {
+	Index          int                 `json:"index"`
+	Name           string              `json:"name"`
+	TaskID         string              `json:"taskId,omitempty"`
+	Status         string              `json:"status"`
+	Score          *task.RecoveryScore `json:"score,omitempty"`
+	ErrorCode      string              `json:"errorCode,omitempty"`
+	TopDiagnostics []DiagnosticCount   `json:"topDiagnostics,omitempty"`
+}
+
+type DiagnosticCount struct {
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	Count    int    `json:"count"`
+}
+
+// BuildBatchReport aggregates the current state of every task in a batch.
+// Items whose task record is gone (retention, memory repository restart) are
+// reported as "expired" rather than failing the whole report.
+func BuildBatchReport(batch *task.Batch, tasks map[string]*task.Task) *BatchReport {
+	result := &BatchReport{
+		BatchID:   batch.ID,
+		CreatedAt: batch.CreatedAt,
+		Done:      true,
+		Total:     len(batch.Items),
+		Summary:   make(map[string]int),
+		Items:     make([]BatchItemReport, 0, len(batch.Items)),
+	}
+	for _, item := range batch.Items {
+		entry := BatchItemReport{Index: item.Index, Name: item.Name, TaskID: item.TaskID, ErrorCode: item.ErrorCode}
+		current := tasks[item.TaskID]
+		switch {
+		case item.TaskID == "":
+			entry.Status = string(task.TaskFailed)
+		case current == nil:
+			entry.Status = "expired"
+		default:
+			entry.Status = string(current.Status)
+			if current.RecoveryScore != nil {
+				score := *current.RecoveryScore
+				entry.Score = &score
+			}
+			if current.ErrorCode != nil {
+				entry.ErrorCode = *current.ErrorCode
+			}
+			entry.TopDiagnostics = topDiagnosticCodes(current, maxBatchTopDiagnostics)
+			if !isTerminalStatus(current.Status) {
+				result.Done = false
+			}
+		}
+		result.Summary[entry.Status]++
+		result.Items = append(result.Items, entry)
+	}
+	return result
+}
+
+// topDiagnosticCodes ranks codes by severity first, then frequency, so a
+// single error is not hidden behind many informational notes.
+func topDiagnosticCodes(t *task.Task, limit int) []DiagnosticCount {
+	index := make(map[string]int)
+	var counts []DiagnosticCount
+	for _, diagnostic := range SanitizeDiagnostics(t.Diagnostics) {
+		if diagnostic.Code == "" {
+			continue
+		}
+		position, ok := index[diagnostic.Code]
+		if !ok {
+			position = len(counts)
+			index[diagnostic.Code] = position
+			counts = append(counts, DiagnosticCount{Code: diagnostic.Code, Severity: string(diagnostic.Severity)})
+		}
+		if severityRank(string(diagnostic.Severity)) > severityRank(counts[position].Severity) {
+			counts[position].Severity = string(diagnostic.Severity)
+		}
+		counts[position].Count++
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		if left, right := severityRank(counts[i].Severity), severityRank(counts[j].Severity); left != right {
+			return left > right
+		}
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Code < counts[j].Code
+	})
+	if len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case "error":
+		return 2
+	case "warn":
+		return 1
+	default:
+		return 0
+	}
+}
+
+func isTerminalStatus(status task.TaskStatus) bool {
+	return status.IsTerminal()
+}
@@ -0,0 +1,70 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+)
+
+func TestBuildBatchReportAggregatesTaskOutcomes(t *testing.T) {
+	batch := &task.Batch{
+		ID:        "batch-1",
+		CreatedAt: time.Unix(0, 0),
+		Items: []task.BatchItem{
+			{Index: 0, Name: "a.wxapkg", TaskID: "task-a"},
+			{Index: 1, Name: "b.wxapkg", TaskID: "task-b"},
+			{Index: 2, Name: "c.wxapkg", ErrorCode: "task_create_failed"},
+			{Index: 3, Name: "d.wxapkg", TaskID: "task-gone"},
+		},
+	}
+	code := "app_id_required"
+	tasks := map[string]*task.Task{
+		"task-a": {
+			ID:            "task-a",
+			Status:        task.TaskPartial,
+			RecoveryScore: &task.RecoveryScore{Overall: 72},
+			Diagnostics: []pkg.Diagnostic{
+				pkg.Info("recover.js.native", "ok", "recovering_js", ""),
+				pkg.Info("recover.js.native", "ok", "recovering_js", ""),
+				pkg.Warn("verify.page.missing", "missing", "verifying", ""),
+				pkg.Error("recover.wxml.failed", "failed", "recovering_wxml", ""),
+			},
+		},
+		"task-b": {ID: "task-b", Status: task.TaskFailed, ErrorCode: &code},
+	}
+
+	result := BuildBatchReport(batch, tasks)
+
+	if !result.Done || result.Total != 4 {
+		t.Fatalf("done=%v total=%d", result.Done, result.Total)
+	}
+	wantSummary := map[string]int{"partial": 1, "failed": 2, "expired": 1}
+	if !reflect.DeepEqual(result.Summary, wantSummary) {
+		t.Fatalf("summary = %#v, want %#v", result.Summary, wantSummary)
+	}
+	if result.Items[0].Score == nil || result.Items[0].Score.Overall != 72 {
+		t.Fatalf("score not copied: %#v", result.Items[0].Score)
+	}
+	codes := []string{}
+	for _, item := range result.Items[0].TopDiagnostics {
+		codes = append(codes, item.Code)
+	}
+	wantCodes := []string{"recover.wxml.failed", "verify.page.missing", "recover.js.native"}
+	if !reflect.DeepEqual(codes, wantCodes) {
+		t.Fatalf("top diagnostics = %#v, want %#v", codes, wantCodes)
+	}
+	if result.Items[1].ErrorCode != code || result.Items[2].ErrorCode != "task_create_failed" {
+		t.Fatalf("error codes not reported: %#v", result.Items)
+	}
+}
+
+func TestBuildBatchReportIsNotDoneWhileTasksRun(t *testing.T) {
+	batch := &task.Batch{ID: "batch-1", Items: []task.BatchItem{{TaskID: "task-a"}}}
+	result := BuildBatchReport(batch, map[string]*task.Task{"task-a": {ID: "task-a", Status: task.TaskUnpacking}})
+	if result.Done {
+		t.Fatal("batch with a running task must not be done")
+	}
+}
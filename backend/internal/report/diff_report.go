@@ -0,0 +1,364 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+const (
+	maxTextDiffFiles = 200
+	// Files above maxTextDiffInputBytes are only listed as modified; patches
+	// above maxTextDiffOutputBytes are cut so one rewritten bundle cannot
+	// dominate the report.
+	maxTextDiffInputBytes  = 1 << 20
+	maxTextDiffOutputBytes = 64 << 10
+)
+
+// DiffSide is one recovered build as seen by BuildDiffReport.
+type DiffSide struct {
+	TaskID string
+	// Files are source-relative paths taken from the task's zip-manifest.
+	Files      []string
+	SourceDir  string
+	Manifest   pkg.ManifestIR
+	Pages      []pkg.PageIR
+	Beautified bool
+}
+
+type DiffReport struct {
+	DiffID     string    `json:"diffId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt,omitzero"`
+	BaseTaskID string    `json:"baseTaskId,omitempty"`
+	HeadTaskID string    `json:"headTaskId,omitempty"`
+	// Status is "pending" until both tasks are terminal, then "ready", or
+	// "failed"/"expired" when a side has no usable result.
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Beautified reports whether both trees went through the final formatting
+	// stage, i.e. whether the text diffs are free of formatting noise.
+	Beautified bool              `json:"beautified"`
+	Summary    *DiffSummary      `json:"summary,omitempty"`
+	Files      *FileChanges      `json:"files,omitempty"`
+	Routes     *RouteChanges     `json:"routes,omitempty"`
+	Components []ComponentChange `json:"components,omitempty"`
+	TextDiffs  []TextDiff        `json:"textDiffs,omitempty"`
+}
+
+type DiffSummary struct {
+	FilesAdded       int `json:"filesAdded"`
+	FilesRemoved     int `json:"filesRemoved"`
+	FilesModified    int `json:"filesModified"`
+	RoutesAdded      int `json:"routesAdded"`
+	RoutesRemoved    int `json:"routesRemoved"`
+	ComponentChanges int `json:"componentChanges"`
+}
+
+type FileChanges struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+type RouteChanges struct {
+	PagesAdded   []string           `json:"pagesAdded,omitempty"`
+	PagesRemoved []string           `json:"pagesRemoved,omitempty"`
+	SubPackages  []SubPackageChange `json:"subPackages,omitempty"`
+	TabBar       *TabBarChange      `json:"tabBar,omitempty"`
+}
+
+type SubPackageChange struct {
+	Root         string   `json:"root"`
+	Change       string   `json:"change"`
+	PagesAdded   []string `json:"pagesAdded,omitempty"`
+	PagesRemoved []string `json:"pagesRemoved,omitempty"`
+}
+
+type TabBarChange struct {
+	PagesAdded   []string `json:"pagesAdded,omitempty"`
+	PagesRemoved []string `json:"pagesRemoved,omitempty"`
+	// ConfigChanged is set whenever the tabBar object differs, so a changed
+	// text, icon or colour is reported even when every route is unchanged.
+	ConfigChanged bool `json:"configChanged"`
+}
+
+// ComponentChange lists the `usingComponents` differences of one page.
+type ComponentChange struct {
+	Page    string                         `json:"page"`
+	Added   map[string]string              `json:"added,omitempty"`
+	Removed map[string]string              `json:"removed,omitempty"`
+	Changed map[string]ComponentPathChange `json:"changed,omitempty"`
+}
+
+type ComponentPathChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type TextDiff struct {
+	Path      string `json:"path"`
+	Diff      string `json:"diff,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	// Skipped explains why no patch is attached.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// BuildDiffReport compares two recovered builds of the same mini program.
+// File lists come from the zip-manifests, contents from the delivered source
+// trees and route/component changes from the normalized manifest and pages.
+func BuildDiffReport(base, head DiffSide) (*DiffReport, error) {
+	result := &DiffReport{
+		BaseTaskID: base.TaskID,
+		HeadTaskID: head.TaskID,
+		Status:     "ready",
+		Beautified: base.Beautified && head.Beautified,
+	}
+	files, err := diffFiles(base, head)
+	if err != nil {
+		return nil, err
+	}
+	result.Files = files
+	result.Routes = diffRoutes(base.Manifest, head.Manifest)
+	result.Components = diffComponents(base.Pages, head.Pages)
+	if result.TextDiffs, err = diffTexts(base.SourceDir, head.SourceDir, files.Modified); err != nil {
+		return nil, err
+	}
+	result.Summary = &DiffSummary{
+		FilesAdded:       len(files.Added),
+		FilesRemoved:     len(files.Removed),
+		FilesModified:    len(files.Modified),
+		RoutesAdded:      len(result.Routes.PagesAdded),
+		RoutesRemoved:    len(result.Routes.PagesRemoved),
+		ComponentChanges: len(result.Components),
+	}
+	for _, change := range result.Routes.SubPackages {
+		result.Summary.RoutesAdded += len(change.PagesAdded)
+		result.Summary.RoutesRemoved += len(change.PagesRemoved)
+	}
+	return result, nil
+}
+
+func diffFiles(base, head DiffSide) (*FileChanges, error) {
+	changes := &FileChanges{Added: []string{}, Removed: []string{}, Modified: []string{}}
+	headFiles := make(map[string]struct{}, len(head.Files))
+	for _, file := range head.Files {
+		headFiles[file] = struct{}{}
+	}
+	for _, file := range base.Files {
+		if _, kept := headFiles[file]; !kept {
+			changes.Removed = append(changes.Removed, file)
+			continue
+		}
+		delete(headFiles, file)
+		same, err := sameFileContent(filepath.Join(base.SourceDir, filepath.FromSlash(file)), filepath.Join(head.SourceDir, filepath.FromSlash(file)))
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			changes.Modified = append(changes.Modified, file)
+		}
+	}
+	for file := range headFiles {
+		changes.Added = append(changes.Added, file)
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Modified)
+	return changes, nil
+}
+
+func sameFileContent(a, b string) (bool, error) {
+	left, err := os.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	right, err := os.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(left, right), nil
+}
+
+func diffRoutes(base, head pkg.ManifestIR) *RouteChanges {
+	changes := &RouteChanges{}
+	changes.PagesAdded, changes.PagesRemoved = diffStringSets(base.Pages, head.Pages)
+
+	baseRoots := subPackagesByRoot(base.SubPackages)
+	headRoots := subPackagesByRoot(head.SubPackages)
+	for _, root := range sortedUnion(baseRoots, headRoots) {
+		before, inBase := baseRoots[root]
+		after, inHead := headRoots[root]
+		change := SubPackageChange{Root: root}
+		change.PagesAdded, change.PagesRemoved = diffStringSets(before.Pages, after.Pages)
+		switch {
+		case !inBase:
+			change.Change = "added"
+		case !inHead:
+			change.Change = "removed"
+		case len(change.PagesAdded) > 0 || len(change.PagesRemoved) > 0:
+			change.Change = "modified"
+		default:
+			continue
+		}
+		changes.SubPackages = append(changes.SubPackages, change)
+	}
+
+	tabBar := &TabBarChange{ConfigChanged: !reflect.DeepEqual(emptyAsNil(base.TabBar), emptyAsNil(head.TabBar))}
+	tabBar.PagesAdded, tabBar.PagesRemoved = diffStringSets(tabBarPages(base.TabBar), tabBarPages(head.TabBar))
+	if tabBar.ConfigChanged {
+		changes.TabBar = tabBar
+	}
+	return changes
+}
+
+func subPackagesByRoot(subPackages []pkg.SubPackageIR) map[string]pkg.SubPackageIR {
+	roots := make(map[string]pkg.SubPackageIR, len(subPackages))
+	for _, subPackage := range subPackages {
+		roots[subPackage.Root] = subPackage
+	}
+	return roots
+}
+
+func tabBarPages(tabBar map[string]interface{}) []string {
+	list, _ := tabBar["list"].([]interface{})
+	pages := make([]string, 0, len(list))
+	for _, item := range list {
+		entry, _ := item.(map[string]interface{})
+		if pagePath, ok := entry["pagePath"].(string); ok {
+			pages = append(pages, pagePath)
+		}
+	}
+	return pages
+}
+
+func emptyAsNil(value map[string]interface{}) map[string]interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	return value
+}
+
+func diffComponents(base, head []pkg.PageIR) []ComponentChange {
+	basePages := make(map[string]map[string]string, len(base))
+	for _, page := range base {
+		basePages[page.Path] = page.UsingComponents
+	}
+	headPages := make(map[string]map[string]string, len(head))
+	for _, page := range head {
+		headPages[page.Path] = page.UsingComponents
+	}
+
+	var changes []ComponentChange
+	for _, page := range sortedUnion(basePages, headPages) {
+		before, after := basePages[page], headPages[page]
+		change := ComponentChange{Page: page}
+		for name, path := range after {
+			previous, existed := before[name]
+			switch {
+			case !existed:
+				if change.Added == nil {
+					change.Added = map[string]string{}
+				}
+				change.Added[name] = path
+			case previous != path:
+				if change.Changed == nil {
+					change.Changed = map[string]ComponentPathChange{}
+				}
+				change.Changed[name] = ComponentPathChange{From: previous, To: path}
+			}
+		}
+		for name, path := range before {
+			if _, kept := after[name]; !kept {
+				if change.Removed == nil {
+					change.Removed = map[string]string{}
+				}
+				change.Removed[name] = path
+			}
+		}
+		if change.Added != nil || change.Removed != nil || change.Changed != nil {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+func diffTexts(baseDir, headDir string, modified []string) ([]TextDiff, error) {
+	var diffs []TextDiff
+	for _, file := range modified {
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".js", ".wxml", ".wxss":
+		default:
+			continue
+		}
+		entry := TextDiff{Path: file}
+		if len(diffs) >= maxTextDiffFiles {
+			entry.Skipped = fmt.Sprintf("only the first %d modified source files carry a patch", maxTextDiffFiles)
+			diffs = append(diffs, entry)
+			continue
+		}
+		before, err := os.ReadFile(filepath.Join(baseDir, filepath.FromSlash(file)))
+		if err != nil {
+			return nil, err
+		}
+		after, err := os.ReadFile(filepath.Join(headDir, filepath.FromSlash(file)))
+		if err != nil {
+			return nil, err
+		}
+		if len(before) > maxTextDiffInputBytes || len(after) > maxTextDiffInputBytes {
+			entry.Skipped = "file too large for a text diff"
+			diffs = append(diffs, entry)
+			continue
+		}
+		entry.Diff = UnifiedDiff(file, string(before), string(after))
+		if len(entry.Diff) > maxTextDiffOutputBytes {
+			cut := strings.LastIndexByte(entry.Diff[:maxTextDiffOutputBytes], '\n')
+			entry.Diff = entry.Diff[:cut+1]
+			entry.Truncated = true
+		}
+		diffs = append(diffs, entry)
+	}
+	return diffs, nil
+}
+
+// diffStringSets returns the values only in head (added) and only in base
+// (removed), each in the order they appear.
+func diffStringSets(base, head []string) (added, removed []string) {
+	inBase := make(map[string]struct{}, len(base))
+	for _, value := range base {
+		inBase[value] = struct{}{}
+	}
+	inHead := make(map[string]struct{}, len(head))
+	for _, value := range head {
+		inHead[value] = struct{}{}
+		if _, ok := inBase[value]; !ok {
+			added = append(added, value)
+		}
+	}
+	for _, value := range base {
+		if _, ok := inHead[value]; !ok {
+			removed = append(removed, value)
+		}
+	}
+	return added, removed
+}
+
+func sortedUnion[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,110 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	pkg "github.com/keepbuild/seewxapkg/internal/domain/pkg"
+)
+
+func writeDiffTree(t *testing.T, files map[string]string) (string, []string) {
+	t.Helper()
+	root := t.TempDir()
+	names := make([]string, 0, len(files))
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return root, names
+}
+
+func TestBuildDiffReportCoversFilesRoutesComponentsAndText(t *testing.T) {
+	baseDir, baseFiles := writeDiffTree(t, map[string]string{
+		"app.json":            `{}`,
+		"pages/home/index.js": "Page({\n  data: {},\n  onLoad() {}\n})\n",
+		"pages/old/index.js":  "Page({})\n",
+	})
+	headDir, headFiles := writeDiffTree(t, map[string]string{
+		"app.json":            `{}`,
+		"pages/home/index.js": "Page({\n  data: { ready: true },\n  onLoad() {}\n})\n",
+		"pages/new/index.js":  "Page({})\n",
+	})
+	base := DiffSide{
+		TaskID: "base", Files: baseFiles, SourceDir: baseDir, Beautified: true,
+		Manifest: pkg.ManifestIR{
+			Pages:       []string{"pages/home/index", "pages/old/index"},
+			SubPackages: []pkg.SubPackageIR{{Root: "packageA", Pages: []string{"pages/a"}}},
+			TabBar:      map[string]interface{}{"list": []interface{}{map[string]interface{}{"pagePath": "pages/home/index"}}},
+		},
+		Pages: []pkg.PageIR{{Path: "pages/home/index", UsingComponents: map[string]string{"card": "/components/card", "old": "/components/old"}}},
+	}
+	head := DiffSide{
+		TaskID: "head", Files: headFiles, SourceDir: headDir, Beautified: true,
+		Manifest: pkg.ManifestIR{
+			Pages:       []string{"pages/home/index", "pages/new/index"},
+			SubPackages: []pkg.SubPackageIR{{Root: "packageA", Pages: []string{"pages/a", "pages/b"}}, {Root: "packageB"}},
+			TabBar:      map[string]interface{}{"list": []interface{}{map[string]interface{}{"pagePath": "pages/home/index"}, map[string]interface{}{"pagePath": "pages/new/index"}}},
+		},
+		Pages: []pkg.PageIR{{Path: "pages/home/index", UsingComponents: map[string]string{"card": "/components/card-v2", "badge": "/components/badge"}}},
+	}
+
+	result, err := BuildDiffReport(base, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFiles := &FileChanges{Added: []string{"pages/new/index.js"}, Removed: []string{"pages/old/index.js"}, Modified: []string{"pages/home/index.js"}}
+	if !reflect.DeepEqual(result.Files, wantFiles) {
+		t.Fatalf("files = %#v", result.Files)
+	}
+	routes := result.Routes
+	if !reflect.DeepEqual(routes.PagesAdded, []string{"pages/new/index"}) || !reflect.DeepEqual(routes.PagesRemoved, []string{"pages/old/index"}) {
+		t.Fatalf("page routes = %#v", routes)
+	}
+	if len(routes.SubPackages) != 2 || routes.SubPackages[0].Change != "modified" || routes.SubPackages[1].Change != "added" {
+		t.Fatalf("subpackage changes = %#v", routes.SubPackages)
+	}
+	if routes.TabBar == nil || !reflect.DeepEqual(routes.TabBar.PagesAdded, []string{"pages/new/index"}) {
+		t.Fatalf("tabBar change = %#v", routes.TabBar)
+	}
+	wantComponents := []ComponentChange{{
+		Page:    "pages/home/index",
+		Added:   map[string]string{"badge": "/components/badge"},
+		Removed: map[string]string{"old": "/components/old"},
+		Changed: map[string]ComponentPathChange{"card": {From: "/components/card", To: "/components/card-v2"}},
+	}}
+	if !reflect.DeepEqual(result.Components, wantComponents) {
+		t.Fatalf("components = %#v", result.Components)
+	}
+	if len(result.TextDiffs) != 1 || !strings.Contains(result.TextDiffs[0].Diff, "-  data: {},\n+  data: { ready: true },\n") {
+		t.Fatalf("text diff = %#v", result.TextDiffs)
+	}
+	if !result.Beautified || result.Summary.RoutesAdded != 2 {
+		t.Fatalf("summary = %#v beautified=%v", result.Summary, result.Beautified)
+	}
+}
+
+func TestUnifiedDiffProducesStandardHunks(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	want := "--- a/x.js\n+++ b/x.js\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -11,3 +11,4 @@\n k\n l\n m\n+n\n"
+	if got := UnifiedDiff("x.js", before, after); got != want {
+		t.Fatalf("diff =\n%s\nwant\n%s", got, want)
+	}
+	if UnifiedDiff("x.js", before, before) != "" {
+		t.Fatal("identical inputs must produce no diff")
+	}
+	if got := UnifiedDiff("new.js", "", "x\n"); got != "--- a/new.js\n+++ b/new.js\n@@ -0,0 +1 @@\n+x\n" {
+		t.Fatalf("insert-only diff =\n%s", got)
+	}
+}
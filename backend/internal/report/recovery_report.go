@@ -73,7 +73,7 @@ func BuildRecoveryReport(t *task.Task) *RecoveryReport {
 		report.Packaging.Status = "ready"
 		report.Packaging.DownloadReady = true
 		report.Packaging.ArchiveSize = t.ArtifactSummary.ArchiveSize
-	} else if t.Status == task.TaskFailed {
+	} else if t.Status == task.TaskFailed || t.Status == task.TaskCancelled {
 		report.Packaging.Status = "unavailable"
 	}
 	return report
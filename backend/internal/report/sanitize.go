@@ -41,6 +41,7 @@ var (
 		"invalidTabBarPages":          {},
 		"isEncrypted":                 {},
 		"manifestPassed":              {},
+		"mergeConflicts":              {},
 		"missingPages":                {},
 		"mode":                        {},
 		"native":                      {},
@@ -52,6 +53,8 @@ var (
 		"scripts":                     {},
 		"skipped":                     {},
 		"styles":                      {},
+		"subPackageFiles":             {},
+		"subPackages":                 {},
 		"supportsRecovery":            {},
 		"templates":                   {},
 		"totalPages":                  {},
@@ -234,7 +237,7 @@ func sanitizeStageMetricValue(key string, value interface{}) (interface{}, bool)
 			return nil, false
 		}
 		switch text {
-		case "encrypted", "game", "standard", "subpackage", "unknown", "wechat4x":
+		case "encrypted", "game", "plugin", "standard", "subpackage", "unknown", "wechat4x":
 			return text, true
 		default:
 			return nil, false
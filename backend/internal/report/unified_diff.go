@@ -0,0 +1,200 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContextLines = 3
+	// maxDiffEditDistance bounds the Myers search; beyond it the changed
+	// region is emitted as one replacement hunk, which is still a valid diff.
+	maxDiffEditDistance = 1000
+)
+
+type lineOp struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// UnifiedDiff renders a `diff -u` style patch between two text files. It
+// returns an empty string when the contents are identical.
+func UnifiedDiff(path, before, after string) string {
+	if before == after {
+		return ""
+	}
+	ops := diffLines(splitLines(before), splitLines(after))
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", path, path)
+	writeHunks(&out, ops)
+	return out.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func diffLines(a, b []string) []lineOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]lineOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, lineOp{' ', line})
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if middle, ok := myersLines(middleA, middleB, maxDiffEditDistance); ok {
+		ops = append(ops, middle...)
+	} else {
+		for _, line := range middleA {
+			ops = append(ops, lineOp{'-', line})
+		}
+		for _, line := range middleB {
+			ops = append(ops, lineOp{'+', line})
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, lineOp{' ', line})
+	}
+	return ops
+}
+
+// myersLines is the O((N+M)D) greedy algorithm from Myers' "An O(ND)
+// Difference Algorithm and Its Variations". It keeps one frontier snapshot
+// per edit step for the backtrack and gives up after maxD steps.
+func myersLines(a, b []string, maxD int) ([]lineOp, bool) {
+	n, m := len(a), len(b)
+	if maxD > n+m {
+		maxD = n + m
+	}
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	trace := make([][]int, 0, 16)
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackMyers(a, b, trace, d), true
+			}
+		}
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+	}
+	return nil, false
+}
+
+func backtrackMyers(a, b []string, trace [][]int, depth int) []lineOp {
+	x, y := len(a), len(b)
+	reversed := make([]lineOp, 0, len(a)+len(b))
+	for d := depth; d > 0; d-- {
+		previous := trace[d-1]
+		base := d - 1
+		k := x - y
+		previousK := k - 1
+		if k == -d || (k != d && previous[base+k-1] < previous[base+k+1]) {
+			previousK = k + 1
+		}
+		previousX := previous[base+previousK]
+		previousY := previousX - previousK
+		for x > previousX && y > previousY {
+			reversed = append(reversed, lineOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == previousX {
+			reversed = append(reversed, lineOp{'+', b[y-1]})
+			y--
+		} else {
+			reversed = append(reversed, lineOp{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, lineOp{' ', a[x-1]})
+		x--
+		y--
+	}
+	ops := make([]lineOp, len(reversed))
+	for index, op := range reversed {
+		ops[len(reversed)-1-index] = op
+	}
+	return ops
+}
+
+func writeHunks(out *strings.Builder, ops []lineOp) {
+	// Line numbers before each op, so a hunk header can be read off directly.
+	aLine := make([]int, len(ops)+1)
+	bLine := make([]int, len(ops)+1)
+	for index, op := range ops {
+		aLine[index+1], bLine[index+1] = aLine[index], bLine[index]
+		if op.kind != '+' {
+			aLine[index+1]++
+		}
+		if op.kind != '-' {
+			bLine[index+1]++
+		}
+	}
+
+	for start := 0; start < len(ops); {
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			return
+		}
+		// Extend the hunk while the gap between changes fits in the context
+		// of two neighbouring hunks.
+		end := start
+		for index := start; index < len(ops); index++ {
+			if ops[index].kind != ' ' {
+				end = index + 1
+			} else if index-end >= 2*diffContextLines {
+				break
+			}
+		}
+		from := max(0, start-diffContextLines)
+		to := min(len(ops), end+diffContextLines)
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aLine[from], aLine[to]-aLine[from]), hunkRange(bLine[from], bLine[to]-bLine[from]))
+		for _, op := range ops[from:to] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+		start = to
+	}
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
@@ -1,19 +1,26 @@
 package report
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"os"
 	"sort"
 	"strings"
 
 	"github.com/keepbuild/seewxapkg/internal/infra/storage"
 )
 
+// ZipManifest lists the archive's entries. Archives are reproducible, so
+// ArchiveSHA256 is the same for every run over the same recovered tree.
 type ZipManifest struct {
-	TaskID string   `json:"taskId"`
-	Files  []string `json:"files"`
+	TaskID        string   `json:"taskId"`
+	ArchiveSHA256 string   `json:"archiveSha256"`
+	Files         []string `json:"files"`
 }
 
-func BuildZipManifest(taskID string, archiveEntries []string, archivePrefix string) (*ZipManifest, error) {
+func BuildZipManifest(taskID, archivePath string, archiveEntries []string, archivePrefix string) (*ZipManifest, error) {
 	if err := storage.ValidateZipEntryPath(archivePrefix); err != nil {
 		return nil, fmt.Errorf("invalid ZIP layout prefix %q", archivePrefix)
 	}
@@ -33,9 +40,27 @@ func BuildZipManifest(taskID string, archiveEntries []string, archivePrefix stri
 		manifest.Files = append(manifest.Files, entry)
 	}
 	sort.Strings(manifest.Files)
+	sum, err := fileSHA256(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	manifest.ArchiveSHA256 = sum
 	return manifest, nil
 }
 
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func WriteZipManifest(path string, manifest *ZipManifest) error {
 	return storage.WriteJSON(path, manifest)
 }
@@ -1,12 +1,18 @@
 package report
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
 
 func TestBuildZipLayoutListsOnlyPrefixedDeliverablesAndIsSorted(t *testing.T) {
-	manifest, err := BuildZipManifest("task-1", []string{"src/z.js", "src/pages/a.js"}, "src")
+	archive := filepath.Join(t.TempDir(), "task-1.zip")
+	if err := os.WriteFile(archive, []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := BuildZipManifest("task-1", archive, []string{"src/z.js", "src/pages/a.js"}, "src")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -14,17 +20,27 @@ func TestBuildZipLayoutListsOnlyPrefixedDeliverablesAndIsSorted(t *testing.T) {
 	if !reflect.DeepEqual(manifest.Files, want) {
 		t.Fatalf("files = %#v, want %#v", manifest.Files, want)
 	}
+	if manifest.ArchiveSHA256 != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Fatalf("archive sha256 = %s", manifest.ArchiveSHA256)
+	}
 }
 
 func TestBuildZipManifestRejectsUnsafeOutsideAndDuplicateEntries(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "task-1.zip")
+	if err := os.WriteFile(archive, []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
 	for _, entries := range [][]string{
 		{"src/../../escape.js"},
 		{`src/..\..\escape.js`},
 		{"reports/private.json"},
 		{"src/app.js", "src/app.js"},
 	} {
-		if _, err := BuildZipManifest("task-1", entries, "src"); err == nil {
+		if _, err := BuildZipManifest("task-1", archive, entries, "src"); err == nil {
 			t.Fatalf("expected unsafe manifest entries %#v to be rejected", entries)
 		}
 	}
+	if _, err := BuildZipManifest("task-1", filepath.Join(t.TempDir(), "missing.zip"), []string{"src/app.js"}, "src"); err == nil {
+		t.Fatal("expected a missing archive to be rejected")
+	}
 }
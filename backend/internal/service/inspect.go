@@ -0,0 +1,91 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+)
+
+// InspectResult 描述包头与索引，不落盘、不解包
+type InspectResult struct {
+	Format    string `json:"format"`
+	Encrypted bool   `json:"encrypted"`
+	Size      int    `json:"size"`
+	// Header fields and entries are absent for an encrypted package that was
+	// inspected without an AppID.
+	Info1       *uint32        `json:"info1,omitempty"`
+	IndexLength *uint32        `json:"indexLength,omitempty"`
+	BodyLength  *uint32        `json:"bodyLength,omitempty"`
+	Entries     []InspectEntry `json:"entries,omitempty"`
+	// Extractable reports whether UnpackWxapkg would accept the index; when it
+	// would not, ExtractIssue carries the first rule the package breaks.
+	Extractable  bool   `json:"extractable"`
+	ExtractIssue string `json:"extractIssue,omitempty"`
+}
+
+type InspectEntry struct {
+	Name   string `json:"name"`
+	Offset uint32 `json:"offset"`
+	Size   uint32 `json:"size"`
+	SHA256 string `json:"sha256"`
+	// DuplicateOf is the index of an earlier entry with the same output path
+	// and identical content; extraction skips this entry.
+	DuplicateOf  *int `json:"duplicateOf,omitempty"`
+	RuntimeAlias bool `json:"runtimeAlias,omitempty"`
+}
+
+// InspectWxapkg 解析明文 wxapkg 的文件头与索引
+// It uses the same parser and duplicate/alias rules as UnpackWxapkg. Index
+// errors are returned; extraction-rule violations are reported in the result
+// so the caller still sees every entry.
+func InspectWxapkg(data []byte) (*InspectResult, error) {
+	src := bytes.NewReader(data)
+	header, files, err := readWxapkgIndex(src)
+	if err != nil {
+		return nil, err
+	}
+	result := &InspectResult{
+		Format:      "wxapkg",
+		Size:        len(data),
+		Info1:       &header.Info1,
+		IndexLength: &header.IndexLength,
+		BodyLength:  &header.BodyLength,
+		Entries:     make([]InspectEntry, 0, len(files)),
+		Extractable: true,
+	}
+	plan, planErr := planEntries(src, header, files, func(name string) (string, error) {
+		// Resolve against a nominal root: only the uniqueness of the
+		// resulting path matters here, nothing is written.
+		return storage.SafePackageOutputPath(".", name)
+	})
+	if planErr != nil {
+		result.Extractable = false
+		result.ExtractIssue = planErr.Error()
+	}
+	for index, file := range files {
+		sum := sha256.Sum256(data[file.Offset : file.Offset+file.Size])
+		entry := InspectEntry{
+			Name:         file.Name,
+			Offset:       file.Offset,
+			Size:         file.Size,
+			SHA256:       hex.EncodeToString(sum[:]),
+			RuntimeAlias: plan.runtimeAliases[index],
+		}
+		if existing, duplicate := plan.duplicateOf[index]; duplicate {
+			entry.DuplicateOf = &existing
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	return result, nil
+}
+
+// EntryNames lists the index names, e.g. for classifier input.
+func (r *InspectResult) EntryNames() []string {
+	names := make([]string, 0, len(r.Entries))
+	for _, entry := range r.Entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
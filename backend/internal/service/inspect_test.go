@@ -0,0 +1,48 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestInspectWxapkgReportsDuplicatesAndRuntimeAliases(t *testing.T) {
+	payload := []byte(`{"a":1}`)
+	result, err := InspectWxapkg(buildDuplicatedWxapkg(t,
+		[]string{"app.json", "dup.json", "dup.json"},
+		[][]byte{[]byte(`{"pages":[]}`), payload, payload}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Extractable || len(result.Entries) != 3 || result.IndexLength == nil {
+		t.Fatalf("unexpected inspection: %#v", result)
+	}
+	duplicate := result.Entries[2]
+	if duplicate.DuplicateOf == nil || *duplicate.DuplicateOf != 1 {
+		t.Fatalf("duplicate entry not linked to its first occurrence: %#v", duplicate)
+	}
+	sum := sha256.Sum256(payload)
+	if duplicate.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("sha256 = %s", duplicate.SHA256)
+	}
+
+	aliased, err := InspectWxapkg(buildSharedRuntimeAliasWxapkg(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !aliased.Entries[2].RuntimeAlias || aliased.Entries[1].RuntimeAlias {
+		t.Fatalf("runtime alias not flagged on the extended entry: %#v", aliased.Entries)
+	}
+}
+
+func TestInspectWxapkgListsEntriesUnpackWouldReject(t *testing.T) {
+	result, err := InspectWxapkg(buildDuplicatedWxapkg(t,
+		[]string{"app.json", "dup.json", "dup.json"},
+		[][]byte{[]byte(`{"pages":[]}`), []byte(`{"a":1}`), []byte(`{"a":2}`)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Extractable || result.ExtractIssue == "" || len(result.Entries) != 3 {
+		t.Fatalf("divergent duplicate must be reported, not hidden: %#v", result)
+	}
+}
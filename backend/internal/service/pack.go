@@ -0,0 +1,105 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/keepbuild/seewxapkg/internal/infra/storage"
+)
+
+const wxapkgHeaderSize = 14
+
+// PackWxapkg 将源码目录重新打包为 wxapkg
+// Every regular file below srcDir becomes one entry named `/<relative path>`,
+// the layout WeChat itself writes, so UnpackWxapkg(PackWxapkg(dir)) reproduces
+// the tree byte for byte. Symlinks are skipped.
+func PackWxapkg(srcDir string) ([]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(srcDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files["/"+filepath.ToSlash(rel)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read source tree: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("source tree contains no files")
+	}
+	return EncodeWxapkg(files)
+}
+
+// EncodeWxapkg 按文件名排序写出 wxapkg
+// Entry names are stored exactly as given (with or without the leading
+// slash) and must stay inside the package root. Output is deterministic.
+func EncodeWxapkg(files map[string][]byte) ([]byte, error) {
+	if len(files) > maxWxapkgFiles {
+		return nil, fmt.Errorf("too many files: %d (max %d)", len(files), maxWxapkgFiles)
+	}
+	names := make([]string, 0, len(files))
+	targets := make(map[string]struct{}, len(files))
+	for name := range files {
+		if len(name) > maxWxapkgNameSize {
+			return nil, fmt.Errorf("file name too long: %d bytes", len(name))
+		}
+		target := strings.TrimPrefix(name, "/")
+		if err := storage.ValidateZipEntryPath(target); err != nil {
+			return nil, fmt.Errorf("invalid package entry %q", name)
+		}
+		// "/a.js" and "a.js" extract to the same file.
+		if _, duplicate := targets[target]; duplicate {
+			return nil, fmt.Errorf("duplicate package entry %q", target)
+		}
+		targets[target] = struct{}{}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	indexSize := uint64(4)
+	bodySize := uint64(0)
+	for _, name := range names {
+		indexSize += 12 + uint64(len(name))
+		bodySize += uint64(len(files[name]))
+	}
+	if wxapkgHeaderSize+indexSize+bodySize > math.MaxUint32 {
+		return nil, fmt.Errorf("package exceeds the 4 GiB format limit")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, wxapkgHeaderSize+indexSize+bodySize))
+	out.WriteByte(0xBE)
+	_ = binary.Write(out, binary.BigEndian, uint32(0))
+	_ = binary.Write(out, binary.BigEndian, uint32(indexSize))
+	_ = binary.Write(out, binary.BigEndian, uint32(bodySize))
+	out.WriteByte(0xED)
+
+	_ = binary.Write(out, binary.BigEndian, uint32(len(names)))
+	offset := uint32(wxapkgHeaderSize + indexSize)
+	for _, name := range names {
+		size := uint32(len(files[name]))
+		_ = binary.Write(out, binary.BigEndian, uint32(len(name)))
+		out.WriteString(name)
+		_ = binary.Write(out, binary.BigEndian, offset)
+		_ = binary.Write(out, binary.BigEndian, size)
+		offset += size
+	}
+	for _, name := range names {
+		out.Write(files[name])
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keepbuild/seewxapkg/tests/testutil"
+)
+
+func TestPackWxapkgRoundTripsUnpackedTree(t *testing.T) {
+	files := map[string][]byte{
+		"/app.json":                   []byte(`{"pages":["pages/home/index"]}`),
+		"/pages/home/index.js":        []byte("Page({})\n"),
+		"/pages/home/index.wxml":      []byte("<view>home</view>"),
+		"/static/empty.wxss":          {},
+		"/packageA/pages/a/index.js":  []byte("Page({a: 1})"),
+		"/packageA/pages/a/index.wxs": []byte("module.exports = {}"),
+	}
+	original, err := EncodeWxapkg(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := t.TempDir()
+	if _, err := UnpackWxapkg(original, first, false); err != nil {
+		t.Fatalf("unpack encoded package: %v", err)
+	}
+	repacked, err := PackWxapkg(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(repacked, original) {
+		t.Fatal("unpack→pack must reproduce the package byte for byte")
+	}
+	second := t.TempDir()
+	if _, err := UnpackWxapkg(repacked, second, false); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		got, err := os.ReadFile(filepath.Join(second, filepath.FromSlash(name[1:])))
+		if err != nil || !bytes.Equal(got, content) {
+			t.Fatalf("%s changed after round trip: %q %v", name, got, err)
+		}
+	}
+}
+
+func TestEncodeWxapkgMatchesFixtureBuilder(t *testing.T) {
+	files := map[string]string{"app.json": `{"pages":[]}`, "pages/a.js": "Page({})"}
+	raw := make(map[string][]byte, len(files))
+	for name, content := range files {
+		raw[name] = []byte(content)
+	}
+	encoded, err := EncodeWxapkg(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, testutil.MustBuildWxapkg(files)) {
+		t.Fatal("encoder and test fixture builder disagree on the layout")
+	}
+}
+
+func TestEncodeWxapkgRejectsUnsafeOrDuplicateNames(t *testing.T) {
+	for _, files := range []map[string][]byte{
+		{"../escape.js": nil},
+		{"/a.js": nil, "a.js": nil},
+		{"": nil},
+	} {
+		if _, err := EncodeWxapkg(files); err == nil {
+			t.Fatalf("expected %v to be rejected", files)
+		}
+	}
+}
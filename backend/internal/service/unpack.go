@@ -66,9 +66,25 @@ type UnpackResult struct {
 	Error     error
 }
 
+// PackageSource is a plaintext wxapkg readable at random offsets, such as a
+// bytes.Reader, an io.SectionReader over an open file or a
+// decrypt.PackageReader.
+type PackageSource interface {
+	io.ReaderAt
+	Size() int64
+}
+
 // UnpackWxapkg 解包 wxapkg 文件
 // 完全按照 Java 代码逻辑实现
 func UnpackWxapkg(data []byte, outputDir string, beautify bool) (*UnpackResult, error) {
+	return UnpackPackage(bytes.NewReader(data), outputDir, beautify)
+}
+
+// UnpackPackage extracts a package without loading it: only the index is
+// held in memory and every entry is copied from src straight to disk, so
+// peak memory does not grow with the package (beautify still buffers one
+// entry at a time).
+func UnpackPackage(src PackageSource, outputDir string, beautify bool) (*UnpackResult, error) {
 	result := &UnpackResult{
 		Files: make([]model.FileEntry, 0),
 	}
@@ -78,125 +94,224 @@ func UnpackWxapkg(data []byte, outputDir string, beautify bool) (*UnpackResult,
 		return nil, fmt.Errorf("create output dir: %w", err)
 	}
 
+	header, files, err := readWxapkgIndex(src)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := planEntries(src, header, files, func(name string) (string, error) {
+		return safeOutputPath(outputDir, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Use a fixed worker pool so an attacker-controlled file count cannot create
+	// an unbounded number of goroutines.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var extractErr error
+	jobs := make(chan model.FileEntry)
+	workerCount := maxExtractWorkers
+	if len(files) < workerCount {
+		workerCount = len(files)
+	}
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range jobs {
+				if err := extractFile(src, f, outputDir, beautify); err != nil {
+					mu.Lock()
+					if extractErr == nil {
+						extractErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for index, file := range files {
+		if _, duplicate := plan.duplicateOf[index]; duplicate {
+			continue
+		}
+		jobs <- file
+	}
+	close(jobs)
+	wg.Wait()
+
+	if extractErr != nil {
+		return nil, extractErr
+	}
+
+	result.Files = files
+	// Every validated index entry maps to one unique regular output file. Using
+	// the index count keeps nested page/component files in the reported total.
+	result.FileCount = len(files)
+	result.Success = true
+
+	return result, nil
+}
+
+// wxapkgHeader 是 0xBE/0xED 之间的三个大端字段
+type wxapkgHeader struct {
+	Info1       uint32
+	IndexLength uint32
+	BodyLength  uint32
+}
+
+func (h wxapkgHeader) indexEnd() uint64 {
+	return uint64(14) + uint64(h.IndexLength)
+}
+
+// readWxapkgIndex 解析文件头和索引区
+// Every entry is bounds-checked against the package size; only the header
+// and index section are read and nothing is written to disk, so inspection
+// and extraction share exactly the same parser.
+func readWxapkgIndex(src PackageSource) (wxapkgHeader, []model.FileEntry, error) {
 	// Java: ByteBuffer buffer = ByteBuffer.wrap(data).order(ByteOrder.BIG_ENDIAN);
 	// Java: byte firstMark = buffer.get();
 	// Java: if (firstMark != (byte) 0xBE) { throw ... }
-	if len(data) < 14 {
-		return nil, fmt.Errorf("file too small: %d bytes", len(data))
+	size := src.Size()
+	if size < 14 {
+		return wxapkgHeader{}, nil, fmt.Errorf("file too small: %d bytes", size)
+	}
+	headerBytes := make([]byte, 14)
+	if _, err := src.ReadAt(headerBytes, 0); err != nil && err != io.EOF {
+		return wxapkgHeader{}, nil, fmt.Errorf("read header: %w", err)
 	}
 
-	firstMark := data[0]
+	firstMark := headerBytes[0]
 	if firstMark != 0xBE {
 		// 检查是否是加密文件
-		if len(data) >= 6 && string(data[:6]) == "V1MMWX" {
-			return nil, fmt.Errorf("文件是加密格式（V1MMWX），需要提供正确的 AppID 进行解密")
+		if string(headerBytes[:6]) == "V1MMWX" {
+			return wxapkgHeader{}, nil, fmt.Errorf("文件是加密格式（V1MMWX），需要提供正确的 AppID 进行解密")
 		}
-		return nil, fmt.Errorf("无效的 wxapkg 文件：首标记错误（期望 0xBE，实际 0x%02X）", firstMark)
+		return wxapkgHeader{}, nil, fmt.Errorf("无效的 wxapkg 文件：首标记错误（期望 0xBE，实际 0x%02X）", firstMark)
 	}
 
 	// 使用 bytes.Reader 按照大端序读取
-	reader := bytes.NewReader(data[1:])
+	reader := bytes.NewReader(headerBytes[1:])
 
 	// Java: int info1 = buffer.getInt();
-	var info1 uint32
-	if err := binary.Read(reader, binary.BigEndian, &info1); err != nil {
-		return nil, fmt.Errorf("read info1: %w", err)
+	var header wxapkgHeader
+	if err := binary.Read(reader, binary.BigEndian, &header.Info1); err != nil {
+		return wxapkgHeader{}, nil, fmt.Errorf("read info1: %w", err)
 	}
 
 	// Java: int indexInfoLength = buffer.getInt();
-	var indexInfoLength uint32
-	if err := binary.Read(reader, binary.BigEndian, &indexInfoLength); err != nil {
-		return nil, fmt.Errorf("read indexInfoLength: %w", err)
+	if err := binary.Read(reader, binary.BigEndian, &header.IndexLength); err != nil {
+		return wxapkgHeader{}, nil, fmt.Errorf("read indexInfoLength: %w", err)
 	}
 
 	// Java: int bodyInfoLength = buffer.getInt();
-	var bodyInfoLength uint32
-	if err := binary.Read(reader, binary.BigEndian, &bodyInfoLength); err != nil {
-		return nil, fmt.Errorf("read bodyInfoLength: %w", err)
+	if err := binary.Read(reader, binary.BigEndian, &header.BodyLength); err != nil {
+		return wxapkgHeader{}, nil, fmt.Errorf("read bodyInfoLength: %w", err)
 	}
 
 	// Java: byte lastMark = buffer.get();
 	var lastMark uint8
 	if err := binary.Read(reader, binary.BigEndian, &lastMark); err != nil {
-		return nil, fmt.Errorf("read lastMark: %w", err)
+		return wxapkgHeader{}, nil, fmt.Errorf("read lastMark: %w", err)
 	}
 
 	// Java: if (lastMark != (byte) 0xED) { throw ... }
 	if lastMark != 0xED {
-		return nil, fmt.Errorf("无效的 wxapkg 文件：尾标记错误（期望 0xED，实际 0x%02X）", lastMark)
+		return wxapkgHeader{}, nil, fmt.Errorf("无效的 wxapkg 文件：尾标记错误（期望 0xED，实际 0x%02X）", lastMark)
 	}
-	if indexInfoLength < 4 {
-		return nil, fmt.Errorf("invalid wxapkg index length: %d", indexInfoLength)
+	if header.IndexLength < 4 {
+		return wxapkgHeader{}, nil, fmt.Errorf("invalid wxapkg index length: %d", header.IndexLength)
 	}
-	indexEnd := uint64(14) + uint64(indexInfoLength)
-	packageEnd := indexEnd + uint64(bodyInfoLength)
-	if indexEnd > uint64(len(data)) || packageEnd > uint64(len(data)) {
-		return nil, fmt.Errorf("wxapkg sections out of bounds: index=%d, body=%d, dataLen=%d",
-			indexInfoLength, bodyInfoLength, len(data))
+	indexEnd := header.indexEnd()
+	packageEnd := indexEnd + uint64(header.BodyLength)
+	if indexEnd > uint64(size) || packageEnd > uint64(size) {
+		return wxapkgHeader{}, nil, fmt.Errorf("wxapkg sections out of bounds: index=%d, body=%d, dataLen=%d",
+			header.IndexLength, header.BodyLength, size)
 	}
 
 	// Never let malformed index metadata consume bytes from the package body.
-	reader = bytes.NewReader(data[14:indexEnd])
-
+	index := make([]byte, header.IndexLength)
+	if _, err := src.ReadAt(index, 14); err != nil && err != io.EOF {
+		return wxapkgHeader{}, nil, fmt.Errorf("read index: %w", err)
+	}
+	reader = bytes.NewReader(index)
 	// Java: int fileCount = buffer.getInt();
 	var fileCount uint32
 	if err := binary.Read(reader, binary.BigEndian, &fileCount); err != nil {
-		return nil, fmt.Errorf("read fileCount: %w", err)
+		return wxapkgHeader{}, nil, fmt.Errorf("read fileCount: %w", err)
 	}
 
 	if fileCount > maxWxapkgFiles {
-		return nil, fmt.Errorf("wxapkg contains too many files: %d (max %d)", fileCount, maxWxapkgFiles)
+		return wxapkgHeader{}, nil, fmt.Errorf("wxapkg contains too many files: %d (max %d)", fileCount, maxWxapkgFiles)
 	}
 	// Even an empty-name entry needs nameLen, offset and size fields.
 	if uint64(fileCount)*12 > uint64(reader.Len()) {
-		return nil, fmt.Errorf("invalid wxapkg file count %d for index length %d", fileCount, indexInfoLength)
+		return wxapkgHeader{}, nil, fmt.Errorf("invalid wxapkg file count %d for index length %d", fileCount, header.IndexLength)
 	}
 
 	// Java: for (int i = 0; i < fileCount; i++) {
 	files := make([]model.FileEntry, int(fileCount))
-	var totalExtracted uint64
-	maxReferencedEnd := uint64(indexEnd)
-	runtimeAliasSeen := false
-	seenTargets := make(map[string]int, int(fileCount))
-	duplicateSkip := make(map[int]bool)
 	for i := uint32(0); i < fileCount; i++ {
 		// Java: int nameLen = buffer.getInt();
 		var nameLen uint32
 		if err := binary.Read(reader, binary.BigEndian, &nameLen); err != nil {
-			return nil, fmt.Errorf("read nameLen: %w", err)
+			return wxapkgHeader{}, nil, fmt.Errorf("read nameLen: %w", err)
 		}
 		if nameLen == 0 || nameLen > maxWxapkgNameSize {
-			return nil, fmt.Errorf("invalid file name length at index %d: %d", i, nameLen)
+			return wxapkgHeader{}, nil, fmt.Errorf("invalid file name length at index %d: %d", i, nameLen)
 		}
 		if uint64(nameLen)+8 > uint64(reader.Len()) {
-			return nil, fmt.Errorf("file index entry %d exceeds declared index section", i)
+			return wxapkgHeader{}, nil, fmt.Errorf("file index entry %d exceeds declared index section", i)
 		}
 
 		// Java: byte[] nameBytes = new byte[nameLen];
 		// Java: buffer.get(nameBytes);
 		nameBytes := make([]byte, nameLen)
 		if _, err := io.ReadFull(reader, nameBytes); err != nil {
-			return nil, fmt.Errorf("read file name: %w", err)
+			return wxapkgHeader{}, nil, fmt.Errorf("read file name: %w", err)
 		}
 		files[i].Name = string(nameBytes)
 
 		// Java: file.offset = buffer.getInt();
 		if err := binary.Read(reader, binary.BigEndian, &files[i].Offset); err != nil {
-			return nil, fmt.Errorf("read file offset: %w", err)
+			return wxapkgHeader{}, nil, fmt.Errorf("read file offset: %w", err)
 		}
 
 		// Java: file.size = buffer.getInt();
 		if err := binary.Read(reader, binary.BigEndian, &files[i].Size); err != nil {
-			return nil, fmt.Errorf("read file size: %w", err)
+			return wxapkgHeader{}, nil, fmt.Errorf("read file size: %w", err)
 		}
 
-		if err := validateFileBounds(files[i], len(data)); err != nil {
-			return nil, err
+		if err := validateFileBounds(files[i], size); err != nil {
+			return wxapkgHeader{}, nil, err
 		}
+	}
+	return header, files, nil
+}
+
+// entryPlan records how extraction treats index entries that do not map to
+// a fresh output file.
+type entryPlan struct {
+	// duplicateOf maps an entry to the earlier entry with the same output path
+	// and identical content; the duplicate is skipped.
+	duplicateOf map[int]int
+	// runtimeAliases are entries that re-reference an earlier body range.
+	runtimeAliases map[int]bool
+}
+
+// planEntries applies the duplicate and runtime-alias rules. targetFor maps
+// an entry name to its output path and rejects unsafe names.
+func planEntries(src PackageSource, header wxapkgHeader, files []model.FileEntry, targetFor func(name string) (string, error)) (entryPlan, error) {
+	plan := entryPlan{duplicateOf: make(map[int]int), runtimeAliases: make(map[int]bool)}
+	indexEnd := header.indexEnd()
+	var totalExtracted uint64
+	maxReferencedEnd := indexEnd
+	runtimeAliasSeen := false
+	seenTargets := make(map[string]int, len(files))
+	for i := range files {
 		fileEnd := uint64(files[i].Offset) + uint64(files[i].Size)
-		target, err := safeOutputPath(outputDir, files[i].Name)
+		target, err := targetFor(files[i].Name)
 		if err != nil {
-			return nil, err
+			return plan, err
 		}
 		if existing, exists := seenTargets[target]; exists {
 			// WeChat plugin packages duplicate metadata entries
@@ -204,10 +319,10 @@ func UnpackWxapkg(data []byte, outputDir string, beautify bool) (*UnpackResult,
 			// content). Tolerate identical duplicates — keep the first entry,
 			// skip the copy — but keep rejecting divergent duplicates that
 			// would silently overwrite distinct data.
-			if !sameFileData(data, files[existing], files[i]) {
-				return nil, fmt.Errorf("duplicate output path with differing content: %s", files[i].Name)
+			if !sameFileData(src, files[existing], files[i]) {
+				return plan, fmt.Errorf("duplicate output path with differing content: %s", files[i].Name)
 			}
-			duplicateSkip[int(i)] = true
+			plan.duplicateOf[i] = existing
 			continue
 		}
 
@@ -222,81 +337,27 @@ func UnpackWxapkg(data []byte, outputDir string, beautify bool) (*UnpackResult,
 			isSharedRuntimeAlias(files[i].Name)
 		if !isRuntimeAlias {
 			totalExtracted += uint64(files[i].Size)
-			if totalExtracted > uint64(len(data)) {
-				return nil, fmt.Errorf("declared extracted data exceeds package size")
+			if totalExtracted > uint64(src.Size()) {
+				return plan, fmt.Errorf("declared extracted data exceeds package size")
 			}
 		} else {
 			runtimeAliasSeen = true
+			plan.runtimeAliases[i] = true
 		}
 		if fileEnd > maxReferencedEnd {
 			maxReferencedEnd = fileEnd
 		}
-		seenTargets[target] = int(i)
+		seenTargets[target] = i
 	}
-
-	// Use a fixed worker pool so an attacker-controlled file count cannot create
-	// an unbounded number of goroutines.
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var extractErr error
-	jobs := make(chan model.FileEntry)
-	workerCount := maxExtractWorkers
-	if len(files) < workerCount {
-		workerCount = len(files)
-	}
-	for i := 0; i < workerCount; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for f := range jobs {
-				if err := extractFile(data, f, outputDir, beautify); err != nil {
-					mu.Lock()
-					if extractErr == nil {
-						extractErr = err
-					}
-					mu.Unlock()
-				}
-			}
-		}()
-	}
-	for index, file := range files {
-		if duplicateSkip[index] {
-			continue
-		}
-		jobs <- file
-	}
-	close(jobs)
-	wg.Wait()
-
-	if extractErr != nil {
-		return nil, extractErr
-	}
-
-	result.Files = files
-	// Every validated index entry maps to one unique regular output file. Using
-	// the index count keeps nested page/component files in the reported total.
-	result.FileCount = len(files)
-	result.Success = true
-
-	return result, nil
+	return plan, nil
 }
 
 // extractFile 提取单个文件
-func extractFile(data []byte, file model.FileEntry, outputDir string, beautify bool) error {
-	if err := validateFileBounds(file, len(data)); err != nil {
+func extractFile(src PackageSource, file model.FileEntry, outputDir string, beautify bool) error {
+	if err := validateFileBounds(file, src.Size()); err != nil {
 		return err
 	}
-	start := int(file.Offset)
-	end := start + int(file.Size)
-
-	// 读取文件内容
-	content := make([]byte, int(file.Size))
-	copy(content, data[start:end])
-
-	// 美化代码
-	if beautify {
-		content = beautifyContent(content, file.Name)
-	}
+	entry := io.NewSectionReader(src, int64(file.Offset), int64(file.Size))
 
 	// 创建完整路径
 	fullPath, err := safeOutputPath(outputDir, file.Name)
@@ -307,15 +368,35 @@ func extractFile(data []byte, file model.FileEntry, outputDir string, beautify b
 		return fmt.Errorf("create directory: %w", err)
 	}
 
+	// 美化代码
+	if beautify {
+		content := make([]byte, int(file.Size))
+		if _, err := io.ReadFull(entry, content); err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		content = beautifyContent(content, file.Name)
+		if err := os.WriteFile(fullPath, content, 0600); err != nil {
+			return fmt.Errorf("write file: %w", err)
+		}
+		return nil
+	}
+
 	// 写入文件
-	if err := os.WriteFile(fullPath, content, 0600); err != nil {
+	output, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	if _, err := io.Copy(output, entry); err != nil {
+		output.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := output.Close(); err != nil {
 		return fmt.Errorf("write file: %w", err)
 	}
-
 	return nil
 }
 
-func validateFileBounds(file model.FileEntry, dataLen int) error {
+func validateFileBounds(file model.FileEntry, dataLen int64) error {
 	end := uint64(file.Offset) + uint64(file.Size)
 	if uint64(file.Offset) > uint64(dataLen) || end > uint64(dataLen) {
 		return fmt.Errorf("file out of bounds: %s (offset=%d, size=%d, dataLen=%d)",
@@ -325,12 +406,30 @@ func validateFileBounds(file model.FileEntry, dataLen int) error {
 }
 
 // sameFileData reports whether two index entries reference byte-identical
-// content. Both entries are assumed to have passed validateFileBounds.
-func sameFileData(data []byte, a, b model.FileEntry) bool {
+// content. Both entries are assumed to have passed validateFileBounds; the
+// ranges are compared in chunks so large entries are never buffered whole.
+func sameFileData(src PackageSource, a, b model.FileEntry) bool {
 	if a.Size != b.Size {
 		return false
 	}
-	return bytes.Equal(data[a.Offset:a.Offset+a.Size], data[b.Offset:b.Offset+b.Size])
+	if a.Offset == b.Offset {
+		return true
+	}
+	const chunkSize = 32 * 1024
+	left, right := make([]byte, chunkSize), make([]byte, chunkSize)
+	for done := int64(0); done < int64(a.Size); done += chunkSize {
+		n := min(chunkSize, int64(a.Size)-done)
+		if _, err := src.ReadAt(left[:n], int64(a.Offset)+done); err != nil && err != io.EOF {
+			return false
+		}
+		if _, err := src.ReadAt(right[:n], int64(b.Offset)+done); err != nil && err != io.EOF {
+			return false
+		}
+		if !bytes.Equal(left[:n], right[:n]) {
+			return false
+		}
+	}
+	return true
 }
 
 func isSharedRuntimeAlias(name string) bool {
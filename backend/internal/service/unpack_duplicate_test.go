@@ -86,7 +86,7 @@ func buildSharedRuntimeAliasWxapkg(t *testing.T) []byte {
 }
 
 func TestSameFileData(t *testing.T) {
-	data := []byte("0123456701234567")
+	data := bytes.NewReader([]byte("0123456701234567"))
 	a := model.FileEntry{Offset: 0, Size: 4, Name: "a"}
 	b := model.FileEntry{Offset: 8, Size: 4, Name: "b"}
 	if !sameFileData(data, a, b) {
@@ -160,7 +160,7 @@ func TestUnpackRejectsDuplicateNormalizedPathBeforeWriting(t *testing.T) {
 
 func TestExtractFileRejectsUint32Overflow(t *testing.T) {
 	entry := model.FileEntry{Name: "app.js", Offset: math.MaxUint32, Size: 2}
-	if err := extractFile([]byte("small"), entry, t.TempDir(), false); err == nil || !strings.Contains(err.Error(), "out of bounds") {
+	if err := extractFile(bytes.NewReader([]byte("small")), entry, t.TempDir(), false); err == nil || !strings.Contains(err.Error(), "out of bounds") {
 		t.Fatalf("expected bounds error, got %v", err)
 	}
 }
@@ -0,0 +1 @@
+Page({data:{message:"compose-smoke"}});
@@ -0,0 +1,2 @@
+App({onLaunch(){}});
+Page({data:{}});
@@ -0,0 +1 @@
+(function(z){var a=11;function Z(ops){z.push(ops)}Z([3,'style']);})(z);__WXML_GLOBAL__.ops_set.$gwx=z;var _C=[];
@@ -0,0 +1,20 @@
+(function(z){var a=11;function Z(ops){z.push(ops)}
+Z([3,'index']);
+Z([3,'hello-both']);
+})(z);__WXML_GLOBAL__.ops_set.$gwx=z;
+function np_0(){var nv_module={nv_exports:{}};nv_module.nv_exports=({nv_msg:'hello'});return nv_module.nv_exports;}
+var nv_require=function(){var nnm={'p_utils/msg.wxs':np_0};var path='pages/index/index';return function(p){return nnm[p]}}()
+var d_={};
+d_["pages/index/index.wxml"]={};
+var f_={};
+f_["utils/msg.wxs"]=np_0;
+var e_={};
+e_["pages/index/index.wxml"]={f:function(e,t,n){
+var a=_v();
+var b=_n("view","");
+var c=_o(1);
+_(a,b);
+_(b,c);
+return a;
+}};
+if(path&&e_[path]){e_[path].call(null)}
\ No newline at end of file
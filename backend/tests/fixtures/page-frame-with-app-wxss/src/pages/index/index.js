@@ -0,0 +1 @@
+Page({data:{}});
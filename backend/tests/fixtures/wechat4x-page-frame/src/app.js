@@ -0,0 +1 @@
+App({onLaunch(){}});
@@ -1,6 +1,7 @@
 package golden
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -68,6 +69,41 @@ func TestGoldenProfilesAndManifest(t *testing.T) {
 	}
 }
 
+// TestFixtureSourceTreesPackDeterministically covers the packaged fixtures
+// that CI and manual regressions build with `seewxapkg pack`.
+func TestFixtureSourceTreesPackDeterministically(t *testing.T) {
+	for _, name := range []string{"compose-smoke", "classic3x", "wechat4x-page-frame", "page-frame-with-app-wxss"} {
+		t.Run(name, func(t *testing.T) {
+			srcDir := filepath.Join("..", "fixtures", name, "src")
+			first, err := service.PackWxapkg(srcDir)
+			if err != nil {
+				t.Fatalf("PackWxapkg: %v", err)
+			}
+			second, err := service.PackWxapkg(srcDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(first, second) {
+				t.Fatal("packing the same tree twice must be byte-identical")
+			}
+			extractedDir := t.TempDir()
+			if _, err := service.UnpackWxapkg(first, extractedDir, false); err != nil {
+				t.Fatalf("UnpackWxapkg: %v", err)
+			}
+			repacked, err := service.PackWxapkg(extractedDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(first, repacked) {
+				t.Fatal("unpack→pack round trip changed the package")
+			}
+			if _, err := classifier.DetectPackageProfile(first, extractedDir); err != nil {
+				t.Fatalf("DetectPackageProfile: %v", err)
+			}
+		})
+	}
+}
+
 func assertGoldenSubset(t *testing.T, fixturePath string, actual interface{}) {
 	t.Helper()
 
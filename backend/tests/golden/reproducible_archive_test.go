@@ -0,0 +1,83 @@
+package golden
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keepbuild/seewxapkg/internal/app"
+	"github.com/keepbuild/seewxapkg/internal/config"
+	"github.com/keepbuild/seewxapkg/internal/domain/task"
+	"github.com/keepbuild/seewxapkg/internal/infra/events"
+	"github.com/keepbuild/seewxapkg/internal/infra/persistence"
+	"github.com/keepbuild/seewxapkg/internal/service"
+)
+
+type idleQueue struct{}
+
+func (idleQueue) Enqueue(context.Context, string) error                                  { return nil }
+func (idleQueue) StartWorkers(context.Context, int, func(context.Context, string) error) {}
+func (idleQueue) Wait()                                                                  {}
+func (idleQueue) Remove(context.Context, string) error                                   { return nil }
+
+// TestSameFixtureProducesIdenticalArchives runs the whole pipeline twice
+// over one package; the archives must match byte for byte and the
+// zip-manifest must record their hash.
+func TestSameFixtureProducesIdenticalArchives(t *testing.T) {
+	data, err := service.PackWxapkg(filepath.Join("..", "fixtures", "wechat4x-page-frame", "src"))
+	if err != nil {
+		t.Fatalf("PackWxapkg: %v", err)
+	}
+	input := filepath.Join(t.TempDir(), "__APP__.wxapkg")
+	if err := os.WriteFile(input, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{TempDir: t.TempDir(), OutputDir: t.TempDir(), NativeRecoverEnabled: true}
+	repo := persistence.NewMemoryTaskRepo()
+	compileService := app.NewCompileService(cfg, repo, events.NewBroker(), idleQueue{})
+	queryService := app.NewTaskQueryService(cfg, repo)
+	ctx := context.Background()
+
+	var archives [][]byte
+	for run := 0; run < 2; run++ {
+		created, err := compileService.CreateTask(ctx, app.StartCompileCommand{InputPath: input, Beautify: true, Decompile: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = compileService.RunTask(ctx, created.ID)
+		finished, err := repo.Get(ctx, created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if finished.Status != task.TaskCompleted && finished.Status != task.TaskPartial {
+			t.Fatalf("run %d ended %s", run, finished.Status)
+		}
+		archive, err := os.ReadFile(queryService.ResolveZipPath(created.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := queryService.GetNamedReport(ctx, created.ID, "zip-manifest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var manifest struct {
+			ArchiveSHA256 string `json:"archiveSha256"`
+		}
+		if err := json.Unmarshal(payload, &manifest); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(archive)
+		if manifest.ArchiveSHA256 != hex.EncodeToString(sum[:]) {
+			t.Fatalf("run %d: zip-manifest records %s, archive hashes to %x", run, manifest.ArchiveSHA256, sum)
+		}
+		archives = append(archives, archive)
+	}
+	if !bytes.Equal(archives[0], archives[1]) {
+		t.Fatal("processing the same package twice must produce identical archives")
+	}
+}
@@ -257,6 +257,8 @@ func newTestEnv(t *testing.T, fallbackEnabled bool) *testEnv {
 		ServerHost:             "127.0.0.1",
 		ServerPort:             0,
 		MaxUploadSize:          10 * 1024 * 1024,
+		MaxBatchUploadSize:     20 * 1024 * 1024,
+		MaxBatchPackages:       10,
 		TempDir:                tempDir,
 		OutputDir:              outputDir,
 		TaskRepoDriver:         "file",
@@ -300,6 +302,8 @@ func newTestEnv(t *testing.T, fallbackEnabled bool) *testEnv {
 		httpapi.NewTaskHandler(queryService, broker),
 		httpapi.NewDownloadHandler(queryService),
 		httpapi.NewGitHubStarsHandler(app.NewGitHubStarsService()),
+		httpapi.NewBatchHandler(app.NewBatchService(cfg, repo, compileService), cfg.MaxBatchUploadSize),
+		httpapi.NewDiffHandler(app.NewDiffService(cfg, repo, compileService), cfg.MaxBatchUploadSize),
 	).RegisterRoutes(router)
 
 	return &testEnv{router: router}
@@ -5,10 +5,16 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/keepbuild/seewxapkg/internal/pipeline/decrypt"
+	"github.com/keepbuild/seewxapkg/internal/service"
 	"github.com/keepbuild/seewxapkg/tests/testutil"
 	"golang.org/x/crypto/pbkdf2"
 )
@@ -108,3 +114,157 @@ func TestDecryptWxapkgWrongAppIDProducesGarbage(t *testing.T) {
 		t.Fatalf("wrong AppID must not produce a magic-valid package")
 	}
 }
+
+func TestEncryptWxapkgMatchesReferenceAndRoundTrips(t *testing.T) {
+	plain := testutil.MustBuildWxapkg(map[string]string{
+		"app.json":            `{"pages":["pages/home/index"]}`,
+		"pages/home/index.js": "Page({data:{big:\"" + strings.Repeat("0123456789abcdef", 160) + "\"}});\n",
+	})
+	appID := "wx0123456789abcdef"
+	encrypted, err := decrypt.EncryptWxapkg(plain, appID)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !bytes.Equal(encrypted, encryptForTest(t, plain, appID)) {
+		t.Fatal("EncryptWxapkg must match the reference algorithm")
+	}
+	decrypted, err := decrypt.DecryptWxapkg(encrypted, appID)
+	if err != nil || !bytes.Equal(decrypted, plain) {
+		t.Fatalf("round-trip mismatch: err=%v", err)
+	}
+}
+
+func TestPackageReaderStreamsPlaintextFromFile(t *testing.T) {
+	files := map[string]string{
+		"app.json":            `{"pages":["pages/home/index"]}`,
+		"pages/home/index.js": "Page({data:{big:\"" + strings.Repeat("0123456789abcdef", 320) + "\"}});\n",
+	}
+	plain := testutil.MustBuildWxapkg(files)
+	appID := "wx0123456789abcdef"
+	encrypted, err := decrypt.EncryptWxapkg(plain, appID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "__APP__.wxapkg")
+	if err := os.WriteFile(path, encrypted, 0600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := decrypt.NewPackageReader(file, int64(len(encrypted)), appID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Size() != int64(len(plain)) {
+		t.Fatalf("size = %d, want %d", reader.Size(), len(plain))
+	}
+	// Reads inside the AES head, across its 1023-byte boundary, in the XORed
+	// body and past the end.
+	for _, span := range [][2]int{{0, 14}, {1000, 100}, {1023, 1}, {2000, len(plain) - 2000}, {len(plain) - 5, 20}} {
+		buf := make([]byte, span[1])
+		n, err := reader.ReadAt(buf, int64(span[0]))
+		want := plain[span[0]:min(span[0]+span[1], len(plain))]
+		if !bytes.Equal(buf[:n], want) {
+			t.Fatalf("ReadAt(%d, %d) returned different bytes", span[0], span[1])
+		}
+		if n < span[1] && !errors.Is(err, io.EOF) {
+			t.Fatalf("short read at %d without io.EOF: %v", span[0], err)
+		}
+	}
+
+	outDir := t.TempDir()
+	result, err := service.UnpackPackage(reader, outDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FileCount != len(files) {
+		t.Fatalf("file count = %d, want %d", result.FileCount, len(files))
+	}
+	for name, content := range files {
+		got, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil || string(got) != content {
+			t.Fatalf("%s was not extracted intact: %v", name, err)
+		}
+	}
+}
+
+func TestEncryptWxapkgRejectsInvalidInput(t *testing.T) {
+	small := testutil.MustBuildWxapkg(map[string]string{"app.json": `{}`})
+	if _, err := decrypt.EncryptWxapkg(small, "wx0123456789abcdef"); err == nil {
+		t.Fatal("packages shorter than the AES head must be rejected")
+	}
+	if _, err := decrypt.EncryptWxapkg(small, "bad"); !errors.Is(err, decrypt.ErrBadAppID) {
+		t.Fatalf("expected ErrBadAppID, got %v", err)
+	}
+	if _, err := decrypt.EncryptWxapkg([]byte(strings.Repeat("x", 2048)), "wx0123456789abcdef"); !errors.Is(err, decrypt.ErrInvalidHeader) {
+		t.Fatalf("expected ErrInvalidHeader, got %v", err)
+	}
+}
+
+func TestAppIDFromPath(t *testing.T) {
+	for input, want := range map[string]string{
+		`C:\Users\me\Documents\WeChat Files\Applet\wx0123456789abcdef\12\__APP__.wxapkg`: "wx0123456789abcdef",
+		"Applet/wx0123456789abcdef/12/pkg/__APP__.wxapkg":                                "wx0123456789abcdef",
+		"wxffffffffffffffff/Applet/wx0123456789abcdef/3/sub.wxapkg":                      "wx0123456789abcdef",
+		"uploads/__APP__.wxapkg":                      "",
+		"wx0123456789abcdef.wxapkg":                   "",
+		"Applet/WX0123456789ABCDEF/12/__APP__.wxapkg": "",
+	} {
+		if got := decrypt.AppIDFromPath(input); got != want {
+			t.Fatalf("AppIDFromPath(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestSearchAppIDFindsCandidateByPlaintextMarkers(t *testing.T) {
+	plain := testutil.MustBuildWxapkg(map[string]string{
+		"app.json":            `{"pages":["pages/home/index"]}`,
+		"pages/home/index.js": "Page({data:{big:\"" + strings.Repeat("0123456789abcdef", 80) + "\"}});\n",
+	})
+	encrypted := encryptForTest(t, plain, "wx0123456789abcdef")
+	candidates, err := decrypt.ReadAppIDCandidates(strings.NewReader("# team list\nwxffffffffffffffff\n\nnot-an-appid\nwx0123456789abcdef # main app\nwx0123456789abcdef\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("unexpected candidates: %#v", candidates)
+	}
+
+	// Callers only read the head of the package, never the whole file.
+	head, size := encrypted[:len(decrypt.FileHeader)+1024], int64(len(encrypted))
+	appID, err := decrypt.SearchAppID(head, size, candidates)
+	if err != nil || appID != "wx0123456789abcdef" {
+		t.Fatalf("SearchAppID = %q, %v", appID, err)
+	}
+	if _, err := decrypt.SearchAppID(head, size, candidates[:1]); !errors.Is(err, decrypt.ErrAppIDNotFound) {
+		t.Fatalf("expected ErrAppIDNotFound, got %v", err)
+	}
+	if _, err := decrypt.SearchAppID(plain, int64(len(plain)), candidates); !errors.Is(err, decrypt.ErrInvalidHeader) {
+		t.Fatalf("plain packages need no search, got %v", err)
+	}
+}
+
+func TestMatchesAppIDChecksTheWholeHeader(t *testing.T) {
+	plain := testutil.MustBuildWxapkg(map[string]string{
+		"pages/home/index.js": "Page({data:{big:\"" + strings.Repeat("0123456789abcdef", 80) + "\"}});\n",
+	})
+	const appID = "wx0123456789abcdef"
+	matches := func(encrypted []byte) bool {
+		return decrypt.MatchesAppID(encrypted[:len(decrypt.FileHeader)+1024], int64(len(encrypted)), appID)
+	}
+	if !matches(encryptForTest(t, plain, appID)) {
+		t.Fatal("the right AppID should match")
+	}
+	// Both markers survive, so only the header fields can reject these.
+	for name, field := range map[string]int{"info": 1, "index length": 5, "body length": 9} {
+		forged := append([]byte{}, plain...)
+		binary.BigEndian.PutUint32(forged[field:], uint32(len(plain)))
+		if matches(encryptForTest(t, forged, appID)) {
+			t.Fatalf("a header with a bad %s should not match", name)
+		}
+	}
+}
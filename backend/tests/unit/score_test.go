@@ -76,3 +76,31 @@ func TestComputeRecoveryScoreReflectsManifestAndWXMLQualityIssues(t *testing.T)
 		t.Fatalf("failed static verification is overstated: %#v", score)
 	}
 }
+
+func TestComputeRecoveryScoreUsesGameRulesWithoutPages(t *testing.T) {
+	score := verify.ComputeRecoveryScore(
+		&verify.ManifestVerifyResult{
+			Success: true,
+			Game:    &verify.GameVerifyResult{Checks: 4, PassedChecks: 4},
+		},
+		&verify.ArtifactVerifyResult{
+			Success:        true,
+			ParserPassed:   true,
+			VerifierPassed: true,
+			JSFiles:        5,
+			JSParseable:    5,
+		},
+		true,
+		false,
+	)
+
+	if score.Kind != "game" || score.Manifest != 100 || score.JS != 100 {
+		t.Fatalf("unexpected game score: %#v", score)
+	}
+	if score.WXML != 0 || score.WXSS != 0 || score.GeneratedRatio != 0 {
+		t.Fatalf("game score must not depend on page triplets: %#v", score)
+	}
+	if !score.VerifierPassed || score.Overall != 100 {
+		t.Fatalf("complete game recovery should score fully, got %#v", score)
+	}
+}
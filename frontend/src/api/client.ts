@@ -10,7 +10,7 @@ interface CompileRequest {
 }
 
 export interface ProgressEvent {
-  type: 'progress' | 'complete' | 'partial' | 'error'
+  type: 'progress' | 'complete' | 'partial' | 'error' | 'cancelled'
   stage: string
   percent: number
   message: string
@@ -66,6 +66,8 @@ export interface PackageProfile {
   isWeChat4xLike: boolean
   isSubPackage: boolean
   isGamePackage: boolean
+  isPluginPackage: boolean
+  pluginAppId?: string
   hasAppConfigJSON: boolean
   hasAppServiceJS: boolean
   hasWorkersJS: boolean
@@ -126,10 +128,12 @@ export interface TaskResponse {
     | 'fallback_recovering'
     | 'formatting'
     | 'verifying'
+    | 'analyzing'
     | 'packaging'
     | 'completed'
     | 'partial'
     | 'failed'
+    | 'cancelled'
   progress: number
   currentStage?: string
   currentMessage?: string
@@ -142,6 +146,27 @@ export interface TaskResponse {
   errorCode?: string
   errorMessage?: string
   errorDetail?: string
+  parentTaskId?: string
+}
+
+export interface TaskFileEntry {
+  path: string
+  size: number
+  kind?: string
+  source?: string
+  diagnostics?: Diagnostic[]
+}
+
+export interface TaskFileTree {
+  files: TaskFileEntry[]
+  maxFileBytes: number
+}
+
+export interface DependencyGraph {
+  nodes: { id: string; kind: 'app' | 'page' | 'component' | 'plugin' | 'template' | 'script'; orphan?: boolean }[]
+  edges: { from: string; to: string; kind: 'component' | 'import' | 'include' | 'require'; name?: string }[]
+  orphanComponents: string[]
+  diagnostics: Diagnostic[]
 }
 
 interface GithubStarsResponse {
@@ -342,6 +367,34 @@ export class ApiClient {
     return response.json()
   }
 
+  async getTaskFileTree(taskId: string, signal?: AbortSignal): Promise<TaskFileTree> {
+    const response = await fetchWithTimeout(`${this.base}/tasks/${taskId}/tree`, undefined, signal)
+    if (!response.ok) {
+      throw new Error('源码目录暂时无法加载')
+    }
+    return response.json()
+  }
+
+  async getTaskGraph(taskId: string, signal?: AbortSignal): Promise<DependencyGraph> {
+    const response = await fetchWithTimeout(`${this.base}/tasks/${taskId}/graph`, undefined, signal)
+    if (!response.ok) {
+      throw new Error('依赖图暂时无法加载')
+    }
+    return response.json()
+  }
+
+  async getTaskFile(taskId: string, path: string, signal?: AbortSignal): Promise<string> {
+    const response = await fetchWithTimeout(
+      `${this.base}/tasks/${taskId}/files?path=${encodeURIComponent(path)}`,
+      undefined,
+      signal
+    )
+    if (!response.ok) {
+      throw new Error(await extractErrorMessage(response))
+    }
+    return response.text()
+  }
+
   async getGithubStars(signal?: AbortSignal): Promise<GithubStarsResponse> {
     const response = await fetchWithTimeout(
       `${this.base}/github/stars`,
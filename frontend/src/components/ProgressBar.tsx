@@ -43,7 +43,7 @@ const userSteps = [
     key: 'finish',
     shortLabel: '下载',
     label: '检查并打包',
-    stages: ['verifying', 'packaging'],
+    stages: ['verifying', 'analyzing', 'packaging'],
   },
 ]
 
@@ -58,6 +58,7 @@ describe('ResultPanel', () => {
           isWeChat4xLike: true,
           isSubPackage: false,
           isGamePackage: false,
+          isPluginPackage: false,
           hasAppConfigJSON: true,
           hasAppServiceJS: true,
           hasWorkersJS: false,
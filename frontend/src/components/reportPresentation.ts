@@ -48,6 +48,9 @@ const STAGE_COPY: Record<string, { label: string; description: string }> = {
   },
   formatting: { label: '整理代码格式', description: '调整代码排版，提升阅读体验' },
   verifying: { label: '检查文件结构与语法', description: '检查文件是否齐全、语法和引用是否可解析' },
+  analyzing: { label: '安全与隐私分析', description: '扫描硬编码密钥、网络地址和隐私接口调用' },
+  analyzing_dependency_graph: { label: '构建依赖图', description: '梳理页面、组件、模板和脚本之间的引用关系' },
+  analyzing_api_inventory: { label: '整理接口清单', description: '汇总代码调用的服务端接口和云函数' },
   packaging: { label: '生成下载文件', description: '压缩并生成本次处理结果' },
   completed: { label: '结果已生成', description: '反编译结果可以下载' },
   partial: { label: '部分内容需检查', description: '结果已生成，部分内容需人工检查' },
@@ -184,6 +187,7 @@ const VARIANT_COPY: Record<string, string> = {
   wechat4x: '微信 4.x 格式小程序包',
   subpackage: '独立分包',
   game: '小游戏包',
+  plugin: '插件包',
   unknown: '暂未识别具体类型',
 }
 
@@ -193,6 +197,7 @@ export function getPackagePresentation(profile: PackageProfile) {
   if (profile.isStandardWxapkg) features.push('wxapkg 结构有效')
   if (profile.isSubPackage) features.push('检测到独立分包')
   if (profile.isGamePackage) features.push('检测到小游戏配置')
+  if (profile.isPluginPackage) features.push('检测到插件配置')
   if (profile.isWeChat4xLike) features.push('包含运行时聚合文件')
   if (profile.hasAppConfigJSON) features.push('发现应用配置')
   if (profile.hasAppServiceJS) features.push('发现主逻辑包')
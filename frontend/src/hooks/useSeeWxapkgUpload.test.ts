@@ -24,6 +24,7 @@ describe('useSeeWxapkgUpload helpers', () => {
     expect(mapTaskStatusToUI('completed')).toBe('completed')
     expect(mapTaskStatusToUI('partial')).toBe('partial')
     expect(mapTaskStatusToUI('failed')).toBe('failed')
+    expect(mapTaskStatusToUI('cancelled')).toBe('failed')
     expect(mapTaskStatusToUI('verifying')).toBe('processing')
     expect(mapTaskStatusToUI('')).toBe('idle')
   })
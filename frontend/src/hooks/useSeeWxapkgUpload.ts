@@ -215,7 +215,7 @@ export function useSeeWxapkgUpload() {
         return
       }
 
-      if (event.type === 'error') {
+      if (event.type === 'error' || event.type === 'cancelled') {
         terminalTaskIdRef.current = taskId
         stopSubscription()
         setState((prev) => ({
@@ -225,7 +225,10 @@ export function useSeeWxapkgUpload() {
           stage: 'failed',
           message: event.message,
           status: 'failed',
-          error: event.error || event.message || '处理过程中遇到问题，请重试',
+          error:
+            event.type === 'cancelled'
+              ? '任务已取消'
+              : event.error || event.message || '处理过程中遇到问题，请重试',
           errorCode: event.errorCode ?? prev.errorCode,
           isComplete: false,
           connectionInterrupted: false,
@@ -303,7 +306,7 @@ export function useSeeWxapkgUpload() {
           stopSubscription()
           return
         }
-        if (detail.status === 'failed') {
+        if (detail.status === 'failed' || detail.status === 'cancelled') {
           terminalTaskIdRef.current = stored.taskId
           stopSubscription()
           clearStoredActiveTask(stored.taskId)
@@ -502,7 +505,8 @@ export function mapTaskStatusToUI(status: string): UploadState['status'] {
   if (status === 'partial') {
     return 'partial'
   }
-  if (status === 'failed') {
+  // A cancelled task has no result to show; present it like a failure.
+  if (status === 'failed' || status === 'cancelled') {
     return 'failed'
   }
   if (status) {